@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"strings"
 
 	"github.com/ahmadrosid/yuk/token"
 )
@@ -23,6 +24,14 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	// File is the name of the source file, set when the program is one of
+	// the files of a package.
+	File string
+	// Doc is the comment group right before the package clause.
+	Doc *CommentGroup
+	// Comments are all the comments of the source, in order. The ones
+	// documenting a declaration are also attached to it.
+	Comments []*CommentGroup
 }
 
 func (p *Program) TokenLiteral() string {
@@ -33,6 +42,21 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+// Package returns the name in the package clause of the program, or nil
+// if it has none.
+func (p *Program) Package() *Identifier {
+	for _, stmt := range p.Statements {
+		s, ok := stmt.(*ExpressionStatement)
+		if !ok {
+			continue
+		}
+		if lit, ok := s.Expression.(*ExpressionLiteral); ok && lit.Token.Type == token.PACKAGE {
+			return lit.Name
+		}
+	}
+	return nil
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -41,6 +65,63 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// CommentGroup is a sequence of // or /* */ comments, kept with their
+// markers, without tokens or blank lines between them.
+type CommentGroup struct {
+	List []token.Token
+}
+
+// Text returns the text of the comments without their markers and the
+// space after //, one line per line. Directives like //go:build and //line
+// are left out.
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+	var lines []string
+	for _, c := range g.List {
+		if strings.HasPrefix(c.Literal, "//") {
+			text := c.Literal[2:]
+			if IsDirective(text) {
+				continue
+			}
+			lines = append(lines, strings.TrimPrefix(text, " "))
+			continue
+		}
+		text := strings.TrimSuffix(strings.TrimPrefix(c.Literal, "/*"), "*/")
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// IsDirective reports whether the text of a // comment, after the slashes,
+// is a directive for the Go toolchain, like go:generate or line.
+func IsDirective(text string) bool {
+	if strings.HasPrefix(text, "line ") {
+		return true
+	}
+	colon := strings.Index(text, ":")
+	if colon <= 0 || colon+1 >= len(text) {
+		return false
+	}
+	for _, r := range text[:colon] {
+		if r < 'a' || r > 'z' {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+	}
+	return text[colon+1] >= 'a' && text[colon+1] <= 'z'
+}
+
 type Identifier struct {
 	Token token.Token
 	Value string
@@ -67,18 +148,36 @@ func (v *VarExpression) String() string {
 	return out.String()
 }
 
+// VarStatement declares a variable: `var x = 1`, `var x int = 1` or
+// `var x int`. Type is nil when the type is inferred from the value, and
+// Value is nil when the variable starts at its zero value.
 type VarStatement struct {
 	Token token.Token
 	Name  *Identifier
+	Type  *Identifier
 	Value Expression
+	// Doc is the comment group before the declaration, and Comment the one
+	// after it on its last line.
+	Doc     *CommentGroup
+	Comment *CommentGroup
 }
 
 func (vs *VarStatement) statementNode()       {}
 func (vs *VarStatement) TokenLiteral() string { return vs.Token.Literal }
+
+// IsImmutable reports whether the binding was declared with `let`.
+func (vs *VarStatement) IsImmutable() bool { return vs.Token.Type == token.LET }
+
 func (vs *VarStatement) String() string {
 	var out bytes.Buffer
-	out.WriteString(vs.TokenLiteral() + " ")
+	out.WriteString("var ")
 	out.WriteString(vs.Name.String())
+	if vs.Type != nil {
+		out.WriteString(" " + vs.Type.String())
+		if vs.Value == nil {
+			return out.String()
+		}
+	}
 	out.WriteString(" = ")
 	if vs.Value != nil {
 		out.WriteString(vs.Value.String())
@@ -86,6 +185,24 @@ func (vs *VarStatement) String() string {
 	return out.String()
 }
 
+// AssignStatement assigns to a variable, a struct field or an index:
+// `x = 1`, `user.Name = "yuk"` or `xs[0] = 2`.
+type AssignStatement struct {
+	Token  token.Token
+	Target Expression
+	Value  Expression
+}
+
+func (as *AssignStatement) statementNode()       {}
+func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(as.Target.String())
+	out.WriteString(" = ")
+	out.WriteString(as.Value.String())
+	return out.String()
+}
+
 type IfExpression struct {
 	Token       token.Token
 	Condition   Expression
@@ -143,8 +260,18 @@ func (m *MapLiteral) String() string {
 	return out.String()
 }
 
+// HashLiteral holds the entries of a map literal in source order.
 type HashLiteral struct {
-	KeyValue map[Expression]Expression
+	// Start and End are the opening and closing braces.
+	Start token.Token
+	Pairs []*HashPair
+	End   token.Token
+}
+
+// HashPair is a `key: value` entry of a map literal.
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (h *HashLiteral) expressionNode()      {}
@@ -152,10 +279,10 @@ func (h *HashLiteral) TokenLiteral() string { return "" }
 func (h *HashLiteral) String() string {
 	var out bytes.Buffer
 	out.WriteString("{\n")
-	for k, v := range h.KeyValue {
-		out.WriteString(k.String())
+	for _, pair := range h.Pairs {
+		out.WriteString(pair.Key.String())
 		out.WriteString(":")
-		out.WriteString(v.String())
+		out.WriteString(pair.Value.String())
 		out.WriteString(",\n")
 	}
 	out.WriteString("}")
@@ -181,6 +308,8 @@ func (rs *ReturnStatement) String() string {
 type ImportStatement struct {
 	Token       token.Token
 	PackageName Expression
+	Doc         *CommentGroup
+	Comment     *CommentGroup
 }
 
 func (s *ImportStatement) statementNode()       {}
@@ -241,6 +370,8 @@ func (m *MetaKeyValueLiteral) String() string {
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	// End is the closing brace.
+	End token.Token
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -262,6 +393,9 @@ type StructStatement struct {
 	Name       *token.Token
 	Attributes []*StructAttributes
 	Block      *BlockStatement
+	// End is the closing parenthesis.
+	End token.Token
+	Doc *CommentGroup
 }
 
 func (ss *StructStatement) expressionNode()      {}
@@ -285,10 +419,15 @@ func (ss *StructStatement) String() string {
 }
 
 type StructAttributes struct {
-	Token *token.Token
-	Name  token.Token
-	Type  token.Token
-	Meta  *MetaLiteral
+	Token   *token.Token
+	Name    token.Token
+	Type    token.Token
+	Meta    *MetaLiteral
+	Mutable bool
+	// Doc is the comment group before the field or the type declaration,
+	// and Comment the one after it on its line.
+	Doc     *CommentGroup
+	Comment *CommentGroup
 }
 
 func (ts *StructAttributes) statementNode()       {}
@@ -311,6 +450,8 @@ type SwitchStatement struct {
 	Token token.Token
 	Input token.Token
 	Case  []*CaseLiteral
+	// End is the closing brace.
+	End token.Token
 }
 
 func (ss *SwitchStatement) statementNode()       {}
@@ -427,33 +568,302 @@ func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
-	// TODO: format string for StringLiteral
+	out.WriteString(ie.Left.String())
+	out.WriteString(" " + ie.Operator + " ")
+	out.WriteString(ie.Right.String())
 	return out.String()
 }
 
-type FunctionLiteral struct {
+type PrefixExpression struct {
+	Token    token.Token
+	Operator string
+	Right    Expression
+}
+
+func (pe *PrefixExpression) expressionNode()      {}
+func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(pe.Operator)
+	out.WriteString(pe.Right.String())
+	return out.String()
+}
+
+// GroupedExpression keeps the parentheses written in the source so the
+// generated code evaluates in the same order.
+type GroupedExpression struct {
 	Token      token.Token
-	Name       string
-	Params     []*Identifier
+	Expression Expression
+}
+
+func (ge *GroupedExpression) expressionNode()      {}
+func (ge *GroupedExpression) TokenLiteral() string { return ge.Token.Literal }
+func (ge *GroupedExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ge.Expression.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+type Parameter struct {
+	Name *Identifier
+	Type *Identifier
+}
+
+func (p *Parameter) String() string {
+	return p.Name.String() + " " + p.Type.String()
+}
+
+type FunctionLiteral struct {
+	Token    token.Token
+	Unsafe   bool
+	Receiver *Parameter
+	Name     string
+	// NameToken is the token of Name, for named functions and methods.
+	NameToken  token.Token
+	Params     []*Parameter
 	ReturnType Expression
 	Body       *BlockStatement
+	// Doc is the comment group before a function declaration.
+	Doc *CommentGroup
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
-	out.WriteString(fl.TokenLiteral() + " ")
-	out.WriteString(fl.Name)
+	out.WriteString(fl.TokenLiteral())
+	if fl.Receiver != nil {
+		out.WriteString(" (" + fl.Receiver.String() + ")")
+	}
+	if fl.Name != "" {
+		out.WriteString(" " + fl.Name)
+	}
 	out.WriteString("(")
-	for _, s := range fl.Params {
+	for i, s := range fl.Params {
+		if i > 0 {
+			out.WriteString(", ")
+		}
 		out.WriteString(s.String())
 	}
 	out.WriteString(") ")
 	if fl.ReturnType != nil {
-		out.WriteString(fl.ReturnType.TokenLiteral())
+		out.WriteString(fl.ReturnType.String())
 		out.WriteString(" ")
 	}
 	out.WriteString(fl.Body.String())
 	return out.String()
 }
+
+type CallExpression struct {
+	Token     token.Token
+	Function  Expression
+	Arguments []Expression
+	// End is the closing parenthesis.
+	End token.Token
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) String() string {
+	if lowered, ok := lowerExtension(ce); ok {
+		return lowered
+	}
+
+	var out bytes.Buffer
+	out.WriteString(ce.Function.String())
+	out.WriteString("(")
+	writeExpressions(&out, ce.Arguments)
+	out.WriteString(")")
+	return out.String()
+}
+
+type SelectorExpression struct {
+	Token token.Token
+	Left  Expression
+	Name  *Identifier
+}
+
+func (se *SelectorExpression) expressionNode()      {}
+func (se *SelectorExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectorExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(se.Left.String())
+	out.WriteString(".")
+	out.WriteString(se.Name.String())
+	return out.String()
+}
+
+// ArrayLiteral is either `[1, 2, 3]`, where the element type is inferred
+// from the elements, or the Go form `[]int{1, 2, 3}`.
+type ArrayLiteral struct {
+	Token    token.Token
+	Type     *Identifier
+	Elements []Expression
+	// End is the closing bracket or brace.
+	End token.Token
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+	out.WriteString("[]")
+	out.WriteString(al.ElementType())
+	out.WriteString("{")
+	writeExpressions(&out, al.Elements)
+	out.WriteString("}")
+	return out.String()
+}
+
+// ElementType returns the declared element type, or infers one from the
+// literal elements. Mixed or non literal elements fall back to interface{}.
+func (al *ArrayLiteral) ElementType() string {
+	if al.Type != nil {
+		return al.Type.Value
+	}
+
+	elementType := ""
+	for _, el := range al.Elements {
+		var t string
+		switch el.(type) {
+		case *IntegerLiteral:
+			t = "int"
+		case *StringLiteral:
+			t = "string"
+		case *Boolean:
+			t = "bool"
+		default:
+			return "interface{}"
+		}
+		if elementType != "" && elementType != t {
+			return "interface{}"
+		}
+		elementType = t
+	}
+
+	if elementType == "" {
+		return "interface{}"
+	}
+	return elementType
+}
+
+// StructLiteral creates a struct value: `User{Name: "yuk", Age: 1}`.
+type StructLiteral struct {
+	Token  token.Token
+	Type   *Identifier
+	Fields []*StructLiteralField
+	// End is the closing brace.
+	End token.Token
+}
+
+type StructLiteralField struct {
+	Name  *Identifier
+	Value Expression
+}
+
+func (sl *StructLiteral) expressionNode()      {}
+func (sl *StructLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StructLiteral) String() string {
+	var out bytes.Buffer
+	out.WriteString(sl.Type.String())
+	out.WriteString("{")
+	for i, f := range sl.Fields {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(f.Name.String())
+		out.WriteString(": ")
+		out.WriteString(f.Value.String())
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token token.Token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("]")
+	return out.String()
+}
+
+func writeExpressions(out *bytes.Buffer, exps []Expression) {
+	for i, e := range exps {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(e.String())
+	}
+}
+
+// MacroStatement defines a macro: `macro name!(a, b) { ... }`.
+type MacroStatement struct {
+	Token  token.Token
+	Name   *Identifier
+	Params []*Identifier
+	Body   *BlockStatement
+	Doc    *CommentGroup
+}
+
+func (ms *MacroStatement) statementNode()       {}
+func (ms *MacroStatement) TokenLiteral() string { return ms.Token.Literal }
+func (ms *MacroStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ms.TokenLiteral() + " ")
+	out.WriteString(ms.Name.String())
+	out.WriteString("!(")
+	for i, p := range ms.Params {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(p.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(ms.Body.String())
+	return out.String()
+}
+
+// MacroCall is a macro invocation: `assert!(x == 1)`. Macro calls are
+// replaced by their expansion before code generation.
+type MacroCall struct {
+	Token     token.Token
+	Name      *Identifier
+	Arguments []Expression
+	// End is the closing parenthesis.
+	End token.Token
+}
+
+func (mc *MacroCall) expressionNode()      {}
+func (mc *MacroCall) TokenLiteral() string { return mc.Token.Literal }
+func (mc *MacroCall) String() string {
+	var out bytes.Buffer
+	out.WriteString(mc.Name.String())
+	out.WriteString("!(")
+	writeExpressions(&out, mc.Arguments)
+	out.WriteString(")")
+	return out.String()
+}
+
+// UnsafeStatement is an `unsafe { ... }` block, the only place besides an
+// `unsafe func` where pointers can be dereferenced and package unsafe used.
+type UnsafeStatement struct {
+	Token token.Token
+	Body  *BlockStatement
+}
+
+func (us *UnsafeStatement) statementNode()       {}
+func (us *UnsafeStatement) TokenLiteral() string { return us.Token.Literal }
+func (us *UnsafeStatement) String() string {
+	return us.Body.String()
+}
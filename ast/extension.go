@@ -0,0 +1,72 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ExtensionPackage is the import path of the runtime helpers used by the
+// slice extension methods that can not be written as a single Go expression.
+const ExtensionPackage = "github.com/ahmadrosid/yuk/std"
+
+// Extension describes a builtin method available on every slice value,
+// e.g. `[1, 2, 3].len()` or `users.filter(isActive)`.
+type Extension struct {
+	Name  string
+	Arity int
+	// Helper is the function in ExtensionPackage implementing the method.
+	// Extensions without a helper are lowered inline.
+	Helper string
+}
+
+var extensions = map[string]Extension{
+	"len":      {Name: "len", Arity: 0},
+	"is_empty": {Name: "is_empty", Arity: 0},
+	"map":      {Name: "map", Arity: 1, Helper: "Map"},
+	"filter":   {Name: "filter", Arity: 1, Helper: "Filter"},
+	"reduce":   {Name: "reduce", Arity: 2, Helper: "Reduce"},
+	"contains": {Name: "contains", Arity: 1, Helper: "Contains"},
+	"index_of": {Name: "index_of", Arity: 1, Helper: "IndexOf"},
+	"first":    {Name: "first", Arity: 0, Helper: "First"},
+	"last":     {Name: "last", Arity: 0, Helper: "Last"},
+}
+
+// LookupExtension reports whether the call is an extension method call
+// like `xs.len()` and returns the extension it refers to. It only looks at
+// the method name: a method of the receiver's own type named like an
+// extension hides it, which takes the types of the receiver to tell.
+func LookupExtension(ce *CallExpression) (Extension, bool) {
+	sel, ok := ce.Function.(*SelectorExpression)
+	if !ok {
+		return Extension{}, false
+	}
+	ext, ok := extensions[sel.Name.Value]
+	return ext, ok
+}
+
+func lowerExtension(ce *CallExpression) (string, bool) {
+	ext, ok := LookupExtension(ce)
+	if !ok {
+		return "", false
+	}
+
+	receiver := ce.Function.(*SelectorExpression).Left.String()
+	switch ext.Name {
+	case "len":
+		return fmt.Sprintf("len(%s)", receiver), true
+	case "is_empty":
+		return fmt.Sprintf("(len(%s) == 0)", receiver), true
+	}
+
+	var out bytes.Buffer
+	out.WriteString("std.")
+	out.WriteString(ext.Helper)
+	out.WriteString("(")
+	out.WriteString(receiver)
+	for _, arg := range ce.Arguments {
+		out.WriteString(", ")
+		out.WriteString(arg.String())
+	}
+	out.WriteString(")")
+	return out.String(), true
+}
@@ -0,0 +1,95 @@
+package ast
+
+type ModifierFunc func(Node) Node
+
+// Modify rewrites the AST bottom up, replacing every node with the result
+// of modifier. Only nodes in expression or statement position are passed
+// to modifier; names being declared, selected fields and types are left
+// untouched.
+func Modify(node Node, modifier ModifierFunc) Node {
+	if isNil(node) {
+		return node
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for i, s := range node.Statements {
+			node.Statements[i], _ = Modify(s, modifier).(Statement)
+		}
+	case *BlockStatement:
+		for i, s := range node.Statements {
+			node.Statements[i], _ = Modify(s, modifier).(Statement)
+		}
+	case *UnsafeStatement:
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ExpressionStatement:
+		node.Expression = modifyExpression(node.Expression, modifier)
+	case *VarStatement:
+		node.Value = modifyExpression(node.Value, modifier)
+	case *AssignStatement:
+		node.Target = modifyExpression(node.Target, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+	case *VarExpression:
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ReturnStatement:
+		node.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+	case *IfExpression:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
+		node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+	case *SwitchStatement:
+		for _, c := range node.Case {
+			if c != nil {
+				c.Body, _ = Modify(c.Body, modifier).(*BlockStatement)
+			}
+		}
+	case *MapLiteral:
+		if node.KeyValue != nil {
+			for _, pair := range node.KeyValue.Pairs {
+				pair.Key = modifyExpression(pair.Key, modifier)
+				pair.Value = modifyExpression(pair.Value, modifier)
+			}
+		}
+	case *FunctionLiteral:
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *CallExpression:
+		node.Function = modifyExpression(node.Function, modifier)
+		for i, arg := range node.Arguments {
+			node.Arguments[i] = modifyExpression(arg, modifier)
+		}
+	case *MacroCall:
+		for i, arg := range node.Arguments {
+			node.Arguments[i] = modifyExpression(arg, modifier)
+		}
+	case *SelectorExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+	case *IndexExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Index = modifyExpression(node.Index, modifier)
+	case *ArrayLiteral:
+		for i, el := range node.Elements {
+			node.Elements[i] = modifyExpression(el, modifier)
+		}
+	case *StructLiteral:
+		for _, f := range node.Fields {
+			f.Value = modifyExpression(f.Value, modifier)
+		}
+	case *InfixExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Right = modifyExpression(node.Right, modifier)
+	case *PrefixExpression:
+		node.Right = modifyExpression(node.Right, modifier)
+	case *GroupedExpression:
+		node.Expression = modifyExpression(node.Expression, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if isNil(exp) {
+		return exp
+	}
+	modified, _ := Modify(exp, modifier).(Expression)
+	return modified
+}
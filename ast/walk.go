@@ -0,0 +1,131 @@
+package ast
+
+import "reflect"
+
+// Inspect traverses the AST in depth-first order. It calls f for every
+// node; when f returns false the children of that node are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *VarStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Value, f)
+	case *AssignStatement:
+		Inspect(n.Target, f)
+		Inspect(n.Value, f)
+	case *VarExpression:
+		Inspect(n.Value, f)
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, f)
+	case *ImportStatement:
+		Inspect(n.PackageName, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Consequence, f)
+		Inspect(n.Alternative, f)
+	case *SwitchStatement:
+		for _, c := range n.Case {
+			Inspect(c, f)
+		}
+	case *CaseLiteral:
+		Inspect(n.Body, f)
+	case *StructStatement:
+		for _, attr := range n.Attributes {
+			Inspect(attr, f)
+		}
+		Inspect(n.Block, f)
+	case *StructAttributes:
+		Inspect(n.Meta, f)
+	case *MetaLiteral:
+		for _, kv := range n.KeyValue {
+			Inspect(kv, f)
+		}
+	case *MetaKeyValueLiteral:
+		Inspect(n.Value, f)
+	case *MapLiteral:
+		Inspect(n.Key, f)
+		Inspect(n.Value, f)
+		Inspect(n.KeyValue, f)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			Inspect(pair.Key, f)
+			Inspect(pair.Value, f)
+		}
+	case *FunctionLiteral:
+		if n.Receiver != nil {
+			Inspect(n.Receiver.Name, f)
+			Inspect(n.Receiver.Type, f)
+		}
+		for _, p := range n.Params {
+			Inspect(p.Name, f)
+			Inspect(p.Type, f)
+		}
+		Inspect(n.ReturnType, f)
+		Inspect(n.Body, f)
+	case *CallExpression:
+		Inspect(n.Function, f)
+		for _, arg := range n.Arguments {
+			Inspect(arg, f)
+		}
+	case *SelectorExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Name, f)
+	case *StructLiteral:
+		Inspect(n.Type, f)
+		for _, field := range n.Fields {
+			Inspect(field.Name, f)
+			Inspect(field.Value, f)
+		}
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *ArrayLiteral:
+		Inspect(n.Type, f)
+		for _, el := range n.Elements {
+			Inspect(el, f)
+		}
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *GroupedExpression:
+		Inspect(n.Expression, f)
+	case *UnsafeStatement:
+		Inspect(n.Body, f)
+	case *MacroStatement:
+		Inspect(n.Name, f)
+		for _, p := range n.Params {
+			Inspect(p, f)
+		}
+		Inspect(n.Body, f)
+	case *MacroCall:
+		Inspect(n.Name, f)
+		for _, arg := range n.Arguments {
+			Inspect(arg, f)
+		}
+	}
+}
+
+// isNil reports whether the node is nil, including typed nil pointers
+// stored in an interface, which is how optional children are kept.
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
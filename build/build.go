@@ -0,0 +1,604 @@
+// Package build compiles the yuk files of whole directories to Go files.
+//
+// Each directory is one package: all of its .yuk files must have the same
+// package clause, and the declarations of each file are visible to the
+// others. A file is compiled to a Go file of the same name, written next to
+// it or under an output directory that mirrors the source tree.
+//
+// A package may also have hand-written Go files, checked together with its
+// yuk files. A Go file named like a yuk file is the output of a previous
+// build and is ignored.
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/compiler"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+	"github.com/ahmadrosid/yuk/sourcemap"
+	"github.com/ahmadrosid/yuk/token"
+	"github.com/ahmadrosid/yuk/types"
+)
+
+// Config controls a build.
+type Config struct {
+	// Output is the directory the Go files are written to, in the same
+	// layout as their sources relative to the current directory. When it
+	// is empty, each Go file is written next to its source.
+	Output string
+	// LineDirectives and SourceMap are passed on to the compiler. The
+	// source map of a file is written next to its Go file.
+	LineDirectives bool
+	SourceMap      bool
+	// Cache is the directory of the build cache, see CacheDir. Every
+	// package is compiled when it is empty.
+	Cache string
+	// ImportMap replaces import paths in the Go files, like
+	// compiler.Package.ImportMap.
+	ImportMap map[string]string
+}
+
+// Error is a diagnostic of a file or directory.
+type Error struct {
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	msg := e.Err.Error()
+	if msg != "" && msg[0] >= '0' && msg[0] <= '9' {
+		// A position in the file.
+		return e.Path + ":" + msg
+	}
+	return e.Path + ": " + msg
+}
+
+// Package is a directory of yuk files.
+type Package struct {
+	Dir string
+	// Name is the name in the package clause of the files.
+	Name string
+	// Files are the paths of the .yuk files, and GoFiles the paths of the
+	// hand-written .go files, in lexical order.
+	Files   []string
+	GoFiles []string
+}
+
+// Output is a compiled file.
+type Output struct {
+	Source string
+	Path   string
+	Go     string
+	Map    *sourcemap.Map
+}
+
+// Dirs returns the directories matched by patterns, sorted. A pattern is
+// a directory, or a directory followed by /... to match every directory
+// under it holding .yuk files, like ./... for the current directory.
+func Dirs(patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var dirs []string
+	add := func(dir string) {
+		dir = filepath.Clean(dir)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, pattern := range patterns {
+		if root := strings.TrimSuffix(pattern, "..."); root != pattern {
+			root = filepath.Clean(root)
+			err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				name := info.Name()
+				if info.IsDir() && path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata") {
+					return filepath.SkipDir
+				}
+				if !info.IsDir() && strings.HasSuffix(name, ".yuk") {
+					add(filepath.Dir(path))
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", pattern)
+		}
+		add(pattern)
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// Load returns the package in dir.
+func Load(dir string) (*Package, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yuk"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, &Error{Path: dir, Err: fmt.Errorf("no .yuk files")}
+	}
+	sort.Strings(files)
+	pkg := &Package{Dir: dir, Files: files}
+
+	goFiles, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	generated := map[string]bool{}
+	for _, file := range files {
+		generated[strings.TrimSuffix(file, ".yuk")+".go"] = true
+	}
+	for _, file := range goFiles {
+		if !generated[file] && !strings.HasSuffix(file, "_test.go") {
+			pkg.GoFiles = append(pkg.GoFiles, file)
+		}
+	}
+	sort.Strings(pkg.GoFiles)
+	return pkg, nil
+}
+
+// Build compiles the packages in dirs, and the yuk packages of their
+// module they import, and writes their Go files. Every package is compiled
+// once, after the packages it imports. Nothing is written unless every file
+// compiles, and all the diagnostics are returned.
+//
+// With a cache, a package is only compiled again when its files, the
+// compiler or the API of a yuk package it imports changed since it was
+// last compiled. Otherwise its outputs are taken from the cache.
+func (cfg *Config) Build(dirs []string) []error {
+	b := &builder{cfg: cfg, pkgs: map[string]*built{}}
+	for _, dir := range dirs {
+		b.build(dir)
+	}
+	if len(b.errors) > 0 {
+		return b.errors
+	}
+
+	var errors []error
+	for _, out := range b.outputs {
+		if err := out.Write(); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+// builder compiles packages and the yuk packages they import.
+type builder struct {
+	cfg *Config
+	// pkgs holds the packages by absolute directory, nil for the ones that
+	// failed.
+	pkgs map[string]*built
+	// stack is the import paths of the packages being loaded, innermost
+	// last.
+	stack   []string
+	outputs []*Output
+	errors  []error
+}
+
+// built is a package of a build.
+type built struct {
+	pkg        *Package
+	c          *compiler.Package
+	module     *Module
+	importPath string
+	outputs    []*Output
+	// deps are the yuk packages imported, by import path.
+	deps map[string]*built
+	// api is the hash of the API of the package. types holds its exports,
+	// it is nil until the package is checked: a package taken from the
+	// cache is only checked when a package importing it is compiled.
+	api   string
+	types *types.Package
+}
+
+// build loads the package in dir after the ones it imports, returning nil
+// when it or one of its imports failed.
+func (b *builder) build(dir string) *built {
+	key, err := filepath.Abs(dir)
+	if err != nil {
+		b.errors = append(b.errors, err)
+		return nil
+	}
+	if p, ok := b.pkgs[key]; ok {
+		return p
+	}
+	p := b.load(dir)
+	b.pkgs[key] = p
+	return p
+}
+
+// load compiles the package in dir, or takes its outputs from the cache.
+func (b *builder) load(dir string) *built {
+	pkg, err := Load(dir)
+	if err != nil {
+		b.errors = append(b.errors, err)
+		return nil
+	}
+	module, err := FindModule(dir)
+	if err != nil {
+		b.errors = append(b.errors, err)
+		return nil
+	}
+	c, outputs, errs := b.cfg.compiler(pkg, b.cfg.outputPath)
+	if len(errs) == 0 {
+		errs = c.Parse()
+	}
+	if len(errs) > 0 {
+		b.errors = append(b.errors, errs...)
+		return nil
+	}
+
+	p := &built{pkg: pkg, c: c, module: module, outputs: outputs}
+	if module != nil {
+		p.importPath, _ = module.ImportPath(dir)
+	}
+	b.stack = append(b.stack, p.importPath)
+	deps, ok := b.imports(p)
+	b.stack = b.stack[:len(b.stack)-1]
+	if !ok {
+		return nil
+	}
+	p.deps = deps
+
+	key := ""
+	if b.cfg.Cache != "" {
+		apis := map[string]string{}
+		for path, dep := range deps {
+			apis[path] = dep.api
+		}
+		if key, err = b.cfg.cacheKey(pkg, p.importPath, apis); err != nil {
+			b.errors = append(b.errors, err)
+			return nil
+		}
+		if entry, ok := getCache(b.cfg.Cache, key); ok {
+			p.api = entry.API
+			b.outputs = append(b.outputs, entry.Outputs...)
+			return p
+		}
+	}
+
+	outputs, ok = b.check(p)
+	if !ok {
+		return nil
+	}
+	b.outputs = append(b.outputs, outputs...)
+	if key != "" {
+		// The cache only saves work, a build does not fail when it
+		// cannot be written.
+		putCache(b.cfg.Cache, key, &cacheEntry{API: p.api, Outputs: outputs})
+	}
+	return p
+}
+
+// imports loads the yuk packages of the module imported by p, reporting
+// the import cycles. It returns false when one of them failed.
+func (b *builder) imports(p *built) (map[string]*built, bool) {
+	deps := map[string]*built{}
+	if p.module == nil {
+		return deps, true
+	}
+	ok := true
+	for _, imp := range p.c.Imports() {
+		dir, in := p.module.Dir(imp.Path)
+		if !in || !hasYukFiles(dir) {
+			continue
+		}
+		if cycle := b.cycle(imp.Path); cycle != nil {
+			msg := "import cycle not allowed: " + strings.Join(cycle, " -> ")
+			b.errors = append(b.errors, &token.Error{File: imp.File, Line: imp.Pos.Line, Column: imp.Pos.Column, Msg: msg})
+			ok = false
+			continue
+		}
+		dep := b.build(displayPath(dir))
+		if dep == nil {
+			ok = false
+			continue
+		}
+		deps[imp.Path] = dep
+	}
+	return deps, ok
+}
+
+// check compiles p, after checking the packages it imports, and sets its
+// exports. It returns the outputs of p.
+func (b *builder) check(p *built) ([]*Output, bool) {
+	im := importer{}
+	for path, dep := range p.deps {
+		if dep.types == nil {
+			if _, ok := b.check(dep); !ok {
+				return nil, false
+			}
+		}
+		im[path] = dep.types
+	}
+	p.c.Importer = im
+	if b.cfg.Output != "" {
+		p.c.ImportMap = b.importMap(p)
+	}
+
+	outputs, errs := b.cfg.generate(p.pkg, p.c, p.outputs)
+	if len(errs) == 0 {
+		var copies []*Output
+		copies, errs = b.cfg.copyGoFiles(p.pkg)
+		outputs = append(outputs, copies...)
+	}
+	if len(errs) > 0 {
+		b.errors = append(b.errors, errs...)
+		return nil, false
+	}
+	p.types = types.NewPackage(p.importPath, p.pkg.Name, p.c.Info.Scope)
+	p.api = hash(p.types.API())
+	return outputs, true
+}
+
+// cycle returns the import cycle closed by importing path, or nil.
+func (b *builder) cycle(path string) []string {
+	for i, p := range b.stack {
+		if p == path {
+			return append(append([]string{}, b.stack[i:]...), path)
+		}
+	}
+	return nil
+}
+
+// importMap returns the import paths of the Go files of the imports of p
+// under the output directory, when it is in the module.
+func (b *builder) importMap(p *built) map[string]string {
+	m := map[string]string{}
+	for path, to := range b.cfg.ImportMap {
+		m[path] = to
+	}
+	for path := range p.deps {
+		dir, _ := p.module.Dir(path)
+		out, err := b.cfg.place(displayPath(dir))
+		if err != nil {
+			continue
+		}
+		if outPath, ok := p.module.ImportPath(out); ok {
+			m[path] = outPath
+		}
+	}
+	return m
+}
+
+// importer loads the yuk packages compiled by a build, and the Go packages
+// with types.GoImporter.
+type importer map[string]*types.Package
+
+func (im importer) Import(path string) (*types.Package, error) {
+	if pkg, ok := im[path]; ok {
+		return pkg, nil
+	}
+	return types.GoImporter().Import(path)
+}
+
+// Compile compiles the files of pkg, setting its Name. The packages it
+// imports are loaded as Go packages. Under an output directory, the
+// hand-written Go files of pkg are copied next to the generated ones.
+func (cfg *Config) Compile(pkg *Package) ([]*Output, []error) {
+	outputs, errors := cfg.CompileTo(pkg, cfg.outputPath)
+	if len(errors) > 0 {
+		return nil, errors
+	}
+	copies, errors := cfg.copyGoFiles(pkg)
+	return append(outputs, copies...), errors
+}
+
+// CompileTo compiles the files of pkg, setting its Name. The Go file of
+// each yuk file is named by outputPath.
+func (cfg *Config) CompileTo(pkg *Package, outputPath func(path string) (string, error)) ([]*Output, []error) {
+	c, outputs, errors := cfg.compiler(pkg, outputPath)
+	if len(errors) > 0 {
+		return nil, errors
+	}
+	return cfg.generate(pkg, c, outputs)
+}
+
+// compiler returns the compiler of pkg, and its outputs named by
+// outputPath.
+func (cfg *Config) compiler(pkg *Package, outputPath func(path string) (string, error)) (*compiler.Package, []*Output, []error) {
+	c := &compiler.Package{
+		Dir:            pkg.Dir,
+		GoFiles:        pkg.GoFiles,
+		ImportMap:      cfg.ImportMap,
+		LineDirectives: cfg.LineDirectives,
+		SourceMap:      cfg.SourceMap,
+	}
+	var outputs []*Output
+	var errors []error
+	for _, path := range pkg.Files {
+		goFile, err := outputPath(path)
+		if err != nil {
+			errors = append(errors, &Error{Path: path, Err: err})
+			continue
+		}
+		// Line directives and source maps name the source relative to
+		// the generated file.
+		c.Files = append(c.Files, &compiler.File{Path: path, Filename: relativePath(filepath.Dir(goFile), path)})
+		outputs = append(outputs, &Output{Source: path, Path: goFile})
+	}
+	return c, outputs, errors
+}
+
+// generate runs c, setting the Name of pkg, and fills in its outputs.
+func (cfg *Config) generate(pkg *Package, c *compiler.Package, outputs []*Output) ([]*Output, []error) {
+	errors := c.Generate()
+	pkg.Name = c.Name()
+	if len(errors) > 0 {
+		return nil, errors
+	}
+	for i, f := range c.Files {
+		out := outputs[i]
+		out.Go, out.Map = f.Go, f.Map
+		if out.Map != nil {
+			out.Map.File = filepath.Base(out.Path)
+		}
+	}
+	return outputs, nil
+}
+
+// copyGoFiles returns the copies of the hand-written Go files of pkg under
+// the output directory, if there is one.
+func (cfg *Config) copyGoFiles(pkg *Package) ([]*Output, []error) {
+	if cfg.Output == "" {
+		return nil, nil
+	}
+	var outputs []*Output
+	var errors []error
+	for _, file := range pkg.GoFiles {
+		path, err := cfg.place(file)
+		if err != nil {
+			errors = append(errors, &Error{Path: file, Err: err})
+			continue
+		}
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		outputs = append(outputs, &Output{Source: file, Path: path, Go: string(src)})
+	}
+	return outputs, errors
+}
+
+// hasYukFiles reports whether dir holds .yuk files.
+func hasYukFiles(dir string) bool {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.yuk"))
+	return len(files) > 0
+}
+
+// displayPath returns path relative to the current directory when it is
+// below it, to name it in diagnostics.
+func displayPath(path string) string {
+	rel := relativePath(".", path)
+	if rel == ".." || strings.HasPrefix(rel, "../") || filepath.IsAbs(rel) {
+		return path
+	}
+	return filepath.FromSlash(rel)
+}
+
+// CompileFile compiles the yuk file path to be written to goFile, or to be
+// printed if goFile is empty, returning its package name.
+func (cfg *Config) CompileFile(path, goFile string) (*Output, string, []error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", []error{err}
+	}
+	out := &Output{Source: path, Path: goFile}
+
+	c := compiler.New(parser.New(lexer.New(string(source))))
+	c.Filename = path
+	if goFile != "" {
+		// Line directives and source maps name the source relative to
+		// the generated file.
+		c.Filename = relativePath(filepath.Dir(goFile), path)
+	}
+	c.LineDirectives = cfg.LineDirectives
+	c.SourceMap = cfg.SourceMap && goFile != ""
+	res, errs := c.Generate()
+
+	name := ""
+	if c.Program != nil {
+		if ident := c.Program.Package(); ident != nil {
+			name = ident.Value
+		}
+	}
+	if len(errs) > 0 {
+		errors := make([]error, len(errs))
+		for i, err := range errs {
+			errors[i] = &Error{Path: path, Err: err}
+		}
+		return nil, name, errors
+	}
+
+	out.Go = res
+	out.Map = c.Map
+	if out.Map != nil {
+		out.Map.File = filepath.Base(goFile)
+	}
+	return out, name, nil
+}
+
+// outputPath returns the path of the Go file compiled from the yuk file
+// path.
+func (cfg *Config) outputPath(path string) (string, error) {
+	return cfg.place(strings.TrimSuffix(path, ".yuk") + ".go")
+}
+
+// place returns the path a file is written to, under the output directory
+// when there is one.
+func (cfg *Config) place(path string) (string, error) {
+	if cfg.Output == "" {
+		return path, nil
+	}
+	rel := relativePath(".", path)
+	if rel == ".." || strings.HasPrefix(rel, "../") || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("outside the current directory, cannot be placed under %s", cfg.Output)
+	}
+	return filepath.Join(cfg.Output, rel), nil
+}
+
+// Write writes the Go file and the source map of out. Files that already
+// have the same content are left untouched.
+func (out *Output) Write() error {
+	if err := os.MkdirAll(filepath.Dir(out.Path), 0755); err != nil {
+		return err
+	}
+	if err := writeFile(out.Path, []byte(out.Go)); err != nil {
+		return err
+	}
+	if out.Map == nil {
+		return nil
+	}
+	var m bytes.Buffer
+	if err := out.Map.Write(&m); err != nil {
+		return err
+	}
+	return writeFile(sourcemap.Path(out.Path), m.Bytes())
+}
+
+// writeFile writes data to path, unless it already holds data.
+func writeFile(path string, data []byte) error {
+	if old, err := ioutil.ReadFile(path); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// relativePath returns path relative to dir, or path itself if it has none.
+func relativePath(dir, path string) string {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return path
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,306 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree creates the files in a new temporary directory and makes it
+// the current directory for the rest of the test.
+func writeTree(t *testing.T, files map[string]string) {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestDirs(t *testing.T) {
+	writeTree(t, map[string]string{
+		"main.yuk":              "package main",
+		"models/user.yuk":       "package models",
+		"models/sql/query.yuk":  "package sql",
+		"docs/README.md":        "",
+		".git/hooks/x.yuk":      "package x",
+		"testdata/bad/a.yuk":    "package bad",
+		"models/_old/draft.yuk": "package draft",
+	})
+
+	tests := []struct {
+		patterns []string
+		expected []string
+	}{
+		{[]string{"."}, []string{"."}},
+		{[]string{"./..."}, []string{".", "models", "models/sql"}},
+		{[]string{"models/..."}, []string{"models", "models/sql"}},
+		{[]string{"models", "./models/"}, []string{"models"}},
+	}
+	for _, tt := range tests {
+		dirs, err := Dirs(tt.patterns)
+		if err != nil {
+			t.Errorf("%q: %v", tt.patterns, err)
+			continue
+		}
+		if !reflect.DeepEqual(dirs, tt.expected) {
+			t.Errorf("%q: expected=%q, got=%q", tt.patterns, tt.expected, dirs)
+		}
+	}
+
+	if _, err := Dirs([]string{"main.yuk"}); err == nil {
+		t.Errorf("expected an error for a file pattern")
+	}
+}
+
+func TestConfig_Build(t *testing.T) {
+	writeTree(t, map[string]string{
+		"cmd/main.yuk":    "package main\n\nfunc main() {\n    println(1)\n}",
+		"models/user.yuk": "package models\n\nstruct User(Name string)",
+	})
+
+	cfg := &Config{LineDirectives: true}
+	if errs := cfg.Build([]string{"cmd", "models"}); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+	for _, path := range []string{"cmd/main.go", "models/user.go"} {
+		if !exists(path) {
+			t.Errorf("%s was not written", path)
+		}
+	}
+
+	cfg = &Config{Output: "out", SourceMap: true}
+	if errs := cfg.Build([]string{"cmd"}); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+	for _, path := range []string{"out/cmd/main.go", "out/cmd/main.yuk.map"} {
+		if !exists(path) {
+			t.Errorf("%s was not written", path)
+		}
+	}
+}
+
+func TestConfig_Build_Errors(t *testing.T) {
+	writeTree(t, map[string]string{
+		"app/main.yuk":   "package main\n\nfunc main() {\n    println(x)\n}",
+		"app/util.yuk":   "package util",
+		"cmd/main.yuk":   "package main\n\nfunc main() {\n    println(x)\n}",
+		"lib/lib.yuk":    "var y = 1",
+		"ok/ok.yuk":      "package ok",
+		"empty/notes.md": "",
+	})
+
+	cfg := &Config{}
+	errs := cfg.Build([]string{"app", "cmd", "empty", "lib", "ok"})
+	expected := []string{
+		"app: found packages main (main.yuk) and util (util.yuk)",
+		filepath.Join("cmd", "main.yuk") + ":4:13: undefined: x",
+		"empty: no .yuk files",
+		filepath.Join("lib", "lib.yuk") + ": missing package clause",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got=%q", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong error. expected=%q, got=%q", expected[i], err.Error())
+		}
+	}
+	if exists("ok/ok.go") {
+		t.Errorf("ok/ok.go was written by a failed build")
+	}
+}
+
+func TestConfig_Build_Package(t *testing.T) {
+	writeTree(t, map[string]string{
+		"models/user.yuk":  "package models\n\nstruct User(Name string)\n\nfunc NewUser(name string) *User {\n    return &User{Name: greeting + name}\n}",
+		"models/admin.yuk": "package models\n\nfunc Admin() *User {\n    return NewUser(\"root\").Promote()\n}",
+		"models/go.go":     "package models\n\nconst greeting = \"hi \"\n\nfunc (u *User) Promote() *User {\n\treturn u\n}\n",
+		"models/user.go":   "package models\n\nthis is a stale output",
+		"models/x_test.go": "package models",
+	})
+
+	pkg, err := Load("models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{filepath.Join("models", "go.go")}; !reflect.DeepEqual(pkg.GoFiles, expected) {
+		t.Errorf("wrong Go files. expected=%q, got=%q", expected, pkg.GoFiles)
+	}
+
+	cfg := &Config{Output: "out"}
+	if errs := cfg.Build([]string{"models"}); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+	for _, path := range []string{"out/models/user.go", "out/models/admin.go", "out/models/go.go"} {
+		if !exists(path) {
+			t.Errorf("%s was not written", path)
+		}
+	}
+}
+
+func TestConfig_Build_PackageErrors(t *testing.T) {
+	writeTree(t, map[string]string{
+		"models/user.yuk": "package models\n\nstruct User(Name string)\n\nfunc Count() int {\n    return total\n}",
+		"models/go.go":    "package models\n\nvar total = \"none\"\n\nfunc name(u User) int {\n\treturn u.Name\n}\n",
+	})
+
+	errs := (&Config{}).Build([]string{"models"})
+	expected := []string{
+		filepath.Join("models", "user.yuk") + ":6:12: cannot use total (variable of type string) as int value in return statement",
+		filepath.Join("models", "go.go") + ":6:9: cannot use u.Name (variable of type string) as int value in return statement",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got=%q", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong error. expected=%q, got=%q", expected[i], err.Error())
+		}
+	}
+}
+
+func TestConfig_Build_Imports(t *testing.T) {
+	writeTree(t, map[string]string{
+		"go.mod":          "module example.com/app\n\ngo 1.18\n",
+		"models/user.yuk": "package models\n\nstruct User(Name string)\n\nfunc (u User) Greeting() string {\n    return \"hi \" + u.Name\n}",
+		"cmd/main.yuk":    "package main\n\nimport \"example.com/app/models\"\n\nfunc main() {\n    let u = models.User{Name: \"yuk\"}\n    println(u.Greeting())\n}",
+	})
+
+	cfg := &Config{Output: "gen"}
+	if errs := cfg.Build([]string{"cmd"}); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+	src, err := ioutil.ReadFile("gen/cmd/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), `import "example.com/app/gen/models"`) {
+		t.Errorf("the import is not rewritten to the output directory:\n%s", src)
+	}
+	if !exists("gen/models/user.go") {
+		t.Errorf("the imported package was not written")
+	}
+
+	writeTree(t, map[string]string{
+		"go.mod":          "module example.com/app\n\ngo 1.18\n",
+		"models/user.yuk": "package models\n\nstruct User(Name string)",
+		"cmd/main.yuk":    "package main\n\nimport \"example.com/app/models\"\n\nvar u = models.User{Name: 1}",
+	})
+	errs := (&Config{}).Build([]string{"cmd"})
+	expected := filepath.Join("cmd", "main.yuk") + ":5:27: cannot use 1 (untyped int constant) as string value in struct literal"
+	if len(errs) != 1 || errs[0].Error() != expected {
+		t.Errorf("expected %q, got=%q", expected, errs)
+	}
+}
+
+func TestConfig_Build_ImportCycle(t *testing.T) {
+	writeTree(t, map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.18\n",
+		"a/a.yuk": "package a\n\nimport \"example.com/app/b\"\n\nvar A = b.B",
+		"b/b.yuk": "package b\n\nimport \"example.com/app/c\"\n\nvar B = c.C",
+		"c/c.yuk": "package c\n\nimport \"example.com/app/a\"\n\nvar C = a.A",
+	})
+
+	errs := (&Config{}).Build([]string{"a", "b", "c"})
+	expected := filepath.Join("c", "c.yuk") + ":3:8: import cycle not allowed: example.com/app/a -> example.com/app/b -> example.com/app/c -> example.com/app/a"
+	if len(errs) != 1 || errs[0].Error() != expected {
+		t.Errorf("expected %q, got=%q", expected, errs)
+	}
+	if exists("a/a.go") {
+		t.Errorf("a/a.go was written by a failed build")
+	}
+}
+
+func TestConfig_Build_Cache(t *testing.T) {
+	writeTree(t, map[string]string{
+		"go.mod":          "module example.com/app\n\ngo 1.18\n",
+		"models/user.yuk": "package models\n\nstruct User(Name string)\n\nfunc Name() string {\n    return \"yuk\"\n}",
+		"cmd/main.yuk":    "package main\n\nimport \"example.com/app/models\"\n\nfunc main() {\n    println(models.Name())\n}",
+	})
+	cfg := &Config{Cache: "cache"}
+	build := func() {
+		t.Helper()
+		if errs := cfg.Build([]string{"cmd"}); len(errs) > 0 {
+			t.Fatalf("unexpected errors: %q", errs)
+		}
+	}
+	// mark replaces the cached output of path, so the next build shows
+	// whether it was compiled again or taken from the cache.
+	mark := func(path string) {
+		t.Helper()
+		entries, _ := filepath.Glob("cache/*/*.json")
+		for _, file := range entries {
+			entry, ok := getCache("cache", strings.TrimSuffix(filepath.Base(file), ".json"))
+			if !ok || entry.Outputs[0].Path != path {
+				continue
+			}
+			entry.Outputs[0].Go = "// cached\n"
+			if err := putCache("cache", strings.TrimSuffix(filepath.Base(file), ".json"), entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	cached := func(path string) bool {
+		t.Helper()
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(src) == "// cached\n"
+	}
+	edit := func(path, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	build()
+	mark(filepath.Join("cmd", "main.go"))
+	mark(filepath.Join("models", "user.go"))
+	build()
+	if !cached("cmd/main.go") || !cached("models/user.go") {
+		t.Fatalf("unchanged packages were compiled again")
+	}
+
+	// A change keeping the API of models only compiles models.
+	edit("models/user.yuk", "package models\n\nstruct User(Name string)\n\nfunc Name() string {\n    return \"YUK\"\n}")
+	build()
+	if !cached("cmd/main.go") || cached("models/user.go") {
+		t.Errorf("expected only models to be compiled again")
+	}
+
+	// A change of its API compiles the packages importing it too.
+	mark(filepath.Join("models", "user.go"))
+	edit("models/user.yuk", "package models\n\nstruct User(Name string, Age int)\n\nfunc Name() string {\n    return \"YUK\"\n}")
+	build()
+	if cached("cmd/main.go") || cached("models/user.go") {
+		t.Errorf("expected both packages to be compiled again")
+	}
+
+	if err := Clean("cache"); err != nil || exists("cache") {
+		t.Errorf("the cache was not removed: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// cacheFormat is changed when the layout of the cache entries changes.
+const cacheFormat = 1
+
+// CacheDir returns the default build cache: yuk under $XDG_CACHE_HOME when
+// it is set, and .yuk-cache in the current directory otherwise.
+func CacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "yuk")
+	}
+	return ".yuk-cache"
+}
+
+// Clean removes the build cache dir.
+func Clean(dir string) error {
+	return os.RemoveAll(dir)
+}
+
+// cacheEntry is the result of compiling a package.
+type cacheEntry struct {
+	// API is the hash of the API of the package.
+	API     string    `json:"api"`
+	Outputs []*Output `json:"outputs"`
+}
+
+// cachePath returns the file of the entry with the key in dir.
+func cachePath(dir, key string) string {
+	return filepath.Join(dir, key[:2], key+".json")
+}
+
+// getCache returns the entry with the key in the cache dir.
+func getCache(dir, key string) (*cacheEntry, bool) {
+	data, err := ioutil.ReadFile(cachePath(dir, key))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// putCache stores the entry with the key in the cache dir. The entry is
+// written to a temporary file first, so a concurrent build never reads a
+// partial entry.
+func putCache(dir, key string, entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := cachePath(dir, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "entry-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// cacheKey returns the key of pkg built with cfg, from the contents of its
+// files, the compiler and the APIs of the yuk packages it imports, by
+// import path.
+func (cfg *Config) cacheKey(pkg *Package, importPath string, deps map[string]string) (string, error) {
+	h := sha256.New()
+	id, err := compilerID()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "yuk build cache %d\ncompiler %s\n", cacheFormat, id)
+	fmt.Fprintf(h, "output %q\nlines %v\nsourcemap %v\n", cfg.Output, cfg.LineDirectives, cfg.SourceMap)
+	fmt.Fprintf(h, "package %q\n", importPath)
+	mapped := make([]string, 0, len(cfg.ImportMap))
+	for path := range cfg.ImportMap {
+		mapped = append(mapped, path)
+	}
+	sort.Strings(mapped)
+	for _, path := range mapped {
+		fmt.Fprintf(h, "importmap %q %q\n", path, cfg.ImportMap[path])
+	}
+	for _, files := range [][]string{pkg.Files, pkg.GoFiles} {
+		for _, file := range files {
+			sum, err := fileHash(file)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "file %q %s\n", file, sum)
+		}
+	}
+	paths := make([]string, 0, len(deps))
+	for path := range deps {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(h, "import %q %s\n", path, deps[path])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hash returns the hex encoded SHA-256 of s.
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+var executable struct {
+	once sync.Once
+	id   string
+	err  error
+}
+
+// compilerID identifies the running compiler by the hash of its
+// executable, so a new build of yuk never reuses the outputs of another.
+func compilerID() (string, error) {
+	executable.once.Do(func() {
+		exe, err := os.Executable()
+		if err != nil {
+			executable.err = err
+			return
+		}
+		executable.id, executable.err = fileHash(exe)
+	})
+	return executable.id, executable.err
+}
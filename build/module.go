@@ -0,0 +1,104 @@
+package build
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Module is the Go module of a build, whose yuk packages can import each
+// other by import path.
+type Module struct {
+	// Root is the directory of the go.mod file, and Path the module path
+	// it declares.
+	Root string
+	Path string
+}
+
+// FindModule returns the module governing dir, or nil when there is none.
+func FindModule(dir string) (*Module, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		file := filepath.Join(dir, "go.mod")
+		if _, err := os.Stat(file); err == nil {
+			modPath, err := modulePath(file)
+			if err != nil {
+				return nil, err
+			}
+			return &Module{Root: dir, Path: modPath}, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// modulePath returns the path in the module directive of a go.mod file.
+func modulePath(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "module") {
+			continue
+		}
+		name := strings.TrimSpace(strings.TrimPrefix(line, "module"))
+		if i := strings.Index(name, "//"); i >= 0 {
+			name = strings.TrimSpace(name[:i])
+		}
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			name = unquoted
+		}
+		if name != "" {
+			return name, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s: no module directive", file)
+}
+
+// Dir returns the directory of the package with the import path importPath,
+// if it is in the module.
+func (m *Module) Dir(importPath string) (string, bool) {
+	if importPath == m.Path {
+		return m.Root, true
+	}
+	rel := strings.TrimPrefix(importPath, m.Path+"/")
+	if rel == importPath {
+		return "", false
+	}
+	return filepath.Join(m.Root, filepath.FromSlash(rel)), true
+}
+
+// ImportPath returns the import path of the package in dir, if it is in
+// the module.
+func (m *Module) ImportPath(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(m.Root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		return m.Path, true
+	}
+	return path.Join(m.Path, filepath.ToSlash(rel)), true
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/ahmadrosid/yuk/build"
+)
+
+// buildCommand compiles the yuk packages matched by the arguments, the
+// current directory by default, to Go files.
+func buildCommand(args []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	flags.Usage = func() {
+		log.Print("usage: yuk build [-a] [-o dir] [-lines=false] [-sourcemap] [dir | dir/...]...")
+		flags.PrintDefaults()
+	}
+	output := flags.String("o", "", "write the Go files under this directory instead of next to their sources")
+	lines := flags.Bool("lines", true, "emit //line directives so Go errors and stack traces point at the yuk source")
+	sourceMap := flags.Bool("sourcemap", false, "write a .yuk.map source map next to each Go file")
+	all := flags.Bool("a", false, "compile every package, instead of taking the unchanged ones from the build cache")
+	flags.Parse(args)
+
+	patterns := flags.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	dirs, err := build.Dirs(patterns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg := &build.Config{Output: *output, LineDirectives: *lines, SourceMap: *sourceMap}
+	if !*all {
+		cfg.Cache = build.CacheDir()
+	}
+	exitOnErrors(cfg.Build(dirs))
+}
@@ -0,0 +1,407 @@
+// Package checker runs the compile time safety checks on a parsed program
+// before any Go code is generated.
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+type binding struct {
+	token token.Token
+	// file is the name of the file declaring the binding.
+	file    string
+	mutable bool
+	typ     string
+}
+
+type scope struct {
+	outer    *scope
+	bindings map[string]*binding
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, bindings: map[string]*binding{}}
+}
+
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.bindings[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+type field struct {
+	mutable bool
+	typ     string
+}
+
+type Checker struct {
+	errors []error
+	scope  *scope
+	// file is the name of the program being checked.
+	file string
+
+	// unsafe is the number of enclosing unsafe blocks and functions.
+	unsafe int
+	// hasUnsafe is set when the program contains an unsafe region.
+	hasUnsafe bool
+
+	// structs maps a struct name to its fields.
+	structs map[string]map[string]field
+	// mutating maps a struct name to its methods that assign to the
+	// receiver, directly or by calling another mutating method.
+	mutating map[string]map[string]bool
+}
+
+func newChecker() *Checker {
+	return &Checker{
+		scope:    newScope(nil),
+		structs:  map[string]map[string]field{},
+		mutating: map[string]map[string]bool{},
+	}
+}
+
+// Check reports every reassignment of a `let` binding, every assignment to
+// a struct field not declared `mut`, and every mutation made through an
+// immutable binding, including calls to methods that mutate their receiver.
+// It also reports the operations only allowed inside unsafe regions, and
+// the //go: directives Go would ignore or reject.
+//
+// The programs are the files of one package: the structs, methods and
+// package level bindings of each are visible to the others.
+func Check(programs ...*ast.Program) []error {
+	c := newChecker()
+	for _, program := range programs {
+		c.file = program.File
+		c.collectStructs(program)
+		c.collectGlobals(program)
+	}
+	c.collectMutatingMethods(programs)
+	for _, program := range programs {
+		c.file = program.File
+		c.hasUnsafe = len(UnsafeRegions(program)) > 0
+		for _, stmt := range program.Statements {
+			c.checkStatement(stmt)
+		}
+		c.checkDirectives(program)
+	}
+	return c.errors
+}
+
+func (c *Checker) errorf(tok token.Token, format string, args ...interface{}) {
+	err := token.Errorf(tok, format, args...)
+	err.File = c.file
+	c.errors = append(c.errors, err)
+}
+
+// position formats the position of the declaration of b for messages, with
+// its file when it is not the file being checked.
+func (c *Checker) position(b *binding) string {
+	if b.file != c.file {
+		return fmt.Sprintf("%s:%d:%d", b.file, b.token.Line, b.token.Column)
+	}
+	return fmt.Sprintf("%d:%d", b.token.Line, b.token.Column)
+}
+
+// collectGlobals declares the package level bindings of the program, so
+// they are known to the functions of every file.
+func (c *Checker) collectGlobals(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		if s, ok := stmt.(*ast.VarStatement); ok {
+			c.declare(s.Name.Token, s.Name.Value, !s.IsImmutable(), c.typeOf(s.Value))
+		}
+	}
+}
+
+func (c *Checker) collectStructs(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		es, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			continue
+		}
+		st, ok := es.Expression.(*ast.StructStatement)
+		if !ok || st.Name == nil {
+			continue
+		}
+		fields := map[string]field{}
+		for _, attr := range st.Attributes {
+			fields[attr.Name.Literal] = field{mutable: attr.Mutable, typ: attr.Type.Literal}
+		}
+		c.structs[st.Name.Literal] = fields
+	}
+}
+
+func (c *Checker) collectMutatingMethods(programs []*ast.Program) {
+	var methods []*ast.FunctionLiteral
+	for _, program := range programs {
+		for _, stmt := range program.Statements {
+			es, ok := stmt.(*ast.ExpressionStatement)
+			if !ok {
+				continue
+			}
+			fn, ok := es.Expression.(*ast.FunctionLiteral)
+			if ok && fn.Receiver != nil && strings.HasPrefix(fn.Receiver.Type.Value, "*") {
+				methods = append(methods, fn)
+			}
+		}
+	}
+
+	// A method calling a mutating method on its receiver is mutating too,
+	// so repeat until nothing changes.
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range methods {
+			typ := baseType(fn.Receiver.Type.Value)
+			if c.mutating[typ][fn.Name] || !c.mutatesReceiver(fn) {
+				continue
+			}
+			if c.mutating[typ] == nil {
+				c.mutating[typ] = map[string]bool{}
+			}
+			c.mutating[typ][fn.Name] = true
+			changed = true
+		}
+	}
+}
+
+func (c *Checker) mutatesReceiver(fn *ast.FunctionLiteral) bool {
+	receiver := fn.Receiver.Name.Value
+	typ := baseType(fn.Receiver.Type.Value)
+	mutates := false
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignStatement:
+			if _, ok := n.Target.(*ast.Identifier); !ok && rootName(n.Target) == receiver {
+				mutates = true
+			}
+		case *ast.CallExpression:
+			sel, ok := n.Function.(*ast.SelectorExpression)
+			if ok && rootName(sel.Left) == receiver && c.mutating[typ][sel.Name.Value] {
+				mutates = true
+			}
+		}
+		return !mutates
+	})
+	return mutates
+}
+
+func (c *Checker) declare(tok token.Token, name string, mutable bool, typ string) {
+	c.scope.bindings[name] = &binding{token: tok, file: c.file, mutable: mutable, typ: typ}
+}
+
+func (c *Checker) enterScope() {
+	c.scope = newScope(c.scope)
+}
+
+func (c *Checker) leaveScope() {
+	c.scope = c.scope.outer
+}
+
+func (c *Checker) checkStatement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.VarStatement:
+		if s.Value != nil {
+			c.checkExpression(s.Value)
+		}
+		c.declare(s.Name.Token, s.Name.Value, !s.IsImmutable(), c.typeOf(s.Value))
+	case *ast.AssignStatement:
+		c.checkExpression(s.Value)
+		c.checkExpression(s.Target)
+		c.checkAssign(s)
+	case *ast.ReturnStatement:
+		c.checkExpression(s.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.checkExpression(s.Expression)
+	case *ast.BlockStatement:
+		c.checkBlock(s)
+	case *ast.UnsafeStatement:
+		c.unsafe++
+		c.checkBlock(s.Body)
+		c.unsafe--
+	case *ast.ImportStatement:
+		c.checkImport(s)
+	case *ast.SwitchStatement:
+		for _, cl := range s.Case {
+			if cl != nil {
+				c.checkBlock(cl.Body)
+			}
+		}
+	}
+}
+
+func (c *Checker) checkBlock(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	c.enterScope()
+	for _, stmt := range block.Statements {
+		c.checkStatement(stmt)
+	}
+	c.leaveScope()
+}
+
+// checkExpression looks for mutating method calls in an expression and
+// checks the bodies of the function literals it contains.
+func (c *Checker) checkExpression(exp ast.Expression) {
+	if exp == nil {
+		return
+	}
+	ast.Inspect(exp, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FunctionLiteral:
+			c.checkFunction(n)
+			return false
+		case *ast.IfExpression:
+			c.checkExpression(n.Condition)
+			c.checkBlock(n.Consequence)
+			c.checkBlock(n.Alternative)
+			return false
+		case *ast.VarExpression:
+			c.checkExpression(n.Value)
+			c.declare(n.Token, n.Token.Literal, true, c.typeOf(n.Value))
+			return false
+		case *ast.CallExpression:
+			c.checkMethodCall(n)
+			c.checkUnwrap(n)
+		case *ast.PrefixExpression:
+			c.checkDereference(n)
+		case *ast.SelectorExpression:
+			c.checkUnsafePackage(n)
+		}
+		return true
+	})
+}
+
+func (c *Checker) checkFunction(fn *ast.FunctionLiteral) {
+	if fn.Unsafe {
+		c.unsafe++
+		defer func() { c.unsafe-- }()
+	}
+	c.enterScope()
+	if fn.Receiver != nil {
+		c.declare(fn.Receiver.Name.Token, fn.Receiver.Name.Value, true, fn.Receiver.Type.Value)
+	}
+	for _, param := range fn.Params {
+		c.declare(param.Name.Token, param.Name.Value, true, param.Type.Value)
+	}
+	if fn.Body != nil {
+		for _, stmt := range fn.Body.Statements {
+			c.checkStatement(stmt)
+		}
+	}
+	c.leaveScope()
+}
+
+func (c *Checker) checkAssign(s *ast.AssignStatement) {
+	if ident, ok := s.Target.(*ast.Identifier); ok {
+		if b := c.scope.lookup(ident.Value); b != nil && !b.mutable {
+			c.errorf(ident.Token, "cannot assign to %s, it is declared with let at %s", ident.Value, c.position(b))
+		}
+		return
+	}
+
+	root := rootIdentifier(s.Target)
+	if root != nil {
+		if b := c.scope.lookup(root.Value); b != nil && !b.mutable {
+			c.errorf(root.Token, "cannot assign to %s, %s is declared with let at %s",
+				s.Target.String(), root.Value, c.position(b))
+			return
+		}
+	}
+
+	sel, ok := s.Target.(*ast.SelectorExpression)
+	if !ok {
+		return
+	}
+	typ := baseType(c.typeOf(sel.Left))
+	if f, ok := c.structs[typ][sel.Name.Value]; ok && !f.mutable {
+		c.errorf(sel.Name.Token, "cannot assign to %s, field %s of %s is not declared mut",
+			s.Target.String(), sel.Name.Value, typ)
+	}
+}
+
+func (c *Checker) checkMethodCall(call *ast.CallExpression) {
+	sel, ok := call.Function.(*ast.SelectorExpression)
+	if !ok {
+		return
+	}
+	typ := baseType(c.typeOf(sel.Left))
+	if !c.mutating[typ][sel.Name.Value] {
+		return
+	}
+	root := rootIdentifier(sel.Left)
+	if root == nil {
+		return
+	}
+	if b := c.scope.lookup(root.Value); b != nil && !b.mutable {
+		c.errorf(sel.Name.Token, "cannot call %s, it mutates %s which is declared with let at %s",
+			sel.String(), root.Value, c.position(b))
+	}
+}
+
+// typeOf returns the name of the type of exp when it can be known without
+// a type checker, or an empty string.
+func (c *Checker) typeOf(exp ast.Expression) string {
+	switch e := exp.(type) {
+	case *ast.Identifier:
+		if b := c.scope.lookup(e.Value); b != nil {
+			return b.typ
+		}
+	case *ast.StructLiteral:
+		return e.Type.Value
+	case *ast.GroupedExpression:
+		return c.typeOf(e.Expression)
+	case *ast.SelectorExpression:
+		typ := baseType(c.typeOf(e.Left))
+		if f, ok := c.structs[typ][e.Name.Value]; ok {
+			return f.typ
+		}
+	case *ast.CallExpression:
+		ext, ok := ast.LookupExtension(e)
+		if !ok || (ext.Name != "first" && ext.Name != "last") {
+			break
+		}
+		// A struct is no slice: its first or last is a method of its own.
+		recv := baseType(c.typeOf(e.Function.(*ast.SelectorExpression).Left))
+		if _, ok := c.structs[recv]; !ok {
+			return optionType
+		}
+	}
+	return ""
+}
+
+// rootIdentifier returns the variable at the start of a selector or index
+// chain, e.g. `user` for `user.Posts[0].Title`.
+func rootIdentifier(exp ast.Expression) *ast.Identifier {
+	for {
+		switch e := exp.(type) {
+		case *ast.Identifier:
+			return e
+		case *ast.SelectorExpression:
+			exp = e.Left
+		case *ast.IndexExpression:
+			exp = e.Left
+		case *ast.GroupedExpression:
+			exp = e.Expression
+		default:
+			return nil
+		}
+	}
+}
+
+func rootName(exp ast.Expression) string {
+	if ident := rootIdentifier(exp); ident != nil {
+		return ident.Value
+	}
+	return ""
+}
+
+func baseType(typ string) string {
+	return strings.TrimPrefix(typ, "*")
+}
@@ -0,0 +1,174 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+)
+
+func check(t *testing.T, input string) []error {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %q", p.Errors())
+	}
+	return Check(program)
+}
+
+func TestCheck_Immutability(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"var x = 1\nx = 2", nil},
+		{"let x = 1\nx = 2", []string{"2:1: cannot assign to x, it is declared with let at 1:5"}},
+		{"let xs = [1, 2]\nxs[0] = 2", []string{"2:1: cannot assign to xs[0], xs is declared with let at 1:5"}},
+		{"let x = 1\nfunc main() {\nvar x = 2\nx = 3\n}", nil},
+		{"let x = 1\nfunc main() {\nx = 3\n}", []string{"3:1: cannot assign to x, it is declared with let at 1:5"}},
+		{
+			"struct User(mut Name string, Age int)\nvar u = User{Name: \"a\", Age: 1}\nu.Name = \"b\"\nu.Age = 2",
+			[]string{"4:3: cannot assign to u.Age, field Age of User is not declared mut"},
+		},
+		{
+			"struct User(mut Name string)\nlet u = User{Name: \"a\"}\nu.Name = \"b\"",
+			[]string{"3:1: cannot assign to u.Name, u is declared with let at 2:5"},
+		},
+		{
+			"struct User(Age int)\nfunc (u *User) Birthday() {\nu.Age = u.Age + 1\n}",
+			[]string{"3:3: cannot assign to u.Age, field Age of User is not declared mut"},
+		},
+		{
+			`struct User(mut Name string)
+func (u *User) Rename(name string) {
+	u.Name = name
+}
+func (u *User) Reset() {
+	u.Rename("")
+}
+func main() {
+	var a = User{Name: "a"}
+	a.Reset()
+	let b = User{Name: "b"}
+	b.Reset()
+}`,
+			[]string{"12:4: cannot call b.Reset, it mutates b which is declared with let at 11:6"},
+		},
+	}
+
+	for _, tt := range tests {
+		errs := check(t, tt.input)
+		if len(errs) != len(tt.expected) {
+			t.Errorf("%s\nexpected %d errors, got=%q", tt.input, len(tt.expected), errs)
+			continue
+		}
+		for i, err := range errs {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+func TestCheck_Unsafe(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"func f(p *int) int {\nreturn *p\n}", []string{"2:8: cannot dereference p outside of an unsafe block, it may be nil"}},
+		{"unsafe func f(p *int) int {\nreturn *p\n}", nil},
+		{"func f(p *int) {\nunsafe {\nvar x = *p\n}\n}", nil},
+		{"unsafe func f() {\nvar g = func(p *int) int { return *p }\n}", nil},
+		{"import \"unsafe\"\nfunc f() {}", []string{"1:8: import of package unsafe requires an unsafe block or unsafe func"}},
+		{"import \"unsafe\"\nunsafe func f() {}", nil},
+		{"func f(p *int) {\nvar q = unsafe.Pointer(p)\n}", []string{"2:9: unsafe.Pointer can only be used inside an unsafe block"}},
+		{"var x = [1].first().Unwrap()", []string{"1:21: cannot call Unwrap on an Option outside of an unsafe block, use Get or UnwrapOr"}},
+		{"var first = [1].first()\nvar x = first.UnwrapOr(0)", nil},
+		{"func f() {\nunsafe {\nvar x = [1].last().Unwrap()\n}\n}", nil},
+	}
+
+	for _, tt := range tests {
+		errs := check(t, tt.input)
+		if len(errs) != len(tt.expected) {
+			t.Errorf("%s\nexpected %d errors, got=%q", tt.input, len(tt.expected), errs)
+			continue
+		}
+		for i, err := range errs {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+func TestCheck_Directives(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"//go:build linux\n\npackage main", nil},
+		{"//go:build ignore\n// +build ignore\n\npackage main", nil},
+		{"//go:build linux\npackage main", []string{"1:1: build constraint must be followed by a blank line"}},
+		{"package main\n\n//go:build linux\n\nvar x = 1", []string{"3:1: misplaced build constraint, it must come before the package clause"}},
+		{"//go:build linux &&\n\npackage main", []string{"1:1: invalid build constraint: unexpected end of expression"}},
+		{"//go:build linux\n//go:build darwin\n\npackage main", []string{"2:1: multiple //go:build comments"}},
+		{"//go:embed hello.txt\nvar hello string", nil},
+		{"//go:embed static/*\nvar files embed.FS", nil},
+		{"//go:embed hello.txt\nvar hello = \"hi\"", []string{"1:1: go:embed cannot apply to var with initializer"}},
+		{"//go:embed hello.txt\nvar hello int", []string{"1:1: go:embed cannot apply to var of type int, only string, []byte and embed.FS"}},
+		{"//go:embed hello.txt\n\nvar hello string", []string{"1:1: misplaced //go:embed directive, it must be right above a var declaration"}},
+		{"func f() {\n//go:embed hello.txt\nvar hello string\n}", []string{"2:1: go:embed cannot apply to var inside func"}},
+		{"//go:embed\nvar hello string", []string{"1:1: missing patterns in //go:embed"}},
+		{"//go:generate stringer -type=Kind\ntype Kind int", nil},
+		{"//go:generate\ntype Kind int", []string{"1:1: missing command in //go:generate"}},
+		{"//go:noinline\nfunc f() {}", nil},
+		{"// f adds.\n//go:nosplit\nunsafe func f() {}", nil},
+		{"//go:noinline\nvar x = 1", []string{"1:1: misplaced compiler directive //go:noinline, it must be right above a func declaration"}},
+		{"//go:noinlin\nfunc f() {}", []string{"1:1: unknown directive //go:noinlin"}},
+		{"// go:noinline is not a directive\nvar x = 1", nil},
+	}
+
+	for _, tt := range tests {
+		errs := check(t, tt.input)
+		if len(errs) != len(tt.expected) {
+			t.Errorf("%s\nexpected %d errors, got=%q", tt.input, len(tt.expected), errs)
+			continue
+		}
+		for i, err := range errs {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+func TestUnsafeRegions(t *testing.T) {
+	input := `unsafe func peek(p *int) int {
+	return *p
+}
+
+func main() {
+	unsafe {
+		var x = 1
+	}
+}`
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %q", p.Errors())
+	}
+
+	expected := []string{
+		"1:8-3:1: unsafe func peek",
+		"6:2-8:2: unsafe block in main",
+	}
+	regions := UnsafeRegions(program)
+	if len(regions) != len(expected) {
+		t.Fatalf("expected %d regions, got=%d", len(expected), len(regions))
+	}
+	for i, region := range regions {
+		if region.String() != expected[i] {
+			t.Errorf("wrong region. expected=%q, got=%q", expected[i], region.String())
+		}
+	}
+}
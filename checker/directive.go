@@ -0,0 +1,148 @@
+package checker
+
+import (
+	"go/build/constraint"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// funcDirectives are the compiler directives written above a function
+// declaration.
+var funcDirectives = map[string]bool{
+	"noinline":       true,
+	"nosplit":        true,
+	"noescape":       true,
+	"norace":         true,
+	"nocheckptr":     true,
+	"uintptrescapes": true,
+}
+
+// embedTypes are the types a variable embedding files can have.
+var embedTypes = map[string]bool{
+	"string":   true,
+	"[]byte":   true,
+	"embed.FS": true,
+}
+
+// checkDirectives reports the //go: directives of the program that Go
+// would ignore or reject: build constraints after the package clause or
+// not followed by a blank line, //go:embed anywhere but above a package
+// level var, compiler directives anywhere but above a function, and
+// unknown directives.
+func (c *Checker) checkDirectives(program *ast.Program) {
+	var pkg *token.Token
+	for _, stmt := range program.Statements {
+		if s, ok := stmt.(*ast.ExpressionStatement); ok {
+			if lit, ok := s.Expression.(*ast.ExpressionLiteral); ok && lit.Token.Type == token.PACKAGE {
+				pkg = &lit.Token
+				break
+			}
+		}
+	}
+
+	// The declarations documented by each comment group, inner being the
+	// variables declared inside functions.
+	funcs := map[*ast.CommentGroup]bool{}
+	vars := map[*ast.CommentGroup]*ast.VarStatement{}
+	inner := map[*ast.CommentGroup]bool{}
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *ast.VarStatement:
+			if s.Doc != nil {
+				vars[s.Doc] = s
+			}
+		case *ast.ExpressionStatement:
+			if fn, ok := s.Expression.(*ast.FunctionLiteral); ok && fn.Name != "" && fn.Doc != nil {
+				funcs[fn.Doc] = true
+			}
+		}
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			if v, ok := node.(*ast.VarStatement); ok && v != stmt && v.Doc != nil {
+				inner[v.Doc] = true
+			}
+			return true
+		})
+	}
+
+	builds := 0
+	for _, group := range program.Comments {
+		for _, comment := range group.List {
+			text := strings.TrimPrefix(comment.Literal, "//")
+			if text == comment.Literal {
+				continue
+			}
+			if strings.HasPrefix(text, " +build") {
+				c.checkBuild(comment, group, program, pkg)
+				continue
+			}
+			if !strings.HasPrefix(text, "go:") {
+				continue
+			}
+			name, args := text[len("go:"):], ""
+			if i := strings.IndexAny(name, " \t"); i >= 0 {
+				name, args = name[:i], strings.TrimSpace(name[i:])
+			}
+
+			switch {
+			case name == "build":
+				builds++
+				if builds > 1 {
+					c.errorf(comment, "multiple //go:build comments")
+				}
+				c.checkBuild(comment, group, program, pkg)
+			case name == "embed":
+				c.checkEmbed(comment, args, vars[group], inner[group])
+			case name == "generate":
+				if args == "" {
+					c.errorf(comment, "missing command in //go:generate")
+				}
+			case funcDirectives[name]:
+				if !funcs[group] {
+					c.errorf(comment, "misplaced compiler directive //go:%s, it must be right above a func declaration", name)
+				}
+			default:
+				c.errorf(comment, "unknown directive //go:%s", name)
+			}
+		}
+	}
+}
+
+// checkBuild reports a build constraint that does not parse, comes after
+// the package clause, or is followed by the package clause without a blank
+// line in between, where Go takes it for documentation.
+func (c *Checker) checkBuild(comment token.Token, group *ast.CommentGroup, program *ast.Program, pkg *token.Token) {
+	switch {
+	case pkg != nil && (comment.Line > pkg.Line || comment.Line == pkg.Line && comment.Column > pkg.Column):
+		c.errorf(comment, "misplaced build constraint, it must come before the package clause")
+	case group == program.Doc:
+		c.errorf(comment, "build constraint must be followed by a blank line")
+	default:
+		if _, err := constraint.Parse(comment.Literal); err != nil {
+			c.errorf(comment, "invalid build constraint: %v", err)
+		}
+	}
+}
+
+// checkEmbed reports a //go:embed directive without patterns or not right
+// above a package level var without value, of type string, []byte or
+// embed.FS.
+func (c *Checker) checkEmbed(comment token.Token, patterns string, v *ast.VarStatement, inner bool) {
+	switch {
+	case patterns == "":
+		c.errorf(comment, "missing patterns in //go:embed")
+	case inner:
+		c.errorf(comment, "go:embed cannot apply to var inside func")
+	case v == nil:
+		c.errorf(comment, "misplaced //go:embed directive, it must be right above a var declaration")
+	case v.Value != nil:
+		c.errorf(comment, "go:embed cannot apply to var with initializer")
+	case v.Type == nil || !embedTypes[v.Type.Value]:
+		typ := "no type"
+		if v.Type != nil {
+			typ = "type " + v.Type.Value
+		}
+		c.errorf(comment, "go:embed cannot apply to var of %s, only string, []byte and embed.FS", typ)
+	}
+}
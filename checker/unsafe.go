@@ -0,0 +1,88 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// optionType is the type of the values returned by `first()` and `last()`.
+const optionType = "std.Option"
+
+// UnsafeRegion is an `unsafe { ... }` block or an `unsafe func`.
+type UnsafeRegion struct {
+	// Func is the name of the unsafe function, or of the function holding
+	// the unsafe block.
+	Func  string
+	Block bool
+	Start token.Token
+	End   token.Token
+}
+
+func (r UnsafeRegion) String() string {
+	kind := "unsafe func"
+	if r.Block {
+		kind = "unsafe block in"
+	}
+	return fmt.Sprintf("%d:%d-%d:%d: %s %s", r.Start.Line, r.Start.Column, r.End.Line, r.End.Column, kind, r.Func)
+}
+
+// UnsafeRegions lists the unsafe regions of the program in source order,
+// so they can be reviewed.
+func UnsafeRegions(program *ast.Program) []UnsafeRegion {
+	var regions []UnsafeRegion
+	var walk func(node ast.Node, fn string)
+	walk = func(node ast.Node, fn string) {
+		ast.Inspect(node, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.FunctionLiteral:
+				name := fn
+				if n.Name != "" {
+					name = n.Name
+				}
+				if n.Unsafe {
+					regions = append(regions, UnsafeRegion{Func: name, Start: n.Token, End: n.Body.End})
+				}
+				walk(n.Body, name)
+				return false
+			case *ast.UnsafeStatement:
+				regions = append(regions, UnsafeRegion{Func: fn, Block: true, Start: n.Token, End: n.Body.End})
+			}
+			return true
+		})
+	}
+	walk(program, "")
+	return regions
+}
+
+func (c *Checker) checkImport(s *ast.ImportStatement) {
+	lit, ok := s.PackageName.(*ast.StringLiteral)
+	if ok && lit.Value == "unsafe" && !c.hasUnsafe {
+		c.errorf(lit.Token, "import of package unsafe requires an unsafe block or unsafe func")
+	}
+}
+
+func (c *Checker) checkDereference(n *ast.PrefixExpression) {
+	if n.Operator == "*" && c.unsafe == 0 {
+		c.errorf(n.Token, "cannot dereference %s outside of an unsafe block, it may be nil", n.Right.String())
+	}
+}
+
+func (c *Checker) checkUnsafePackage(n *ast.SelectorExpression) {
+	ident, ok := n.Left.(*ast.Identifier)
+	if ok && ident.Token.Type == token.UNSAFE && c.unsafe == 0 {
+		c.errorf(ident.Token, "%s can only be used inside an unsafe block", n.String())
+	}
+}
+
+func (c *Checker) checkUnwrap(call *ast.CallExpression) {
+	sel, ok := call.Function.(*ast.SelectorExpression)
+	if !ok || sel.Name.Value != "Unwrap" || c.unsafe > 0 {
+		return
+	}
+	if strings.HasPrefix(c.typeOf(sel.Left), optionType) {
+		c.errorf(sel.Name.Token, "cannot call Unwrap on an Option outside of an unsafe block, use Get or UnwrapOr")
+	}
+}
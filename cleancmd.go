@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/ahmadrosid/yuk/build"
+)
+
+// cleanCommand removes the build cache.
+func cleanCommand(args []string) {
+	flags := flag.NewFlagSet("clean", flag.ExitOnError)
+	flags.Usage = func() {
+		log.Print("usage: yuk clean")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		log.Fatalf("yuk clean takes no arguments")
+	}
+
+	if err := build.Clean(build.CacheDir()); err != nil {
+		log.Fatal(err)
+	}
+}
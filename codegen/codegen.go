@@ -0,0 +1,647 @@
+// Package codegen lowers a checked yuk program to a Go syntax tree and
+// prints it with go/format, so the generated code is always gofmt-clean
+// and syntactically valid Go.
+//
+// Go nodes keep the position of the yuk tokens they come from. go/printer
+// uses them to keep the line structure of the yuk source, like blank lines
+// between statements and short functions written on one line.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	goast "go/ast"
+	"go/format"
+	gotoken "go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/sourcemap"
+	"github.com/ahmadrosid/yuk/token"
+	"github.com/ahmadrosid/yuk/types"
+)
+
+// lineWidth is the number of columns of each line of the position space
+// Go nodes are placed in. Longer lines are clamped to it.
+const lineWidth = 1 << 12
+
+type generator struct {
+	fset *gotoken.FileSet
+	file *gotoken.File
+	// dropped lists, in order, the yuk lines without a Go counterpart.
+	// They are left out of the lines of file so they don't print as
+	// blank lines.
+	dropped []int
+	errors  []error
+	// importMap is the ImportMap of the Config.
+	importMap map[string]string
+	info      *types.Info
+	// comments are the Go comment groups of the yuk ones, which are placed
+	// both in the comments of the file and on the nodes they document.
+	comments map[*ast.CommentGroup]*goast.CommentGroup
+}
+
+// Config controls the output of Generate.
+type Config struct {
+	// LineDirectives adds //line comments giving the yuk position of each
+	// line, so Go compiler errors, vet reports and stack traces point back
+	// to the yuk source. The output is no longer gofmt-clean.
+	LineDirectives bool
+	// SourceMap makes Generate return the map from the tokens of the
+	// output to the yuk source.
+	SourceMap bool
+	// Filename names the yuk source in the line directives and the source
+	// map. Like any //line file name, a relative name is relative to the
+	// directory of the generated file.
+	Filename string
+	// ImportMap replaces the import paths of the program, e.g. for yuk
+	// packages whose Go files are written elsewhere than their sources.
+	ImportMap map[string]string
+	// Info is the result of type checking the program. Its Extensions
+	// tell the calls of slice extension methods from the calls of methods
+	// named like them. Without it, every call of a method named like an
+	// extension is one.
+	Info *types.Info
+}
+
+// Generate returns the Go source of program. A program without a package
+// clause is printed as a list of declarations, without a package clause
+// either.
+func Generate(program *ast.Program) (string, []error) {
+	src, _, errs := (&Config{}).Generate(program)
+	return src, errs
+}
+
+// Generate returns the Go source of program, configured by cfg, and its
+// source map when cfg.SourceMap is set. The File of the map is left for
+// the caller to fill in.
+func (cfg *Config) Generate(program *ast.Program) (string, *sourcemap.Map, []error) {
+	g := &generator{
+		fset:      gotoken.NewFileSet(),
+		importMap: cfg.ImportMap,
+		info:      cfg.Info,
+		comments:  map[*ast.CommentGroup]*goast.CommentGroup{},
+	}
+	// The file starts at base 2, so position 1 comes before the source,
+	// for the package clause of printDecls.
+	g.file = g.fset.AddFile("main.yuk", 2, lineWidth<<18)
+	g.dropped = droppedLines(program)
+
+	file := g.program(program)
+	if len(g.errors) > 0 {
+		return "", nil, g.errors
+	}
+
+	var out bytes.Buffer
+	var err error
+	roots := []goast.Node{file}
+	if file.Name != nil {
+		err = format.Node(&out, g.fset, file)
+	} else {
+		err = g.printDecls(&out, file)
+		roots = declNodes(file.Decls)
+	}
+	if err == nil {
+		// The layout go/printer picks from positions that are out of order,
+		// as in macro expansions, is not always canonical.
+		var src []byte
+		src, err = format.Source(out.Bytes())
+		out.Reset()
+		out.Write(bytes.TrimSpace(src))
+		out.WriteByte('\n')
+	}
+	if err != nil {
+		return "", nil, []error{fmt.Errorf("generated invalid Go: %v", err)}
+	}
+	if !cfg.LineDirectives && !cfg.SourceMap || len(roots) == 0 {
+		return out.String(), nil, nil
+	}
+
+	mappings, err := g.printedNodes(roots, out.Bytes())
+	if err != nil {
+		return "", nil, []error{err}
+	}
+	src := out.Bytes()
+	if cfg.LineDirectives {
+		src = lineDirectives(cfg.Filename, mappings, src)
+	}
+	var m *sourcemap.Map
+	if cfg.SourceMap {
+		m = &sourcemap.Map{Version: sourcemap.Version, Source: cfg.Filename, Mappings: mappings}
+	}
+	return string(src), m, nil
+}
+
+// printDecls prints the declarations of a file without package clause,
+// with its comments. go/printer only prints the comments of whole files,
+// so the file is printed with a package clause that is then dropped.
+func (g *generator) printDecls(out *bytes.Buffer, file *goast.File) error {
+	if len(file.Comments) == 0 {
+		return format.Node(out, g.fset, file.Decls)
+	}
+	var src bytes.Buffer
+	err := format.Node(&src, g.fset, &goast.File{Package: 1, Name: &goast.Ident{NamePos: 1, Name: "p"}, Decls: file.Decls, Comments: file.Comments})
+	if err != nil {
+		return err
+	}
+	out.Write(bytes.TrimLeft(bytes.TrimPrefix(src.Bytes(), []byte("package p\n")), "\n"))
+	return nil
+}
+
+func (g *generator) errorf(tok token.Token, format string, args ...interface{}) {
+	g.errors = append(g.errors, token.Errorf(tok, format, args...))
+}
+
+// pos returns the Go position of a yuk token, or NoPos for the tokens
+// added by the compiler, which have no line.
+func (g *generator) pos(tok token.Token) gotoken.Pos {
+	line := tok.Line - sort.SearchInts(g.dropped, tok.Line)
+	if tok.Line <= 0 || line >= g.file.Size()/lineWidth {
+		return gotoken.NoPos
+	}
+	for g.file.LineCount() < line {
+		g.file.AddLine(g.file.LineCount() * lineWidth)
+	}
+	column := tok.Column
+	if column < 1 {
+		column = 1
+	} else if column >= lineWidth {
+		column = lineWidth - 1
+	}
+	return g.file.Pos((line-1)*lineWidth + column - 1)
+}
+
+// droppedLines returns the lines holding only the brace closing a switch
+// arm, which Go case clauses don't have.
+func droppedLines(program *ast.Program) []int {
+	var lines []int
+	ast.Inspect(program, func(node ast.Node) bool {
+		s, ok := node.(*ast.SwitchStatement)
+		if !ok {
+			return true
+		}
+		for i, c := range s.Case {
+			if c == nil || c.Body == nil {
+				continue
+			}
+			line := c.Body.End.Line
+			if line == c.Body.Token.Line || line == s.End.Line {
+				continue
+			}
+			if i+1 < len(s.Case) && s.Case[i+1] != nil && s.Case[i+1].Token.Line == line {
+				continue
+			}
+			lines = append(lines, line)
+		}
+		return true
+	})
+	sort.Ints(lines)
+	return lines
+}
+
+func (g *generator) ident(tok token.Token, name string) *goast.Ident {
+	return &goast.Ident{NamePos: g.pos(tok), Name: name}
+}
+
+// commentGroup returns the Go comment group of c, or nil if c is nil.
+func (g *generator) commentGroup(c *ast.CommentGroup) *goast.CommentGroup {
+	if c == nil {
+		return nil
+	}
+	if group, ok := g.comments[c]; ok {
+		return group
+	}
+	group := &goast.CommentGroup{}
+	for _, tok := range c.List {
+		group.List = append(group.List, &goast.Comment{Slash: g.pos(tok), Text: tok.Literal})
+	}
+	g.comments[c] = group
+	return group
+}
+
+// program lowers the top level statements. Imports are gathered into one
+// declaration placed first, as Go requires.
+func (g *generator) program(program *ast.Program) *goast.File {
+	file := &goast.File{Doc: g.commentGroup(program.Doc)}
+	for _, c := range program.Comments {
+		file.Comments = append(file.Comments, g.commentGroup(c))
+	}
+	imports := &goast.GenDecl{Tok: gotoken.IMPORT}
+	var importStmts []*ast.ImportStatement
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *ast.ImportStatement:
+			lit, ok := s.PackageName.(*ast.StringLiteral)
+			if !ok {
+				continue
+			}
+			if imports.TokPos == gotoken.NoPos {
+				imports.TokPos = g.pos(s.Token)
+			}
+			spec := &goast.ImportSpec{
+				Doc:     g.commentGroup(s.Doc),
+				Path:    g.stringLit(lit),
+				Comment: g.commentGroup(s.Comment),
+			}
+			if path, ok := g.importMap[lit.Value]; ok {
+				spec.Path.Value = strconv.Quote(path)
+			}
+			imports.Specs = append(imports.Specs, spec)
+			importStmts = append(importStmts, s)
+		case *ast.ExpressionStatement:
+			if lit, ok := s.Expression.(*ast.ExpressionLiteral); ok && lit.Token.Type == token.PACKAGE {
+				file.Package = g.pos(lit.Token)
+				file.Name = g.ident(lit.Name.Token, lit.Name.Value)
+				continue
+			}
+			if decl := g.topLevel(s); decl != nil {
+				file.Decls = append(file.Decls, decl)
+			}
+		default:
+			if decl := g.topLevel(s); decl != nil {
+				file.Decls = append(file.Decls, decl)
+			}
+		}
+	}
+
+	if len(imports.Specs) > 1 {
+		// Imports gathered from all over the file are printed one per
+		// line, without the gaps between their source lines. Imports on
+		// consecutive lines keep their positions, and their comments.
+		imports.Lparen = imports.TokPos
+		if !consecutive(importStmts) {
+			for _, spec := range imports.Specs {
+				spec.(*goast.ImportSpec).Path.ValuePos = gotoken.NoPos
+			}
+			// In the order go/format sorts them, so the tree is in the
+			// order of the output for the source map.
+			sort.SliceStable(imports.Specs, func(i, j int) bool {
+				return imports.Specs[i].(*goast.ImportSpec).Path.Value < imports.Specs[j].(*goast.ImportSpec).Path.Value
+			})
+		}
+	}
+	var decls []goast.Decl
+	if len(imports.Specs) > 0 {
+		decls = append(decls, imports)
+	}
+	if embeds(program) && !imported(importStmts, "embed") {
+		// Embedding into a string or []byte needs the package imported,
+		// though nothing of it is used. It is a declaration of its own, so
+		// go/format doesn't move it among the others.
+		decls = append(decls, &goast.GenDecl{Tok: gotoken.IMPORT, Specs: []goast.Spec{&goast.ImportSpec{
+			Name: goast.NewIdent("_"),
+			Path: &goast.BasicLit{Kind: gotoken.STRING, Value: strconv.Quote("embed")},
+		}}})
+	}
+	file.Decls = append(decls, file.Decls...)
+	return file
+}
+
+// embeds reports whether program has a //go:embed directive.
+func embeds(program *ast.Program) bool {
+	for _, group := range program.Comments {
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Literal, "//go:embed ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func imported(imports []*ast.ImportStatement, path string) bool {
+	for _, imp := range imports {
+		if lit, ok := imp.PackageName.(*ast.StringLiteral); ok && lit.Value == path {
+			return true
+		}
+	}
+	return false
+}
+
+// consecutive reports whether each import starts on the line after the
+// previous one, or after the comments documenting it. The imports added by
+// the compiler have no line and are left out.
+func consecutive(imports []*ast.ImportStatement) bool {
+	prev := 0
+	for _, imp := range imports {
+		line := imp.Token.Line
+		if line == 0 {
+			continue
+		}
+		if imp.Doc != nil {
+			line = imp.Doc.List[0].Line
+		}
+		if prev != 0 && line != prev+1 {
+			return false
+		}
+		prev = imp.Token.Line
+	}
+	return true
+}
+
+func (g *generator) topLevel(stmt ast.Statement) goast.Decl {
+	switch s := stmt.(type) {
+	case *ast.VarStatement:
+		return g.varDecl(s)
+	case *ast.StructAttributes:
+		if s.Token != nil {
+			return g.typeDecl(s)
+		}
+	case *ast.ExpressionStatement:
+		switch e := s.Expression.(type) {
+		case *ast.FunctionLiteral:
+			if e.Name != "" {
+				return g.funcDecl(e)
+			}
+		case *ast.StructStatement:
+			if e.Name != nil {
+				return g.structDecl(e)
+			}
+		}
+	case *ast.MacroStatement:
+		// Macros are expanded before code generation.
+		return nil
+	}
+	g.errorf(statementToken(stmt), "non-declaration statement outside function body")
+	return nil
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch s := stmt.(type) {
+	case *ast.ExpressionStatement:
+		if tok, ok := expressionToken(s.Expression); ok {
+			return tok
+		}
+		return s.Token
+	case *ast.AssignStatement:
+		if tok, ok := expressionToken(s.Target); ok {
+			return tok
+		}
+		return s.Token
+	case *ast.SwitchStatement:
+		return s.Token
+	case *ast.ReturnStatement:
+		return s.Token
+	case *ast.BlockStatement:
+		return s.Token
+	case *ast.UnsafeStatement:
+		return s.Token
+	}
+	return token.Token{}
+}
+
+// varDecl lowers `var x int = 1`. An anonymous struct written as the value,
+// `var user = struct(Name string)`, is the type of the variable in Go.
+func (g *generator) varDecl(s *ast.VarStatement) *goast.GenDecl {
+	spec := &goast.ValueSpec{
+		Names:   []*goast.Ident{g.ident(s.Name.Token, s.Name.Value)},
+		Comment: g.commentGroup(s.Comment),
+	}
+	if s.Type != nil {
+		spec.Type = g.typeExpr(s.Type.Token, s.Type.Value)
+	}
+	if st, ok := s.Value.(*ast.StructStatement); ok && st.Name == nil && s.Type == nil {
+		spec.Type = g.structType(st)
+	} else if s.Value != nil {
+		spec.Values = []goast.Expr{g.expr(s.Value)}
+	}
+	return &goast.GenDecl{Doc: g.commentGroup(s.Doc), TokPos: g.pos(s.Token), Tok: gotoken.VAR, Specs: []goast.Spec{spec}}
+}
+
+// typeDecl lowers `type Name string`.
+func (g *generator) typeDecl(s *ast.StructAttributes) *goast.GenDecl {
+	spec := &goast.TypeSpec{
+		Name:    g.ident(s.Name, s.Name.Literal),
+		Type:    g.typeExpr(s.Type, s.Type.Literal),
+		Comment: g.commentGroup(s.Comment),
+	}
+	return &goast.GenDecl{Doc: g.commentGroup(s.Doc), TokPos: g.pos(*s.Token), Tok: gotoken.TYPE, Specs: []goast.Spec{spec}}
+}
+
+// structDecl lowers `struct User(Name string)` to a type declaration.
+func (g *generator) structDecl(s *ast.StructStatement) *goast.GenDecl {
+	spec := &goast.TypeSpec{Name: g.ident(*s.Name, s.Name.Literal), Type: g.structType(s)}
+	return &goast.GenDecl{Doc: g.commentGroup(s.Doc), TokPos: g.pos(s.Token), Tok: gotoken.TYPE, Specs: []goast.Spec{spec}}
+}
+
+func (g *generator) structType(s *ast.StructStatement) *goast.StructType {
+	fields := &goast.FieldList{Opening: g.pos(s.Token)}
+	for _, attr := range s.Attributes {
+		field := &goast.Field{
+			Doc:     g.commentGroup(attr.Doc),
+			Names:   []*goast.Ident{g.ident(attr.Name, attr.Name.Literal)},
+			Type:    g.typeExpr(attr.Type, attr.Type.Literal),
+			Comment: g.commentGroup(attr.Comment),
+		}
+		if attr.Meta != nil {
+			field.Tag = g.tag(attr.Meta)
+		}
+		fields.List = append(fields.List, field)
+	}
+	return &goast.StructType{Struct: g.pos(s.Token), Fields: fields}
+}
+
+// tag lowers struct field metadata to a raw string tag, with the key value
+// pairs separated by spaces as reflect.StructTag expects.
+func (g *generator) tag(meta *ast.MetaLiteral) *goast.BasicLit {
+	var out bytes.Buffer
+	out.WriteByte('`')
+	for i, kv := range meta.KeyValue {
+		if i > 0 {
+			out.WriteByte(' ')
+		}
+		out.WriteString(kv.Key.Literal)
+		out.WriteByte(':')
+		if lit, ok := kv.Value.(*ast.StringLiteral); ok {
+			out.WriteString(strconv.Quote(lit.Value))
+		} else {
+			out.WriteString(kv.Value.String())
+		}
+	}
+	out.WriteByte('`')
+	return &goast.BasicLit{ValuePos: g.pos(meta.Token), Kind: gotoken.STRING, Value: out.String()}
+}
+
+func (g *generator) funcDecl(fn *ast.FunctionLiteral) *goast.FuncDecl {
+	decl := &goast.FuncDecl{
+		Doc:  g.commentGroup(fn.Doc),
+		Name: g.ident(fn.NameToken, fn.Name),
+		Type: g.funcType(fn),
+		Body: g.block(fn.Body),
+	}
+	if fn.Receiver != nil {
+		decl.Recv = &goast.FieldList{List: []*goast.Field{g.param(fn.Receiver)}}
+	}
+	return decl
+}
+
+func (g *generator) funcType(fn *ast.FunctionLiteral) *goast.FuncType {
+	typ := &goast.FuncType{Func: g.pos(fn.Token), Params: &goast.FieldList{}}
+	for _, p := range fn.Params {
+		typ.Params.List = append(typ.Params.List, g.param(p))
+	}
+	if ret, ok := fn.ReturnType.(*ast.Identifier); ok {
+		typ.Results = &goast.FieldList{List: []*goast.Field{{Type: g.typeExpr(ret.Token, ret.Value)}}}
+	}
+	return typ
+}
+
+func (g *generator) param(p *ast.Parameter) *goast.Field {
+	return &goast.Field{
+		Names: []*goast.Ident{g.ident(p.Name.Token, p.Name.Value)},
+		Type:  g.typeExpr(p.Type.Token, p.Type.Value),
+	}
+}
+
+func (g *generator) block(block *ast.BlockStatement) *goast.BlockStmt {
+	if block == nil {
+		return &goast.BlockStmt{}
+	}
+	return &goast.BlockStmt{
+		Lbrace: g.pos(block.Token),
+		List:   g.statements(block.Statements),
+		Rbrace: g.pos(block.End),
+	}
+}
+
+func (g *generator) statements(stmts []ast.Statement) []goast.Stmt {
+	var list []goast.Stmt
+	for _, stmt := range stmts {
+		if s := g.statement(stmt); s != nil {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func (g *generator) statement(stmt ast.Statement) goast.Stmt {
+	switch s := stmt.(type) {
+	case *ast.VarStatement:
+		return &goast.DeclStmt{Decl: g.varDecl(s)}
+	case *ast.StructAttributes:
+		if s.Token != nil {
+			return &goast.DeclStmt{Decl: g.typeDecl(s)}
+		}
+	case *ast.AssignStatement:
+		return &goast.AssignStmt{
+			Lhs:    []goast.Expr{g.expr(s.Target)},
+			TokPos: g.pos(s.Token),
+			Tok:    gotoken.ASSIGN,
+			Rhs:    []goast.Expr{g.expr(s.Value)},
+		}
+	case *ast.ReturnStatement:
+		ret := &goast.ReturnStmt{Return: g.pos(s.Token)}
+		if s.ReturnValue != nil {
+			ret.Results = []goast.Expr{g.expr(s.ReturnValue)}
+		}
+		return ret
+	case *ast.BlockStatement:
+		return g.block(s)
+	case *ast.UnsafeStatement:
+		// Unsafe blocks only matter to the checker.
+		return g.block(s.Body)
+	case *ast.SwitchStatement:
+		return g.switchStmt(s)
+	case *ast.ExpressionStatement:
+		return g.expressionStatement(s)
+	case *ast.MacroStatement:
+		return nil
+	}
+	g.errorf(statementToken(stmt), "unexpected %s in function body", stmt.TokenLiteral())
+	return nil
+}
+
+func (g *generator) expressionStatement(s *ast.ExpressionStatement) goast.Stmt {
+	switch e := s.Expression.(type) {
+	case nil:
+		return nil
+	case *ast.IfExpression:
+		return g.ifStmt(e)
+	case *ast.VarExpression:
+		return &goast.AssignStmt{
+			Lhs: []goast.Expr{g.ident(e.Ident, e.Ident.Literal)},
+			Tok: gotoken.DEFINE,
+			Rhs: []goast.Expr{g.expr(e.Value)},
+		}
+	case *ast.StructStatement:
+		if e.Name != nil {
+			return &goast.DeclStmt{Decl: g.structDecl(e)}
+		}
+	case *ast.FunctionLiteral:
+		// Go has no nested function declarations, a named function in a
+		// body becomes a variable holding a function literal.
+		if e.Name != "" {
+			return &goast.AssignStmt{
+				Lhs: []goast.Expr{g.ident(e.NameToken, e.Name)},
+				Tok: gotoken.DEFINE,
+				Rhs: []goast.Expr{&goast.FuncLit{Type: g.funcType(e), Body: g.block(e.Body)}},
+			}
+		}
+	case *ast.Identifier:
+		switch e.Value {
+		case "break":
+			return &goast.BranchStmt{TokPos: g.pos(e.Token), Tok: gotoken.BREAK}
+		case "continue":
+			return &goast.BranchStmt{TokPos: g.pos(e.Token), Tok: gotoken.CONTINUE}
+		}
+	}
+	return &goast.ExprStmt{X: g.expr(s.Expression)}
+}
+
+func (g *generator) ifStmt(e *ast.IfExpression) *goast.IfStmt {
+	stmt := &goast.IfStmt{If: g.pos(e.Token), Cond: g.expr(e.Condition), Body: g.block(e.Consequence)}
+	if e.Alternative != nil {
+		stmt.Else = g.block(e.Alternative)
+	}
+	return stmt
+}
+
+// switchStmt lowers a switch, where each arm is written `value => { ... }`
+// and `_` is the default arm. The statements of an arm are the body of the
+// Go case clause.
+func (g *generator) switchStmt(s *ast.SwitchStatement) *goast.SwitchStmt {
+	stmt := &goast.SwitchStmt{
+		Switch: g.pos(s.Token),
+		Tag:    g.tokenExpr(s.Input),
+		Body:   &goast.BlockStmt{Lbrace: g.pos(s.Input), Rbrace: g.pos(s.End)},
+	}
+	for _, c := range s.Case {
+		if c == nil {
+			continue
+		}
+		clause := &goast.CaseClause{Case: g.pos(c.Token), Body: g.statements(c.Body.Statements)}
+		if c.Token.Type != token.UNDERSCORE {
+			clause.List = []goast.Expr{g.tokenExpr(c.Token)}
+		}
+		stmt.Body.List = append(stmt.Body.List, clause)
+	}
+	return stmt
+}
+
+// tokenExpr lowers the single token a switch input or case is written as.
+func (g *generator) tokenExpr(tok token.Token) goast.Expr {
+	switch tok.Type {
+	case token.CHAR:
+		return &goast.BasicLit{ValuePos: g.pos(tok), Kind: gotoken.CHAR, Value: "'" + tok.Literal + "'"}
+	case token.STRING_LIT:
+		return &goast.BasicLit{ValuePos: g.pos(tok), Kind: gotoken.STRING, Value: `"` + tok.Literal + `"`}
+	case token.INT:
+		return &goast.BasicLit{ValuePos: g.pos(tok), Kind: gotoken.INT, Value: tok.Literal}
+	case token.TRUE, token.FALSE:
+		return g.ident(tok, tok.Literal)
+	case token.IDENT:
+		return g.ident(tok, tok.Literal)
+	}
+	g.errorf(tok, "unexpected %s in switch", tok.Literal)
+	return &goast.BadExpr{}
+}
+
+func (g *generator) stringLit(lit *ast.StringLiteral) *goast.BasicLit {
+	return &goast.BasicLit{ValuePos: g.pos(lit.Token), Kind: gotoken.STRING, Value: `"` + lit.Value + `"`}
+}
+
+// extensionPackage is the name ExtensionPackage is imported as.
+var extensionPackage = path.Base(ast.ExtensionPackage)
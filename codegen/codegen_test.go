@@ -0,0 +1,247 @@
+package codegen
+
+import (
+	"fmt"
+	goast "go/ast"
+	"go/format"
+	goparser "go/parser"
+	gotoken "go/token"
+	"strings"
+	"testing"
+
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+	"github.com/ahmadrosid/yuk/sourcemap"
+)
+
+func generate(t *testing.T, input string) (string, []error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%s\nparse errors: %q", input, p.Errors())
+	}
+	return Generate(program)
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"package main", "package main\n"},
+		{"var x = (1 + 2) * 3", "var x = (1 + 2) * 3\n"},
+		{"var x = 1 + 2 * 3", "var x = 1 + 2*3\n"},
+		{"var ok = !true", "var ok = !true\n"},
+		{"var m = map(string, int)", "var m = map[string]int{}\n"},
+		{"var p *map(string, []int)", "var p *map[string][]int\n"},
+		{
+			"var m = map(string, int){\"c\": 3, \"a\": 1, \"d\": 4, \"b\": 2}",
+			"var m = map[string]int{\"c\": 3, \"a\": 1, \"d\": 4, \"b\": 2}\n",
+		},
+		{
+			"var m = map(string, int){\n\"a\": 1,\n\"b\": 2\n}\nvar n = 1",
+			"var m = map[string]int{\n\t\"a\": 1,\n\t\"b\": 2,\n}\nvar n = 1\n",
+		},
+		{"var xs = [\"a\", 1]", "var xs = []interface{}{\"a\", 1}\n"},
+		{"var empty = ![1].is_empty()", "var empty = !(len([]int{1}) == 0)\n"},
+		{"import \"encoding/json\"\nvar raw json.RawMessage", "import \"encoding/json\"\n\nvar raw json.RawMessage\n"},
+		{
+			"package main\nimport \"fmt\"\nvar x = 1\nimport \"strings\"",
+			"package main\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\nvar x = 1\n",
+		},
+		{
+			"struct Post(Title string `json:\"title\" db:\"title\"`)",
+			"type Post struct {\n\tTitle string `json:\"title\" db:\"title\"`\n}\n",
+		},
+		{
+			"func f(a int) int {\nif a > 1 {\nreturn a\n} else {\nreturn 1\n}\n}",
+			"func f(a int) int {\n\tif a > 1 {\n\t\treturn a\n\t} else {\n\t\treturn 1\n\t}\n}\n",
+		},
+		{
+			"func f() {\nvar a = 1\n\nvar b = 2\nprintln(a, b)\n}",
+			"func f() {\n\tvar a = 1\n\n\tvar b = 2\n\tprintln(a, b)\n}\n",
+		},
+		{
+			"func f(s string) {\nswitch s {\n\"a\" => {\nbreak\n},\n_ => {\nprintln(s)\n}\n}\n}",
+			"func f(s string) {\n\tswitch s {\n\tcase \"a\":\n\t\tbreak\n\tdefault:\n\t\tprintln(s)\n\t}\n}\n",
+		},
+		{
+			"func f() {\nfunc g() int { return 1 }\nprintln(g())\n}",
+			"func f() {\n\tg := func() int { return 1 }\n\tprintln(g())\n}\n",
+		},
+		{
+			"func f(p *int) int {\nunsafe {\nreturn *p\n}\n}",
+			"func f(p *int) int {\n\t{\n\t\treturn *p\n\t}\n}\n",
+		},
+		{
+			"//go:build linux\n\n// Package main is a demo.\npackage main\n\n// Answer is 42.\nvar Answer = 42 // always",
+			"//go:build linux\n\n// Package main is a demo.\npackage main\n\n// Answer is 42.\nvar Answer = 42 // always\n",
+		},
+		{
+			"import \"fmt\" // printing\n// strings is for upper.\nimport \"strings\"",
+			"import (\n\t\"fmt\" // printing\n\t// strings is for upper.\n\t\"strings\"\n)\n",
+		},
+		{
+			"struct User(\n// Name is the name.\nName string // never empty\nAge int\n)",
+			"type User struct {\n\t// Name is the name.\n\tName string // never empty\n\tAge  int\n}\n",
+		},
+		{
+			"package main\nimport \"fmt\"\n//go:embed hello.txt\nvar hello string",
+			"package main\n\nimport \"fmt\"\nimport _ \"embed\"\n\n//go:embed hello.txt\nvar hello string\n",
+		},
+		{
+			"import \"embed\"\n//go:embed static\nvar static embed.FS",
+			"import \"embed\"\n\n//go:embed static\nvar static embed.FS\n",
+		},
+		{
+			"//go:noinline\nfunc f() {\n/* nothing */\n}",
+			"//go:noinline\nfunc f() {\n\t/* nothing */\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		res, errs := generate(t, tt.input)
+		if len(errs) > 0 {
+			t.Errorf("%s\nunexpected errors: %q", tt.input, errs)
+			continue
+		}
+		if res != tt.expected {
+			t.Errorf("%s\nexpected=%q\ngot=%q", tt.input, tt.expected, res)
+		}
+		if formatted, err := format.Source([]byte(res)); err != nil || string(formatted) != res {
+			t.Errorf("%s\noutput is not gofmt-clean: %q, %v", tt.input, formatted, err)
+		}
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"one := 1", "1:1: non-declaration statement outside function body"},
+		{"var x = 1\nprintln(x)", "2:1: non-declaration statement outside function body"},
+		{"switch x {\n_ => {\nprintln(1)\n}\n}", "1:1: non-declaration statement outside function body"},
+	}
+
+	for _, tt := range tests {
+		_, errs := generate(t, tt.input)
+		if len(errs) != 1 {
+			t.Errorf("%s\nexpected 1 error, got=%q", tt.input, errs)
+			continue
+		}
+		if errs[0].Error() != tt.expected {
+			t.Errorf("%s\nwrong error. expected=%q, got=%q", tt.input, tt.expected, errs[0].Error())
+		}
+	}
+}
+
+func TestConfig_Positions(t *testing.T) {
+	tests := []struct {
+		input string
+		// positions maps the names in the output to their yuk position.
+		positions map[string]string
+	}{
+		{
+			"package main\n\nfunc main() {\n    var xs = [1, 2]\n    println(xs)\n}",
+			map[string]string{"xs": "4:9", "println": "5:5"},
+		},
+		{
+			"func f(s string) {\nswitch s {\n\"a\" => {\nprintln(s)\n},\n_ => {\nempty := !s.is_empty()\nprintln(empty)\n}\n}\n}",
+			map[string]string{"f": "1:6", "switch": "2:1", "empty": "7:1"},
+		},
+		{
+			"func f() {\nfunc g() int { return 1 }\nprintln(g())\n}",
+			map[string]string{"g": "2:6", "println": "3:1"},
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s\nparse errors: %q", tt.input, p.Errors())
+		}
+		cfg := &Config{LineDirectives: true, SourceMap: true, Filename: "main.yuk"}
+		res, m, errs := cfg.Generate(program)
+		if len(errs) > 0 {
+			t.Errorf("%s\nunexpected errors: %q", tt.input, errs)
+			continue
+		}
+
+		prefix := ""
+		if program.Statements[0].TokenLiteral() != "package" {
+			prefix = "package p\n"
+		}
+		src := prefix + res
+		fset := gotoken.NewFileSet()
+		file, err := goparser.ParseFile(fset, "main.go", src, 0)
+		if err != nil {
+			t.Errorf("%s\ninvalid output %q: %v", tt.input, res, err)
+			continue
+		}
+
+		// The first use of each name, and the switch keyword.
+		found := map[string]string{}
+		goast.Inspect(file, func(node goast.Node) bool {
+			var name string
+			switch n := node.(type) {
+			case *goast.Ident:
+				name = n.Name
+			case *goast.SwitchStmt:
+				name = "switch"
+			default:
+				return true
+			}
+			pos := fset.Position(node.Pos())
+			if pos.Filename != "main.yuk" {
+				return true
+			}
+			first := found[name] == ""
+			if first {
+				found[name] = fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+			}
+
+			// The source map agrees with the line directives, which only
+			// give the column of the first token of a line.
+			at := fset.PositionFor(node.Pos(), false)
+			generated := sourcemap.Position{Line: at.Line - strings.Count(prefix, "\n"), Column: at.Column}
+			source, ok := m.SourcePosition(generated)
+			if !ok || source.Line != pos.Line || first && tt.positions[name] != "" && source.String() != tt.positions[name] {
+				t.Errorf("%s\n%s at %s maps to %s, directives give %s", tt.input, name, generated, source, pos)
+			}
+			return true
+		})
+		for name, expected := range tt.positions {
+			if found[name] != expected {
+				t.Errorf("%s\n%s is at %s, expected %s in %q", tt.input, name, found[name], expected, res)
+			}
+		}
+	}
+}
+
+func TestConfig_Positions_Imports(t *testing.T) {
+	// gofmt sorts the imports: "fmt" is printed before "strings".
+	input := "package main\nimport \"strings\"\nimport \"fmt\"\nvar s = strings.ToUpper(fmt.Sprint(1))"
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %q", p.Errors())
+	}
+	res, m, errs := (&Config{SourceMap: true, Filename: "main.yuk"}).Generate(program)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+
+	expected := map[string]int{`"fmt"`: 3, `"strings"`: 2}
+	for i, line := range strings.Split(res, "\n") {
+		path := strings.TrimSpace(line)
+		if expected[path] == 0 {
+			continue
+		}
+		source, ok := m.SourcePosition(sourcemap.Position{Line: i + 1, Column: strings.Index(line, path) + 1})
+		if !ok || source.Line != expected[path] {
+			t.Errorf("%s on line %d maps to %s, expected line %d", path, i+1, source, expected[path])
+		}
+	}
+}
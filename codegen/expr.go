@@ -0,0 +1,243 @@
+package codegen
+
+import (
+	goast "go/ast"
+	gotoken "go/token"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// binaryOps maps the spelling of an operator to its Go token.
+var binaryOps = map[string]gotoken.Token{}
+
+func init() {
+	for tok := gotoken.ADD; tok <= gotoken.TILDE; tok++ {
+		if tok.IsOperator() {
+			binaryOps[tok.String()] = tok
+		}
+	}
+}
+
+func (g *generator) expr(exp ast.Expression) goast.Expr {
+	switch e := exp.(type) {
+	case *ast.Identifier:
+		return g.ident(e.Token, e.Value)
+	case *ast.IntegerLiteral:
+		return &goast.BasicLit{ValuePos: g.pos(e.Token), Kind: gotoken.INT, Value: e.Value}
+	case *ast.StringLiteral:
+		return g.stringLit(e)
+	case *ast.Boolean:
+		return g.ident(e.Token, e.Token.Literal)
+	case *ast.ExpressionLiteral:
+		// The `string` keyword, used as a conversion.
+		return g.ident(e.Name.Token, e.Name.Value)
+	case *ast.GroupedExpression:
+		return &goast.ParenExpr{Lparen: g.pos(e.Token), X: g.expr(e.Expression)}
+	case *ast.PrefixExpression:
+		if e.Operator == "*" {
+			return &goast.StarExpr{Star: g.pos(e.Token), X: g.expr(e.Right)}
+		}
+		if op, ok := binaryOps[e.Operator]; ok {
+			return &goast.UnaryExpr{OpPos: g.pos(e.Token), Op: op, X: g.expr(e.Right)}
+		}
+	case *ast.InfixExpression:
+		if op, ok := binaryOps[e.Operator]; ok {
+			return &goast.BinaryExpr{X: g.expr(e.Left), OpPos: g.pos(e.Token), Op: op, Y: g.expr(e.Right)}
+		}
+	case *ast.SelectorExpression:
+		return &goast.SelectorExpr{X: g.expr(e.Left), Sel: g.ident(e.Name.Token, e.Name.Value)}
+	case *ast.IndexExpression:
+		return &goast.IndexExpr{X: g.expr(e.Left), Lbrack: g.pos(e.Token), Index: g.expr(e.Index)}
+	case *ast.CallExpression:
+		return g.call(e)
+	case *ast.FunctionLiteral:
+		if e.Name == "" {
+			return &goast.FuncLit{Type: g.funcType(e), Body: g.block(e.Body)}
+		}
+	case *ast.ArrayLiteral:
+		lit := &goast.CompositeLit{
+			Type:   &goast.ArrayType{Lbrack: g.pos(e.Token), Elt: g.typeExpr(e.Token, e.ElementType())},
+			Lbrace: g.pos(e.Token),
+		}
+		for _, el := range e.Elements {
+			lit.Elts = append(lit.Elts, g.expr(el))
+		}
+		return lit
+	case *ast.MapLiteral:
+		// `map(string, int)` without entries is an empty map.
+		lit := &goast.CompositeLit{Type: &goast.MapType{
+			Map:   g.pos(e.Token),
+			Key:   g.typeExpr(e.Key.Token, e.Key.Value),
+			Value: g.typeExpr(e.Value.Token, e.Value.Value),
+		}}
+		if e.KeyValue != nil {
+			lit.Lbrace, lit.Rbrace = g.pos(e.KeyValue.Start), g.pos(e.KeyValue.End)
+			for _, pair := range e.KeyValue.Pairs {
+				lit.Elts = append(lit.Elts, &goast.KeyValueExpr{Key: g.expr(pair.Key), Value: g.expr(pair.Value)})
+			}
+		}
+		return lit
+	case *ast.StructLiteral:
+		lit := &goast.CompositeLit{Type: g.typeExpr(e.Type.Token, e.Type.Value), Lbrace: g.pos(e.Token)}
+		for _, f := range e.Fields {
+			lit.Elts = append(lit.Elts, &goast.KeyValueExpr{
+				Key:   g.ident(f.Name.Token, f.Name.Value),
+				Value: g.expr(f.Value),
+			})
+		}
+		return lit
+	case *ast.StructStatement:
+		if e.Name == nil {
+			return g.structType(e)
+		}
+	case *ast.MacroCall:
+		g.errorf(e.Token, "macro %s! was not expanded", e.Name.Value)
+		return &goast.BadExpr{}
+	}
+
+	if tok, ok := expressionToken(exp); ok {
+		g.errorf(tok, "unexpected %s, expected expression", exp.TokenLiteral())
+	} else {
+		g.errors = append(g.errors, token.Errorf(token.Token{}, "unexpected %T, expected expression", exp))
+	}
+	return &goast.BadExpr{}
+}
+
+// call lowers a call, turning the slice extension methods into calls of
+// the builtin len or of the helpers in ExtensionPackage.
+func (g *generator) call(e *ast.CallExpression) goast.Expr {
+	var args []goast.Expr
+	for _, arg := range e.Arguments {
+		args = append(args, g.expr(arg))
+	}
+
+	ext, ok := g.extension(e)
+	if !ok {
+		return &goast.CallExpr{Fun: g.expr(e.Function), Lparen: g.pos(e.Token), Args: args}
+	}
+
+	sel := e.Function.(*ast.SelectorExpression)
+	receiver := g.expr(sel.Left)
+	length := &goast.CallExpr{Fun: g.ident(sel.Name.Token, "len"), Args: []goast.Expr{receiver}}
+	switch ext.Name {
+	case "len":
+		return length
+	case "is_empty":
+		return &goast.BinaryExpr{X: length, Op: gotoken.EQL, Y: &goast.BasicLit{Kind: gotoken.INT, Value: "0"}}
+	}
+	return &goast.CallExpr{
+		Fun: &goast.SelectorExpr{
+			X:   g.ident(sel.Name.Token, extensionPackage),
+			Sel: goast.NewIdent(ext.Helper),
+		},
+		Args: append([]goast.Expr{receiver}, args...),
+	}
+}
+
+// extension returns the slice extension method e calls, if any.
+func (g *generator) extension(e *ast.CallExpression) (ast.Extension, bool) {
+	if g.info != nil {
+		ext, ok := g.info.Extensions[e]
+		return ext, ok
+	}
+	return ast.LookupExtension(e)
+}
+
+// typeExpr lowers a type spelled by the parser in Go syntax, like "[]*User",
+// "map[string]interface{}" or "json.RawMessage". Every node of the type is
+// placed at tok.
+func (g *generator) typeExpr(tok token.Token, spelling string) goast.Expr {
+	pos := g.pos(tok)
+	switch {
+	case strings.HasPrefix(spelling, "*"):
+		return &goast.StarExpr{Star: pos, X: g.typeExpr(tok, spelling[1:])}
+	case strings.HasPrefix(spelling, "[]"):
+		return &goast.ArrayType{Lbrack: pos, Elt: g.typeExpr(tok, spelling[2:])}
+	case strings.HasPrefix(spelling, "map["):
+		end := matchingBracket(spelling, len("map"))
+		if end < 0 {
+			break
+		}
+		return &goast.MapType{
+			Map:   pos,
+			Key:   g.typeExpr(tok, spelling[len("map["):end]),
+			Value: g.typeExpr(tok, spelling[end+1:]),
+		}
+	case spelling == "interface" || spelling == "interface{}":
+		// Braces on one line keep the empty interface on one line.
+		return &goast.InterfaceType{Interface: pos, Methods: &goast.FieldList{Opening: pos, Closing: pos}}
+	case strings.Contains(spelling, "."):
+		dot := strings.Index(spelling, ".")
+		return &goast.SelectorExpr{
+			X:   &goast.Ident{NamePos: pos, Name: spelling[:dot]},
+			Sel: &goast.Ident{NamePos: pos, Name: spelling[dot+1:]},
+		}
+	case spelling != "" && !strings.ContainsAny(spelling, "[]{}() "):
+		return &goast.Ident{NamePos: pos, Name: spelling}
+	}
+	g.errorf(tok, "invalid type %s", spelling)
+	return &goast.BadExpr{}
+}
+
+// matchingBracket returns the index of the bracket closing the one at
+// open, or -1.
+func matchingBracket(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// expressionToken returns the first token of an expression.
+func expressionToken(exp ast.Expression) (token.Token, bool) {
+	switch e := exp.(type) {
+	case *ast.Identifier:
+		return e.Token, true
+	case *ast.InfixExpression:
+		return expressionToken(e.Left)
+	case *ast.CallExpression:
+		return expressionToken(e.Function)
+	case *ast.SelectorExpression:
+		return expressionToken(e.Left)
+	case *ast.IndexExpression:
+		return expressionToken(e.Left)
+	case *ast.VarExpression:
+		return e.Ident, true
+	case *ast.IfExpression:
+		return e.Token, true
+	case *ast.FunctionLiteral:
+		return e.Token, true
+	case *ast.IntegerLiteral:
+		return e.Token, true
+	case *ast.StringLiteral:
+		return e.Token, true
+	case *ast.PrefixExpression:
+		return e.Token, true
+	case *ast.GroupedExpression:
+		return e.Token, true
+	case *ast.ArrayLiteral:
+		return e.Token, true
+	case *ast.MapLiteral:
+		return e.Token, true
+	case *ast.StructLiteral:
+		return e.Type.Token, true
+	case *ast.StructStatement:
+		return e.Token, true
+	case *ast.MacroCall:
+		return e.Name.Token, true
+	case *ast.Boolean:
+		return e.Token, true
+	}
+	return token.Token{}, false
+}
@@ -0,0 +1,178 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	goast "go/ast"
+	"go/parser"
+	gotoken "go/token"
+	"reflect"
+	"sort"
+
+	"github.com/ahmadrosid/yuk/sourcemap"
+)
+
+// printedNodes returns the mappings from the nodes of the printed source
+// src to the yuk tokens they come from, in the order of src. roots is the
+// tree src was printed from.
+//
+// src is parsed again and its nodes are paired with the nodes of roots in
+// walk order. The parentheses go/printer adds and the field lists it fills
+// in are skipped on both sides, the trees are otherwise the same.
+func (g *generator) printedNodes(roots []goast.Node, src []byte) ([]sourcemap.Mapping, error) {
+	prefix := []byte{}
+	if _, ok := roots[0].(*goast.File); !ok {
+		// A list of declarations parses as the body of a file.
+		prefix = []byte("package p\n")
+	}
+	fset := gotoken.NewFileSet()
+	parsed, err := parser.ParseFile(fset, "", append(prefix, src...), parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	generated := lineNodes(roots...)
+	var printed []goast.Node
+	if len(prefix) > 0 {
+		printed = lineNodes(declNodes(parsed.Decls)...)
+	} else {
+		printed = lineNodes(parsed)
+	}
+	if len(generated) != len(printed) {
+		return nil, fmt.Errorf("mapping generated Go: %d nodes printed as %d", len(generated), len(printed))
+	}
+
+	var mappings []sourcemap.Mapping
+	for i, node := range generated {
+		if reflect.TypeOf(node) != reflect.TypeOf(printed[i]) {
+			return nil, fmt.Errorf("mapping generated Go: %T printed as %T", node, printed[i])
+		}
+		if !node.Pos().IsValid() {
+			continue
+		}
+		at := fset.Position(printed[i].Pos())
+		pos := g.fset.Position(node.Pos())
+		mapping := sourcemap.Mapping{
+			Generated: sourcemap.Position{Line: at.Line - bytes.Count(prefix, []byte("\n")), Column: at.Column},
+			Source:    sourcemap.Position{Line: g.sourceLine(pos.Line), Column: pos.Column},
+		}
+		switch n := printed[i].(type) {
+		case *goast.Ident:
+			mapping.Length = len(n.Name)
+		case *goast.BasicLit:
+			mapping.Length = len(n.Value)
+		}
+		mappings = append(mappings, mapping)
+	}
+	sort.SliceStable(mappings, func(i, j int) bool {
+		a, b := mappings[i].Generated, mappings[j].Generated
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	// A node starts where its first child does, keep the innermost, which
+	// is walked last.
+	unique := mappings[:0]
+	for _, m := range mappings {
+		if last := len(unique) - 1; last >= 0 && unique[last].Generated == m.Generated {
+			unique[last] = m
+			continue
+		}
+		unique = append(unique, m)
+	}
+	return unique, nil
+}
+
+// lineDirectives inserts a `//line filename:L:C` directive before every line
+// of src that starts with a mapped node, so that the Go toolchain reports
+// the yuk position of the node. The indentation of these lines is dropped
+// and mappings are moved to the output.
+func lineDirectives(filename string, mappings []sourcemap.Mapping, src []byte) []byte {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	directives := make([]*sourcemap.Mapping, len(lines))
+	for i := range mappings {
+		m := &mappings[i]
+		line := lines[m.Generated.Line-1]
+		indent := len(line) - len(bytes.TrimLeft(line, " \t"))
+		if directives[m.Generated.Line-1] == nil && m.Generated.Column == indent+1 {
+			directives[m.Generated.Line-1] = m
+		}
+	}
+
+	var out bytes.Buffer
+	shift := make([]struct{ line, column int }, len(lines))
+	added := 0
+	for i, line := range lines {
+		m := directives[i]
+		if m == nil {
+			out.Write(line)
+			shift[i].line = added
+			continue
+		}
+		// The directive gives the position of the first column of the next
+		// line, where the node starts once its indentation is dropped.
+		fmt.Fprintf(&out, "//line %s:%d:%d\n", filename, m.Source.Line, m.Source.Column)
+		added++
+		trimmed := bytes.TrimLeft(line, " \t")
+		out.Write(trimmed)
+		shift[i].line = added
+		shift[i].column = len(line) - len(trimmed)
+	}
+
+	for i := range mappings {
+		s := shift[mappings[i].Generated.Line-1]
+		mappings[i].Generated.Line += s.line
+		mappings[i].Generated.Column -= s.column
+	}
+	return out.Bytes()
+}
+
+// sourceLine returns the yuk line of a line of the generated position space.
+func (g *generator) sourceLine(line int) int {
+	for _, dropped := range g.dropped {
+		if dropped <= line {
+			line++
+		}
+	}
+	return line
+}
+
+func declNodes(decls []goast.Decl) []goast.Node {
+	nodes := make([]goast.Node, len(decls))
+	for i, decl := range decls {
+		nodes[i] = decl
+	}
+	return nodes
+}
+
+// lineNodes lists the nodes under roots in walk order, leaving out the ones
+// go/printer may add. gofmt sorts the imports, which are listed by path so
+// that the imports added by the compiler pair with their printed copies.
+func lineNodes(roots ...goast.Node) []goast.Node {
+	var nodes []goast.Node
+	var walk func(node goast.Node) bool
+	walk = func(node goast.Node) bool {
+		switch n := node.(type) {
+		case nil, *goast.ParenExpr, *goast.FieldList, *goast.CommentGroup, *goast.Comment:
+			// Comments are only on the nodes of the generated tree.
+		case *goast.GenDecl:
+			nodes = append(nodes, n)
+			if n.Tok != gotoken.IMPORT {
+				return true
+			}
+			specs := append([]goast.Spec{}, n.Specs...)
+			sort.SliceStable(specs, func(i, j int) bool {
+				return specs[i].(*goast.ImportSpec).Path.Value < specs[j].(*goast.ImportSpec).Path.Value
+			})
+			for _, spec := range specs {
+				goast.Inspect(spec, walk)
+			}
+			return false
+		default:
+			nodes = append(nodes, node)
+		}
+		return true
+	}
+	for _, root := range roots {
+		goast.Inspect(root, walk)
+	}
+	return nodes
+}
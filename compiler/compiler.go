@@ -1,15 +1,33 @@
 package compiler
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/checker"
+	"github.com/ahmadrosid/yuk/codegen"
+	"github.com/ahmadrosid/yuk/macro"
 	"github.com/ahmadrosid/yuk/parser"
+	"github.com/ahmadrosid/yuk/resolver"
+	"github.com/ahmadrosid/yuk/sourcemap"
+	"github.com/ahmadrosid/yuk/token"
+	"github.com/ahmadrosid/yuk/types"
 )
 
 type Compiler struct {
 	Parser  *parser.Parser
 	Program *ast.Program
+	// Names binds the names of the program to their declarations.
+	Names *resolver.Info
+	// Info holds the types inferred for the program by Generate.
+	Info *types.Info
+	// Filename is the path of the yuk source, named by the //line
+	// directives of the output when LineDirectives is set, and by Map.
+	Filename       string
+	LineDirectives bool
+	// SourceMap makes Generate set Map, the map from the output to the
+	// yuk source.
+	SourceMap bool
+	Map       *sourcemap.Map
 }
 
 func New(p *parser.Parser) *Compiler {
@@ -26,10 +44,90 @@ func (c *Compiler) Generate() (string, []error) {
 		return "", errors
 	}
 
-	var out bytes.Buffer
-	for _, stmt := range c.Program.Statements {
-		out.WriteString(stmt.String())
-		out.WriteByte('\n')
+	expander := macro.New()
+	if errors := expander.Expand(c.Program); len(errors) > 0 {
+		return "", errors
+	}
+	for _, path := range expander.Imports() {
+		addImport(c.Program, path)
+	}
+
+	names, errors := resolver.Resolve(c.Program)
+	c.Names = names
+	errors = append(errors, checker.Check(c.Program)...)
+	info, typeErrors := types.Check(c.Program)
+	c.Info = info
+	errors = append(errors, typeErrors...)
+	errors = append(errors, checkExtensions(c.Program, info)...)
+	if len(errors) > 0 {
+		for i, err := range errors {
+			errors[i] = expander.Annotate(err)
+		}
+		return "", errors
 	}
-	return out.String(), nil
+
+	cfg := &codegen.Config{LineDirectives: c.LineDirectives, SourceMap: c.SourceMap, Filename: c.Filename, Info: info}
+	src, m, errors := cfg.Generate(c.Program)
+	c.Map = m
+	return src, errors
+}
+
+// checkExtensions validates the slice extension method calls of program,
+// the ones info tells from the calls of methods named like them, and
+// imports the runtime helper package when one of them needs it.
+func checkExtensions(program *ast.Program, info *types.Info) []error {
+	var errors []error
+	needsHelper := false
+	ast.Inspect(program, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		ext, ok := info.Extensions[call]
+		if !ok {
+			return true
+		}
+		if len(call.Arguments) != ext.Arity {
+			name := call.Function.(*ast.SelectorExpression).Name.Token
+			errors = append(errors, token.Errorf(name, "%s() takes %d argument(s), got %d",
+				ext.Name, ext.Arity, len(call.Arguments)))
+		}
+		if ext.Helper != "" {
+			needsHelper = true
+		}
+		return true
+	})
+
+	if needsHelper {
+		addImport(program, ast.ExtensionPackage)
+	}
+	return errors
+}
+
+// addImport adds an import right after the package clause, unless the
+// program already imports the path.
+func addImport(program *ast.Program, path string) {
+	position := 0
+	for i, stmt := range program.Statements {
+		if imp, ok := stmt.(*ast.ImportStatement); ok {
+			if lit, ok := imp.PackageName.(*ast.StringLiteral); ok && lit.Value == path {
+				return
+			}
+		}
+		if stmt.TokenLiteral() == "package" {
+			position = i + 1
+		}
+	}
+
+	imp := &ast.ImportStatement{
+		Token: token.Token{Type: token.IMPORT, Literal: "import"},
+		PackageName: &ast.StringLiteral{
+			Token: token.Token{Type: token.STRING_LIT, Literal: path},
+			Value: path,
+		},
+	}
+
+	statements := append([]ast.Statement{}, program.Statements[:position]...)
+	statements = append(statements, imp)
+	program.Statements = append(statements, program.Statements[position:]...)
 }
@@ -30,17 +30,31 @@ func TestCompiler_Generate(t *testing.T) {
 		{"import \"encoding/json\"", "import \"encoding/json\""},
 		{"func main() {}", "func main() {}"},
 		{"type TokenType string", "type TokenType string"},
-		{"type Token struct {\na Some\nb string\n}", "struct Token(a Some, b string)"},
+		{"type Some int\ntype Token struct {\n\ta Some\n\tb string\n}", "type Some int\nstruct Token(a Some, b string)"},
 		{"var some = 1", "var some = 1"},
 		{"var some = \"Ahmad Rosid\"", "var some = \"Ahmad Rosid\""},
-		{"func ReturnFunc() string {\nreturn \"hello\"\n}", "func ReturnFunc() string {return \"hello\"}"},
-		{"switch '=' {case '=': {\nvar you = \"me\"\n}\n}", "switch '=' {\n'=' => {\nvar you = \"me\"\n}\n}"},
-		{"var data = map[string]interface{}", "var data = map(string, interface)"},
-		{"var data = map[string]interface{}{\n\"a\":1,\n}", "var data = map(string, interface){\"a\": 1}"},
-		{"var data = map[string]interface{}{\n\"c\":3,\n\"d\":2,\n}", "var data = map(string, interface){\"c\": 3, \"d\": 2}"},
-		{"type Post struct {\nTitle string `json:\"input\"`\nCreatedBy User `json:\"created_by\"`\nCreatedAt Date `json:\"created_at\"`\nUpdatedAt Date\n}", "struct Post (\n\tTitle        string      `json:\"input\"`\n\tCreatedBy    User        `json:\"created_by\"`\n\tCreatedAt    Date  `json:\"created_at\"`\n\tUpdatedAt    Date\n)\n"},
-		{"var user = struct {\nName string\nAge int\n}", "var user = struct(Name string, Age int)"},
-		{"one := 1", "one := 1"},
+		{"var count int64 = 1", "var count int64 = 1"},
+		{"var names []string", "var names []string"},
+		{"func ReturnFunc() string { return \"hello\" }", "func ReturnFunc() string {return \"hello\"}"},
+		{"func main() {\n\tswitch '=' {\n\tcase '=':\n\t\tvar you = \"me\"\n\t\tprintln(you)\n\t}\n}", "func main() {\nswitch '=' {\n'=' => {\nvar you = \"me\"\nprintln(you)\n}\n}\n}"},
+		{"var data = map[string]interface{}{}", "var data = map(string, interface)"},
+		{"var data = map[string]interface{}{\"a\": 1}", "var data = map(string, interface){\"a\": 1}"},
+		{"var data = map[string]interface{}{\"c\": 3, \"d\": 2}", "var data = map(string, interface){\"c\": 3, \"d\": 2}"},
+		{"type User string\ntype Date string\ntype Post struct {\n\tTitle     string `json:\"input\"`\n\tCreatedBy User   `json:\"created_by\"`\n\tCreatedAt Date   `json:\"created_at\"`\n\tUpdatedAt Date\n}", "type User string\ntype Date string\nstruct Post (\n\tTitle        string      `json:\"input\"`\n\tCreatedBy    User        `json:\"created_by\"`\n\tCreatedAt    Date  `json:\"created_at\"`\n\tUpdatedAt    Date\n)\n"},
+		{"var user struct {\n\tName string\n\tAge  int\n}", "var user = struct(Name string, Age int)"},
+		{"func main() {\n\tone := 1\n\tprintln(one)\n}", "func main() {\none := 1\nprintln(one)\n}"},
+		{"var name = \"yuk\"", "let name = \"yuk\""},
+		{"type User struct {\n\tName string\n\tAge  int\n}", "struct User(mut Name string, Age int)"},
+		{"type User struct {\n\tName string\n}\n\nfunc (u *User) Rename(name string) { u.Name = name }", "struct User(mut Name string)\nfunc (u *User) Rename(name string) { u.Name = name }"},
+		{"type User struct {\n\tName string\n}\n\nvar u = User{Name: \"yuk\"}", "struct User(Name string)\nvar u = User{Name: \"yuk\"}"},
+		{"package main\n\nimport \"fmt\"\n\nvar name = \"yuk\"\nvar s = fmt.Sprintf(\"%v!\", name)", "package main\nvar name = \"yuk\"\nvar s = format!(\"{}!\", name)"},
+		{"var y = 3 * 3", "macro square!(x) { x * x }\nvar y = square!(3)"},
+		{"var xs = []int{1, 2}\nvar n = len(xs)", "var xs = [1, 2]\nvar n = xs.len()"},
+		{"var empty = len([]int{1, 2}) == 0", "var empty = [1, 2].is_empty()"},
+		{"import \"github.com/ahmadrosid/yuk/std\"\n\nvar ok = std.Contains([]string{\"a\"}, \"a\")", "var ok = [\"a\"].contains(\"a\")"},
+		{"package main\n\nimport \"github.com/ahmadrosid/yuk/std\"\n\nvar xs = []int{1, 2}\nvar first = std.First(xs)", "package main\nvar xs = [1, 2]\nvar first = xs.first()"},
+		{"type Stack struct {\n\ttop int\n}\n\nfunc (s Stack) len() int            { return s.top }\nfunc (s Stack) contains(x int) bool { return x < s.top }\n\nvar s = Stack{top: 2}\nvar n = s.len()\nvar ok = s.contains(1)", "struct Stack(top int)\nfunc (s Stack) len() int { return s.top }\nfunc (s Stack) contains(x int) bool { return x < s.top }\nvar s = Stack{top: 2}\nvar n = s.len()\nvar ok = s.contains(1)"},
+		{"import \"github.com/ahmadrosid/yuk/std\"\n\nvar xs = []int{1, 2}\nvar doubled = std.Map(xs, func(x int) int { return x * 2 })", "import \"github.com/ahmadrosid/yuk/std\"\nvar xs = [1, 2]\nvar doubled = xs.map(func(x int) int { return x * 2 })"},
 	}
 	for _, tt := range tests {
 		res := compile(t, tt.input)
@@ -50,3 +64,65 @@ func TestCompiler_Generate(t *testing.T) {
 		}
 	}
 }
+
+func TestCompiler_ExtensionArity(t *testing.T) {
+	lex := lexer.New("var xs = [1]\nvar n = xs.filter()")
+	com := New(parser.New(lex))
+	_, errs := com.Generate()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got=%d", len(errs))
+	}
+
+	expected := "2:12: filter() takes 1 argument(s), got 0"
+	if errs[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errs[0].Error())
+	}
+}
+
+func TestCompiler_Immutability(t *testing.T) {
+	lex := lexer.New("let name = \"yuk\"\nname = \"go\"")
+	com := New(parser.New(lex))
+	_, errs := com.Generate()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got=%d", len(errs))
+	}
+
+	expected := "2:1: cannot assign to name, it is declared with let at 1:5"
+	if errs[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errs[0].Error())
+	}
+}
+
+func TestCompiler_TypeCheck(t *testing.T) {
+	lex := lexer.New("var x int = \"hello\"")
+	com := New(parser.New(lex))
+	_, errs := com.Generate()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got=%d", len(errs))
+	}
+
+	expected := `1:13: cannot use "hello" (untyped string constant) as int value in variable declaration`
+	if errs[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errs[0].Error())
+	}
+}
+
+func TestCompiler_MacroTrace(t *testing.T) {
+	input := `macro reset!() {
+	count = 0
+}
+let count = 1
+func main() {
+	reset!()
+}`
+	com := New(parser.New(lexer.New(input)))
+	_, errs := com.Generate()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got=%d", len(errs))
+	}
+
+	expected := "6:2: cannot assign to count, it is declared with let at 4:5 (in expansion of reset!)"
+	if errs[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errs[0].Error())
+	}
+}
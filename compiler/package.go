@@ -0,0 +1,288 @@
+package compiler
+
+import (
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/checker"
+	"github.com/ahmadrosid/yuk/codegen"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/macro"
+	"github.com/ahmadrosid/yuk/parser"
+	"github.com/ahmadrosid/yuk/resolver"
+	"github.com/ahmadrosid/yuk/sourcemap"
+	"github.com/ahmadrosid/yuk/token"
+	"github.com/ahmadrosid/yuk/types"
+)
+
+// File is a yuk file of a package.
+type File struct {
+	// Path is the file to read. Filename is the name of the source in the
+	// line directives and the source map of the output, Path when empty.
+	Path     string
+	Filename string
+	// Source is the content of the file, read from Path when nil, like
+	// the unsaved buffer of an editor.
+	Source  []byte
+	Program *ast.Program
+	// Go is the generated code, and Map its source map when the package
+	// has SourceMap set.
+	Go  string
+	Map *sourcemap.Map
+}
+
+// Package compiles the files of one Go package together: the top level
+// declarations of every yuk file are visible to the others, and the ones
+// of the hand-written Go files of the package are visible to the yuk files
+// and the other way around. The errors name the file they are in.
+type Package struct {
+	// Dir is the directory of the package, named by the errors about the
+	// package as a whole.
+	Dir   string
+	Files []*File
+	// GoFiles are the paths of the hand-written Go files of the package.
+	GoFiles []string
+	// Names binds the names of the files to their declarations, and Info
+	// holds the types inferred for them by Generate.
+	Names *resolver.Info
+	Info  *types.Info
+
+	// Importer loads the packages imported by the files, it is
+	// types.GoImporter() when nil. ImportMap replaces import paths in the
+	// generated code.
+	Importer  types.Importer
+	ImportMap map[string]string
+
+	LineDirectives bool
+	SourceMap      bool
+	parsed         bool
+}
+
+// Import is an import of a file of the package.
+type Import struct {
+	Path string
+	File string
+	Pos  token.Token
+}
+
+// Name returns the name in the package clause of the first file, or an
+// empty string before Generate or when it has none.
+func (pkg *Package) Name() string {
+	for _, f := range pkg.Files {
+		if f.Program != nil {
+			if ident := f.Program.Package(); ident != nil {
+				return ident.Value
+			}
+		}
+	}
+	return ""
+}
+
+// Imports returns the imports of the files, in order. The files must be
+// parsed.
+func (pkg *Package) Imports() []Import {
+	var imports []Import
+	for _, f := range pkg.Files {
+		for _, stmt := range f.Program.Statements {
+			imp, ok := stmt.(*ast.ImportStatement)
+			if !ok {
+				continue
+			}
+			if lit, ok := imp.PackageName.(*ast.StringLiteral); ok {
+				imports = append(imports, Import{Path: lit.Value, File: f.Path, Pos: lit.Token})
+			}
+		}
+	}
+	return imports
+}
+
+// Generate parses the files unless Parse did, checks them and generates
+// the Go code of every yuk file. Nothing is generated unless every file
+// checks.
+func (pkg *Package) Generate() []error {
+	var errors []error
+	if !pkg.parsed {
+		errors = pkg.Parse()
+	}
+	fset := gotoken.NewFileSet()
+	var goFiles []*goast.File
+	for _, path := range pkg.GoFiles {
+		f, err := goparser.ParseFile(fset, path, nil, goparser.ParseComments)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		goFiles = append(goFiles, f)
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+	if errors := pkg.checkPackageClauses(goFiles); len(errors) > 0 {
+		return errors
+	}
+
+	programs := make([]*ast.Program, len(pkg.Files))
+	expanders := map[string]*macro.Expander{}
+	for i, f := range pkg.Files {
+		program := f.Program
+		programs[i] = program
+		expander := macro.New()
+		expanders[program.File] = expander
+		if errs := expander.Expand(program); len(errs) > 0 {
+			errors = append(errors, inFile(program.File, errs)...)
+			continue
+		}
+		for _, path := range expander.Imports() {
+			addImport(program, path)
+		}
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+
+	conf := &resolver.Config{Fset: fset, GoFiles: goFiles}
+	names, nameErrors := conf.Resolve(programs...)
+	pkg.Names = names
+	errors = append(errors, nameErrors...)
+	errors = append(errors, checker.Check(programs...)...)
+	info, typeErrors := pkg.typeConfig(fset, goFiles).Check(programs...)
+	pkg.Info = info
+	errors = append(errors, typeErrors...)
+	for _, program := range programs {
+		errors = append(errors, inFile(program.File, checkExtensions(program, info))...)
+	}
+	if len(errors) > 0 {
+		for i, err := range errors {
+			if terr, ok := err.(*token.Error); ok && expanders[terr.File] != nil {
+				errors[i] = expanders[terr.File].Annotate(err)
+			}
+		}
+		return errors
+	}
+
+	for _, f := range pkg.Files {
+		filename := f.Filename
+		if filename == "" {
+			filename = f.Path
+		}
+		cfg := &codegen.Config{
+			LineDirectives: pkg.LineDirectives,
+			SourceMap:      pkg.SourceMap,
+			Filename:       filename,
+			ImportMap:      pkg.ImportMap,
+			Info:           info,
+		}
+		src, m, errs := cfg.Generate(f.Program)
+		f.Go, f.Map = src, m
+		errors = append(errors, errs...)
+	}
+	return errors
+}
+
+// Parse reads and parses the yuk files concurrently.
+func (pkg *Package) Parse() []error {
+	pkg.parsed = true
+	errs := make([][]error, len(pkg.Files))
+	var wg sync.WaitGroup
+	for i, f := range pkg.Files {
+		wg.Add(1)
+		go func(i int, f *File) {
+			defer wg.Done()
+			source := f.Source
+			if source == nil {
+				var err error
+				source, err = ioutil.ReadFile(f.Path)
+				if err != nil {
+					errs[i] = []error{err}
+					return
+				}
+			}
+			p := parser.New(lexer.New(string(source)))
+			f.Program = p.ParseProgram()
+			f.Program.File = f.Path
+			for _, msg := range p.Errors() {
+				errs[i] = append(errs[i], parser.FileError(f.Path, msg))
+			}
+		}(i, f)
+	}
+	wg.Wait()
+
+	var errors []error
+	for _, e := range errs {
+		errors = append(errors, e...)
+	}
+	return errors
+}
+
+// checkPackageClauses reports the files without a package clause, and the
+// first file whose package clause differs from the one of the first file.
+func (pkg *Package) checkPackageClauses(goFiles []*goast.File) []error {
+	var errors []error
+	var name, first string
+	check := func(path, clause string) {
+		switch {
+		case name == "":
+			name, first = clause, path
+		case clause != name && len(errors) == 0:
+			msg := fmt.Sprintf("found packages %s (%s) and %s (%s)", name, filepath.Base(first), clause, filepath.Base(path))
+			dir := pkg.Dir
+			if dir == "" {
+				dir = filepath.Dir(first)
+			}
+			errors = append(errors, &token.Error{File: dir, Msg: msg})
+		}
+	}
+	for _, f := range pkg.Files {
+		ident := f.Program.Package()
+		if ident == nil {
+			errors = append(errors, &token.Error{File: f.Path, Msg: "missing package clause"})
+			continue
+		}
+		check(f.Path, ident.Value)
+	}
+	for i, f := range goFiles {
+		check(pkg.GoFiles[i], f.Name.Name)
+	}
+	return errors
+}
+
+// typeConfig returns the configuration of the type check of the files.
+// With hand-written Go files, the Go code of the yuk files is generated
+// ahead of the check so the Go files can be checked against it.
+func (pkg *Package) typeConfig(fset *gotoken.FileSet, goFiles []*goast.File) *types.Config {
+	conf := &types.Config{Importer: pkg.Importer, Fset: fset, GoFiles: goFiles}
+	if conf.Importer == nil {
+		conf.Importer = types.GoImporter()
+	}
+	if len(goFiles) == 0 {
+		return conf
+	}
+	for _, f := range pkg.Files {
+		src, errs := codegen.Generate(f.Program)
+		if len(errs) > 0 {
+			continue
+		}
+		stub, err := goparser.ParseFile(fset, strings.TrimSuffix(f.Path, ".yuk")+".go", src, 0)
+		if err == nil {
+			conf.GoStubs = append(conf.GoStubs, stub)
+		}
+	}
+	return conf
+}
+
+// inFile sets the file of the errors positioned in the source.
+func inFile(file string, errs []error) []error {
+	for _, err := range errs {
+		if terr, ok := err.(*token.Error); ok && terr.File == "" {
+			terr.File = file
+		}
+	}
+	return errs
+}
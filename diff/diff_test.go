@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	numbers := func(from, to int, change map[int]string) string {
+		var out strings.Builder
+		for i := from; i <= to; i++ {
+			if s, ok := change[i]; ok {
+				out.WriteString(s)
+				continue
+			}
+			out.WriteString(strings.Repeat("x", i) + "\n")
+		}
+		return out.String()
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+		expected string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{
+			"change",
+			"a\nb\nc\n",
+			"a\nB\nc\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"insert and delete",
+			"a\nb\n",
+			"z\na\n",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n+z\n a\n-b\n",
+		},
+		{
+			"from empty",
+			"",
+			"a\n",
+			"--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			"no newline at end",
+			"a\nb",
+			"a\nb\n",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			"two hunks",
+			numbers(1, 12, nil),
+			numbers(1, 12, map[int]string{2: "two\n", 11: ""}),
+			"--- old\n+++ new\n@@ -1,5 +1,5 @@\n x\n-xx\n+two\n xxx\n xxxx\n xxxxx\n" +
+				"@@ -8,5 +8,4 @@\n xxxxxxxx\n xxxxxxxxx\n xxxxxxxxxx\n-xxxxxxxxxxx\n xxxxxxxxxxxx\n",
+		},
+		{
+			"one hunk",
+			numbers(1, 9, nil),
+			numbers(1, 9, map[int]string{2: "two\n", 8: "eight\n"}),
+			"--- old\n+++ new\n@@ -1,9 +1,9 @@\n x\n-xx\n+two\n xxx\n xxxx\n xxxxx\n xxxxxx\n xxxxxxx\n-xxxxxxxx\n+eight\n xxxxxxxxx\n",
+		},
+	}
+	for _, tt := range tests {
+		got := string(Unified("old", "new", []byte(tt.old), []byte(tt.new)))
+		if got != tt.expected {
+			t.Errorf("%s: wrong diff.\nexpected=\n%s\ngot=\n%s", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// universe returns the scope of the builtins, enclosing the package
+// scope.
+func universe() *Environment {
+	env := NewEnvironment(nil)
+	env.Define("nil", Nil{})
+	for _, b := range []*Builtin{
+		{Name: "len", Fn: builtinLen},
+		{Name: "append", Fn: builtinAppend},
+		{Name: "delete", Fn: builtinDelete},
+		{Name: "panic", Fn: builtinPanic},
+		{Name: "print", Fn: builtinPrint(false)},
+		{Name: "println", Fn: builtinPrint(true)},
+	} {
+		env.Define(b.Name, b)
+	}
+	return env
+}
+
+func builtinLen(in *Interpreter, call token.Token, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, in.tokenError(call, "wrong number of arguments in call to len, have %d, want 1", len(args))
+	}
+	if n, ok := length(args[0]); ok {
+		return intOf(n), nil
+	}
+	return nil, in.tokenError(call, "invalid argument: %s (value of type %s) for built-in len", args[0], args[0].Type())
+}
+
+// length returns the length of a slice, map or string.
+func length(v Value) (int, bool) {
+	switch v := v.(type) {
+	case *Slice:
+		return len(v.Elements), true
+	case *Map:
+		return len(v.Pairs), true
+	case String:
+		return len(v), true
+	}
+	return 0, false
+}
+
+func builtinAppend(in *Interpreter, call token.Token, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, in.tokenError(call, "not enough arguments for append()")
+	}
+	s, ok := args[0].(*Slice)
+	if !ok {
+		return nil, in.tokenError(call, "invalid argument: %s (value of type %s) is not a slice", args[0], args[0].Type())
+	}
+	out := &Slice{Elem: s.Elem, Elements: make([]Value, 0, len(s.Elements)+len(args)-1)}
+	out.Elements = append(out.Elements, s.Elements...)
+	for _, v := range args[1:] {
+		out.Elements = append(out.Elements, in.convert(own(v), s.Elem))
+	}
+	return out, nil
+}
+
+func builtinDelete(in *Interpreter, call token.Token, args []Value) (Value, error) {
+	if len(args) != 2 {
+		return nil, in.tokenError(call, "wrong number of arguments in call to delete, have %d, want 2", len(args))
+	}
+	m, ok := args[0].(*Map)
+	if !ok {
+		return nil, in.tokenError(call, "invalid argument: %s (value of type %s) is not a map", args[0], args[0].Type())
+	}
+	delete(m.Pairs, in.convert(args[1], m.Key))
+	return nil, nil
+}
+
+// builtinPanic stops the program with an error reporting the value.
+func builtinPanic(in *Interpreter, call token.Token, args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, in.tokenError(call, "wrong number of arguments in call to panic, have %d, want 1", len(args))
+	}
+	return nil, in.tokenError(call, "panic: %s", args[0])
+}
+
+// builtinPrint returns print, or println when newline is set. Like in Go,
+// they write to Stderr.
+func builtinPrint(newline bool) func(*Interpreter, token.Token, []Value) (Value, error) {
+	return func(in *Interpreter, call token.Token, args []Value) (Value, error) {
+		parts := make([]string, len(args))
+		for i, arg := range args {
+			parts[i] = arg.String()
+		}
+		if newline {
+			fmt.Fprintln(in.Stderr, strings.Join(parts, " "))
+		} else {
+			fmt.Fprint(in.Stderr, strings.Join(parts, ""))
+		}
+		return nil, nil
+	}
+}
+
+// extension evaluates the slice extension method ext called on recv.
+func (in *Interpreter) extension(e *ast.CallExpression, ext ast.Extension, recv Value, env *Environment) (Value, error) {
+	sel := e.Function.(*ast.SelectorExpression)
+	if len(e.Arguments) != ext.Arity {
+		return nil, in.errorf(e, "%s takes %d argument(s), got %d", sel, ext.Arity, len(e.Arguments))
+	}
+	args := make([]Value, len(e.Arguments))
+	for i, arg := range e.Arguments {
+		var err error
+		if args[i], err = in.value(arg, env); err != nil {
+			return nil, err
+		}
+	}
+
+	switch ext.Name {
+	case "len":
+		n, _ := length(recv)
+		return intOf(n), nil
+	case "is_empty":
+		n, _ := length(recv)
+		return Bool(n == 0), nil
+	}
+	s, ok := recv.(*Slice)
+	if !ok {
+		return nil, in.errorf(sel.Name, "%s undefined (type %s is not a slice)", sel, recv.Type())
+	}
+	switch ext.Name {
+	case "first", "last":
+		if len(s.Elements) == 0 {
+			return &Option{Elem: s.Elem, Value: in.zero(s.Elem)}, nil
+		}
+		v := s.Elements[0]
+		if ext.Name == "last" {
+			v = s.Elements[len(s.Elements)-1]
+		}
+		return &Option{Elem: s.Elem, Value: v, Ok: true}, nil
+	case "contains", "index_of":
+		for i, el := range s.Elements {
+			if eq, _ := equal(el, args[0]); eq {
+				if ext.Name == "contains" {
+					return Bool(true), nil
+				}
+				return intOf(i), nil
+			}
+		}
+		if ext.Name == "contains" {
+			return Bool(false), nil
+		}
+		return intOf(-1), nil
+	case "map":
+		out := &Slice{Elem: resultType(args[0])}
+		for _, el := range s.Elements {
+			v, err := in.callback(e, 0, args[0], el)
+			if err != nil {
+				return nil, err
+			}
+			out.Elements = append(out.Elements, v)
+		}
+		return out, nil
+	case "filter":
+		out := &Slice{Elem: s.Elem}
+		for _, el := range s.Elements {
+			v, err := in.callback(e, 0, args[0], el)
+			if err != nil {
+				return nil, err
+			}
+			keep, ok := v.(Bool)
+			if !ok {
+				return nil, in.errorf(e.Arguments[0], "%s returned %s, want bool", e.Arguments[0], v.Type())
+			}
+			if keep {
+				out.Elements = append(out.Elements, el)
+			}
+		}
+		return out, nil
+	case "reduce":
+		acc := args[0]
+		for _, el := range s.Elements {
+			v, err := in.callback(e, 1, args[1], acc, el)
+			if err != nil {
+				return nil, err
+			}
+			acc = v
+		}
+		return acc, nil
+	}
+	return nil, in.errorf(sel.Name, "%s is not supported by the interpreter", sel)
+}
+
+// callback calls fn, the argument i of the extension method call e, with
+// args. It must return a value.
+func (in *Interpreter) callback(e *ast.CallExpression, i int, fn Value, args ...Value) (Value, error) {
+	v, err := in.call(start(e.Arguments[i]), fn, args)
+	if err == nil && v == nil {
+		err = in.errorf(e.Arguments[i], "%s (no value) used as value", e.Arguments[i])
+	}
+	return v, err
+}
+
+// resultType returns the result type of fn, when it is known.
+func resultType(fn Value) string {
+	if f, ok := fn.(*Function); ok && f.Literal.ReturnType != nil {
+		return f.Literal.ReturnType.String()
+	}
+	return "interface{}"
+}
+
+// optionMethod returns the method name of o bound to it.
+func optionMethod(o *Option, name string) *Builtin {
+	switch name {
+	case "IsSome":
+		return function(name, "", func([]Value) Value { return Bool(o.Ok) })
+	case "IsNone":
+		return function(name, "", func([]Value) Value { return Bool(!o.Ok) })
+	case "UnwrapOr":
+		return function(name, ".", func(a []Value) Value {
+			if o.Ok {
+				return o.Value
+			}
+			return a[0]
+		})
+	case "Unwrap":
+		return &Builtin{Name: name, Fn: func(in *Interpreter, call token.Token, args []Value) (Value, error) {
+			if len(args) != 0 {
+				return nil, in.tokenError(call, "too many arguments in call to Unwrap")
+			}
+			if !o.Ok {
+				return nil, in.tokenError(call, "panic: std: unwrap of an empty Option")
+			}
+			return o.Value, nil
+		}}
+	}
+	return nil
+}
+
+// importPackage returns the package imported as path. Its members are nil
+// when the interpreter does not implement it, which is reported when one
+// is used.
+func importPackage(path string) *Package {
+	pkg := &Package{Path: path}
+	switch path {
+	case "fmt":
+		pkg.Members = fmtPackage()
+	case "strings":
+		pkg.Members = stringsPackage()
+	case "strconv":
+		pkg.Members = strconvPackage()
+	case ast.ExtensionPackage:
+		pkg.Members = stdPackage()
+	}
+	return pkg
+}
+
+func fmtPackage() map[string]Value {
+	print := func(w io.Writer, args []interface{}) { fmt.Fprint(w, args...) }
+	println := func(w io.Writer, args []interface{}) { fmt.Fprintln(w, args...) }
+	printf := func(w io.Writer, args []interface{}) { fmt.Fprintf(w, args[0].(string), args[1:]...) }
+	return map[string]Value{
+		"Print":    fmtFunction("Print", print),
+		"Println":  fmtFunction("Println", println),
+		"Printf":   fmtFunction("Printf", printf),
+		"Sprint":   fmtFunction("Sprint", print),
+		"Sprintln": fmtFunction("Sprintln", println),
+		"Sprintf":  fmtFunction("Sprintf", printf),
+	}
+}
+
+// fmtFunction returns the function name of fmt, which formats its
+// arguments with format. The functions whose name starts with S return
+// the text, the others write it to Stdout.
+func fmtFunction(name string, format func(w io.Writer, args []interface{})) *Builtin {
+	return &Builtin{Name: "fmt." + name, Fn: func(in *Interpreter, call token.Token, args []Value) (Value, error) {
+		if strings.HasSuffix(name, "f") && (len(args) == 0 || args[0].Type() != "string") {
+			return nil, in.tokenError(call, "fmt.%s takes a format string", name)
+		}
+		var b strings.Builder
+		format(&b, natives(args))
+		if strings.HasPrefix(name, "S") {
+			return String(b.String()), nil
+		}
+		io.WriteString(in.Stdout, b.String())
+		return nil, nil
+	}}
+}
+
+// natives returns the Go values fmt formats like the values of args.
+func natives(args []Value) []interface{} {
+	out := make([]interface{}, len(args))
+	for i, arg := range args {
+		out[i] = native(arg)
+	}
+	return out
+}
+
+func native(v Value) interface{} {
+	switch v := v.(type) {
+	case Int:
+		return nativeInt(v)
+	case String:
+		return string(v)
+	case Bool:
+		return bool(v)
+	case Nil:
+		return nil
+	case *Slice:
+		return natives(v.Elements)
+	}
+	return v
+}
+
+// nativeInt returns i as a Go integer of its type, for fmt to format it
+// and print its type.
+func nativeInt(i Int) interface{} {
+	switch i.Kind {
+	case "int8":
+		return int8(i.Value)
+	case "int16":
+		return int16(i.Value)
+	case "int32":
+		return int32(i.Value)
+	case "int64":
+		return i.Value
+	case "uint":
+		return uint(i.Value)
+	case "uint8":
+		return uint8(i.Value)
+	case "uint16":
+		return uint16(i.Value)
+	case "uint32":
+		return uint32(i.Value)
+	case "uint64":
+		return uint64(i.Value)
+	case "uintptr":
+		return uintptr(i.Value)
+	}
+	return int(i.Value)
+}
+
+func stringsPackage() map[string]Value {
+	return map[string]Value{
+		"Contains":   function("strings.Contains", "ss", func(a []Value) Value { return Bool(strings.Contains(str(a[0]), str(a[1]))) }),
+		"HasPrefix":  function("strings.HasPrefix", "ss", func(a []Value) Value { return Bool(strings.HasPrefix(str(a[0]), str(a[1]))) }),
+		"HasSuffix":  function("strings.HasSuffix", "ss", func(a []Value) Value { return Bool(strings.HasSuffix(str(a[0]), str(a[1]))) }),
+		"EqualFold":  function("strings.EqualFold", "ss", func(a []Value) Value { return Bool(strings.EqualFold(str(a[0]), str(a[1]))) }),
+		"Index":      function("strings.Index", "ss", func(a []Value) Value { return intOf(strings.Index(str(a[0]), str(a[1]))) }),
+		"Count":      function("strings.Count", "ss", func(a []Value) Value { return intOf(strings.Count(str(a[0]), str(a[1]))) }),
+		"ToUpper":    function("strings.ToUpper", "s", func(a []Value) Value { return String(strings.ToUpper(str(a[0]))) }),
+		"ToLower":    function("strings.ToLower", "s", func(a []Value) Value { return String(strings.ToLower(str(a[0]))) }),
+		"TrimSpace":  function("strings.TrimSpace", "s", func(a []Value) Value { return String(strings.TrimSpace(str(a[0]))) }),
+		"Trim":       function("strings.Trim", "ss", func(a []Value) Value { return String(strings.Trim(str(a[0]), str(a[1]))) }),
+		"TrimPrefix": function("strings.TrimPrefix", "ss", func(a []Value) Value { return String(strings.TrimPrefix(str(a[0]), str(a[1]))) }),
+		"TrimSuffix": function("strings.TrimSuffix", "ss", func(a []Value) Value { return String(strings.TrimSuffix(str(a[0]), str(a[1]))) }),
+		"Repeat":     function("strings.Repeat", "si", func(a []Value) Value { return String(strings.Repeat(str(a[0]), int(a[1].(Int).Value))) }),
+		"Replace": function("strings.Replace", "sssi", func(a []Value) Value {
+			return String(strings.Replace(str(a[0]), str(a[1]), str(a[2]), int(a[3].(Int).Value)))
+		}),
+		"ReplaceAll": function("strings.ReplaceAll", "sss", func(a []Value) Value {
+			return String(strings.Replace(str(a[0]), str(a[1]), str(a[2]), -1))
+		}),
+		"Split":  function("strings.Split", "ss", func(a []Value) Value { return strs(strings.Split(str(a[0]), str(a[1]))) }),
+		"Fields": function("strings.Fields", "s", func(a []Value) Value { return strs(strings.Fields(str(a[0]))) }),
+		"Join": function("strings.Join", "Ss", func(a []Value) Value {
+			var elems []string
+			for _, el := range a[0].(*Slice).Elements {
+				elems = append(elems, str(el))
+			}
+			return String(strings.Join(elems, str(a[1])))
+		}),
+	}
+}
+
+func strconvPackage() map[string]Value {
+	return map[string]Value{
+		"Itoa":  function("strconv.Itoa", "i", func(a []Value) Value { return String(strconv.Itoa(int(a[0].(Int).Value))) }),
+		"Quote": function("strconv.Quote", "s", func(a []Value) Value { return String(strconv.Quote(str(a[0]))) }),
+	}
+}
+
+// stdPackage returns the runtime helpers of the generated code that are
+// not lowered by the interpreter itself.
+func stdPackage() map[string]Value {
+	return map[string]Value{
+		"Dbg": &Builtin{Name: "std.Dbg", Fn: func(in *Interpreter, call token.Token, args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, in.tokenError(call, "wrong number of arguments in call to std.Dbg, have %d, want 2", len(args))
+			}
+			fmt.Fprintf(in.Stderr, "%s = %#v\n", args[0], native(args[1]))
+			return args[1], nil
+		}},
+		"Some": function("std.Some", ".", func(a []Value) Value { return &Option{Elem: a[0].Type(), Value: a[0], Ok: true} }),
+	}
+}
+
+// function returns the builtin name, which checks its arguments have the
+// types spelled by params before calling fn with them: s for a string, i
+// for an int, S for a []string and . for any value.
+func function(name, params string, fn func(args []Value) Value) *Builtin {
+	return &Builtin{Name: name, Fn: func(in *Interpreter, call token.Token, args []Value) (Value, error) {
+		if len(args) != len(params) {
+			return nil, in.tokenError(call, "wrong number of arguments in call to %s, have %d, want %d", name, len(args), len(params))
+		}
+		for i, arg := range args {
+			want := ""
+			switch params[i] {
+			case 's':
+				want = "string"
+			case 'i':
+				want = "int"
+			case 'S':
+				want = "[]string"
+			}
+			if want != "" && arg.Type() != want {
+				return nil, in.tokenError(call, "cannot use %s (value of type %s) as %s value in argument to %s", arg, arg.Type(), want, name)
+			}
+		}
+		return fn(args), nil
+	}}
+}
+
+func str(v Value) string {
+	return string(v.(String))
+}
+
+func strs(list []string) *Slice {
+	s := &Slice{Elem: "string"}
+	for _, el := range list {
+		s.Elements = append(s.Elements, String(el))
+	}
+	return s
+}
@@ -0,0 +1,1030 @@
+// Package eval interprets yuk programs by walking their syntax tree, so
+// scripts run without generating Go or invoking the Go toolchain.
+//
+// The interpreter covers the language: variables, functions and closures,
+// structs and their methods, slices, maps, if and switch. Yuk has no loop
+// statement, so iteration is recursion or the slice extension methods like
+// `xs.map(f)` and `xs.reduce(0, f)`, which are evaluated directly. Of the
+// standard library, the builtins and a few packages are available: fmt,
+// strings and strconv for formatting and string operations.
+//
+// Before running a program, Run checks its names, its let bindings and
+// its unsafe regions like the compiler does, but leaves unused variables
+// alone. Its types are checked as it runs.
+package eval
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/checker"
+	"github.com/ahmadrosid/yuk/macro"
+	"github.com/ahmadrosid/yuk/resolver"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// maxDepth is the number of nested calls after which a program is
+// stopped, like a Go program overflowing its stack.
+const maxDepth = 10000
+
+// Environment binds the names of a scope to their values.
+type Environment struct {
+	store map[string]Value
+	outer *Environment
+}
+
+func NewEnvironment(outer *Environment) *Environment {
+	return &Environment{store: map[string]Value{}, outer: outer}
+}
+
+// Get returns the value of name in the innermost scope declaring it.
+func (e *Environment) Get(name string) (Value, bool) {
+	for ; e != nil; e = e.outer {
+		if v, ok := e.store[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Define declares name in the scope of e.
+func (e *Environment) Define(name string, v Value) {
+	e.store[name] = v
+}
+
+// set assigns v to name in the innermost scope declaring it, and reports
+// whether there is one.
+func (e *Environment) set(name string, v Value) bool {
+	for ; e != nil; e = e.outer {
+		if _, ok := e.store[name]; ok {
+			e.store[name] = v
+			return true
+		}
+	}
+	return false
+}
+
+// Interpreter runs programs. The declarations of the programs it runs are
+// kept, so the ones run later can use them.
+type Interpreter struct {
+	// Stdout receives the output of the fmt package, and Stderr the one of
+	// the print and println builtins and of dbg!.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	globals *Environment
+	// structs maps the struct types to their declaration, and types the
+	// other declared types to their underlying type.
+	structs map[string]*ast.StructStatement
+	types   map[string]string
+	// methods maps a type name to its methods.
+	methods map[string]map[string]*ast.FunctionLiteral
+	// file names the program running, in errors.
+	file  string
+	depth int
+}
+
+func New(stdout, stderr io.Writer) *Interpreter {
+	return &Interpreter{
+		Stdout:  stdout,
+		Stderr:  stderr,
+		globals: NewEnvironment(universe()),
+		structs: map[string]*ast.StructStatement{},
+		types:   map[string]string{},
+		methods: map[string]map[string]*ast.FunctionLiteral{},
+	}
+}
+
+// Run runs program: it checks the names, the let bindings and the unsafe
+// regions of program like the compiler does, declares its functions and
+// types, evaluates its other top level statements in order, then calls its
+// main function when it declares one.
+func (in *Interpreter) Run(program *ast.Program) error {
+	e, err := in.expand(program)
+	if err != nil {
+		return err
+	}
+	_, errs := (&resolver.Config{IgnoreUnused: true}).Resolve(program)
+	errs = append(errs, checker.Check(program)...)
+	for i, err := range errs {
+		errs[i] = e.Annotate(err)
+	}
+	if err := joinErrors(errs); err != nil {
+		return err
+	}
+	if _, err := in.execProgram(program); err != nil {
+		return err
+	}
+	main, ok := in.globals.store["main"].(*Function)
+	if !ok {
+		return nil
+	}
+	_, err = in.call(main.Literal.Token, main, nil)
+	return err
+}
+
+// Exec expands the macros of program, declares its functions and types
+// and evaluates its other top level statements in order. It returns the
+// value of the last statement, which is nil unless it is an expression
+// with a value. Unlike Run, it does not check program, which may use the
+// declarations of the programs executed before.
+func (in *Interpreter) Exec(program *ast.Program) (Value, error) {
+	if _, err := in.expand(program); err != nil {
+		return nil, err
+	}
+	return in.execProgram(program)
+}
+
+// expand expands the macros of program, and imports the packages the
+// expanded code uses.
+func (in *Interpreter) expand(program *ast.Program) (*macro.Expander, error) {
+	in.file = program.File
+	e := macro.New()
+	if err := joinErrors(e.Expand(program)); err != nil {
+		return nil, err
+	}
+	for _, imp := range e.Imports() {
+		addImport(program, imp)
+	}
+	return e, nil
+}
+
+// joinErrors returns the error of errs, which is the only one or lists
+// them all on their own lines, or nil when there are none.
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "\n"))
+}
+
+// addImport adds an import of path to program, unless it already has one.
+func addImport(program *ast.Program, path string) {
+	for _, stmt := range program.Statements {
+		if imp, ok := stmt.(*ast.ImportStatement); ok {
+			if lit, ok := imp.PackageName.(*ast.StringLiteral); ok && lit.Value == path {
+				return
+			}
+		}
+	}
+	imp := &ast.ImportStatement{
+		Token:       token.Token{Type: token.IMPORT, Literal: "import"},
+		PackageName: &ast.StringLiteral{Token: token.Token{Type: token.STRING_LIT, Literal: path}, Value: path},
+	}
+	program.Statements = append([]ast.Statement{imp}, program.Statements...)
+}
+
+// execProgram declares the functions and types of program and evaluates its
+// other top level statements in order, returning the value of the last
+// one.
+func (in *Interpreter) execProgram(program *ast.Program) (Value, error) {
+	// Functions and types can be used before their declaration.
+	var rest []ast.Statement
+	for _, stmt := range program.Statements {
+		if !in.declare(stmt, in.globals) {
+			rest = append(rest, stmt)
+		}
+	}
+
+	var last Value
+	for _, stmt := range rest {
+		v, ctl, err := in.exec(stmt, in.globals)
+		if err != nil {
+			return nil, err
+		}
+		if ctl != next {
+			return nil, in.errorf(stmt, "%s outside of a function", stmt.TokenLiteral())
+		}
+		last = v
+	}
+	return last, nil
+}
+
+// declare declares stmt when it is a named function, a method or a
+// type, and reports whether it is one.
+func (in *Interpreter) declare(stmt ast.Statement, env *Environment) bool {
+	switch s := stmt.(type) {
+	case *ast.StructAttributes:
+		if s.Token != nil {
+			in.types[s.Name.Literal] = s.Type.Literal
+			return true
+		}
+	case *ast.ExpressionStatement:
+		switch e := s.Expression.(type) {
+		case *ast.StructStatement:
+			if e.Name != nil {
+				in.structs[e.Name.Literal] = e
+				return true
+			}
+		case *ast.FunctionLiteral:
+			if e.Receiver != nil && e.Receiver.Type != nil {
+				typ := strings.TrimPrefix(e.Receiver.Type.Value, "*")
+				if in.methods[typ] == nil {
+					in.methods[typ] = map[string]*ast.FunctionLiteral{}
+				}
+				in.methods[typ][e.Name] = e
+				return true
+			}
+			if e.Name != "" {
+				env.Define(e.Name, &Function{Literal: e, Env: env})
+				return true
+			}
+		case *ast.ExpressionLiteral:
+			return e.Token.Type == token.PACKAGE
+		}
+	case *ast.MacroStatement:
+		return true
+	}
+	return false
+}
+
+// control tells how the statement executed last left its block.
+type control int
+
+const (
+	next control = iota
+	returned
+	broke
+)
+
+func (in *Interpreter) block(block *ast.BlockStatement, env *Environment) (Value, control, error) {
+	if block == nil {
+		return nil, next, nil
+	}
+	for _, stmt := range block.Statements {
+		v, ctl, err := in.exec(stmt, env)
+		if err != nil || ctl != next {
+			return v, ctl, err
+		}
+	}
+	return nil, next, nil
+}
+
+// exec executes stmt. A return statement returns the value returned.
+func (in *Interpreter) exec(stmt ast.Statement, env *Environment) (Value, control, error) {
+	switch s := stmt.(type) {
+	case *ast.VarStatement:
+		var v Value
+		if s.Value != nil {
+			var err error
+			if v, err = in.value(s.Value, env); err != nil {
+				return nil, next, err
+			}
+			if s.Type != nil {
+				v = in.convert(v, s.Type.Value)
+			}
+		} else if s.Type != nil {
+			v = in.zero(s.Type.Value)
+		}
+		env.Define(s.Name.Value, own(v))
+		return nil, next, nil
+	case *ast.AssignStatement:
+		return nil, next, in.assign(s, env)
+	case *ast.ReturnStatement:
+		if s.ReturnValue == nil {
+			return nil, returned, nil
+		}
+		v, err := in.value(s.ReturnValue, env)
+		return v, returned, err
+	case *ast.SwitchStatement:
+		return in.switchStmt(s, env)
+	case *ast.BlockStatement:
+		return in.block(s, NewEnvironment(env))
+	case *ast.UnsafeStatement:
+		return in.block(s.Body, NewEnvironment(env))
+	case *ast.ImportStatement:
+		lit, ok := s.PackageName.(*ast.StringLiteral)
+		if !ok {
+			return nil, next, in.errorf(s, "invalid import %s", s.PackageName)
+		}
+		imp := unquote(lit.Value)
+		env.Define(path.Base(imp), importPackage(imp))
+		return nil, next, nil
+	case *ast.ExpressionStatement:
+		switch e := s.Expression.(type) {
+		case *ast.IfExpression:
+			return in.ifStmt(e, env)
+		case *ast.Identifier:
+			switch e.Value {
+			case "break":
+				return nil, broke, nil
+			case "continue":
+				return nil, next, in.errorf(e, "continue is not in a loop")
+			}
+		}
+		if in.declare(s, env) {
+			return nil, next, nil
+		}
+		v, err := in.eval(s.Expression, env)
+		return v, next, err
+	case *ast.MacroStatement, *ast.StructAttributes:
+		in.declare(s, env)
+		return nil, next, nil
+	case nil:
+		return nil, next, nil
+	}
+	return nil, next, in.errorf(stmt, "cannot interpret %s", stmt)
+}
+
+func (in *Interpreter) ifStmt(e *ast.IfExpression, env *Environment) (Value, control, error) {
+	cond, err := in.value(e.Condition, env)
+	if err != nil {
+		return nil, next, err
+	}
+	b, ok := cond.(Bool)
+	if !ok {
+		return nil, next, in.errorf(e.Condition, "non-boolean condition in if statement")
+	}
+	if b {
+		return in.block(e.Consequence, NewEnvironment(env))
+	}
+	if e.Alternative != nil {
+		return in.block(e.Alternative, NewEnvironment(env))
+	}
+	return nil, next, nil
+}
+
+// switchStmt runs the first arm whose value equals the input, or the
+// default arm `_` when none does. A break leaves the arm.
+func (in *Interpreter) switchStmt(s *ast.SwitchStatement, env *Environment) (Value, control, error) {
+	input, err := in.literal(s.Input, env)
+	if err != nil {
+		return nil, next, err
+	}
+	var arm *ast.CaseLiteral
+	for _, c := range s.Case {
+		if c == nil {
+			continue
+		}
+		if c.Token.Type == token.UNDERSCORE {
+			if arm == nil {
+				arm = c
+			}
+			continue
+		}
+		v, err := in.literal(c.Token, env)
+		if err != nil {
+			return nil, next, err
+		}
+		eq, ok := equal(input, v)
+		if !ok {
+			return nil, next, token.Errorf(c.Token, "invalid case %s in switch (mismatched types %s and %s)", c.Token.Literal, v.Type(), input.Type())
+		}
+		if eq {
+			arm = c
+			break
+		}
+	}
+	if arm == nil {
+		return nil, next, nil
+	}
+	v, ctl, err := in.block(arm.Body, NewEnvironment(env))
+	if ctl == broke {
+		ctl = next
+	}
+	return v, ctl, err
+}
+
+// literal evaluates the single token a switch input or case is written
+// as.
+func (in *Interpreter) literal(tok token.Token, env *Environment) (Value, error) {
+	switch tok.Type {
+	case token.CHAR:
+		r, _, _, err := strconv.UnquoteChar(tok.Literal, '\'')
+		if err != nil {
+			return nil, in.tokenError(tok, "invalid character literal '%s'", tok.Literal)
+		}
+		return intOf(int(r)), nil
+	case token.STRING_LIT:
+		return String(unquote(tok.Literal)), nil
+	case token.INT:
+		return in.parseInt(tok)
+	case token.TRUE, token.FALSE:
+		return Bool(tok.Type == token.TRUE), nil
+	case token.IDENT:
+		if v, ok := env.Get(tok.Literal); ok {
+			return v, nil
+		}
+		return nil, in.tokenError(tok, "undefined: %s", tok.Literal)
+	}
+	return nil, in.tokenError(tok, "unexpected %s in switch", tok.Literal)
+}
+
+func (in *Interpreter) assign(s *ast.AssignStatement, env *Environment) error {
+	v, err := in.value(s.Value, env)
+	if err != nil {
+		return err
+	}
+	v = own(v)
+	switch target := s.Target.(type) {
+	case *ast.Identifier:
+		if old, ok := env.Get(target.Value); ok {
+			v = in.convert(v, old.Type())
+		}
+		if !env.set(target.Value, v) {
+			return in.errorf(target, "undefined: %s", target.Value)
+		}
+	case *ast.SelectorExpression:
+		left, err := in.value(target.Left, env)
+		if err != nil {
+			return err
+		}
+		st := deref(left)
+		if st == nil {
+			return in.errorf(target.Name, "%s undefined (type %s has no field %s)", target, left.Type(), target.Name.Value)
+		}
+		old, ok := st.Values[target.Name.Value]
+		if !ok {
+			return in.errorf(target.Name, "%s undefined (type %s has no field %s)", target, st.Name, target.Name.Value)
+		}
+		st.Values[target.Name.Value] = in.convert(v, old.Type())
+	case *ast.IndexExpression:
+		left, err := in.value(target.Left, env)
+		if err != nil {
+			return err
+		}
+		index, err := in.value(target.Index, env)
+		if err != nil {
+			return err
+		}
+		switch left := left.(type) {
+		case *Slice:
+			i, err := in.index(target, index, len(left.Elements))
+			if err != nil {
+				return err
+			}
+			left.Elements[i] = in.convert(v, left.Elem)
+		case *Map:
+			if !comparable(index) {
+				return in.errorf(target.Index, "invalid map key type %s", index.Type())
+			}
+			left.Pairs[in.convert(index, left.Key)] = in.convert(v, left.Elem)
+		default:
+			return in.errorf(target, "cannot assign to %s (value of type %s)", target, left.Type())
+		}
+	}
+	return nil
+}
+
+// deref returns the struct v is or points to, or nil.
+func deref(v Value) *Struct {
+	switch v := v.(type) {
+	case *Struct:
+		return v
+	case *Pointer:
+		return v.Elem
+	}
+	return nil
+}
+
+// value evaluates exp, which must have a value.
+func (in *Interpreter) value(exp ast.Expression, env *Environment) (Value, error) {
+	v, err := in.eval(exp, env)
+	if err == nil && v == nil {
+		return nil, in.errorf(exp, "%s (no value) used as value", exp)
+	}
+	return v, err
+}
+
+// eval evaluates exp. Calls of functions returning no value evaluate to
+// nil.
+func (in *Interpreter) eval(exp ast.Expression, env *Environment) (Value, error) {
+	switch e := exp.(type) {
+	case *ast.IntegerLiteral:
+		return in.parseInt(e.Token)
+	case *ast.StringLiteral:
+		return String(unquote(e.Value)), nil
+	case *ast.Boolean:
+		return Bool(e.Value == "true"), nil
+	case *ast.Identifier:
+		if v, ok := env.Get(e.Value); ok {
+			return v, nil
+		}
+		return nil, in.errorf(e, "undefined: %s", e.Value)
+	case *ast.VarExpression:
+		v, err := in.value(e.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		env.Define(e.Ident.Literal, own(v))
+		return nil, nil
+	case *ast.GroupedExpression:
+		return in.value(e.Expression, env)
+	case *ast.PrefixExpression:
+		return in.prefix(e, env)
+	case *ast.InfixExpression:
+		return in.infix(e, env)
+	case *ast.IfExpression:
+		_, _, err := in.ifStmt(e, env)
+		return nil, err
+	case *ast.FunctionLiteral:
+		fn := &Function{Literal: e, Env: env}
+		if e.Name != "" {
+			env.Define(e.Name, fn)
+		}
+		return fn, nil
+	case *ast.CallExpression:
+		return in.callExpr(e, env)
+	case *ast.SelectorExpression:
+		return in.selector(e, env)
+	case *ast.IndexExpression:
+		return in.indexExpr(e, env)
+	case *ast.ArrayLiteral:
+		s := &Slice{Elem: e.ElementType()}
+		for _, el := range e.Elements {
+			v, err := in.value(el, env)
+			if err != nil {
+				return nil, err
+			}
+			s.Elements = append(s.Elements, in.convert(own(v), s.Elem))
+		}
+		return s, nil
+	case *ast.MapLiteral:
+		m := &Map{Key: e.Key.Value, Elem: e.Value.Value, Pairs: map[Value]Value{}}
+		if e.KeyValue == nil {
+			return m, nil
+		}
+		for _, pair := range e.KeyValue.Pairs {
+			k, err := in.value(pair.Key, env)
+			if err != nil {
+				return nil, err
+			}
+			if !comparable(k) {
+				return nil, in.errorf(pair.Key, "invalid map key type %s", k.Type())
+			}
+			v, err := in.value(pair.Value, env)
+			if err != nil {
+				return nil, err
+			}
+			m.Pairs[in.convert(k, m.Key)] = in.convert(own(v), m.Elem)
+		}
+		return m, nil
+	case *ast.StructLiteral:
+		return in.structLiteral(e, env)
+	case *ast.MacroCall:
+		return nil, in.errorf(e, "undefined macro %s!", e.Name.Value)
+	}
+	return nil, in.errorf(exp, "cannot interpret %s", exp)
+}
+
+func (in *Interpreter) structLiteral(e *ast.StructLiteral, env *Environment) (Value, error) {
+	s, ok := in.zero(e.Type.Value).(*Struct)
+	if !ok {
+		return nil, in.errorf(e.Type, "invalid composite literal type %s", e.Type.Value)
+	}
+	for _, f := range e.Fields {
+		if _, ok := s.Values[f.Name.Value]; !ok {
+			return nil, in.errorf(f.Name, "unknown field %s in struct literal of type %s", f.Name.Value, s.Name)
+		}
+		v, err := in.value(f.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		s.Values[f.Name.Value] = in.convert(own(v), s.Values[f.Name.Value].Type())
+	}
+	return s, nil
+}
+
+func (in *Interpreter) prefix(e *ast.PrefixExpression, env *Environment) (Value, error) {
+	right, err := in.value(e.Right, env)
+	if err != nil {
+		return nil, err
+	}
+	switch e.Operator {
+	case "!":
+		if b, ok := right.(Bool); ok {
+			return !b, nil
+		}
+	case "-":
+		if i, ok := right.(Int); ok {
+			return sized(i.Kind, -i.Value), nil
+		}
+	case "&":
+		// Taking the address of a variable shares the struct it holds.
+		if s, ok := right.(*Struct); ok {
+			return &Pointer{Elem: s}, nil
+		}
+		return nil, in.errorf(e, "cannot take the address of %s (value of type %s)", e.Right, right.Type())
+	case "*":
+		switch p := right.(type) {
+		case *Pointer:
+			return p.Elem, nil
+		case Nil:
+			return nil, in.errorf(e, "invalid memory address or nil pointer dereference")
+		}
+		return nil, in.errorf(e, "invalid operation: cannot indirect %s (value of type %s)", e.Right, right.Type())
+	}
+	return nil, in.errorf(e, "invalid operation: operator %s not defined on %s (value of type %s)", e.Operator, e.Right, right.Type())
+}
+
+func (in *Interpreter) infix(e *ast.InfixExpression, env *Environment) (Value, error) {
+	left, err := in.value(e.Left, env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := in.value(e.Right, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Operator {
+	case "==", "!=":
+		eq, ok := equal(left, right)
+		if !ok {
+			break
+		}
+		return Bool(eq == (e.Operator == "==")), nil
+	case "<", ">":
+		result, ok := compare(left, right, e.Operator)
+		if _, isBool := left.(Bool); !ok || isBool {
+			break
+		}
+		return Bool(result), nil
+	case "+":
+		if l, ok := left.(String); ok {
+			if r, ok := right.(String); ok {
+				return l + r, nil
+			}
+		}
+		fallthrough
+	case "-", "*", "/":
+		l, lok := left.(Int)
+		r, rok := right.(Int)
+		if !lok || !rok {
+			break
+		}
+		kind, ok := operandKind(l, r)
+		if !ok {
+			break
+		}
+		x, y := sized(kind, l.Value).Value, sized(kind, r.Value).Value
+		switch e.Operator {
+		case "+":
+			return sized(kind, x+y), nil
+		case "-":
+			return sized(kind, x-y), nil
+		case "*":
+			return sized(kind, x*y), nil
+		}
+		if y == 0 {
+			return nil, in.errorf(e, "integer divide by zero")
+		}
+		if unsigned(kind) {
+			return sized(kind, int64(uint64(x)/uint64(y))), nil
+		}
+		return sized(kind, x/y), nil
+	}
+	if left.Type() != right.Type() {
+		return nil, in.errorf(e, "invalid operation: %s (mismatched types %s and %s)", e, left.Type(), right.Type())
+	}
+	return nil, in.errorf(e, "invalid operation: operator %s not defined on %s (value of type %s)", e.Operator, e.Left, left.Type())
+}
+
+func (in *Interpreter) indexExpr(e *ast.IndexExpression, env *Environment) (Value, error) {
+	left, err := in.value(e.Left, env)
+	if err != nil {
+		return nil, err
+	}
+	index, err := in.value(e.Index, env)
+	if err != nil {
+		return nil, err
+	}
+	switch left := left.(type) {
+	case *Slice:
+		i, err := in.index(e, index, len(left.Elements))
+		if err != nil {
+			return nil, err
+		}
+		return left.Elements[i], nil
+	case String:
+		i, err := in.index(e, index, len(left))
+		if err != nil {
+			return nil, err
+		}
+		return sized("uint8", int64(left[i])), nil
+	case *Map:
+		if v, ok := left.Pairs[in.convert(index, left.Key)]; ok {
+			return v, nil
+		}
+		return in.zero(left.Elem), nil
+	}
+	return nil, in.errorf(e, "invalid operation: cannot index %s (value of type %s)", e.Left, left.Type())
+}
+
+// index checks index is an integer in range of a length n.
+func (in *Interpreter) index(e *ast.IndexExpression, index Value, n int) (int, error) {
+	i, ok := index.(Int)
+	if !ok {
+		return 0, in.errorf(e.Index, "invalid argument: index %s (value of type %s) must be integer", e.Index, index.Type())
+	}
+	if i.Value < 0 || i.Value >= int64(n) || unsigned(i.Kind) && uint64(i.Value) >= uint64(n) {
+		return 0, in.errorf(e, "index out of range [%s] with length %d", i, n)
+	}
+	return int(i.Value), nil
+}
+
+// selector evaluates the member of a package, or the field or method of a
+// value.
+func (in *Interpreter) selector(e *ast.SelectorExpression, env *Environment) (Value, error) {
+	left, err := in.value(e.Left, env)
+	if err != nil {
+		return nil, err
+	}
+	name := e.Name.Value
+	switch l := left.(type) {
+	case *Package:
+		if l.Members == nil {
+			return nil, in.errorf(e, "package %s is not supported by the interpreter", l.Path)
+		}
+		if v, ok := l.Members[name]; ok {
+			return v, nil
+		}
+		return nil, in.errorf(e.Name, "undefined: %s", e)
+	case *Option:
+		if m := optionMethod(l, name); m != nil {
+			return m, nil
+		}
+	}
+
+	if st := deref(left); st != nil {
+		if v, ok := st.Values[name]; ok {
+			return v, nil
+		}
+	}
+	if m := in.method(left, name); m != nil {
+		return m, nil
+	}
+	return nil, in.errorf(e.Name, "%s undefined (type %s has no field or method %s)", e, left.Type(), name)
+}
+
+// method returns the method name of recv bound to it. Methods with a
+// pointer receiver get a pointer to the struct recv is or points to, the
+// others a copy of it.
+func (in *Interpreter) method(recv Value, name string) *Function {
+	typ := strings.TrimPrefix(recv.Type(), "*")
+	lit, ok := in.methods[typ][name]
+	if !ok {
+		return nil
+	}
+	pointer := strings.HasPrefix(lit.Receiver.Type.Value, "*")
+	switch r := recv.(type) {
+	case *Struct:
+		if pointer {
+			recv = &Pointer{Elem: r}
+		} else {
+			recv = r.copy()
+		}
+	case *Pointer:
+		if !pointer {
+			recv = r.Elem.copy()
+		}
+	}
+	return &Function{Literal: lit, Env: in.globals, Receiver: recv}
+}
+
+func (in *Interpreter) callExpr(e *ast.CallExpression, env *Environment) (Value, error) {
+	if sel, ok := e.Function.(*ast.SelectorExpression); ok {
+		if ext, ok := ast.LookupExtension(e); ok {
+			recv, err := in.value(sel.Left, env)
+			if err != nil {
+				return nil, err
+			}
+			switch recv.(type) {
+			case *Slice, *Map, String:
+				if in.method(recv, ext.Name) == nil {
+					return in.extension(e, ext, recv, env)
+				}
+			}
+		}
+	}
+	switch fn := e.Function.(type) {
+	case *ast.Identifier:
+		if _, declared := env.Get(fn.Value); !declared && in.isType(fn.Value) {
+			return in.conversion(e, fn.Value, env)
+		}
+	case *ast.ExpressionLiteral:
+		// `string` is a keyword, parsed as a type.
+		if fn.Token.Type == token.STRING {
+			return in.conversion(e, "string", env)
+		}
+	}
+
+	fn, err := in.value(e.Function, env)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]Value, len(e.Arguments))
+	for i, arg := range e.Arguments {
+		if args[i], err = in.value(arg, env); err != nil {
+			return nil, err
+		}
+	}
+	return in.call(start(e), fn, args)
+}
+
+// call calls fn with args at the call token.
+func (in *Interpreter) call(at token.Token, fn Value, args []Value) (Value, error) {
+	switch fn := fn.(type) {
+	case *Builtin:
+		return fn.Fn(in, at, args)
+	case *Function:
+		lit := fn.Literal
+		name := lit.Name
+		if name == "" {
+			name = "func literal"
+		}
+		if len(args) < len(lit.Params) {
+			return nil, in.tokenError(at, "not enough arguments in call to %s", name)
+		}
+		if len(args) > len(lit.Params) {
+			return nil, in.tokenError(at, "too many arguments in call to %s", name)
+		}
+		if in.depth >= maxDepth {
+			return nil, in.tokenError(at, "stack overflow in call to %s", name)
+		}
+		in.depth++
+		defer func() { in.depth-- }()
+
+		env := NewEnvironment(fn.Env)
+		if lit.Receiver != nil && lit.Receiver.Name != nil {
+			env.Define(lit.Receiver.Name.Value, fn.Receiver)
+		}
+		for i, p := range lit.Params {
+			arg := own(args[i])
+			if p.Type != nil {
+				arg = in.convert(arg, p.Type.Value)
+			}
+			env.Define(p.Name.Value, arg)
+		}
+		v, ctl, err := in.block(lit.Body, env)
+		if err != nil {
+			return nil, err
+		}
+		if ctl != returned {
+			return nil, nil
+		}
+		if lit.ReturnType != nil && v != nil {
+			v = in.convert(v, lit.ReturnType.String())
+		}
+		return v, nil
+	case Nil:
+		return nil, in.tokenError(at, "invalid memory address or nil pointer dereference")
+	}
+	return nil, in.tokenError(at, "invalid operation: cannot call non-function (value of type %s)", fn.Type())
+}
+
+// isType reports whether name is a type the interpreter converts to.
+func (in *Interpreter) isType(name string) bool {
+	_, declared := in.types[name]
+	return declared || intTypes[name] || name == "string" || name == "bool"
+}
+
+// conversion evaluates the conversion of its single argument to typ.
+func (in *Interpreter) conversion(e *ast.CallExpression, typ string, env *Environment) (Value, error) {
+	if len(e.Arguments) != 1 {
+		return nil, in.errorf(e, "wrong number of arguments in conversion to %s", typ)
+	}
+	v, err := in.value(e.Arguments[0], env)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		underlying, ok := in.types[typ]
+		if !ok {
+			break
+		}
+		typ = underlying
+	}
+	switch {
+	case typ == "string":
+		switch v := v.(type) {
+		case String:
+			return v, nil
+		case Int:
+			return String(string(rune(v.Value))), nil
+		case *Slice:
+			var b strings.Builder
+			for _, el := range v.Elements {
+				if i, ok := el.(Int); ok {
+					if v.Elem == "rune" || v.Elem == "int32" {
+						b.WriteRune(rune(i.Value))
+					} else {
+						b.WriteByte(byte(i.Value))
+					}
+				}
+			}
+			return String(b.String()), nil
+		}
+	case typ == "[]byte", typ == "[]rune":
+		if s, ok := v.(String); ok {
+			out := &Slice{Elem: typ[2:]}
+			if typ == "[]byte" {
+				for i := 0; i < len(s); i++ {
+					out.Elements = append(out.Elements, sized("uint8", int64(s[i])))
+				}
+			} else {
+				for _, r := range s {
+					out.Elements = append(out.Elements, sized("int32", int64(r)))
+				}
+			}
+			return out, nil
+		}
+	case intTypes[typ]:
+		if i, ok := v.(Int); ok {
+			return sized(typ, i.Value), nil
+		}
+	case typ == "bool":
+		if b, ok := v.(Bool); ok {
+			return b, nil
+		}
+	}
+	return nil, in.errorf(e, "cannot convert %s (value of type %s) to type %s", e.Arguments[0], v.Type(), typ)
+}
+
+func (in *Interpreter) errorf(node ast.Node, format string, args ...interface{}) error {
+	return in.tokenError(start(node), format, args...)
+}
+
+func (in *Interpreter) tokenError(tok token.Token, format string, args ...interface{}) error {
+	err := token.Errorf(tok, format, args...)
+	err.File = in.file
+	return err
+}
+
+// start returns the first token of node, where errors about it are
+// reported.
+func start(node ast.Node) token.Token {
+	switch n := node.(type) {
+	case *ast.InfixExpression:
+		return start(n.Left)
+	case *ast.CallExpression:
+		return start(n.Function)
+	case *ast.SelectorExpression:
+		return start(n.Left)
+	case *ast.IndexExpression:
+		return start(n.Left)
+	case *ast.Identifier:
+		return n.Token
+	case *ast.PrefixExpression:
+		return n.Token
+	case *ast.GroupedExpression:
+		return n.Token
+	case *ast.StringLiteral:
+		return n.Token
+	case *ast.IntegerLiteral:
+		return n.Token
+	case *ast.Boolean:
+		return n.Token
+	case *ast.StructLiteral:
+		return n.Token
+	case *ast.ExpressionStatement:
+		return n.Token
+	case *ast.VarStatement:
+		return n.Token
+	case *ast.AssignStatement:
+		return start(n.Target)
+	case *ast.IfExpression:
+		return n.Token
+	case *ast.ImportStatement:
+		return n.Token
+	case *ast.ReturnStatement:
+		return n.Token
+	case *ast.MacroCall:
+		return n.Token
+	case *ast.FunctionLiteral:
+		return n.Token
+	case *ast.ArrayLiteral:
+		return n.Token
+	case *ast.MapLiteral:
+		return n.Token
+	}
+	return token.Token{}
+}
+
+func (in *Interpreter) parseInt(tok token.Token) (Value, error) {
+	i, err := strconv.ParseInt(tok.Literal, 0, 64)
+	if err != nil {
+		return nil, in.tokenError(tok, "cannot use %s (untyped int constant) as int value (overflows)", tok.Literal)
+	}
+	return Int{Kind: "int", Value: i}, nil
+}
+
+// unquote returns the string a string literal holds, whose escapes are
+// the ones of Go.
+func unquote(lit string) string {
+	if s, err := strconv.Unquote(`"` + lit + `"`); err == nil {
+		return s
+	}
+	return lit
+}
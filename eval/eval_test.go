@@ -0,0 +1,337 @@
+package eval
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+	yukrun "github.com/ahmadrosid/yuk/run"
+)
+
+// run runs the program input and returns its standard output and error.
+func run(t *testing.T, input string) (string, string, error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %q", p.Errors())
+	}
+	var stdout, stderr bytes.Buffer
+	err := New(&stdout, &stderr).Run(program)
+	return stdout.String(), stderr.String(), err
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`import "fmt"
+
+fmt.Println(1 + 2 * 3, (1 + 2) * 3, 7 / 2, -4 + 1)
+fmt.Println("yuk" + "lang", 1 < 2, 2 > 3, "a" == "a", !true, 1 != 1)`,
+			"7 9 3 -3\nyuklang true false true false false\n",
+		},
+		{
+			`package main
+
+import "fmt"
+
+func fib(n int) int {
+    if n < 2 {
+        return n
+    }
+    return fib(n - 1) + fib(n - 2)
+}
+
+func main() {
+    fmt.Println(fib(20))
+}`,
+			"6765\n",
+		},
+		{
+			`import "fmt"
+
+var n = 0
+inc := func() int {
+    n = n + 1
+    return n
+}
+inc()
+inc()
+fmt.Println(inc(), n)`,
+			"3 3\n",
+		},
+		{
+			`import "fmt"
+
+struct User(Name string, mut Age int)
+
+func (u User) Greet() string {
+    return "hi " + u.Name
+}
+
+func (u *User) Birthday() {
+    u.Age = u.Age + 1
+}
+
+u := User{Name: "yuk", Age: 1}
+u.Birthday()
+copy := u
+copy.Age = 10
+p := &u
+p.Birthday()
+fmt.Println(u.Greet(), u.Age, copy.Age, u, p)`,
+			"hi yuk 3 10 {yuk 3} &{yuk 3}\n",
+		},
+		{
+			`import "fmt"
+
+var m = map(string, int){"b": 2, "a": 1}
+m["c"] = 3
+delete(m, "b")
+var empty map(string, int)
+fmt.Println(m, len(m), m["missing"], empty)`,
+			"map[a:1 c:3] 2 0 map[]\n",
+		},
+		{
+			`import "fmt"
+
+xs := [1, 2, 3, 4]
+xs[0] = 5
+squares := xs.map(func(x int) int { return x * x })
+big := squares.filter(func(x int) bool { return x > 4 })
+sum := xs.reduce(0, func(acc int, x int) int { return acc + x })
+fmt.Println(squares, big, sum, xs.len(), xs.is_empty(), append(xs, 6))
+unsafe {
+    fmt.Println(xs.contains(3), xs.index_of(4), xs.first().Unwrap(), xs.last().IsSome(), []int{}.first().UnwrapOr(-1))
+}`,
+			"[25 4 9 16] [25 9 16] 14 4 false [5 2 3 4 6]\ntrue 3 5 true -1\n",
+		},
+		{
+			`import "fmt"
+
+func describe(x int) string {
+    switch x {
+        1 => {
+            return "one"
+        },
+        2 => {
+            break
+        },
+        _ => {
+            return "many"
+        }
+    }
+    return "two"
+}
+
+func kind(c byte) string {
+    switch c {
+        '+' => { return "plus" },
+        _ => { return "other" }
+    }
+}
+
+fmt.Println(describe(1), describe(2), describe(3), kind("a+"[1]), kind("a+"[0]))`,
+			"one two many plus other\n",
+		},
+		{
+			`import "fmt"
+import "strings"
+import "strconv"
+
+words := strings.Split("go yuk go", " ")
+fmt.Println(strings.ToUpper("yuk"), words, strings.Join(words, "-"), strings.Contains("yuk", "u"))
+fmt.Println(strings.Repeat("ab", 3) + strconv.Itoa(42), strings.TrimSpace("  x  "), len("héllo"), "abc"[1])
+fmt.Printf("%s=%d %v\n", "n", 7, words)
+fmt.Print(fmt.Sprintf("%q", string(65)), "\n")`,
+			"YUK [go yuk go] go-yuk-go true\nababab42 x 6 98\nn=7 [go yuk go]\n\"A\"\n",
+		},
+		{
+			`import "fmt"
+
+type Celsius int
+
+func freeze() Celsius {
+    return Celsius(0)
+}
+
+var t Celsius
+var name string
+var flags []bool
+fmt.Println(t == freeze(), name == "", flags.len())
+s := format!("{} + {} = {:?}", 1, 2, "3")
+fmt.Println(s)`,
+			"true true 0\n1 + 2 = \"3\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		stdout, _, err := run(t, tt.input)
+		if err != nil {
+			t.Errorf("%s\nunexpected error: %v", tt.input, err)
+			continue
+		}
+		if stdout != tt.expected {
+			t.Errorf("output wrong\nexpected=%q\ngot=%q", tt.expected, stdout)
+		}
+	}
+}
+
+// TestRun_Go checks the interpreter prints what the program compiled by
+// yuk run does.
+func TestRun_Go(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds with the go command")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no go command")
+	}
+
+	inputs := []string{
+		`package main
+
+import "fmt"
+
+func inc(x int8) int8 {
+    return x + 1
+}
+
+func main() {
+    var z int8 = 127
+    var b byte = byte(255)
+    var u uint = 0
+    var w uint16 = 65535
+    var n int32 = -2147483648
+    fmt.Println(z + 1, b + 1, u - 1, w * 2, n - 1, -n, inc(z))
+    z = z + 1
+    b = b + 2
+    fmt.Println(z, b, z < 0, u - 1 > 5, w / 2, z / -1)
+    fmt.Printf("%T %T %T %v\n", z, b, u, int64(z) * 2)
+}`,
+		`package main
+
+import "fmt"
+
+struct Pixel(mut R byte, mut G byte)
+
+func main() {
+    var big = 300
+    p := Pixel{R: 250, G: byte(big)}
+    p.R = p.R + 10
+    xs := []int16{32767}
+    xs[0] = xs[0] + 1
+    m := map(uint8, string){255: "max"}
+    fmt.Println(int8(big), uint8(big), uint16(-big), p.R, p.G, xs, m[byte(big - 45)], "ab"[1] + 200)
+}`,
+	}
+	for _, input := range inputs {
+		stdout, stderr, err := run(t, input)
+		if err != nil {
+			t.Errorf("%s\nunexpected error: %v %s", input, err, stderr)
+			continue
+		}
+
+		file := filepath.Join(t.TempDir(), "main.yuk")
+		if err := ioutil.WriteFile(file, []byte(input), 0644); err != nil {
+			t.Fatal(err)
+		}
+		p, errs := yukrun.Compile([]string{file}, nil)
+		if len(errs) > 0 {
+			t.Errorf("%s\nunexpected errors: %q", input, errs)
+			continue
+		}
+		exe, err := p.Build()
+		if err != nil {
+			p.Remove()
+			t.Errorf("%s\n%v", input, err)
+			continue
+		}
+		expected, err := exec.Command(exe).Output()
+		p.Remove()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stdout != string(expected) {
+			t.Errorf("%s\noutput differs from the Go program\nexpected=%q\ngot=%q", input, expected, stdout)
+		}
+	}
+}
+
+func TestRun_Print(t *testing.T) {
+	_, stderr, err := run(t, "print(1, \"a\")\nprintln(2, \"b\")\nx := dbg!(3 * 2)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "1a2 b\n[3:6] 3 * 2 = 6\n"
+	if stderr != expected {
+		t.Errorf("stderr wrong\nexpected=%q\ngot=%q", expected, stderr)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"println(x)", "1:9: undefined: x"},
+		{"func f() {\n    assert!(1 == 2, \"bad\")\n}\nf()", "2:5: panic: bad"},
+		{"xs := [1, 2]\nprintln(xs[2])", "2:9: index out of range [2] with length 2"},
+		{"x := 1 / 0", "1:6: integer divide by zero"},
+		{"x := 1 + \"a\"", "1:6: invalid operation: 1 + \"a\" (mismatched types int and string)"},
+		{"struct User(Name string)\nu := User{Age: 1}", "2:11: unknown field Age in struct literal of type User"},
+		{"func loop(n int) int {\n    return loop(n + 1)\n}\nloop(0)", "2:12: stack overflow in call to loop"},
+		{"unsafe {\n    [1].first().Unwrap()\n    [].last().Unwrap()\n}", "3:5: panic: std: unwrap of an empty Option"},
+		{"let x = 1\nx = 2\nprintln(x)", "2:1: cannot assign to x, it is declared with let at 1:5"},
+		{"func f(p *int) int {\n    return *p\n}", "2:12: cannot dereference p outside of an unsafe block, it may be nil"},
+		{"import \"os\"\nos.Exit(1)", "2:1: package os is not supported by the interpreter"},
+		{"func f(a int) int {\n    return a\n}\nf()", "4:1: not enough arguments in call to f"},
+	}
+
+	for _, tt := range tests {
+		_, _, err := run(t, tt.input)
+		if err == nil {
+			t.Errorf("%s\nexpected error %q", tt.input, tt.expected)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%s\nerror wrong\nexpected=%q\ngot=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestExec(t *testing.T) {
+	in := New(&bytes.Buffer{}, &bytes.Buffer{})
+	inputs := []struct {
+		input    string
+		expected string
+	}{
+		{"func double(x int) int {\n    return x * 2\n}", "<none>"},
+		{"var n = 21", "<none>"},
+		{"double(n)", "42"},
+		{"[\"a\", \"b\"].map(func(s string) string { return s + s })", "[aa bb]"},
+	}
+	for _, tt := range inputs {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("parse errors: %q", p.Errors())
+		}
+		v, err := in.Exec(program)
+		if err != nil {
+			t.Fatalf("%s\nunexpected error: %v", tt.input, err)
+		}
+		got := "<none>"
+		if v != nil {
+			got = v.String()
+		}
+		if got != tt.expected {
+			t.Errorf("%s\nvalue wrong\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
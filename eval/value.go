@@ -0,0 +1,403 @@
+package eval
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// Value is a value of the program being interpreted.
+type Value interface {
+	// Type returns the Go spelling of the type of the value.
+	Type() string
+	// String formats the value like the %v verb of fmt.
+	String() string
+}
+
+// Int is a value of the integer type Kind, wrapped to its size like Go
+// wraps the results of arithmetic. A value of an unsigned type holds the
+// int64 with the same bits.
+//
+// Integer literals are ints. In an operation on an int and a value of
+// another integer type, the other value gives the type of the result, like
+// an untyped constant operand would.
+type Int struct {
+	Kind  string
+	Value int64
+}
+
+func (i Int) Type() string { return i.Kind }
+func (i Int) String() string {
+	if unsigned(i.Kind) {
+		return strconv.FormatUint(uint64(i.Value), 10)
+	}
+	return strconv.FormatInt(i.Value, 10)
+}
+
+// intOf returns the int n.
+func intOf(n int) Int { return Int{Kind: "int", Value: int64(n)} }
+
+// sized returns v converted to the integer type kind, truncated to its
+// size.
+func sized(kind string, v int64) Int {
+	switch kind {
+	case "byte":
+		kind = "uint8"
+	case "rune":
+		kind = "int32"
+	}
+	switch kind {
+	case "int8":
+		v = int64(int8(v))
+	case "int16":
+		v = int64(int16(v))
+	case "int32":
+		v = int64(int32(v))
+	case "uint8":
+		v = int64(uint8(v))
+	case "uint16":
+		v = int64(uint16(v))
+	case "uint32":
+		v = int64(uint32(v))
+	}
+	return Int{Kind: kind, Value: v}
+}
+
+func unsigned(kind string) bool { return strings.HasPrefix(kind, "uint") }
+
+// operandKind returns the type of an operation on a and b, and whether
+// they can be its operands.
+func operandKind(a, b Int) (string, bool) {
+	switch {
+	case a.Kind == b.Kind || b.Kind == "int":
+		return a.Kind, true
+	case a.Kind == "int":
+		return b.Kind, true
+	}
+	return "", false
+}
+
+type String string
+
+func (s String) Type() string   { return "string" }
+func (s String) String() string { return string(s) }
+
+type Bool bool
+
+func (b Bool) Type() string   { return "bool" }
+func (b Bool) String() string { return strconv.FormatBool(bool(b)) }
+
+// Nil is the value of nil, and of the variables of the types the
+// interpreter has no zero value for.
+type Nil struct{}
+
+func (Nil) Type() string   { return "nil" }
+func (Nil) String() string { return "<nil>" }
+
+// Slice is a slice value. Like a Go slice, copies of it share the
+// elements.
+type Slice struct {
+	Elem     string
+	Elements []Value
+}
+
+func (s *Slice) Type() string { return "[]" + s.Elem }
+func (s *Slice) String() string {
+	parts := make([]string, len(s.Elements))
+	for i, el := range s.Elements {
+		parts[i] = el.String()
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// Map is a map value. Its keys are the comparable values: Int, String and
+// Bool.
+type Map struct {
+	Key, Elem string
+	Pairs     map[Value]Value
+}
+
+func (m *Map) Type() string { return "map[" + m.Key + "]" + m.Elem }
+
+// String prints the pairs sorted by key, like fmt does.
+func (m *Map) String() string {
+	keys := make([]Value, 0, len(m.Pairs))
+	for k := range m.Pairs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		less, _ := compare(keys[i], keys[j], "<")
+		return less
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.String() + ":" + m.Pairs[k].String()
+	}
+	return "map[" + strings.Join(parts, " ") + "]"
+}
+
+// Struct is a value of a struct type. Variables holding one own it: it is
+// copied when assigned or passed, and shared through a Pointer.
+type Struct struct {
+	Name   string
+	Fields []string
+	Values map[string]Value
+}
+
+func (s *Struct) Type() string { return s.Name }
+func (s *Struct) String() string {
+	parts := make([]string, len(s.Fields))
+	for i, name := range s.Fields {
+		parts[i] = s.Values[name].String()
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+func (s *Struct) copy() *Struct {
+	c := &Struct{Name: s.Name, Fields: s.Fields, Values: make(map[string]Value, len(s.Values))}
+	for name, v := range s.Values {
+		c.Values[name] = own(v)
+	}
+	return c
+}
+
+// Pointer points to a struct, the only values whose address can be taken.
+type Pointer struct {
+	Elem *Struct
+}
+
+func (p *Pointer) Type() string   { return "*" + p.Elem.Name }
+func (p *Pointer) String() string { return "&" + p.Elem.String() }
+
+// Option is the value of std.Option, returned by `xs.first()` and
+// `xs.last()`.
+type Option struct {
+	Elem  string
+	Value Value
+	Ok    bool
+}
+
+func (o *Option) Type() string { return "std.Option[" + o.Elem + "]" }
+func (o *Option) String() string {
+	return "{" + o.Value.String() + " " + strconv.FormatBool(o.Ok) + "}"
+}
+
+// Function is a function declared or written as a literal in the program,
+// with the environment it closes over. Methods are bound to their
+// receiver.
+type Function struct {
+	Literal  *ast.FunctionLiteral
+	Env      *Environment
+	Receiver Value
+}
+
+func (f *Function) Type() string {
+	var params []string
+	for _, p := range f.Literal.Params {
+		if p.Type != nil {
+			params = append(params, p.Type.Value)
+		}
+	}
+	typ := "func(" + strings.Join(params, ", ") + ")"
+	if f.Literal.ReturnType != nil {
+		typ += " " + f.Literal.ReturnType.String()
+	}
+	return typ
+}
+
+func (f *Function) String() string { return fmt.Sprintf("%p", f) }
+
+// Builtin is a function provided by the interpreter, a Go builtin or a
+// function of a package it implements.
+type Builtin struct {
+	Name string
+	Fn   func(in *Interpreter, call token.Token, args []Value) (Value, error)
+}
+
+func (b *Builtin) Type() string   { return "func" }
+func (b *Builtin) String() string { return b.Name }
+
+// Package is an imported package, whose members are the functions the
+// interpreter implements.
+type Package struct {
+	Path    string
+	Members map[string]Value
+}
+
+func (p *Package) Type() string   { return "package" }
+func (p *Package) String() string { return "package " + p.Path }
+
+// own returns the value stored by an assignment of v: a copy of a
+// struct, v itself otherwise.
+func own(v Value) Value {
+	if s, ok := v.(*Struct); ok {
+		return s.copy()
+	}
+	return v
+}
+
+// equal reports whether a and b are equal, and whether they can be
+// compared.
+func equal(a, b Value) (bool, bool) {
+	switch a := a.(type) {
+	case Int:
+		b, ok := b.(Int)
+		if !ok {
+			return false, false
+		}
+		kind, ok := operandKind(a, b)
+		return sized(kind, a.Value) == sized(kind, b.Value), ok
+	case String, Bool:
+		return a == b, a.Type() == b.Type()
+	case Nil:
+		_, ok := b.(Nil)
+		return ok, true
+	case *Pointer:
+		if _, ok := b.(Nil); ok {
+			return false, true
+		}
+		bp, ok := b.(*Pointer)
+		return ok && a.Elem == bp.Elem, ok
+	case *Struct:
+		bs, ok := b.(*Struct)
+		if !ok || a.Name != bs.Name {
+			return false, false
+		}
+		for _, name := range a.Fields {
+			eq, ok := equal(a.Values[name], bs.Values[name])
+			if !ok {
+				return false, false
+			}
+			if !eq {
+				return false, true
+			}
+		}
+		return true, true
+	case *Slice, *Map, *Function:
+		// Like in Go, they can only be compared to nil, which they are
+		// never equal to here.
+		_, ok := b.(Nil)
+		return false, ok
+	}
+	return false, false
+}
+
+// compare evaluates the ordering operator op, < or >, on a and b.
+func compare(a, b Value, op string) (bool, bool) {
+	switch a := a.(type) {
+	case Int:
+		b, ok := b.(Int)
+		if !ok {
+			return false, false
+		}
+		kind, ok := operandKind(a, b)
+		x, y := sized(kind, a.Value).Value, sized(kind, b.Value).Value
+		if unsigned(kind) {
+			if op == "<" {
+				return uint64(x) < uint64(y), ok
+			}
+			return uint64(x) > uint64(y), ok
+		}
+		if op == "<" {
+			return x < y, ok
+		}
+		return x > y, ok
+	case String:
+		b, ok := b.(String)
+		if op == "<" {
+			return a < b, ok
+		}
+		return a > b, ok
+	case Bool:
+		// Only used to sort map keys: false before true.
+		b, ok := b.(Bool)
+		return op == "<" && !bool(a) && bool(b), ok
+	}
+	return false, false
+}
+
+// comparable reports whether v can be a map key.
+func comparable(v Value) bool {
+	switch v.(type) {
+	case Int, String, Bool:
+		return true
+	}
+	return false
+}
+
+// intTypes are the integer types, all represented by Int.
+var intTypes = map[string]bool{
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"uintptr": true, "byte": true, "rune": true,
+}
+
+// zero returns the zero value of the type spelled typ.
+func (in *Interpreter) zero(typ string) Value {
+	switch {
+	case intTypes[typ]:
+		return sized(typ, 0)
+	case typ == "string":
+		return String("")
+	case typ == "bool":
+		return Bool(false)
+	case strings.HasPrefix(typ, "[]"):
+		return &Slice{Elem: typ[2:]}
+	case strings.HasPrefix(typ, "map["):
+		key, elem := splitMapType(typ)
+		return &Map{Key: key, Elem: elem, Pairs: map[Value]Value{}}
+	}
+	if st, ok := in.structs[typ]; ok {
+		s := &Struct{Name: typ, Values: map[string]Value{}}
+		for _, attr := range st.Attributes {
+			s.Fields = append(s.Fields, attr.Name.Literal)
+			s.Values[attr.Name.Literal] = in.zero(attr.Type.Literal)
+		}
+		return s
+	}
+	if underlying, ok := in.types[typ]; ok {
+		return in.zero(underlying)
+	}
+	return Nil{}
+}
+
+// convert returns v stored in a variable, field or element of the type
+// spelled typ: an int of an integer type is converted to it.
+func (in *Interpreter) convert(v Value, typ string) Value {
+	i, ok := v.(Int)
+	if !ok || i.Kind != "int" {
+		return v
+	}
+	for {
+		underlying, ok := in.types[typ]
+		if !ok {
+			break
+		}
+		typ = underlying
+	}
+	if !intTypes[typ] {
+		return v
+	}
+	return sized(typ, i.Value)
+}
+
+// splitMapType returns the key and element types of the map type typ.
+func splitMapType(typ string) (key, elem string) {
+	depth := 0
+	for i := len("map["); i < len(typ); i++ {
+		switch typ[i] {
+		case '[':
+			depth++
+		case ']':
+			if depth == 0 {
+				return typ[len("map["):i], typ[i+1:]
+			}
+			depth--
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/ahmadrosid/yuk/eval"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+)
+
+// evalCommand runs a yuk file with the interpreter, without the Go
+// toolchain.
+func evalCommand(args []string) {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	flags.Usage = func() {
+		log.Print("usage: yuk eval file.yuk")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	path := flags.Arg(0)
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	program.File = path
+	for _, e := range p.Errors() {
+		log.Printf("%s:%s", path, e)
+	}
+	if len(p.Errors()) > 0 {
+		os.Exit(1)
+	}
+
+	if err := eval.New(os.Stdout, os.Stderr).Run(program); err != nil {
+		log.Fatal(err)
+	}
+}
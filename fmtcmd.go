@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/diff"
+	"github.com/ahmadrosid/yuk/format"
+)
+
+// fmtCommand formats the .yuk files given as arguments, and the ones under
+// the directories given, skipping the directories yuk build skips. Without
+// arguments it formats the standard input to the standard output.
+func fmtCommand(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	flags.Usage = func() {
+		log.Print("usage: yuk fmt [-l] [-w] [-d] [path ...]")
+		flags.PrintDefaults()
+	}
+	list := flags.Bool("l", false, "list the files whose formatting differs from yuk fmt's")
+	write := flags.Bool("w", false, "write the result to the source file instead of the standard output")
+	showDiff := flags.Bool("d", false, "print diffs instead of rewriting the files")
+	flags.Parse(args)
+
+	f := &formatter{list: *list, write: *write, diff: *showDiff}
+	if flags.NArg() == 0 {
+		if f.write {
+			log.Fatal("yuk fmt: cannot use -w with the standard input")
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f.format("<standard input>", src, 0)
+		f.exit()
+	}
+
+	for _, path := range flags.Args() {
+		info, err := os.Stat(path)
+		if err != nil {
+			f.error(err)
+			continue
+		}
+		if !info.IsDir() {
+			f.file(path)
+			continue
+		}
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			name := info.Name()
+			if info.IsDir() {
+				if file != path && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(name, ".yuk") {
+				f.file(file)
+			}
+			return nil
+		})
+		if err != nil {
+			f.error(err)
+		}
+	}
+	f.exit()
+}
+
+// formatter formats files for fmtCommand.
+type formatter struct {
+	list, write, diff bool
+	failed            bool
+}
+
+func (f *formatter) file(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		f.error(err)
+		return
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		f.error(err)
+		return
+	}
+	f.format(path, src, info.Mode().Perm())
+}
+
+// format formats the source src of the file path, with the permissions perm
+// for -w, and reports it as the flags ask.
+func (f *formatter) format(path string, src []byte, perm os.FileMode) {
+	out, errs := format.Source(path, src)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			f.error(err)
+		}
+		return
+	}
+
+	changed := !bytes.Equal(src, out)
+	if f.list && changed {
+		fmt.Println(path)
+	}
+	if f.write && changed {
+		if err := ioutil.WriteFile(path, out, perm); err != nil {
+			f.error(err)
+			return
+		}
+	}
+	if f.diff && changed {
+		fmt.Printf("diff %s.orig %s\n", path, path)
+		os.Stdout.Write(diff.Unified(path+".orig", path, src, out))
+	}
+	if !f.list && !f.write && !f.diff {
+		os.Stdout.Write(out)
+	}
+}
+
+func (f *formatter) error(err error) {
+	log.Print(err)
+	f.failed = true
+}
+
+// exit exits with status 1 when a file could not be formatted.
+func (f *formatter) exit() {
+	if f.failed {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,734 @@
+// Package format prints yuk programs in the canonical yuk style, for
+// `yuk fmt`.
+//
+// Blocks are indented with four spaces. The fields of multi-line structs
+// and the entries of multi-line literals are aligned in columns like gofmt
+// aligns them, and the comments and single blank lines of the source are
+// kept.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// indent is the indentation of one block level.
+const indent = "    "
+
+// Source formats the yuk source src of the file filename. A source which
+// does not parse is left alone, and its errors are returned instead.
+func Source(filename string, src []byte) ([]byte, []error) {
+	program, errs := parse(filename, src)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	out := Program(program)
+
+	// The printer must never change what a program means, as yuk fmt -w
+	// replaces the source with its output.
+	formatted, errs := parse(filename, out)
+	if len(errs) > 0 || formatted.String() != program.String() {
+		return nil, []error{fmt.Errorf("%s: formatting would change the program, leaving it unformatted", filename)}
+	}
+	return out, nil
+}
+
+func parse(filename string, src []byte) (*ast.Program, []error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	var errs []error
+	for _, msg := range p.Errors() {
+		errs = append(errs, parser.FileError(filename, msg))
+	}
+	return program, errs
+}
+
+// Program returns the source of program, with its comments, in the
+// canonical style.
+func Program(program *ast.Program) []byte {
+	p := &printer{start: true}
+	for _, g := range program.Comments {
+		p.comments = append(p.comments, g.List...)
+	}
+	p.statements(program.Statements, token.Token{Type: token.EOF})
+	p.flush(int(^uint(0) >> 1))
+	out := bytes.TrimRight(p.out.Bytes(), "\n")
+	return append(out, '\n')
+}
+
+// printer writes the source of nodes. Comments are not nodes, they are
+// printed in between the nodes they appear between in the source, or after
+// the last node of their line.
+type printer struct {
+	out      bytes.Buffer
+	depth    int
+	comments []token.Token
+	// bol is set at the beginning of a line, before its indentation.
+	bol bool
+	// last is the source line of the last thing printed, and start is set
+	// when nothing was printed yet in the current block.
+	last  int
+	start bool
+}
+
+func (p *printer) write(s string) {
+	if p.bol && s != "" {
+		p.out.WriteString(strings.Repeat(indent, p.depth))
+		p.bol = false
+	}
+	p.out.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.out.WriteByte('\n')
+	p.bol = true
+}
+
+// gap prints a blank line before something starting at line, when the
+// source has one.
+func (p *printer) gap(line int) {
+	if !p.start && line > p.last+1 {
+		p.newline()
+	}
+	p.start = false
+}
+
+// flush prints the comments starting before line on lines of their own.
+func (p *printer) flush(line int) {
+	for len(p.comments) > 0 && p.comments[0].Line < line {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		p.gap(c.Line)
+		p.write(c.Literal)
+		p.newline()
+		p.last = c.Line + strings.Count(c.Literal, "\n")
+	}
+}
+
+// trailing prints the comment after the end of line, if any.
+func (p *printer) trailing(line int) {
+	if len(p.comments) > 0 && p.comments[0].Line == line {
+		p.write(" " + p.comments[0].Literal)
+		p.comments = p.comments[1:]
+	}
+}
+
+// commentIn reports whether a comment starts between the tokens from and
+// to.
+func (p *printer) commentIn(from, to token.Token) bool {
+	for _, c := range p.comments {
+		if before(from, c) && before(c, to) {
+			return true
+		}
+	}
+	return false
+}
+
+func before(a, b token.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// sub returns what f prints with a printer without comments, for the parts
+// of a line printed before the line.
+func sub(f func(p *printer)) string {
+	p := &printer{}
+	f(p)
+	return p.out.String()
+}
+
+// statements prints stmts, one per line, and the comments before end.
+func (p *printer) statements(stmts []ast.Statement, end token.Token) {
+	for _, s := range stmts {
+		line := startLine(s)
+		p.flush(line)
+		p.gap(line)
+		p.statement(s)
+		p.last = endLine(s)
+		p.trailing(p.last)
+		p.newline()
+	}
+	if end.Type != token.EOF {
+		p.flush(end.Line)
+	}
+}
+
+func (p *printer) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		p.expr(s.Expression)
+	case *ast.VarStatement:
+		p.write(s.Token.Literal + " " + s.Name.Value)
+		if s.Type != nil {
+			p.write(" " + yukType(s.Type.Value))
+		}
+		if s.Value != nil {
+			p.write(" = ")
+			p.expr(s.Value)
+		}
+	case *ast.AssignStatement:
+		p.expr(s.Target)
+		p.write(" = ")
+		p.expr(s.Value)
+	case *ast.ReturnStatement:
+		p.write("return")
+		if s.ReturnValue != nil {
+			p.write(" ")
+			p.expr(s.ReturnValue)
+		}
+	case *ast.ImportStatement:
+		p.write("import ")
+		p.expr(s.PackageName)
+	case *ast.StructAttributes:
+		p.write("type " + s.Name.Literal + " " + s.Type.Literal)
+	case *ast.SwitchStatement:
+		p.switchStatement(s)
+	case *ast.MacroStatement:
+		p.write("macro " + s.Name.Value + "!(")
+		for i, param := range s.Params {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(param.Value)
+		}
+		p.write(") ")
+		p.block(s.Body)
+	case *ast.UnsafeStatement:
+		p.write("unsafe ")
+		p.block(s.Body)
+	case *ast.BlockStatement:
+		p.block(s)
+	default:
+		p.write(s.String())
+	}
+}
+
+// block prints a block, with its statements indented.
+func (p *printer) block(b *ast.BlockStatement) {
+	if len(b.Statements) == 0 && !p.commentIn(b.Token, b.End) {
+		p.write("{}")
+		p.last = b.End.Line
+		return
+	}
+	p.write("{")
+	p.last = b.Token.Line
+	p.trailing(b.Token.Line)
+	p.newline()
+	p.depth++
+	p.start = true
+	p.statements(b.Statements, b.End)
+	p.depth--
+	p.write("}")
+	p.last = b.End.Line
+	p.start = false
+}
+
+// body prints the body of a function, on one line when it is written on
+// one line and fits on it.
+func (p *printer) body(b *ast.BlockStatement) {
+	if len(b.Statements) == 1 && b.Token.Line == b.End.Line && !p.commentIn(b.Token, b.End) {
+		if s := sub(func(p *printer) { p.statement(b.Statements[0]) }); !strings.Contains(s, "\n") {
+			p.write("{ " + s + " }")
+			p.last = b.End.Line
+			return
+		}
+	}
+	p.block(b)
+}
+
+func (p *printer) switchStatement(s *ast.SwitchStatement) {
+	p.write("switch " + tokenText(s.Input) + " {")
+	p.last = s.Token.Line
+	p.trailing(s.Token.Line)
+	p.newline()
+	p.depth++
+	p.start = true
+	for i, c := range s.Case {
+		p.flush(c.Token.Line)
+		p.gap(c.Token.Line)
+		p.write(tokenText(c.Token) + " => ")
+		p.block(c.Body)
+		if i < len(s.Case)-1 {
+			p.write(",")
+		}
+		p.trailing(p.last)
+		p.newline()
+	}
+	p.flush(s.End.Line)
+	p.depth--
+	p.write("}")
+	p.last = s.End.Line
+}
+
+func (p *printer) expr(e ast.Expression) {
+	switch e := e.(type) {
+	case nil:
+		// Only programs that parsed are printed, but an expression the
+		// parser could not complete is left out rather than crashing.
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		p.write(e.Value)
+	case *ast.StringLiteral:
+		p.write(`"` + e.Value + `"`)
+	case *ast.Boolean:
+		p.write(e.Value)
+	case *ast.ExpressionLiteral:
+		if e.Token.Type == token.PACKAGE {
+			p.write("package ")
+		}
+		p.write(e.Name.Value)
+	case *ast.VarExpression:
+		p.write(e.Ident.Literal + " := ")
+		p.expr(e.Value)
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.expr(e.Right)
+	case *ast.InfixExpression:
+		p.expr(e.Left)
+		p.write(" " + e.Operator + " ")
+		p.expr(e.Right)
+	case *ast.GroupedExpression:
+		p.write("(")
+		p.expr(e.Expression)
+		p.write(")")
+	case *ast.SelectorExpression:
+		p.expr(e.Left)
+		p.write("." + e.Name.Value)
+	case *ast.IndexExpression:
+		p.expr(e.Left)
+		p.write("[")
+		p.expr(e.Index)
+		p.write("]")
+	case *ast.CallExpression:
+		p.expr(e.Function)
+		p.write("(")
+		p.list(e.Token, e.Arguments, e.End)
+		p.write(")")
+	case *ast.MacroCall:
+		p.write(e.Name.Value + "!(")
+		p.list(e.Token, e.Arguments, e.End)
+		p.write(")")
+	case *ast.ArrayLiteral:
+		if e.Type == nil {
+			p.write("[")
+			p.list(e.Token, e.Elements, e.End)
+			p.write("]")
+			return
+		}
+		p.write("[]" + yukType(e.Type.Value) + "{")
+		p.list(e.Type.Token, e.Elements, e.End)
+		p.write("}")
+	case *ast.FunctionLiteral:
+		p.function(e)
+	case *ast.IfExpression:
+		p.write("if ")
+		p.expr(e.Condition)
+		p.write(" ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.StructStatement:
+		p.structStatement(e)
+	case *ast.StructLiteral:
+		p.structLiteral(e)
+	case *ast.MapLiteral:
+		p.mapLiteral(e)
+	default:
+		p.write(e.String())
+	}
+}
+
+// list prints the expressions of a call or a literal between the open and
+// end tokens. They are printed one per line when the first one starts on
+// a line after open, and on one line otherwise.
+func (p *printer) list(open token.Token, exprs []ast.Expression, end token.Token) {
+	if len(exprs) == 0 || startLine(exprs[0]) == open.Line {
+		for i, e := range exprs {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(e)
+		}
+		return
+	}
+
+	p.last = open.Line
+	p.trailing(open.Line)
+	p.newline()
+	p.depth++
+	p.start = true
+	for i, e := range exprs {
+		line := startLine(e)
+		p.flush(line)
+		p.gap(line)
+		p.expr(e)
+		// A list of expressions can not end with a comma.
+		if i < len(exprs)-1 {
+			p.write(",")
+		}
+		p.last = endLine(e)
+		p.trailing(p.last)
+		p.newline()
+	}
+	p.flush(end.Line)
+	p.depth--
+	p.last = end.Line
+}
+
+func (p *printer) function(fn *ast.FunctionLiteral) {
+	if fn.Unsafe {
+		p.write("unsafe ")
+	}
+	p.write("func")
+	if fn.Receiver != nil {
+		p.write(" (" + parameter(fn.Receiver) + ")")
+	}
+	if fn.Name != "" {
+		p.write(" " + fn.Name)
+	}
+	p.write("(")
+	for i, param := range fn.Params {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.write(parameter(param))
+	}
+	p.write(") ")
+	if fn.ReturnType != nil {
+		if typ, ok := fn.ReturnType.(*ast.Identifier); ok {
+			p.write(yukType(typ.Value))
+		} else {
+			p.expr(fn.ReturnType)
+		}
+		p.write(" ")
+	}
+	p.body(fn.Body)
+}
+
+func parameter(param *ast.Parameter) string {
+	return param.Name.Value + " " + yukType(param.Type.Value)
+}
+
+// structStatement prints `struct Name(...)`. The fields are printed on one
+// line when they are written on the line of the struct, and one per line,
+// aligned, otherwise.
+func (p *printer) structStatement(s *ast.StructStatement) {
+	p.write("struct")
+	if s.Name != nil {
+		p.write(" " + s.Name.Literal)
+	}
+	p.write("(")
+	if len(s.Attributes) == 0 || s.Attributes[0].Name.Line == s.Token.Line {
+		for i, attr := range s.Attributes {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(strings.Join(fieldCells(attr), " "))
+		}
+		p.write(")")
+		return
+	}
+
+	rows := make([]row, len(s.Attributes))
+	for i, attr := range s.Attributes {
+		rows[i] = row{start: attr.Name.Line, end: attr.Name.Line, cells: fieldCells(attr)}
+	}
+	p.rows(s.Token.Line, rows, s.End.Line)
+	p.write(")")
+}
+
+func fieldCells(attr *ast.StructAttributes) []string {
+	name := attr.Name.Literal
+	if attr.Mutable {
+		name = "mut " + name
+	}
+	cells := []string{name, attr.Type.Literal}
+	if attr.Meta != nil {
+		var tag strings.Builder
+		tag.WriteByte('`')
+		for i, kv := range attr.Meta.KeyValue {
+			if i > 0 {
+				tag.WriteByte(' ')
+			}
+			tag.WriteString(kv.Key.Literal + ":")
+			tag.WriteString(sub(func(p *printer) { p.expr(kv.Value) }))
+		}
+		tag.WriteByte('`')
+		cells = append(cells, tag.String())
+	}
+	return cells
+}
+
+// structLiteral prints `User{Name: "yuk"}`, with one field per line ending
+// with a comma when the literal spans several lines.
+func (p *printer) structLiteral(lit *ast.StructLiteral) {
+	p.write(lit.Type.Value + "{")
+	if lit.End.Line == lit.Type.Token.Line {
+		for i, f := range lit.Fields {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(f.Name.Value + ": ")
+			p.expr(f.Value)
+		}
+		p.write("}")
+		return
+	}
+
+	rows := make([]row, len(lit.Fields))
+	for i, f := range lit.Fields {
+		f := f
+		rows[i] = row{start: f.Name.Token.Line, end: endLine(f.Value), cells: []string{f.Name.Value + ":"}}
+		rows[i].value = func() {
+			p.expr(f.Value)
+			p.write(",")
+		}
+	}
+	p.rows(lit.Type.Token.Line, rows, lit.End.Line)
+	p.write("}")
+}
+
+// mapLiteral prints `map(K, V) {k: v}`, with one entry per line when the
+// literal spans several lines.
+func (p *printer) mapLiteral(m *ast.MapLiteral) {
+	p.write("map(" + m.Key.Token.Literal + ", " + m.Value.Token.Literal + ")")
+	h := m.KeyValue
+	if h == nil {
+		return
+	}
+	p.write(" {")
+	if h.End.Line == m.Token.Line {
+		for i, pair := range h.Pairs {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(pair.Key)
+			p.write(": ")
+			p.expr(pair.Value)
+		}
+		p.write("}")
+		return
+	}
+
+	rows := make([]row, len(h.Pairs))
+	for i, pair := range h.Pairs {
+		pair := pair
+		key := sub(func(p *printer) { p.expr(pair.Key) })
+		rows[i] = row{start: startLine(pair.Key), end: endLine(pair.Value), cells: []string{key + ":"}}
+		last := i == len(h.Pairs)-1
+		rows[i].value = func() {
+			p.expr(pair.Value)
+			// The entries of a map literal can not end with a comma.
+			if !last {
+				p.write(",")
+			}
+		}
+	}
+	p.rows(m.Token.Line, rows, h.End.Line)
+	p.write("}")
+}
+
+// row is a line of aligned cells, a struct field or the entry of a
+// literal, from the source line start to end. The value of an entry is
+// printed by value after the cells.
+type row struct {
+	start, end int
+	cells      []string
+	value      func()
+}
+
+// rows prints rows, one per line, indented between the lines open and end.
+// The cells of consecutive rows on one line each are aligned in columns,
+// with the trailing comments of the rows.
+func (p *printer) rows(open int, rows []row, end int) {
+	p.last = open
+	p.trailing(open)
+	p.newline()
+	p.depth++
+	p.start = true
+
+	var section []string
+	align := func() {
+		if len(section) == 0 {
+			return
+		}
+		var buf bytes.Buffer
+		w := tabwriter.NewWriter(&buf, 0, 4, 1, ' ', tabwriter.StripEscape)
+		for _, line := range section {
+			fmt.Fprintln(w, line)
+		}
+		w.Flush()
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			p.write(strings.TrimRight(line, " \n"))
+			p.newline()
+		}
+		section = nil
+	}
+
+	for _, r := range rows {
+		if len(p.comments) > 0 && p.comments[0].Line < r.start || r.start > p.last+1 || r.start != r.end {
+			align()
+		}
+		p.flush(r.start)
+		p.gap(r.start)
+		p.last = r.end
+
+		cells := r.cells
+		if r.start != r.end {
+			// A row spanning several lines is printed as it is.
+			p.write(strings.Join(cells, " "))
+			if r.value != nil {
+				p.write(" ")
+				r.value()
+			}
+			p.trailing(r.end)
+			p.newline()
+			continue
+		}
+		if r.value != nil {
+			cells = append(cells[:len(cells):len(cells)], p.capture(r.value))
+		}
+		if len(p.comments) > 0 && p.comments[0].Line == r.end {
+			cells = append(cells[:len(cells):len(cells)], p.comments[0].Literal)
+			p.comments = p.comments[1:]
+		}
+		for i, cell := range cells {
+			cells[i] = escape(cell)
+		}
+		section = append(section, strings.Join(cells, "\t"))
+	}
+	align()
+	p.flush(end)
+	p.depth--
+	p.last = end
+}
+
+// capture returns what value prints on the current line, which must not
+// span several lines.
+func (p *printer) capture(value func()) string {
+	out, bol, depth := p.out, p.bol, p.depth
+	p.out, p.bol, p.depth = bytes.Buffer{}, false, 0
+	value()
+	s := p.out.String()
+	p.out, p.bol, p.depth = out, bol, depth
+	return s
+}
+
+// escape makes tabwriter keep the tabs of a cell.
+func escape(cell string) string {
+	esc := string([]byte{tabwriter.Escape})
+	return esc + cell + esc
+}
+
+// yukType returns the yuk spelling of a type kept by the parser with its
+// Go spelling, like map(string, []int) for map[string][]int.
+func yukType(typ string) string {
+	switch {
+	case strings.HasPrefix(typ, "*"):
+		return "*" + yukType(typ[1:])
+	case strings.HasPrefix(typ, "[]"):
+		return "[]" + yukType(typ[2:])
+	case strings.HasPrefix(typ, "map["):
+		depth := 0
+		for i := 3; i < len(typ); i++ {
+			switch typ[i] {
+			case '[':
+				depth++
+			case ']':
+				depth--
+				if depth == 0 {
+					return "map(" + yukType(typ[4:i]) + ", " + yukType(typ[i+1:]) + ")"
+				}
+			}
+		}
+	case typ == "interface{}":
+		return "interface"
+	}
+	return typ
+}
+
+// tokenText returns the source of a single token expression, like the
+// input and the cases of a switch.
+func tokenText(tok token.Token) string {
+	switch tok.Type {
+	case token.CHAR:
+		return "'" + tok.Literal + "'"
+	case token.STRING_LIT:
+		return `"` + tok.Literal + `"`
+	}
+	return tok.Literal
+}
+
+// startLine returns the source line node starts on.
+func startLine(node ast.Node) int {
+	switch n := node.(type) {
+	case *ast.ExpressionStatement:
+		if n.Expression != nil {
+			return startLine(n.Expression)
+		}
+	case *ast.AssignStatement:
+		return startLine(n.Target)
+	case *ast.InfixExpression:
+		return startLine(n.Left)
+	case *ast.CallExpression:
+		return startLine(n.Function)
+	case *ast.SelectorExpression:
+		return startLine(n.Left)
+	case *ast.IndexExpression:
+		return startLine(n.Left)
+	case *ast.StructLiteral:
+		return n.Type.Token.Line
+	case *ast.StructAttributes:
+		if n.Token != nil {
+			return n.Token.Line
+		}
+		return n.Name.Line
+	}
+	if field := reflect.ValueOf(node).Elem().FieldByName("Token"); field.IsValid() {
+		if tok, ok := field.Interface().(token.Token); ok {
+			return tok.Line
+		}
+	}
+	return 0
+}
+
+// endLine returns the source line node ends on.
+func endLine(node ast.Node) int {
+	line := 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		end := startLine(n)
+		switch n := n.(type) {
+		case *ast.BlockStatement:
+			end = n.End.Line
+		case *ast.SwitchStatement:
+			end = n.End.Line
+		case *ast.HashLiteral:
+			end = n.End.Line
+		case *ast.StructStatement:
+			end = n.End.Line
+		case *ast.StructLiteral:
+			end = n.End.Line
+		case *ast.ArrayLiteral:
+			end = n.End.Line
+		case *ast.CallExpression:
+			end = n.End.Line
+		case *ast.MacroCall:
+			end = n.End.Line
+		}
+		if end > line {
+			line = end
+		}
+		return true
+	})
+	return line
+}
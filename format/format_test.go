@@ -0,0 +1,108 @@
+package format
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"declarations",
+			"package main\nimport \"fmt\"\ntype Name string\nstruct User (First string,Last string)\nvar users []*User\nlet names map(string, []Name) = load()\n",
+			"package main\nimport \"fmt\"\ntype Name string\nstruct User(First string, Last string)\nvar users []*User\nlet names map(string, []Name) = load()\n",
+		},
+		{
+			"aligned struct fields and tags",
+			"struct Post (\n\tTitle string `json:\"title\"`\n\tmut CreatedBy User `json:\"created_by\" db:\"author\"`\n\tDraft bool\n\n\tID int `json:\"id\"`\n)\n",
+			"struct Post(\n    Title         string `json:\"title\"`\n    mut CreatedBy User   `json:\"created_by\" db:\"author\"`\n    Draft         bool\n\n    ID int `json:\"id\"`\n)\n",
+		},
+		{
+			"functions",
+			"func (u *User) Name() string {\nreturn u.First + \" \" + u.Last\n}\nunsafe func read(p *int) int { return *p }\nfunc empty() {\n}\n",
+			"func (u *User) Name() string {\n    return u.First + \" \" + u.Last\n}\nunsafe func read(p *int) int { return *p }\nfunc empty() {}\n",
+		},
+		{
+			"blank lines",
+			"func main() {\n\n    var x = 1\n\n\n    x = (x + 2) * 3\n\n}\n\n\nfunc other() {\n    return [1, 2].map(func(x int) int { return -x })\n}\n",
+			"func main() {\n    var x = 1\n\n    x = (x + 2) * 3\n}\n\nfunc other() {\n    return [1, 2].map(func(x int) int { return -x })\n}\n",
+		},
+		{
+			"switch and if",
+			"func main() {\nswitch name { '=' => { a() }, \"b\" => { b() }, _ => { break } }\nif !ok { c() } else { d() }\n}\n",
+			"func main() {\n    switch name {\n        '=' => {\n            a()\n        },\n        \"b\" => {\n            b()\n        },\n        _ => {\n            break\n        }\n    }\n    if !ok {\n        c()\n    } else {\n        d()\n    }\n}\n",
+		},
+		{
+			"literals",
+			"var m = map(string, interface) {\n\"name\": 1,\n\"created_at\": \"today\"\n}\nvar u = User{Name: \"yuk\",\nAge: 1}\nvar xs = []int{1,2}\nvar ys = [\n1,\n2\n]\n",
+			"var m = map(string, interface) {\n    \"name\":       1,\n    \"created_at\": \"today\"\n}\nvar u = User{\n    Name: \"yuk\",\n    Age:  1,\n}\nvar xs = []int{1, 2}\nvar ys = [\n    1,\n    2\n]\n",
+		},
+		{
+			"macros",
+			"macro twice!(x) { x + x }\nfunc main() {\nassert!(twice!(1) == 2)\n}\n",
+			"macro twice!(x) {\n    x + x\n}\nfunc main() {\n    assert!(twice!(1) == 2)\n}\n",
+		},
+		{
+			"comments",
+			"// Package main.\npackage main // main\n\n// User is a user.\nstruct User(\n    // Name is the name.\n    Name string // full\n    Age int\n)\n\nfunc main() { // entry\n    // first\n    run() // now\n\n    // last\n}\n// end\n",
+			"// Package main.\npackage main // main\n\n// User is a user.\nstruct User(\n    // Name is the name.\n    Name string // full\n    Age  int\n)\n\nfunc main() { // entry\n    // first\n    run() // now\n\n    // last\n}\n// end\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, errs := Source("test.yuk", []byte(tt.input))
+		if len(errs) > 0 {
+			t.Errorf("%s: unexpected errors %v", tt.name, errs)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("%s: wrong output.\nexpected=\n%s\ngot=\n%s", tt.name, tt.expected, out)
+			continue
+		}
+		again, errs := Source("test.yuk", out)
+		if len(errs) > 0 || string(again) != string(out) {
+			t.Errorf("%s: formatting the output changed it.\nfirst=\n%s\nsecond=\n%s", tt.name, out, again)
+		}
+	}
+}
+
+func TestSource_Sample(t *testing.T) {
+	src, err := ioutil.ReadFile("../sample.yuk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, errs := Source("sample.yuk", src)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if !strings.Contains(string(out), "struct Post(\n    Title     string `json:\"input\"`\n    CreatedBy User   `json:\"created_by\"`\n") {
+		t.Errorf("fields of Post not aligned:\n%s", out)
+	}
+	again, _ := Source("sample.yuk", out)
+	if string(again) != string(out) {
+		t.Errorf("formatting the output changed it.\nfirst=\n%s\nsecond=\n%s", out, again)
+	}
+}
+
+func TestSource_Errors(t *testing.T) {
+	_, errs := Source("bad.yuk", []byte("var = 1"))
+	expected := "bad.yuk:1:5: expected next token to be 'IDENT', got '=' instead"
+	if len(errs) == 0 || errs[0].Error() != expected {
+		t.Errorf("expected=%q, got=%v", expected, errs)
+	}
+
+	// The parser gives up on an unterminated struct.
+	for _, src := range []string{"struct A(", "struct A(x int", "struct A(x int,"} {
+		_, errs := Source("bad.yuk", []byte(src))
+		expected := fmt.Sprintf("bad.yuk:1:%d: expected ')' to end the fields of the struct, got 'EOF' instead", len(src)+1)
+		if len(errs) == 0 || errs[0].Error() != expected {
+			t.Errorf("%q: expected=%q, got=%v", src, expected, errs)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Package highlight classifies the tokens of yuk source for syntax
+// highlighting, from their token types and the names bound by the
+// resolver, and renders highlighted source as HTML or for terminals.
+package highlight
+
+import (
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/resolver"
+	"github.com/ahmadrosid/yuk/token"
+	"github.com/ahmadrosid/yuk/types"
+)
+
+// Kind is the class of a token. The names of the kinds are the semantic
+// token types of LSP.
+type Kind int
+
+const (
+	// Plain is whitespace, punctuation and operators, and the names that
+	// could not be classified.
+	Plain Kind = iota
+	Keyword
+	Type
+	Function
+	Method
+	Macro
+	Parameter
+	Variable
+	Property
+	Namespace
+	String
+	Number
+	Comment
+)
+
+var kindNames = [...]string{
+	Plain:     "plain",
+	Keyword:   "keyword",
+	Type:      "type",
+	Function:  "function",
+	Method:    "method",
+	Macro:     "macro",
+	Parameter: "parameter",
+	Variable:  "variable",
+	Property:  "property",
+	Namespace: "namespace",
+	String:    "string",
+	Number:    "number",
+	Comment:   "comment",
+}
+
+func (k Kind) String() string { return kindNames[k] }
+
+// Kinds are the kinds other than Plain, in order.
+func Kinds() []Kind {
+	kinds := make([]Kind, 0, len(kindNames)-1)
+	for k := Keyword; int(k) < len(kindNames); k++ {
+		kinds = append(kinds, k)
+	}
+	return kinds
+}
+
+// Token is a classified token of the source. The texts of the tokens
+// returned by Tokens make up the whole source.
+type Token struct {
+	Kind Kind
+	// Text is the source of the token, with the quotes of strings, and
+	// Line and Column its position as the lexer counts them.
+	Text         string
+	Line, Column int
+	// Declaration is set for the names of declarations.
+	Declaration bool
+}
+
+// pos is the position of a name in the source.
+type pos struct{ line, column int }
+
+// Tokens classifies the tokens of src. The names are classified with the
+// program parsed from src and names, its names resolved as the file file,
+// when they are not nil; otherwise only the keywords, literals and
+// comments are.
+func Tokens(src string, program *ast.Program, names *resolver.Info, file string) []Token {
+	refs := map[pos]resolver.Ref{}
+	if names != nil {
+		for _, ref := range names.Refs {
+			if ref.File == file {
+				refs[pos{ref.Tok.Line, ref.Tok.Column}] = ref
+			}
+		}
+	}
+	members := map[pos]Kind{}
+	if program != nil {
+		memberKinds(program, refs, members)
+	}
+
+	var toks []token.Token
+	l := lexer.NewTrivia(src)
+	for tok := l.NextToken(); ; tok = l.NextToken() {
+		toks = append(toks, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	starts := lineStarts(src)
+	offset := func(tok token.Token) int {
+		if tok.Line > len(starts) {
+			return len(src)
+		}
+		o := starts[tok.Line-1] + tok.Column - 1
+		if o > len(src) {
+			return len(src)
+		}
+		return o
+	}
+
+	var out []Token
+	for i := 0; i < len(toks)-1; i++ {
+		tok := toks[i]
+		if tok.Type == token.BACKTICK {
+			// A struct tag is one string, up to the closing backtick.
+			if end, ok := closingBacktick(toks, i); ok {
+				out = append(out, Token{Kind: String, Text: src[offset(tok):offset(toks[end+1])], Line: tok.Line, Column: tok.Column})
+				i = end
+				continue
+			}
+		}
+		text := src[offset(tok):offset(toks[i+1])]
+		if text == "" {
+			continue
+		}
+		t := Token{Text: text, Line: tok.Line, Column: tok.Column}
+		switch {
+		case tok.Type == token.COMMENT:
+			t.Kind = Comment
+		case tok.Type == token.STRING_LIT || tok.Type == token.CHAR:
+			t.Kind = String
+		case tok.Type == token.INT:
+			t.Kind = Number
+		case tok.Type == token.STRING:
+			// string is lexed as a keyword.
+			t.Kind = Type
+		case tok.Type == token.IDENT:
+			p := pos{tok.Line, tok.Column}
+			if kind, ok := members[p]; ok {
+				t.Kind = kind
+			} else if ref, ok := refs[p]; ok {
+				t.Kind = symbolKind(ref.Symbol)
+				t.Declaration = ref.Symbol.Kind != resolver.Universe && ref.Symbol.File == file &&
+					ref.Symbol.Decl.Line == tok.Line && ref.Symbol.Decl.Column == tok.Column
+			} else if i+2 < len(toks) && toks[i+1].Type == token.BANG && toks[i+2].Type == token.LPAREN {
+				t.Kind = Macro
+			}
+		case token.IsKeyword(tok.Literal):
+			t.Kind = Keyword
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// closingBacktick returns the index of the backtick closing the one at
+// toks[i], on the same line.
+func closingBacktick(toks []token.Token, i int) (int, bool) {
+	for j := i + 1; j < len(toks)-1 && toks[j].Line == toks[i].Line; j++ {
+		if toks[j].Type == token.BACKTICK {
+			return j, true
+		}
+	}
+	return 0, false
+}
+
+// symbolKind returns the kind of the names bound to sym.
+func symbolKind(sym *resolver.Symbol) Kind {
+	switch sym.Kind {
+	case resolver.Param:
+		return Parameter
+	case resolver.Func:
+		return Function
+	case resolver.Method:
+		return Method
+	case resolver.Type:
+		return Type
+	case resolver.Package:
+		return Namespace
+	case resolver.Universe:
+		obj := types.Universe.Lookup(sym.Name)
+		switch {
+		case obj == nil:
+		case obj.Kind == types.TypeObject:
+			return Type
+		case obj.Kind == types.BuiltinObject:
+			return Function
+		default:
+			return Keyword
+		}
+	}
+	return Variable
+}
+
+// memberKinds classifies the names the resolver does not bind: the
+// package name, the fields of struct declarations and literals, and the
+// members selected from values and packages.
+func memberKinds(program *ast.Program, refs map[pos]resolver.Ref, members map[pos]Kind) {
+	set := func(tok token.Token, kind Kind) {
+		if _, ok := members[pos{tok.Line, tok.Column}]; !ok {
+			members[pos{tok.Line, tok.Column}] = kind
+		}
+	}
+	if ident := program.Package(); ident != nil {
+		set(ident.Token, Namespace)
+	}
+	// member returns the kind of the name selected by sel, a call of it
+	// when called is set.
+	member := func(sel *ast.SelectorExpression, called bool) Kind {
+		pkg := false
+		if left, ok := sel.Left.(*ast.Identifier); ok {
+			ref, ok := refs[pos{left.Token.Line, left.Token.Column}]
+			pkg = ok && ref.Symbol.Kind == resolver.Package
+		}
+		switch {
+		case pkg && called:
+			return Function
+		case pkg:
+			return Variable
+		case called:
+			return Method
+		}
+		return Property
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.StructStatement:
+			for _, attr := range n.Attributes {
+				if attr != nil {
+					set(attr.Name, Property)
+				}
+			}
+		case *ast.StructLiteral:
+			for _, field := range n.Fields {
+				if field.Name != nil {
+					set(field.Name.Token, Property)
+				}
+			}
+		case *ast.CallExpression:
+			if sel, ok := n.Function.(*ast.SelectorExpression); ok && sel.Name != nil {
+				set(sel.Name.Token, member(sel, true))
+			}
+		case *ast.SelectorExpression:
+			if n.Name != nil {
+				set(n.Name.Token, member(n, false))
+			}
+		}
+		return true
+	})
+}
+
+func lineStarts(src string) []int {
+	starts := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
@@ -0,0 +1,159 @@
+package highlight
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+	"github.com/ahmadrosid/yuk/resolver"
+)
+
+const input = `package main
+
+import "strings"
+
+// User is a user.
+struct User(Name string, Age int)
+
+func (u User) Greet(greeting string) string {
+    return greeting + " " + strings.ToUpper(u.Name) /* loud */
+}
+
+func main() {
+    var u = User{Name: "yuk", Age: 3}
+    assert!(u.Age == 3)
+    println(u.Greet("hi"), len(u.Name))
+}
+`
+
+func tokens(t *testing.T, src string) []Token {
+	program := parser.New(lexer.New(src)).ParseProgram()
+	names, _ := resolver.Resolve(program)
+	return Tokens(src, program, names, "")
+}
+
+func TestTokens(t *testing.T) {
+	toks := tokens(t, input)
+	var text strings.Builder
+	for _, tok := range toks {
+		text.WriteString(tok.Text)
+	}
+	if text.String() != input {
+		t.Fatalf("the tokens do not make up the source, got=%q", text.String())
+	}
+
+	type kind struct {
+		text        string
+		kind        Kind
+		declaration bool
+	}
+	var got []kind
+	for _, tok := range toks {
+		if tok.Kind != Plain {
+			got = append(got, kind{tok.Text, tok.Kind, tok.Declaration})
+		}
+	}
+	expected := []kind{
+		{"package", Keyword, false},
+		{"main", Namespace, false},
+		{"import", Keyword, false},
+		{`"strings"`, String, false},
+		{"// User is a user.", Comment, false},
+		{"struct", Keyword, false},
+		{"User", Type, true},
+		{"Name", Property, false},
+		{"string", Type, false},
+		{"Age", Property, false},
+		{"int", Type, false},
+		{"func", Keyword, false},
+		{"u", Parameter, true},
+		{"User", Type, false},
+		{"Greet", Method, true},
+		{"greeting", Parameter, true},
+		{"string", Type, false},
+		{"string", Type, false},
+		{"return", Keyword, false},
+		{"greeting", Parameter, false},
+		{`" "`, String, false},
+		{"strings", Namespace, false},
+		{"ToUpper", Function, false},
+		{"u", Parameter, false},
+		{"Name", Property, false},
+		{"/* loud */", Comment, false},
+		{"func", Keyword, false},
+		{"main", Function, true},
+		{"var", Keyword, false},
+		{"u", Variable, true},
+		{"User", Type, false},
+		{"Name", Property, false},
+		{`"yuk"`, String, false},
+		{"Age", Property, false},
+		{"3", Number, false},
+		{"assert", Macro, false},
+		{"u", Variable, false},
+		{"Age", Property, false},
+		{"3", Number, false},
+		{"println", Function, false},
+		{"u", Variable, false},
+		{"Greet", Method, false},
+		{`"hi"`, String, false},
+		{"len", Function, false},
+		{"u", Variable, false},
+		{"Name", Property, false},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d tokens, got=%v", len(expected), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("tokens[%d] - expected=%v, got=%v", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestTokens_StructTag(t *testing.T) {
+	src := "struct Post(Title string `json:\"title\" db:\"title\"`, Body string)"
+	var strs []string
+	for _, tok := range tokens(t, src) {
+		if tok.Kind == String {
+			strs = append(strs, tok.Text)
+		}
+	}
+	expected := "`json:\"title\" db:\"title\"`"
+	if len(strs) != 1 || strs[0] != expected {
+		t.Errorf("expected the tag as one string %q, got=%q", expected, strs)
+	}
+}
+
+func TestTokensWithoutNames(t *testing.T) {
+	for _, tok := range Tokens("func f() { x }", nil, nil, "") {
+		if tok.Text == "x" && tok.Kind != Plain {
+			t.Errorf("expected an unresolved name to be plain, got=%v", tok.Kind)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	toks := tokens(t, "var s = \"<a>\" // c\n")
+	var b bytes.Buffer
+	if err := HTML(&b, toks); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<pre class="yuk"><span class="keyword" style="color:#a626a4">var</span> s = ` +
+		`<span class="string" style="color:#50a14f">&#34;&lt;a&gt;&#34;</span> ` +
+		`<span class="comment" style="color:#a0a1a7;font-style:italic">// c</span>` + "\n</pre>\n"
+	if b.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, b.String())
+	}
+
+	b.Reset()
+	if err := ANSI(&b, tokens(t, "/* a\nb */ 1")); err != nil {
+		t.Fatal(err)
+	}
+	expected = "\x1b[90m/* a\x1b[0m\n\x1b[90mb */\x1b[0m \x1b[33m1\x1b[0m"
+	if b.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, b.String())
+	}
+}
@@ -0,0 +1,83 @@
+package highlight
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"strings"
+)
+
+// colors are the colors of the kinds in HTML, and ansi their SGR
+// parameters in terminals. The kinds without one are not colored.
+var colors = map[Kind]string{
+	Keyword:   "#a626a4",
+	Type:      "#c18401",
+	Function:  "#4078f2",
+	Method:    "#4078f2",
+	Macro:     "#0184bc",
+	Parameter: "#986801",
+	Property:  "#e45649",
+	Namespace: "#0184bc",
+	String:    "#50a14f",
+	Number:    "#986801",
+	Comment:   "#a0a1a7",
+}
+
+var ansi = map[Kind]string{
+	Keyword:   "35",
+	Type:      "33",
+	Function:  "34",
+	Method:    "34",
+	Macro:     "36",
+	Parameter: "3",
+	Property:  "31",
+	Namespace: "36",
+	String:    "32",
+	Number:    "33",
+	Comment:   "90",
+}
+
+// HTML writes the tokens as a <pre> element, each colored token in a
+// <span> of the class of its kind, styled inline so the element can be
+// pasted anywhere.
+func HTML(w io.Writer, toks []Token) error {
+	b := bufio.NewWriter(w)
+	b.WriteString(`<pre class="yuk">`)
+	for _, tok := range toks {
+		text := html.EscapeString(tok.Text)
+		color, ok := colors[tok.Kind]
+		if !ok {
+			b.WriteString(text)
+			continue
+		}
+		b.WriteString(`<span class="` + tok.Kind.String() + `" style="color:` + color)
+		if tok.Kind == Comment || tok.Kind == Parameter {
+			b.WriteString(";font-style:italic")
+		}
+		b.WriteString(`">` + text + `</span>`)
+	}
+	b.WriteString("</pre>\n")
+	return b.Flush()
+}
+
+// ANSI writes the tokens colored with ANSI escape sequences. Tokens
+// spanning lines are colored on each line, so pagers keep the colors.
+func ANSI(w io.Writer, toks []Token) error {
+	b := bufio.NewWriter(w)
+	for _, tok := range toks {
+		code, ok := ansi[tok.Kind]
+		if !ok {
+			b.WriteString(tok.Text)
+			continue
+		}
+		for i, line := range strings.Split(tok.Text, "\n") {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			if line != "" {
+				b.WriteString("\x1b[" + code + "m" + line + "\x1b[0m")
+			}
+		}
+	}
+	return b.Flush()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ahmadrosid/yuk/build"
+	"github.com/ahmadrosid/yuk/compiler"
+	"github.com/ahmadrosid/yuk/highlight"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+	"github.com/ahmadrosid/yuk/resolver"
+)
+
+// highlightCommand prints the .yuk files given as arguments, or the
+// standard input, with syntax highlighting for terminals or as HTML.
+func highlightCommand(args []string) {
+	flags := flag.NewFlagSet("highlight", flag.ExitOnError)
+	flags.Usage = func() {
+		log.Print("usage: yuk highlight [-html | -ansi] [file ...]")
+		flags.PrintDefaults()
+	}
+	asHTML := flags.Bool("html", false, "print a <pre> element with colored spans")
+	asANSI := flags.Bool("ansi", false, "print with ANSI colors for terminals, the default")
+	flags.Parse(args)
+	if *asHTML && *asANSI {
+		flags.Usage()
+		log.Fatal("yuk highlight: -html and -ansi are exclusive")
+	}
+	render := highlight.ANSI
+	if *asHTML {
+		render = highlight.HTML
+	}
+
+	if flags.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		var names *resolver.Info
+		if len(p.Errors()) == 0 {
+			names, _ = resolver.Resolve(program)
+		}
+		if err := render(os.Stdout, highlight.Tokens(string(src), program, names, "")); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	for _, path := range flags.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		names, file := packageNames(path)
+		if names == nil && len(p.Errors()) == 0 {
+			names, _ = resolver.Resolve(program)
+			file = ""
+		}
+		if err := render(os.Stdout, highlight.Tokens(string(src), program, names, file)); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// packageNames returns the names of the package of the file at path,
+// resolved with the other files of its directory, and the name of the file
+// in them. They are nil when the package does not parse.
+func packageNames(path string) (*resolver.Info, string) {
+	path = filepath.Clean(path)
+	loaded, err := build.Load(filepath.Dir(path))
+	if err != nil {
+		return nil, ""
+	}
+	pkg := &compiler.Package{Dir: loaded.Dir, GoFiles: loaded.GoFiles}
+	found := false
+	for _, file := range loaded.Files {
+		pkg.Files = append(pkg.Files, &compiler.File{Path: file})
+		found = found || file == path
+	}
+	if !found {
+		return nil, ""
+	}
+	pkg.Generate()
+	return pkg.Names, path
+}
@@ -1,24 +1,86 @@
 package lexer
 
-import "github.com/ahmadrosid/yuk/token"
+import (
+	"strings"
+
+	"github.com/ahmadrosid/yuk/token"
+)
 
 type Lexer struct {
 	input        string
 	position     int
 	readPosition int
 	ch           byte
+	line         int
+	column       int
+	// comments are the comments skipped so far.
+	comments []token.Token
+	errors   []*token.Error
+	// trivia is set to return the whitespace and comments as tokens.
+	trivia bool
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// NewTrivia returns a lexer returning the whitespace and the comments as
+// WHITESPACE and COMMENT tokens too, so every byte of the input is part of
+// a token, for tools like highlighters.
+func NewTrivia(input string) *Lexer {
+	l := New(input)
+	l.trivia = true
+	return l
+}
+
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	if l.trivia {
+		return l.nextTrivia()
+	}
 	l.skipWhitespace()
+	for l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+		if l.peekChar() == '/' {
+			l.skipComment()
+		} else {
+			l.skipBlockComment()
+		}
+		l.skipWhitespace()
+	}
 
+	line, column := l.line, l.column
+	tok := l.readToken()
+	tok.Line = line
+	tok.Column = column
+	return tok
+}
+
+// nextTrivia returns the next token, whitespace or comment.
+func (l *Lexer) nextTrivia() token.Token {
+	line, column := l.line, l.column
+	var tok token.Token
+	switch {
+	case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+		position := l.position
+		l.skipWhitespace()
+		tok = token.Token{Type: token.WHITESPACE, Literal: l.input[position:l.position]}
+	case l.ch == '/' && l.peekChar() == '/':
+		l.skipComment()
+		tok = l.comments[len(l.comments)-1]
+	case l.ch == '/' && l.peekChar() == '*':
+		l.skipBlockComment()
+		tok = l.comments[len(l.comments)-1]
+	default:
+		tok = l.readToken()
+	}
+	tok.Line = line
+	tok.Column = column
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -45,14 +107,11 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
-		if l.peekChar() == '/' {
-			l.skipComment()
-			return l.NextToken()
-		} else {
-			tok = newToken(token.SLASH, l.ch)
-		}
+		tok = newToken(token.SLASH, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
+	case '&':
+		tok = newToken(token.AMPERSAND, l.ch)
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
@@ -114,16 +173,59 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+// skipComment skips a // comment, keeping it for Comments.
 func (l *Lexer) skipComment() {
+	tok := token.Token{Type: token.COMMENT, Line: l.line, Column: l.column}
+	position := l.position
 	for l.ch != '\n' {
 		l.readChar()
 		if l.ch == 0 {
 			break
 		}
 	}
+	tok.Literal = strings.TrimRight(l.input[position:l.position], "\r")
+	l.comments = append(l.comments, tok)
+}
+
+// skipBlockComment skips a /* */ comment, keeping it for Comments.
+func (l *Lexer) skipBlockComment() {
+	tok := token.Token{Type: token.COMMENT, Line: l.line, Column: l.column}
+	position := l.position
+	l.readChar()
+	l.readChar()
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			l.errors = append(l.errors, &token.Error{Line: tok.Line, Column: tok.Column, Msg: "comment not terminated"})
+			break
+		}
+		l.readChar()
+	}
+	if l.ch != 0 {
+		l.readChar()
+		l.readChar()
+	}
+	tok.Literal = l.input[position:l.position]
+	l.comments = append(l.comments, tok)
+}
+
+// Comments returns the comments read so far, in source order, with their
+// markers. They are not tokens of the program, NextToken skips them.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// Errors returns the errors found so far, like a comment not terminated.
+func (l *Lexer) Errors() []*token.Error {
+	return l.errors
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -143,7 +245,7 @@ func (l *Lexer) peekChar() byte {
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
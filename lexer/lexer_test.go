@@ -12,6 +12,7 @@ type TokenType string
 struct Token(Type TypeToken, Literal string)
 func main() {
 	var five = 5
+	var n2 int64
 }`
 	tests := []struct {
 		expectedType    token.TokenType
@@ -40,6 +41,9 @@ func main() {
 		{token.IDENT, "five"},
 		{token.ASSIGN, "="},
 		{token.INT, "5"},
+		{token.VAR, "var"},
+		{token.IDENT, "n2"},
+		{token.IDENT, "int64"},
 		{token.RBRACE, "}"},
 	}
 
@@ -56,3 +60,86 @@ func main() {
 		}
 	}
 }
+
+func TestComments(t *testing.T) {
+	input := "// doc\nfunc main() { // body\n\tx // last\n}\n"
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.COMMENT {
+			t.Fatalf("comment %q returned as a token", tok.Literal)
+		}
+	}
+
+	expected := []token.Token{
+		{Type: token.COMMENT, Literal: "// doc", Line: 1, Column: 1},
+		{Type: token.COMMENT, Literal: "// body", Line: 2, Column: 15},
+		{Type: token.COMMENT, Literal: "// last", Line: 3, Column: 4},
+	}
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("expected %d comments, got=%v", len(expected), comments)
+	}
+	for i, c := range comments {
+		if c != expected[i] {
+			t.Errorf("comments[%d]: expected=%+v, got=%+v", i, expected[i], c)
+		}
+	}
+}
+
+func TestBlockComments(t *testing.T) {
+	input := "/* doc\n   more */\nx /* inline */ y\n/* never closed"
+	l := New(input)
+	var idents []token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		idents = append(idents, tok)
+	}
+	if len(idents) != 2 || idents[0].Line != 3 || idents[1].Column != 16 {
+		t.Fatalf("wrong tokens around the comments, got=%+v", idents)
+	}
+
+	expected := []token.Token{
+		{Type: token.COMMENT, Literal: "/* doc\n   more */", Line: 1, Column: 1},
+		{Type: token.COMMENT, Literal: "/* inline */", Line: 3, Column: 3},
+		{Type: token.COMMENT, Literal: "/* never closed", Line: 4, Column: 1},
+	}
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("expected %d comments, got=%v", len(expected), comments)
+	}
+	for i, c := range comments {
+		if c != expected[i] {
+			t.Errorf("comments[%d]: expected=%+v, got=%+v", i, expected[i], c)
+		}
+	}
+	if errs := l.Errors(); len(errs) != 1 || errs[0].Error() != "4:1: comment not terminated" {
+		t.Errorf("wrong errors, got=%v", errs)
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	input := "var x = 1 // one\n\t/* two */x"
+	expected := []token.Token{
+		{Type: token.VAR, Literal: "var", Line: 1, Column: 1},
+		{Type: token.WHITESPACE, Literal: " ", Line: 1, Column: 4},
+		{Type: token.IDENT, Literal: "x", Line: 1, Column: 5},
+		{Type: token.WHITESPACE, Literal: " ", Line: 1, Column: 6},
+		{Type: token.ASSIGN, Literal: "=", Line: 1, Column: 7},
+		{Type: token.WHITESPACE, Literal: " ", Line: 1, Column: 8},
+		{Type: token.INT, Literal: "1", Line: 1, Column: 9},
+		{Type: token.WHITESPACE, Literal: " ", Line: 1, Column: 10},
+		{Type: token.COMMENT, Literal: "// one", Line: 1, Column: 11},
+		{Type: token.WHITESPACE, Literal: "\n\t", Line: 1, Column: 17},
+		{Type: token.COMMENT, Literal: "/* two */", Line: 2, Column: 2},
+		{Type: token.IDENT, Literal: "x", Line: 2, Column: 11},
+		{Type: token.EOF, Literal: "", Line: 2, Column: 12},
+	}
+	l := NewTrivia(input)
+	for i, tt := range expected {
+		if tok := l.NextToken(); tok != tt {
+			t.Fatalf("tests[%d] - expected=%+v, got=%+v", i, tt, tok)
+		}
+	}
+	if len(l.Comments()) != 2 {
+		t.Errorf("expected the comments to be kept, got=%v", l.Comments())
+	}
+}
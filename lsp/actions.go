@@ -0,0 +1,312 @@
+package lsp
+
+import (
+	"fmt"
+	goast "go/ast"
+	gobuild "go/build"
+	goparser "go/parser"
+	gotoken "go/token"
+	gotypes "go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/format"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+)
+
+// codeAction offers the quick fixes for the diagnostics of the client in
+// the range, adding the import of an undefined package, and the rewrite
+// of a Go struct declaration under the cursor to the struct shorthand.
+func (s *Server) codeAction(p CodeActionParams) ([]CodeAction, error) {
+	path, err := uriPath(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	actions := []CodeAction{}
+	for _, d := range p.Context.Diagnostics {
+		actions = append(actions, s.importActions(path, d)...)
+	}
+	if action := s.structAction(path, p.Range); action != nil {
+		actions = append(actions, *action)
+	}
+	return actions, nil
+}
+
+// importActions offers to import the packages named like the undefined
+// name of d when it is selected from, the ones imported by the other
+// files of the package first, then the standard library ones.
+func (s *Server) importActions(file string, d Diagnostic) []CodeAction {
+	name := strings.TrimPrefix(d.Message, "undefined: ")
+	if name == d.Message || !isIdentifier(name) {
+		return nil
+	}
+	src := s.source(file)
+	line, column := src.offset(d.Range.Start)
+	if rest := src.line(line)[column-1:]; !strings.HasPrefix(rest, name+".") {
+		return nil
+	}
+
+	var paths []string
+	if pkg := s.pkgs[filepath.Dir(file)]; pkg != nil {
+		for _, f := range pkg.Files {
+			for _, imp := range imports(f.Program) {
+				if path.Base(imp) == name && !contains(paths, imp) {
+					paths = append(paths, imp)
+				}
+			}
+		}
+	}
+	for _, imp := range stdPackages()[name] {
+		if !contains(paths, imp) {
+			paths = append(paths, imp)
+		}
+	}
+
+	var actions []CodeAction
+	for _, imp := range paths {
+		actions = append(actions, CodeAction{
+			Title:       fmt.Sprintf("Add import %q", imp),
+			Kind:        CodeActionQuickFix,
+			Diagnostics: []Diagnostic{d},
+			Edit:        &WorkspaceEdit{Changes: map[string][]TextEdit{pathURI(file): {importEdit(s.text(file), imp)}}},
+		})
+	}
+	return actions
+}
+
+// imports returns the paths imported by program.
+func imports(program *ast.Program) []string {
+	var paths []string
+	if program == nil {
+		return nil
+	}
+	for _, stmt := range program.Statements {
+		if imp, ok := stmt.(*ast.ImportStatement); ok {
+			if lit, ok := imp.PackageName.(*ast.StringLiteral); ok {
+				paths = append(paths, lit.Value)
+			}
+		}
+	}
+	return paths
+}
+
+// importEdit inserts the import of path after the last import of text, or
+// after its package clause.
+func importEdit(text, path string) TextEdit {
+	program := parser.New(lexer.New(text)).ParseProgram()
+	line, insert := 0, fmt.Sprintf("\nimport %q\n", path)
+	for _, stmt := range program.Statements {
+		if imp, ok := stmt.(*ast.ImportStatement); ok {
+			if lit, ok := imp.PackageName.(*ast.StringLiteral); ok {
+				line, insert = lit.Token.Line, fmt.Sprintf("import %q\n", path)
+			}
+		}
+	}
+	if ident := program.Package(); ident != nil && line == 0 {
+		line = ident.Token.Line
+	}
+	pos := Position{Line: line}
+	return TextEdit{Range: Range{Start: pos, End: pos}, NewText: insert}
+}
+
+var std struct {
+	once     sync.Once
+	packages map[string][]string
+}
+
+// stdPackages returns the import paths of the standard library by package
+// name, the shortest first. The library is listed once, on first use.
+func stdPackages() map[string][]string {
+	std.once.Do(func() {
+		std.packages = map[string][]string{}
+		root := filepath.Join(gobuild.Default.GOROOT, "src")
+		filepath.Walk(root, func(dir string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			switch info.Name() {
+			case "internal", "vendor", "testdata", "cmd":
+				return filepath.SkipDir
+			}
+			matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+			for _, m := range matches {
+				if !strings.HasSuffix(m, "_test.go") {
+					rel, _ := filepath.Rel(root, dir)
+					rel = filepath.ToSlash(rel)
+					std.packages[path.Base(rel)] = append(std.packages[path.Base(rel)], rel)
+					break
+				}
+			}
+			return nil
+		})
+		for _, paths := range std.packages {
+			sort.Slice(paths, func(i, j int) bool {
+				if len(paths[i]) != len(paths[j]) {
+					return len(paths[i]) < len(paths[j])
+				}
+				return paths[i] < paths[j]
+			})
+		}
+	})
+	return std.packages
+}
+
+// structAction offers to rewrite the Go struct type declaration, which
+// yuk does not parse, around the start of r as a yuk struct. Structs with
+// embedded fields or field types the shorthand cannot spell are left
+// alone.
+func (s *Server) structAction(file string, r Range) *CodeAction {
+	src := s.source(file)
+	start, end, decl := goStruct(src.lines, r.Start.Line)
+	if decl == "" {
+		return nil
+	}
+	out := yukStruct(decl)
+	if out == "" {
+		return nil
+	}
+	first := src.lines[start]
+	indent := first[:len(first)-len(strings.TrimLeft(first, " \t"))]
+	out = strings.Replace(out, "\n", "\n"+indent, -1)
+	edit := TextEdit{
+		Range: Range{
+			Start: Position{Line: start, Character: utf16Len(indent)},
+			End:   Position{Line: end, Character: utf16Len(src.lines[end])},
+		},
+		NewText: out,
+	}
+	return &CodeAction{
+		Title: "Convert to struct shorthand",
+		Kind:  CodeActionRewrite,
+		Edit:  &WorkspaceEdit{Changes: map[string][]TextEdit{pathURI(file): {edit}}},
+	}
+}
+
+// goStruct returns the zero based first and last lines of the Go struct
+// type declaration spanning line, and its text.
+func goStruct(lines []string, line int) (start, end int, decl string) {
+	for start = line; start >= 0 && start < len(lines); start-- {
+		if goStructStart.MatchString(lines[start]) {
+			break
+		}
+	}
+	if start < 0 || start >= len(lines) {
+		return 0, 0, ""
+	}
+	depth := 0
+	for end = start; end < len(lines); end++ {
+		depth += strings.Count(lines[end], "{") - strings.Count(lines[end], "}")
+		if depth == 0 {
+			break
+		}
+	}
+	if end == len(lines) || end < line {
+		return 0, 0, ""
+	}
+	return start, end, strings.Join(lines[start:end+1], "\n")
+}
+
+var goStructStart = regexp.MustCompile(`^\s*type\s+\w+\s+struct\s*\{`)
+
+// yukStruct returns the yuk spelling of the Go struct type declaration
+// decl, formatted by yuk fmt, or an empty string when it has none.
+func yukStruct(decl string) string {
+	fset := gotoken.NewFileSet()
+	f, err := goparser.ParseFile(fset, "", "package p\n\n"+decl, goparser.ParseComments)
+	if err != nil || len(f.Decls) != 1 {
+		return ""
+	}
+	gen, ok := f.Decls[0].(*goast.GenDecl)
+	if !ok || len(gen.Specs) != 1 {
+		return ""
+	}
+	spec := gen.Specs[0].(*goast.TypeSpec)
+	st, ok := spec.Type.(*goast.StructType)
+	if !ok {
+		return ""
+	}
+
+	type field struct{ name, typ, tag, doc, comment string }
+	var fields []field
+	multiline := false
+	for _, fd := range st.Fields.List {
+		if len(fd.Names) == 0 {
+			return ""
+		}
+		for _, name := range fd.Names {
+			fl := field{name: name.Name, typ: gotypes.ExprString(fd.Type)}
+			if fd.Tag != nil {
+				fl.tag = fd.Tag.Value
+			}
+			if fd.Doc != nil {
+				for _, c := range fd.Doc.List {
+					fl.doc += c.Text + "\n"
+				}
+			}
+			if fd.Comment != nil && len(fd.Comment.List) == 1 {
+				fl.comment = fd.Comment.List[0].Text
+			}
+			multiline = multiline || fl.tag != "" || fl.doc != "" || fl.comment != ""
+			fields = append(fields, fl)
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("struct " + spec.Name.Name + "(")
+	for i, fl := range fields {
+		if !multiline {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			out.WriteString(fl.name + " " + fl.typ)
+			continue
+		}
+		out.WriteString("\n" + fl.doc + fl.name + " " + fl.typ)
+		if fl.tag != "" {
+			out.WriteString(" " + fl.tag)
+		}
+		if fl.comment != "" {
+			out.WriteString(" " + fl.comment)
+		}
+	}
+	if multiline {
+		out.WriteString("\n")
+	}
+	out.WriteString(")")
+
+	// The formatter both aligns the fields and checks the shorthand parses
+	// to the same fields.
+	const header = "package p\n\n"
+	formatted, errs := format.Source("", []byte(header+out.String()))
+	if len(errs) > 0 {
+		return ""
+	}
+	program := parser.New(lexer.New(string(formatted))).ParseProgram()
+	if len(program.Statements) != 2 {
+		return ""
+	}
+	es, ok := program.Statements[1].(*ast.ExpressionStatement)
+	if !ok {
+		return ""
+	}
+	yst, ok := es.Expression.(*ast.StructStatement)
+	if !ok || len(yst.Attributes) != len(fields) {
+		return ""
+	}
+	for i, attr := range yst.Attributes {
+		if attr.Name.Literal != fields[i].name || attr.Type.Literal != fields[i].typ {
+			return ""
+		}
+		if fields[i].tag != "" && !strings.Contains(string(formatted), fields[i].tag) {
+			return ""
+		}
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(string(formatted), header), "\n")
+}
@@ -0,0 +1,163 @@
+package lsp
+
+import (
+	"go/token"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/compiler"
+	"github.com/ahmadrosid/yuk/resolver"
+	yuktoken "github.com/ahmadrosid/yuk/token"
+	"github.com/ahmadrosid/yuk/types"
+)
+
+// completion lists the names that can be written at the cursor and start
+// like the word before it: after `x.` the members of the package x or the
+// fields and methods of the type of x, and otherwise the keywords and the
+// names in scope.
+func (s *Server) completion(p CompletionParams) (*CompletionList, error) {
+	pkg, f, line, column, err := s.locate(p.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+	path, _ := uriPath(p.TextDocument.URI)
+	word, left, selector := s.source(path).wordBefore(line, column)
+
+	var items []CompletionItem
+	if selector {
+		if f != nil && pkg.Info != nil {
+			items = memberItems(pkg, scopeAt(pkg, f, line, column).Lookup(left))
+		}
+	} else {
+		for _, keyword := range yuktoken.Keywords() {
+			items = append(items, CompletionItem{Label: keyword, Kind: CompletionKeyword})
+		}
+		if f != nil && pkg.Info != nil {
+			items = append(items, scopeItems(pkg, scopeAt(pkg, f, line, column), line, column)...)
+		}
+	}
+
+	list := &CompletionList{Items: []CompletionItem{}}
+	for _, item := range items {
+		if strings.HasPrefix(item.Label, word) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, nil
+}
+
+// scopeAt returns the innermost scope of f at line:column.
+func scopeAt(pkg *compiler.Package, f *compiler.File, line, column int) *resolver.Scope {
+	for _, scope := range pkg.Names.Files {
+		if scope.File == f.Path {
+			return scope.Innermost(line, column)
+		}
+	}
+	return pkg.Names.Package
+}
+
+// scopeItems lists the names visible in scope at line:column, the inner
+// ones first. The local names declared after line:column are left out.
+func scopeItems(pkg *compiler.Package, scope *resolver.Scope, line, column int) []CompletionItem {
+	var items []CompletionItem
+	seen := map[string]bool{}
+	for ; scope != nil; scope = scope.Parent {
+		local := scope.Kind == resolver.FuncScope || scope.Kind == resolver.BlockScope || scope.Kind == resolver.CaseScope
+		for _, sym := range scope.Symbols {
+			if seen[sym.Name] {
+				continue
+			}
+			if local && (sym.Decl.Line > line || sym.Decl.Line == line && sym.Decl.Column >= column) {
+				continue
+			}
+			seen[sym.Name] = true
+			item := CompletionItem{Label: sym.Name}
+			var obj *types.Object
+			switch sym.Kind {
+			case resolver.Package:
+				item.Kind, item.Detail = CompletionModule, sym.Decl.Literal
+			case resolver.Universe:
+				obj = types.Universe.Lookup(sym.Name)
+			default:
+				obj = object(pkg, sym)
+			}
+			if obj != nil {
+				item.Kind, item.Detail = objectItem(obj)
+			} else if item.Kind == 0 {
+				item.Kind = CompletionVariable
+			}
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// memberItems lists the members of the package sym is the import of, or
+// the fields and methods of the type of the variable sym.
+func memberItems(pkg *compiler.Package, sym *resolver.Symbol) []CompletionItem {
+	if sym == nil {
+		return nil
+	}
+	var items []CompletionItem
+	if sym.Kind == resolver.Package {
+		importer := pkg.Importer
+		if importer == nil {
+			importer = types.GoImporter()
+		}
+		imported, err := importer.Import(sym.Decl.Literal)
+		if err != nil {
+			return nil
+		}
+		for _, name := range imported.Scope.Names() {
+			if !token.IsExported(name) {
+				continue
+			}
+			kind, detail := objectItem(imported.Scope.Lookup(name))
+			items = append(items, CompletionItem{Label: name, Kind: kind, Detail: detail})
+		}
+		return items
+	}
+
+	obj := object(pkg, sym)
+	if obj == nil || obj.Kind != types.VarObject {
+		return nil
+	}
+	typ := obj.Type
+	if p, ok := typ.Underlying().(*types.Pointer); ok {
+		typ = p.Elem
+	}
+	if st, ok := typ.Underlying().(*types.Struct); ok {
+		for _, field := range st.Fields {
+			items = append(items, CompletionItem{Label: field.Name, Kind: CompletionField, Detail: field.Type.String()})
+		}
+	}
+	if named, ok := typ.(*types.Named); ok {
+		for _, m := range named.Methods {
+			items = append(items, CompletionItem{Label: m.Name, Kind: CompletionMethod, Detail: m.Sig.String()})
+		}
+	}
+	return items
+}
+
+// objectItem returns the completion kind and detail of obj.
+func objectItem(obj *types.Object) (int, string) {
+	switch obj.Kind {
+	case types.FuncObject, types.BuiltinObject:
+		detail := ""
+		if obj.Type != nil {
+			detail = obj.Type.String()
+		}
+		return CompletionFunction, detail
+	case types.TypeObject:
+		if _, ok := obj.Type.Underlying().(*types.Struct); ok {
+			return CompletionStruct, "struct"
+		}
+		return CompletionClass, obj.Type.Underlying().String()
+	case types.ConstObject:
+		return CompletionConstant, obj.Type.String()
+	case types.PackageObject:
+		return CompletionModule, ""
+	case types.NilObject:
+		return CompletionConstant, ""
+	}
+	return CompletionVariable, obj.Type.String()
+}
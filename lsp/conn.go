@@ -0,0 +1,101 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// conn reads and writes the messages of a JSON-RPC connection, each
+// preceded by a Content-Length header as LSP frames them.
+type conn struct {
+	r *textproto.Reader
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: textproto.NewReader(bufio.NewReader(r)), w: w}
+}
+
+// read returns the content of the next message.
+func (c *conn) read() ([]byte, error) {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(c.r.R, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// write sends v as a message. It can be called from any goroutine.
+func (c *conn) write(v interface{}) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(content)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(content)
+	return err
+}
+
+// Serve runs a server reading requests from r and writing the responses and
+// notifications to w, until the client sends exit or r is closed. Requests
+// are handled one at a time, in order.
+func Serve(r io.Reader, w io.Writer) error {
+	c := newConn(r, w)
+	s := NewServer(func(method string, params interface{}) {
+		c.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+	})
+	for {
+		content, err := c.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(content, &req); err != nil {
+			c.write(&errorResponse{JSONRPC: "2.0", Error: &Error{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		result, err := s.Handle(req.Method, req.Params)
+		if req.ID == nil {
+			// Notifications have no response, not even an error.
+			continue
+		}
+		if err != nil {
+			rpcErr, ok := err.(*Error)
+			if !ok {
+				rpcErr = &Error{Code: codeInternalError, Message: err.Error()}
+			}
+			err = c.write(&errorResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcErr})
+		} else {
+			err = c.write(&response{JSONRPC: "2.0", ID: req.ID, Result: result})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
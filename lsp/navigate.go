@@ -0,0 +1,379 @@
+package lsp
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/compiler"
+	"github.com/ahmadrosid/yuk/resolver"
+	"github.com/ahmadrosid/yuk/token"
+	"github.com/ahmadrosid/yuk/types"
+)
+
+// locate returns the last package checked for the document of p and its
+// file, with the position of p in the lexer's terms. The file is nil when
+// the document never parsed.
+func (s *Server) locate(p TextDocumentPositionParams) (pkg *compiler.Package, file *compiler.File, line, column int, err error) {
+	path, err := uriPath(p.TextDocument.URI)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	line, column = s.source(path).offset(p.Position)
+	pkg = s.pkgs[filepath.Dir(path)]
+	if pkg == nil {
+		return nil, nil, line, column, nil
+	}
+	for _, f := range pkg.Files {
+		if f.Path == path && f.Program != nil {
+			return pkg, f, line, column, nil
+		}
+	}
+	return pkg, nil, line, column, nil
+}
+
+// decl is the declaration of a name.
+type decl struct {
+	file string
+	tok  token.Token
+	doc  *ast.CommentGroup
+}
+
+// symbolDecl returns the declaration of sym, with its doc comment when it
+// is a top level declaration of a yuk file.
+func symbolDecl(pkg *compiler.Package, sym *resolver.Symbol) decl {
+	d := decl{file: sym.File, tok: sym.Decl}
+	for _, f := range pkg.Files {
+		if f.Path != sym.File || f.Program == nil {
+			continue
+		}
+		for _, stmt := range f.Program.Statements {
+			if tok, doc := declaration(stmt); tok != nil && samePos(*tok, sym.Decl) {
+				d.doc = doc
+			}
+		}
+	}
+	return d
+}
+
+// declaration returns the name declared by a top level statement and its
+// doc comment.
+func declaration(stmt ast.Statement) (*token.Token, *ast.CommentGroup) {
+	switch s := stmt.(type) {
+	case *ast.VarStatement:
+		if s.Name != nil {
+			return &s.Name.Token, s.Doc
+		}
+	case *ast.ImportStatement:
+		if lit, ok := s.PackageName.(*ast.StringLiteral); ok {
+			return &lit.Token, s.Doc
+		}
+	case *ast.StructAttributes:
+		return &s.Name, s.Doc
+	case *ast.MacroStatement:
+		if s.Name != nil {
+			return &s.Name.Token, s.Doc
+		}
+	case *ast.ExpressionStatement:
+		switch e := s.Expression.(type) {
+		case *ast.StructStatement:
+			return e.Name, e.Doc
+		case *ast.FunctionLiteral:
+			return &e.NameToken, e.Doc
+		}
+	}
+	return nil, nil
+}
+
+func samePos(a, b token.Token) bool {
+	return a.Line == b.Line && a.Column == b.Column
+}
+
+// memberDecl returns the declaration of the field or method name of the
+// type called typeName declared in a yuk file of pkg.
+func memberDecl(pkg *compiler.Package, typeName, name string) (decl, bool) {
+	for _, f := range pkg.Files {
+		if f.Program == nil {
+			continue
+		}
+		for _, stmt := range f.Program.Statements {
+			s, ok := stmt.(*ast.ExpressionStatement)
+			if !ok {
+				continue
+			}
+			switch e := s.Expression.(type) {
+			case *ast.StructStatement:
+				if e.Name == nil || e.Name.Literal != typeName {
+					continue
+				}
+				for _, attr := range e.Attributes {
+					if attr != nil && attr.Name.Literal == name {
+						return decl{file: f.Path, tok: attr.Name, doc: attr.Doc}, true
+					}
+				}
+			case *ast.FunctionLiteral:
+				if e.Name == name && e.Receiver != nil && e.Receiver.Type != nil && strings.TrimPrefix(e.Receiver.Type.Value, "*") == typeName {
+					return decl{file: f.Path, tok: e.NameToken, doc: e.Doc}, true
+				}
+			}
+		}
+	}
+	return decl{}, false
+}
+
+// nodeAt returns the identifier at line:column of program, and the
+// selector or struct literal it names a member of, if any.
+func nodeAt(program *ast.Program, line, column int) (ident *ast.Identifier, parent ast.Node) {
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Identifier:
+			if covers(n.Token, line, column) {
+				ident = n
+			}
+		case *ast.SelectorExpression:
+			if n.Name != nil && covers(n.Name.Token, line, column) {
+				ident, parent = n.Name, n
+			}
+		case *ast.StructLiteral:
+			for _, field := range n.Fields {
+				if field.Name != nil && covers(field.Name.Token, line, column) {
+					ident, parent = field.Name, n
+				}
+			}
+		}
+		return true
+	})
+	return ident, parent
+}
+
+func covers(tok token.Token, line, column int) bool {
+	return tok.Line == line && tok.Column <= column && column <= tok.Column+len(tok.Literal)
+}
+
+// member describes the field or method name of the values of type typ,
+// like "field Name string", and reports whether there is one.
+func member(typ types.Type, name string) (string, bool) {
+	if typ == nil {
+		return "", false
+	}
+	if p, ok := typ.Underlying().(*types.Pointer); ok {
+		typ = p.Elem
+	}
+	if named, ok := typ.(*types.Named); ok {
+		if m := named.Method(name); m != nil {
+			return "func (" + named.Name + ") " + name + strings.TrimPrefix(m.Sig.String(), "func"), true
+		}
+	}
+	if st, ok := typ.Underlying().(*types.Struct); ok {
+		if f := st.Field(name); f != nil {
+			return "field " + name + " " + f.Type.String(), true
+		}
+	}
+	return "", false
+}
+
+// typeName returns the name of the named type of typ or of the type typ
+// points to, or an empty string.
+func typeName(typ types.Type) string {
+	if typ == nil {
+		return ""
+	}
+	if p, ok := typ.Underlying().(*types.Pointer); ok {
+		typ = p.Elem
+	}
+	if named, ok := typ.(*types.Named); ok {
+		return named.Name
+	}
+	return ""
+}
+
+// describe returns the declaration of obj as it would be written.
+func describe(obj *types.Object) string {
+	switch obj.Kind {
+	case types.ConstObject:
+		return "const " + obj.Name + " " + obj.Type.String()
+	case types.FuncObject:
+		if sig, ok := obj.Type.(*types.Signature); ok {
+			return "func " + obj.Name + strings.TrimPrefix(sig.String(), "func")
+		}
+	case types.TypeObject:
+		if st, ok := obj.Type.Underlying().(*types.Struct); ok && len(st.Fields) > 0 {
+			out := "type " + obj.Name + " struct {\n"
+			for _, f := range st.Fields {
+				out += "\t" + f.Name + " " + f.Type.String() + "\n"
+			}
+			return out + "}"
+		}
+		return "type " + obj.Name + " " + obj.Type.Underlying().String()
+	case types.PackageObject:
+		return "package " + obj.Name
+	case types.BuiltinObject:
+		return "func " + obj.Name
+	case types.NilObject:
+		return "nil"
+	}
+	return "var " + obj.Name + " " + obj.Type.String()
+}
+
+// object returns the object of the package level symbol sym, or of the
+// local variable or parameter declared by it.
+func object(pkg *compiler.Package, sym *resolver.Symbol) *types.Object {
+	if sym.Scope == pkg.Names.Package {
+		return pkg.Info.Scope.Lookup(sym.Name)
+	}
+	for ident, def := range pkg.Names.Defs {
+		if def == sym {
+			return pkg.Info.Defs[ident]
+		}
+	}
+	return nil
+}
+
+// hover describes the name under the cursor, with its type and the doc
+// comment of its declaration.
+func (s *Server) hover(p TextDocumentPositionParams) (*Hover, error) {
+	pkg, f, line, column, err := s.locate(p)
+	if f == nil || pkg.Info == nil {
+		return nil, err
+	}
+	var text string
+	var d decl
+	if ident, parent := nodeAt(f.Program, line, column); parent != nil {
+		text, d = memberHover(pkg, ident, parent)
+	} else if sym := pkg.Names.DefinitionAt(f.Path, line, column); sym != nil {
+		text, d = symbolHover(pkg, sym, ident)
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	value := "```yuk\n" + text + "\n```"
+	if d.doc != nil {
+		if doc := d.doc.Text(); doc != "" {
+			value += "\n\n" + doc
+		}
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: value}}, nil
+}
+
+// memberHover describes the member of a package, the field or the method
+// named by ident in parent, a selector or a struct literal.
+func memberHover(pkg *compiler.Package, ident *ast.Identifier, parent ast.Node) (string, decl) {
+	var typ types.Type
+	switch n := parent.(type) {
+	case *ast.SelectorExpression:
+		if left, ok := n.Left.(*ast.Identifier); ok {
+			if obj := pkg.Info.Uses[left]; obj != nil && obj.Kind == types.PackageObject {
+				if obj.Pkg == nil {
+					return "", decl{}
+				}
+				m := obj.Pkg.Scope.Lookup(ident.Value)
+				if m == nil {
+					return "", decl{}
+				}
+				return describe(&types.Object{Kind: m.Kind, Name: obj.Name + "." + m.Name, Type: m.Type}), decl{}
+			}
+		}
+		typ = pkg.Info.TypeOf(n.Left)
+	case *ast.StructLiteral:
+		typ = pkg.Info.TypeOf(n)
+	}
+	text, _ := member(typ, ident.Value)
+	d, _ := memberDecl(pkg, typeName(typ), ident.Value)
+	return text, d
+}
+
+// symbolHover describes sym, named by ident when it is an identifier of
+// the AST.
+func symbolHover(pkg *compiler.Package, sym *resolver.Symbol, ident *ast.Identifier) (string, decl) {
+	d := symbolDecl(pkg, sym)
+	switch sym.Kind {
+	case resolver.Package:
+		return "package " + sym.Name + " (\"" + sym.Decl.Literal + "\")", d
+	case resolver.Method:
+		for _, f := range pkg.Files {
+			if f.Path != sym.File {
+				continue
+			}
+			for _, stmt := range f.Program.Statements {
+				st, ok := stmt.(*ast.ExpressionStatement)
+				if !ok {
+					continue
+				}
+				fn, ok := st.Expression.(*ast.FunctionLiteral)
+				if !ok || fn.Receiver == nil || !samePos(fn.NameToken, sym.Decl) {
+					continue
+				}
+				if obj := pkg.Info.Scope.Lookup(strings.TrimPrefix(fn.Receiver.Type.Value, "*")); obj != nil {
+					text, _ := member(obj.Type, sym.Name)
+					return text, d
+				}
+			}
+		}
+		return "", d
+	case resolver.Universe:
+		if obj := types.Universe.Lookup(sym.Name); obj != nil {
+			return describe(obj), d
+		}
+		return "", d
+	}
+	obj := object(pkg, sym)
+	if obj == nil && ident != nil {
+		obj = pkg.Info.Uses[ident]
+	}
+	if obj == nil {
+		return "", d
+	}
+	return describe(obj), d
+}
+
+// definition returns the declaration of the name under the cursor.
+func (s *Server) definition(p TextDocumentPositionParams) ([]Location, error) {
+	pkg, f, line, column, err := s.locate(p)
+	if f == nil {
+		return nil, err
+	}
+	if ident, parent := nodeAt(f.Program, line, column); parent != nil && pkg.Info != nil {
+		var typ types.Type
+		switch n := parent.(type) {
+		case *ast.SelectorExpression:
+			typ = pkg.Info.TypeOf(n.Left)
+		case *ast.StructLiteral:
+			typ = pkg.Info.TypeOf(n)
+		}
+		if d, ok := memberDecl(pkg, typeName(typ), ident.Value); ok {
+			return []Location{s.location(d.file, d.tok)}, nil
+		}
+		return nil, nil
+	}
+	sym := pkg.Names.DefinitionAt(f.Path, line, column)
+	if sym == nil || sym.Kind == resolver.Universe {
+		return nil, nil
+	}
+	return []Location{s.location(sym.File, sym.Decl)}, nil
+}
+
+// references returns the declaration and the uses of the name under the
+// cursor, in every file of its package.
+func (s *Server) references(p ReferenceParams) ([]Location, error) {
+	pkg, f, line, column, err := s.locate(p.TextDocumentPositionParams)
+	if f == nil {
+		return nil, err
+	}
+	sym := pkg.Names.DefinitionAt(f.Path, line, column)
+	if sym == nil || sym.Kind == resolver.Universe {
+		return nil, nil
+	}
+	locations := []Location{}
+	for _, ref := range pkg.Names.ReferencesAt(sym) {
+		if !p.Context.IncludeDeclaration && ref.File == sym.File && ref.Tok.Line == sym.Decl.Line && ref.Tok.Column == sym.Decl.Column {
+			continue
+		}
+		locations = append(locations, s.location(ref.File, ref.Tok))
+	}
+	return locations, nil
+}
+
+func (s *Server) location(file string, tok token.Token) Location {
+	return Location{URI: pathURI(file), Range: s.source(file).tokenRange(tok)}
+}
@@ -0,0 +1,232 @@
+package lsp
+
+import (
+	"fmt"
+	goparser "go/parser"
+	gotoken "go/token"
+	"sort"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/compiler"
+	"github.com/ahmadrosid/yuk/resolver"
+	"github.com/ahmadrosid/yuk/token"
+	"github.com/ahmadrosid/yuk/types"
+)
+
+// rename renames the name under the cursor in every yuk file of its
+// package. Package level names are renamed in the hand-written Go files of
+// the package as well, fields and methods where the type checker knows
+// the type they are selected from.
+func (s *Server) rename(p RenameParams) (*WorkspaceEdit, error) {
+	pkg, f, line, column, err := s.locate(p.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil || pkg.Names == nil {
+		return nil, renameError("the document does not parse")
+	}
+	if !isIdentifier(p.NewName) {
+		return nil, renameError("%q is not a valid name", p.NewName)
+	}
+
+	e := &edits{s: s, changes: map[string][]TextEdit{}, seen: map[string]bool{}}
+	if typ, name, ok := memberAt(pkg, f, line, column); ok {
+		err = e.renameMember(pkg, typ, name, p.NewName)
+	} else if sym := pkg.Names.DefinitionAt(f.Path, line, column); sym != nil {
+		err = e.renameSymbol(pkg, sym, p.NewName)
+	} else {
+		err = renameError("there is no name to rename at the cursor")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e.edit(), nil
+}
+
+func renameError(format string, args ...interface{}) error {
+	return &Error{Code: codeRequestFailed, Message: fmt.Sprintf(format, args...)}
+}
+
+// isIdentifier reports whether name can name a declaration.
+func isIdentifier(name string) bool {
+	if name == "" || token.IsKeyword(name) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isWordByte(c) || i == 0 && '0' <= c && c <= '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// memberAt returns the type and the name of the field or method under
+// the cursor: selected from a value, set in a struct literal, or declared
+// by a struct attribute or a method.
+func memberAt(pkg *compiler.Package, f *compiler.File, line, column int) (typ, name string, ok bool) {
+	if ident, parent := nodeAt(f.Program, line, column); parent != nil && pkg.Info != nil {
+		switch n := parent.(type) {
+		case *ast.SelectorExpression:
+			if left, ok := n.Left.(*ast.Identifier); ok {
+				if obj := pkg.Info.Uses[left]; obj != nil && obj.Kind == types.PackageObject {
+					return "", "", false
+				}
+			}
+			return typeName(pkg.Info.TypeOf(n.Left)), ident.Value, true
+		case *ast.StructLiteral:
+			return typeName(pkg.Info.TypeOf(n)), ident.Value, true
+		}
+	}
+	for _, stmt := range f.Program.Statements {
+		s, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			continue
+		}
+		switch e := s.Expression.(type) {
+		case *ast.StructStatement:
+			for _, attr := range e.Attributes {
+				if attr != nil && e.Name != nil && covers(attr.Name, line, column) {
+					return e.Name.Literal, attr.Name.Literal, true
+				}
+			}
+		case *ast.FunctionLiteral:
+			if e.Receiver != nil && e.Receiver.Type != nil && covers(e.NameToken, line, column) {
+				return strings.TrimPrefix(e.Receiver.Type.Value, "*"), e.Name, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// edits collects the edits of a rename.
+type edits struct {
+	s       *Server
+	changes map[string][]TextEdit
+	seen    map[string]bool
+}
+
+// add replaces the name old at line:column of file. Positions where the
+// source does not read old, like the names macros expanded to, are left
+// alone.
+func (e *edits) add(file string, line, column int, old, name string) {
+	src := e.s.source(file)
+	text := src.line(line)
+	if column < 1 || column-1+len(old) > len(text) || text[column-1:column-1+len(old)] != old {
+		return
+	}
+	key := fmt.Sprintf("%s:%d:%d", file, line, column)
+	if e.seen[key] {
+		return
+	}
+	e.seen[key] = true
+	uri := pathURI(file)
+	e.changes[uri] = append(e.changes[uri], TextEdit{
+		Range:   Range{Start: src.position(line, column), End: src.position(line, column+len(old))},
+		NewText: name,
+	})
+}
+
+// edit returns the edits in source order.
+func (e *edits) edit() *WorkspaceEdit {
+	for _, list := range e.changes {
+		sort.Slice(list, func(i, j int) bool {
+			a, b := list[i].Range.Start, list[j].Range.Start
+			return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+		})
+	}
+	return &WorkspaceEdit{Changes: e.changes}
+}
+
+// renameSymbol renames sym and its references.
+func (e *edits) renameSymbol(pkg *compiler.Package, sym *resolver.Symbol, name string) error {
+	switch {
+	case sym.Kind == resolver.Universe:
+		return renameError("cannot rename the predeclared %s", sym.Name)
+	case sym.Kind == resolver.Package:
+		return renameError("cannot rename the import %s", sym.Name)
+	case strings.HasSuffix(sym.File, ".go"):
+		return renameError("%s is declared in the Go file %s", sym.Name, sym.File)
+	}
+	scopes := []*resolver.Scope{sym.Scope}
+	if sym.Scope == pkg.Names.Package {
+		for _, file := range pkg.Names.Files {
+			scopes = append(scopes, file)
+		}
+	}
+	for _, scope := range scopes {
+		for _, other := range scope.Symbols {
+			if other.Name == name && other != sym {
+				return renameError("%s is already declared in this scope", name)
+			}
+		}
+	}
+
+	for _, ref := range pkg.Names.ReferencesAt(sym) {
+		e.add(ref.File, ref.Tok.Line, ref.Tok.Column, sym.Name, name)
+	}
+	if sym.Scope == pkg.Names.Package {
+		e.renameGo(pkg, sym.Name, name)
+	}
+	return nil
+}
+
+// renameGo renames the uses of the package level name old in the Go
+// files of pkg, which are the names the files leave unresolved.
+func (e *edits) renameGo(pkg *compiler.Package, old, name string) {
+	fset := gotoken.NewFileSet()
+	for _, path := range pkg.GoFiles {
+		f, err := goparser.ParseFile(fset, path, e.s.text(path), 0)
+		if err != nil {
+			continue
+		}
+		for _, ident := range f.Unresolved {
+			if ident.Name == old {
+				pos := fset.Position(ident.Pos())
+				e.add(path, pos.Line, pos.Column, old, name)
+			}
+		}
+	}
+}
+
+// renameMember renames the field or method old of the type typ declared
+// in a yuk file, where it is declared and where it is selected from or
+// set on a value of the type.
+func (e *edits) renameMember(pkg *compiler.Package, typ, old, name string) error {
+	d, ok := memberDecl(pkg, typ, old)
+	if !ok {
+		return renameError("%s is not declared in a yuk file of the package", old)
+	}
+	if obj := pkg.Info.Scope.Lookup(typ); obj != nil {
+		if _, ok := member(obj.Type, name); ok {
+			return renameError("%s already has a field or method %s", typ, name)
+		}
+	}
+
+	e.add(d.file, d.tok.Line, d.tok.Column, old, name)
+	for _, f := range pkg.Files {
+		if f.Program == nil {
+			continue
+		}
+		ast.Inspect(f.Program, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.SelectorExpression:
+				if n.Name != nil && n.Name.Value == old && typeName(pkg.Info.TypeOf(n.Left)) == typ {
+					e.add(f.Path, n.Name.Token.Line, n.Name.Token.Column, old, name)
+				}
+			case *ast.StructLiteral:
+				if typeName(pkg.Info.TypeOf(n)) != typ {
+					break
+				}
+				for _, field := range n.Fields {
+					if field.Name != nil && field.Name.Value == old {
+						e.add(f.Path, field.Name.Token.Line, field.Name.Token.Column, old, name)
+					}
+				}
+			}
+			return true
+		})
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package lsp
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/highlight"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+	"github.com/ahmadrosid/yuk/resolver"
+)
+
+// modifierDeclaration is the bit of the declaration modifier.
+const modifierDeclaration = 1
+
+// legend returns the semantic token types, the names of the highlight
+// kinds, and modifiers.
+func legend() SemanticTokensLegend {
+	var types []string
+	for _, kind := range highlight.Kinds() {
+		types = append(types, kind.String())
+	}
+	return SemanticTokensLegend{TokenTypes: types, TokenModifiers: []string{"declaration"}}
+}
+
+// semanticTokens classifies the tokens of the document as the highlight
+// package does. Tokens spanning lines, like block comments, are split
+// into one token per line.
+func (s *Server) semanticTokens(p SemanticTokensParams) (*SemanticTokens, error) {
+	path, err := uriPath(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	text := s.text(path)
+	parse := parser.New(lexer.New(text))
+	program := parse.ParseProgram()
+	names, file := s.names(path, text, program, len(parse.Errors()) == 0)
+	src := newSource(text)
+
+	result := &SemanticTokens{Data: []int{}}
+	var prev Position
+	for _, tok := range highlight.Tokens(text, program, names, file) {
+		if tok.Kind == highlight.Plain {
+			continue
+		}
+		modifiers := 0
+		if tok.Declaration {
+			modifiers = modifierDeclaration
+		}
+		for i, part := range strings.Split(tok.Text, "\n") {
+			part = strings.TrimSuffix(part, "\r")
+			if part == "" {
+				continue
+			}
+			column := 1
+			if i == 0 {
+				column = tok.Column
+			}
+			start := src.position(tok.Line+i, column)
+			delta := start.Character
+			if start.Line == prev.Line {
+				delta -= prev.Character
+			}
+			result.Data = append(result.Data, start.Line-prev.Line, delta, utf16Len(part), int(tok.Kind)-1, modifiers)
+			prev = start
+		}
+	}
+	return result, nil
+}
+
+// names returns the names of the document at path, as the file they are
+// resolved as. They are the ones of its package when it was checked with
+// text, and the ones of program resolved alone otherwise. There are none
+// when program did not parse.
+func (s *Server) names(path, text string, program *ast.Program, parsed bool) (*resolver.Info, string) {
+	if pkg := s.pkgs[filepath.Dir(path)]; pkg != nil {
+		for _, f := range pkg.Files {
+			if f.Path == path && f.Source != nil && string(f.Source) == text {
+				return pkg.Names, path
+			}
+		}
+	}
+	if !parsed {
+		return nil, ""
+	}
+	info, _ := resolver.Resolve(program)
+	return info, ""
+}
@@ -0,0 +1,368 @@
+// Package lsp implements `yuk lsp`, a Language Server Protocol server for
+// yuk over stdio.
+//
+// The server checks the package of every open document in process, with
+// the parser, resolver and type checker of the compiler, and uses the
+// result for diagnostics, document symbols, hover, go to definition, find
+// references, completion, semantic tokens, renaming and code actions.
+// While a document does not parse, the last package that did answers
+// hover, definition, references, completion and rename requests.
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/build"
+	"github.com/ahmadrosid/yuk/compiler"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// Server answers the requests of one client. Its methods are not safe for
+// concurrent use, Serve calls them in order.
+type Server struct {
+	notify      func(method string, params interface{})
+	initialized bool
+	shutdown    bool
+	// docs are the texts of the open documents, by path.
+	docs map[string]string
+	// pkgs are the last packages checked that parsed, by directory.
+	pkgs map[string]*compiler.Package
+	// diagnosed are the files with diagnostics published, by directory of
+	// the package they were reported for.
+	diagnosed map[string]map[string]bool
+}
+
+// NewServer returns a server sending its notifications, like the
+// diagnostics of the files, with notify.
+func NewServer(notify func(method string, params interface{})) *Server {
+	return &Server{
+		notify:    notify,
+		docs:      map[string]string{},
+		pkgs:      map[string]*compiler.Package{},
+		diagnosed: map[string]map[string]bool{},
+	}
+}
+
+// Handle runs the request or notification method with its JSON params and
+// returns the result to send back. The errors are *Error values.
+func (s *Server) Handle(method string, params json.RawMessage) (interface{}, error) {
+	if !s.initialized && method != "initialize" {
+		return nil, &Error{Code: codeNotInitialized, Message: "the server is not initialized"}
+	}
+	if s.shutdown {
+		return nil, &Error{Code: codeInvalidRequest, Message: "the server is shut down"}
+	}
+	switch method {
+	case "initialize":
+		s.initialized = true
+		return s.initialize(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		return nil, s.withParams(params, &p, func() error { return s.didOpen(p) })
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		return nil, s.withParams(params, &p, func() error { return s.didChange(p) })
+	case "textDocument/didSave":
+		var p DidSaveTextDocumentParams
+		return nil, s.withParams(params, &p, func() error { return s.didSave(p) })
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		return nil, s.withParams(params, &p, func() error { return s.didClose(p) })
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		var result []DocumentSymbol
+		err := s.withParams(params, &p, func() (err error) {
+			result, err = s.documentSymbol(p)
+			return err
+		})
+		return result, err
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		var result *Hover
+		err := s.withParams(params, &p, func() (err error) {
+			result, err = s.hover(p)
+			return err
+		})
+		return result, err
+	case "textDocument/definition":
+		var p TextDocumentPositionParams
+		var result []Location
+		err := s.withParams(params, &p, func() (err error) {
+			result, err = s.definition(p)
+			return err
+		})
+		return result, err
+	case "textDocument/references":
+		var p ReferenceParams
+		var result []Location
+		err := s.withParams(params, &p, func() (err error) {
+			result, err = s.references(p)
+			return err
+		})
+		return result, err
+	case "textDocument/completion":
+		var p CompletionParams
+		var result *CompletionList
+		err := s.withParams(params, &p, func() (err error) {
+			result, err = s.completion(p)
+			return err
+		})
+		return result, err
+	case "textDocument/semanticTokens/full":
+		var p SemanticTokensParams
+		var result *SemanticTokens
+		err := s.withParams(params, &p, func() (err error) {
+			result, err = s.semanticTokens(p)
+			return err
+		})
+		return result, err
+	case "textDocument/rename":
+		var p RenameParams
+		var result *WorkspaceEdit
+		err := s.withParams(params, &p, func() (err error) {
+			result, err = s.rename(p)
+			return err
+		})
+		return result, err
+	case "textDocument/codeAction":
+		var p CodeActionParams
+		var result []CodeAction
+		err := s.withParams(params, &p, func() (err error) {
+			result, err = s.codeAction(p)
+			return err
+		})
+		return result, err
+	}
+	if strings.HasPrefix(method, "$/") {
+		// Optional notifications, like $/cancelRequest.
+		return nil, nil
+	}
+	return nil, &Error{Code: codeMethodNotFound, Message: "method not supported: " + method}
+}
+
+// withParams decodes params into v and runs f.
+func (s *Server) withParams(params json.RawMessage, v interface{}, f func() error) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return f()
+}
+
+func (s *Server) initialize() *InitializeResult {
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:       TextDocumentSyncOptions{OpenClose: true, Change: syncFull, Save: true},
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			DocumentSymbolProvider: true,
+			CompletionProvider:     CompletionOptions{TriggerCharacters: []string{"."}},
+			SemanticTokensProvider: SemanticTokensOptions{Legend: legend(), Full: true},
+			RenameProvider:         true,
+			CodeActionProvider:     true,
+		},
+		ServerInfo: ServerInfo{Name: "yuk"},
+	}
+}
+
+func (s *Server) didOpen(p DidOpenTextDocumentParams) error {
+	path, err := uriPath(p.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	s.docs[path] = p.TextDocument.Text
+	s.check(filepath.Dir(path))
+	return nil
+}
+
+func (s *Server) didChange(p DidChangeTextDocumentParams) error {
+	path, err := uriPath(p.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil
+	}
+	s.docs[path] = p.ContentChanges[len(p.ContentChanges)-1].Text
+	s.check(filepath.Dir(path))
+	return nil
+}
+
+func (s *Server) didSave(p DidSaveTextDocumentParams) error {
+	path, err := uriPath(p.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	if p.Text != nil {
+		s.docs[path] = *p.Text
+	}
+	s.check(filepath.Dir(path))
+	return nil
+}
+
+func (s *Server) didClose(p DidCloseTextDocumentParams) error {
+	path, err := uriPath(p.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	delete(s.docs, path)
+	dir := filepath.Dir(path)
+	for doc := range s.docs {
+		if filepath.Dir(doc) == dir {
+			// The file on disk replaces the closed buffer.
+			s.check(dir)
+			return nil
+		}
+	}
+	delete(s.pkgs, dir)
+	s.publish(dir, nil)
+	return nil
+}
+
+// check checks the package in dir, with the open documents in place of
+// their files, and publishes its diagnostics.
+func (s *Server) check(dir string) {
+	pkg := &compiler.Package{Dir: dir}
+	var paths []string
+	if loaded, err := build.Load(dir); err == nil {
+		paths = loaded.Files
+		pkg.GoFiles = loaded.GoFiles
+	}
+	for path := range s.docs {
+		if filepath.Dir(path) == dir && strings.HasSuffix(path, ".yuk") && !contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		f := &compiler.File{Path: path}
+		if text, ok := s.docs[path]; ok {
+			f.Source = []byte(text)
+		}
+		pkg.Files = append(pkg.Files, f)
+	}
+
+	errs := generate(pkg)
+	if pkg.Names != nil && pkg.Info != nil {
+		s.pkgs[dir] = pkg
+	}
+	s.publish(dir, diagnostics(pkg, errs))
+}
+
+// generate checks pkg, reporting a panic of the compiler on code it does
+// not expect, like a half-typed statement, as an error of the package.
+func generate(pkg *compiler.Package) (errs []error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pkg.Names, pkg.Info = nil, nil
+			errs = []error{&token.Error{File: pkg.Dir, Msg: fmt.Sprintf("internal error: %v", r)}}
+		}
+	}()
+	return pkg.Generate()
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// diagnostics groups the errors of pkg by file. The errors about the
+// package as a whole are reported at the start of each of its files.
+func diagnostics(pkg *compiler.Package, errs []error) map[string][]*token.Error {
+	byFile := map[string][]*token.Error{}
+	for _, f := range pkg.Files {
+		byFile[f.Path] = nil
+	}
+	for _, err := range errs {
+		terr, ok := err.(*token.Error)
+		if !ok {
+			terr = &token.Error{File: pkg.Dir, Msg: err.Error()}
+		}
+		if terr.File != pkg.Dir {
+			byFile[terr.File] = append(byFile[terr.File], terr)
+			continue
+		}
+		for _, f := range pkg.Files {
+			byFile[f.Path] = append(byFile[f.Path], &token.Error{File: f.Path, Msg: terr.Msg})
+		}
+	}
+	return byFile
+}
+
+// publish sends the diagnostics of the files of the package in dir, and
+// clears the ones published before for files no longer in it.
+func (s *Server) publish(dir string, byFile map[string][]*token.Error) {
+	previous := s.diagnosed[dir]
+	s.diagnosed[dir] = map[string]bool{}
+	var files []string
+	for file := range byFile {
+		files = append(files, file)
+	}
+	for file := range previous {
+		if _, ok := byFile[file]; !ok {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		errs := byFile[file]
+		src := s.source(file)
+		diags := []Diagnostic{}
+		for _, err := range errs {
+			diags = append(diags, Diagnostic{
+				Range:    src.errorRange(err.Line, err.Column),
+				Severity: SeverityError,
+				Source:   "yuk",
+				Message:  err.Msg,
+			})
+		}
+		if len(diags) > 0 {
+			s.diagnosed[dir][file] = true
+		}
+		s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{URI: pathURI(file), Diagnostics: diags})
+	}
+}
+
+// text returns the text of the file at path, the open document or the
+// file on disk.
+func (s *Server) text(path string) string {
+	if text, ok := s.docs[path]; ok {
+		return text
+	}
+	data, _ := ioutil.ReadFile(path)
+	return string(data)
+}
+
+func (s *Server) source(path string) *source {
+	return newSource(s.text(path))
+}
+
+// uriPath returns the path of a file URI.
+func uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", &Error{Code: codeInvalidParams, Message: "not a file URI: " + uri}
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+// pathURI returns the file URI of an absolute path.
+func pathURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,760 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sourceA = `package main
+
+import "strings"
+
+// User is a user of the app.
+struct User(Name string, Age int)
+
+// Greet says hello.
+func (u User) Greet() string {
+    return "hello " + u.Name
+}
+
+func add(a int, b int) int {
+    return a + b
+}
+
+func main() {
+    var total = add(1, 2)
+    var u = User{Name: "ahmad", Age: 30}
+    println(total, u.Greet(), strings.ToUpper(u.Name))
+}
+`
+
+const sourceB = `package main
+
+func twice(n int) int {
+    return add(n, n)
+}
+`
+
+// message is any message sent by the server.
+type message struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+	Result json.RawMessage  `json:"result"`
+	Error  *Error           `json:"error"`
+}
+
+// client is a scripted LSP client talking to Serve over pipes.
+type client struct {
+	t             *testing.T
+	conn          *conn
+	id            int
+	responses     chan message
+	notifications chan message
+	done          chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{
+		t:             t,
+		conn:          newConn(clientIn, clientOut),
+		responses:     make(chan message, 16),
+		notifications: make(chan message, 64),
+		done:          make(chan error, 1),
+	}
+	go func() {
+		c.done <- Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+	go func() {
+		for {
+			content, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(content, &msg); err != nil {
+				t.Error(err)
+				return
+			}
+			if msg.ID == nil {
+				c.notifications <- msg
+			} else {
+				c.responses <- msg
+			}
+		}
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+	return c
+}
+
+// call sends a request and decodes its result into result, returning the
+// error of the response.
+func (c *client) call(method string, params, result interface{}) *Error {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(strconv.Itoa(c.id))
+	if err := c.conn.write(&request{JSONRPC: "2.0", ID: &id, Method: method, Params: raw(c.t, params)}); err != nil {
+		c.t.Fatal(err)
+	}
+	select {
+	case msg := <-c.responses:
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("%s: expected response %s, got %s", method, id, *msg.ID)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: %v", method, err)
+			}
+		}
+	case <-time.After(10 * time.Second):
+		c.t.Fatalf("%s: no response", method)
+	}
+	return nil
+}
+
+// notify sends a notification.
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.write(&request{JSONRPC: "2.0", Method: method, Params: raw(c.t, params)}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// diagnostics returns the next diagnostics published for the file uri,
+// skipping the ones of the other files.
+func (c *client) diagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+	for {
+		select {
+		case msg := <-c.notifications:
+			if msg.Method != "textDocument/publishDiagnostics" {
+				c.t.Fatalf("unexpected notification %s", msg.Method)
+			}
+			var p PublishDiagnosticsParams
+			if err := json.Unmarshal(msg.Params, &p); err != nil {
+				c.t.Fatal(err)
+			}
+			if p.URI == uri {
+				return p.Diagnostics
+			}
+		case <-time.After(10 * time.Second):
+			c.t.Fatalf("no diagnostics published for %s", uri)
+			return nil
+		}
+	}
+}
+
+func raw(t *testing.T, v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// setup writes a package of two files, starts a server and opens a.yuk.
+func setup(t *testing.T) (c *client, a, b string) {
+	dir := t.TempDir()
+	a, b = filepath.Join(dir, "a.yuk"), filepath.Join(dir, "b.yuk")
+	if err := ioutil.WriteFile(a, []byte(sourceA), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte(sourceB), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c = newClient(t)
+	var result InitializeResult
+	if err := c.call("initialize", InitializeParams{RootURI: pathURI(dir)}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Capabilities.HoverProvider || result.Capabilities.TextDocumentSync.Change != syncFull {
+		t.Fatalf("unexpected capabilities %+v", result.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: pathURI(a), LanguageID: "yuk", Version: 1, Text: sourceA},
+	})
+	if diags := c.diagnostics(pathURI(a)); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %+v", diags)
+	}
+	return c, a, b
+}
+
+func at(uri string, line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func rangeOf(line, start, end int) Range {
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+func TestLifecycle(t *testing.T) {
+	c := newClient(t)
+	if err := c.call("textDocument/hover", at("file:///a.yuk", 0, 0), nil); err == nil || err.Code != codeNotInitialized {
+		t.Errorf("expected not initialized error, got %v", err)
+	}
+	if err := c.call("initialize", InitializeParams{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.call("workspace/symbol", struct{}{}, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected method not found error, got %v", err)
+	}
+	if err := c.call("textDocument/hover", []int{1}, nil); err == nil || err.Code != codeInvalidParams {
+		t.Errorf("expected invalid params error, got %v", err)
+	}
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the server did not exit")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c, a, b := setup(t)
+	uri := pathURI(a)
+
+	broken := strings.Replace(sourceA, "println(total,", "println(totl,", 1)
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: broken}},
+	})
+	expected := []Diagnostic{
+		{Range: rangeOf(17, 8, 13), Severity: SeverityError, Source: "yuk", Message: "declared and not used: total"},
+		{Range: rangeOf(19, 12, 16), Severity: SeverityError, Source: "yuk", Message: "undefined: totl"},
+	}
+	if got := c.diagnostics(uri); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected=%+v, got=%+v", expected, got)
+	}
+	// The other files of the package are checked with the open ones.
+	if got := c.diagnostics(pathURI(b)); len(got) != 0 {
+		t.Errorf("unexpected diagnostics for b.yuk %+v", got)
+	}
+
+	// Saving with the fix clears them.
+	fixed := sourceA
+	c.notify("textDocument/didSave", DidSaveTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}, Text: &fixed})
+	if got := c.diagnostics(uri); len(got) != 0 {
+		t.Errorf("unexpected diagnostics %+v", got)
+	}
+
+	// A syntax error is reported, and the last package that parsed is
+	// still used to answer the requests.
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: sourceA + "\nfunc broken(1) {\n}\n"}},
+	})
+	if got := c.diagnostics(uri); len(got) == 0 || got[0].Range.Start.Line != 22 {
+		t.Errorf("expected a syntax error on line 22, got %+v", got)
+	}
+	var locations []Location
+	if err := c.call("textDocument/definition", at(uri, 17, 17), &locations); err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 1 || locations[0].Range != rangeOf(12, 5, 8) {
+		t.Errorf("unexpected definition %+v", locations)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	c, a, _ := setup(t)
+	var symbols []DocumentSymbol
+	if err := c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: pathURI(a)}}, &symbols); err != nil {
+		t.Fatal(err)
+	}
+	expected := []DocumentSymbol{
+		{Name: "User", Kind: SymbolStruct, Range: rangeOf(5, 0, 33), SelectionRange: rangeOf(5, 7, 11), Children: []DocumentSymbol{
+			{Name: "Name", Detail: "string", Kind: SymbolField, Range: rangeOf(5, 12, 23), SelectionRange: rangeOf(5, 12, 16)},
+			{Name: "Age", Detail: "int", Kind: SymbolField, Range: rangeOf(5, 25, 32), SelectionRange: rangeOf(5, 25, 28)},
+		}},
+		{Name: "(User).Greet", Detail: "() string", Kind: SymbolMethod, Range: Range{Start: Position{8, 0}, End: Position{10, 1}}, SelectionRange: rangeOf(8, 14, 19)},
+		{Name: "add", Detail: "(a int, b int) int", Kind: SymbolFunction, Range: Range{Start: Position{12, 0}, End: Position{14, 1}}, SelectionRange: rangeOf(12, 5, 8)},
+		{Name: "main", Detail: "()", Kind: SymbolFunction, Range: Range{Start: Position{16, 0}, End: Position{20, 1}}, SelectionRange: rangeOf(16, 5, 9)},
+	}
+	if !reflect.DeepEqual(symbols, expected) {
+		t.Errorf("expected=%+v\ngot=%+v", expected, symbols)
+	}
+}
+
+// The declarations that failed to parse are skipped.
+func TestDocumentSymbolSyntaxError(t *testing.T) {
+	c, a, _ := setup(t)
+	uri := pathURI(a)
+	for i, text := range []string{"var x", "let", "struct A", "func"} {
+		c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: i + 2},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: text}},
+		})
+		c.diagnostics(uri)
+		var symbols []DocumentSymbol
+		if err := c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols); err != nil {
+			t.Fatalf("%q: %v", text, err)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	c, a, _ := setup(t)
+	uri := pathURI(a)
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{19, 13, "```yuk\nvar total int\n```"},
+		{13, 11, "```yuk\nvar a int\n```"},
+		{19, 22, "```yuk\nfunc (User) Greet() string\n```\n\nGreet says hello."},
+		{19, 49, "```yuk\nfield Name string\n```"},
+		{18, 13, "```yuk\ntype User struct {\n\tName string\n\tAge int\n}\n```\n\nUser is a user of the app."},
+		{17, 17, "```yuk\nfunc add(int, int) int\n```"},
+		{19, 40, "```yuk\nfunc strings.ToUpper(string) string\n```"},
+		{2, 10, "```yuk\npackage strings (\"strings\")\n```"},
+	}
+	for _, tt := range tests {
+		var hover *Hover
+		if err := c.call("textDocument/hover", at(uri, tt.line, tt.character), &hover); err != nil {
+			t.Fatal(err)
+		}
+		if hover == nil {
+			t.Errorf("%d:%d: no hover, expected %q", tt.line, tt.character, tt.expected)
+			continue
+		}
+		if hover.Contents.Value != tt.expected {
+			t.Errorf("%d:%d: expected=%q, got=%q", tt.line, tt.character, tt.expected, hover.Contents.Value)
+		}
+	}
+
+	var hover *Hover
+	if err := c.call("textDocument/hover", at(uri, 1, 0), &hover); err != nil || hover != nil {
+		t.Errorf("expected no hover on a blank line, got %+v, %v", hover, err)
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c, a, b := setup(t)
+	uri := pathURI(a)
+	tests := []struct {
+		line, character int
+		expected        []Location
+	}{
+		{17, 17, []Location{{URI: uri, Range: rangeOf(12, 5, 8)}}},
+		{19, 13, []Location{{URI: uri, Range: rangeOf(17, 8, 13)}}},
+		{19, 49, []Location{{URI: uri, Range: rangeOf(5, 12, 16)}}},
+		{19, 22, []Location{{URI: uri, Range: rangeOf(8, 14, 19)}}},
+		{18, 13, []Location{{URI: uri, Range: rangeOf(5, 7, 11)}}},
+		{19, 6, nil},
+	}
+	for _, tt := range tests {
+		var locations []Location
+		if err := c.call("textDocument/definition", at(uri, tt.line, tt.character), &locations); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(locations, tt.expected) {
+			t.Errorf("%d:%d: expected=%+v, got=%+v", tt.line, tt.character, tt.expected, locations)
+		}
+	}
+
+	// The references of add span the files of the package.
+	params := ReferenceParams{TextDocumentPositionParams: at(uri, 17, 17)}
+	uses := []Location{
+		{URI: uri, Range: rangeOf(17, 16, 19)},
+		{URI: pathURI(b), Range: rangeOf(3, 11, 14)},
+	}
+	var locations []Location
+	if err := c.call("textDocument/references", params, &locations); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(locations, uses) {
+		t.Errorf("expected=%+v, got=%+v", uses, locations)
+	}
+	params.Context.IncludeDeclaration = true
+	expected := append([]Location{{URI: uri, Range: rangeOf(12, 5, 8)}}, uses...)
+	if err := c.call("textDocument/references", params, &locations); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(locations, expected) {
+		t.Errorf("expected=%+v, got=%+v", expected, locations)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c, a, _ := setup(t)
+	uri := pathURI(a)
+	complete := func(line, character int) map[string]CompletionItem {
+		t.Helper()
+		var list CompletionList
+		if err := c.call("textDocument/completion", CompletionParams{at(uri, line, character)}, &list); err != nil {
+			t.Fatal(err)
+		}
+		items := map[string]CompletionItem{}
+		for _, item := range list.Items {
+			items[item.Label] = item
+		}
+		return items
+	}
+
+	// Struct fields and methods.
+	items := complete(19, 48)
+	expected := map[string]CompletionItem{
+		"Name":  {Label: "Name", Kind: CompletionField, Detail: "string"},
+		"Age":   {Label: "Age", Kind: CompletionField, Detail: "int"},
+		"Greet": {Label: "Greet", Kind: CompletionMethod, Detail: "func() string"},
+	}
+	if !reflect.DeepEqual(items, expected) {
+		t.Errorf("expected=%+v, got=%+v", expected, items)
+	}
+
+	// Package members starting with the word before the cursor.
+	items = complete(19, 40)
+	if item, ok := items["ToUpper"]; !ok || item.Kind != CompletionFunction || item.Detail != "func(string) string" {
+		t.Errorf("expected ToUpper, got %+v", items)
+	}
+	if _, ok := items["Split"]; ok {
+		t.Errorf("unexpected Split in %+v", items)
+	}
+
+	// Locals declared before the cursor, package names and keywords.
+	items = complete(19, 13)
+	for _, label := range []string{"total", "twice", "true", "type"} {
+		if _, ok := items[label]; !ok {
+			t.Errorf("expected %s in %+v", label, items)
+		}
+	}
+	if item := items["total"]; item.Kind != CompletionVariable || item.Detail != "int" {
+		t.Errorf("unexpected %+v", item)
+	}
+	items = complete(17, 20)
+	for _, label := range []string{"add", "main", "User", "strings", "len", "func"} {
+		if _, ok := items[label]; !ok {
+			t.Errorf("expected %s in the completions", label)
+		}
+	}
+	for _, label := range []string{"u", "a", "b", "n"} {
+		if _, ok := items[label]; ok {
+			t.Errorf("unexpected %s in the completions", label)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	src := newSource("a := \"é😀\" + x\nb")
+	// x is after 16 bytes but 13 UTF-16 code units.
+	if pos := src.position(1, 17); pos != (Position{0, 13}) {
+		t.Errorf("unexpected position %+v", pos)
+	}
+	if line, column := src.offset(Position{0, 13}); line != 1 || column != 17 {
+		t.Errorf("unexpected offset %d:%d", line, column)
+	}
+	if r := src.errorRange(1, 1); r != rangeOf(0, 0, 1) {
+		t.Errorf("unexpected range %+v", r)
+	}
+	if r := src.errorRange(0, 0); r != (Range{}) {
+		t.Errorf("unexpected range %+v", r)
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	c, a, _ := setup(t)
+	uri := pathURI(a)
+	types := legend().TokenTypes
+
+	type semantic struct {
+		line, character, length int
+		typ                     string
+		declaration             bool
+	}
+	tokens := func() map[semantic]bool {
+		t.Helper()
+		var result SemanticTokens
+		if err := c.call("textDocument/semanticTokens/full", SemanticTokensParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Data)%5 != 0 {
+			t.Fatalf("unexpected data length %d", len(result.Data))
+		}
+		got := map[semantic]bool{}
+		line, character := 0, 0
+		for i := 0; i < len(result.Data); i += 5 {
+			d := result.Data[i : i+5]
+			if d[0] > 0 {
+				character = 0
+			}
+			line, character = line+d[0], character+d[1]
+			got[semantic{line, character, d[2], types[d[3]], d[4] == modifierDeclaration}] = true
+		}
+		return got
+	}
+
+	got := tokens()
+	for _, expected := range []semantic{
+		{2, 7, 9, "string", false},
+		{4, 0, 29, "comment", false},
+		{5, 0, 6, "keyword", false},
+		{5, 7, 4, "type", true},
+		{5, 12, 4, "property", false},
+		{5, 17, 6, "type", false},
+		{8, 6, 1, "parameter", true},
+		{8, 14, 5, "method", true},
+		{12, 9, 1, "parameter", true},
+		{17, 16, 3, "function", false},
+		{17, 20, 1, "number", false},
+		{19, 4, 7, "function", false},
+		{19, 12, 5, "variable", false},
+		{19, 30, 7, "namespace", false},
+		{19, 38, 7, "function", false},
+		{19, 48, 4, "property", false},
+	} {
+		if !got[expected] {
+			t.Errorf("expected %+v in the tokens", expected)
+		}
+	}
+
+	// A document that does not parse is still highlighted.
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "/* a\nb */ func main( {"}},
+	})
+	c.diagnostics(uri)
+	got = tokens()
+	for _, expected := range []semantic{
+		{0, 0, 4, "comment", false},
+		{1, 0, 4, "comment", false},
+		{1, 5, 4, "keyword", false},
+	} {
+		if !got[expected] {
+			t.Errorf("expected %+v in the tokens %+v", expected, got)
+		}
+	}
+	for i, text := range []string{"let", "switch {"} {
+		c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: i + 3},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: text}},
+		})
+		c.diagnostics(uri)
+		if got := tokens(); !got[semantic{0, 0, len(strings.Fields(text)[0]), "keyword", false}] {
+			t.Errorf("%q: expected a keyword in the tokens %+v", text, got)
+		}
+	}
+}
+
+const sourceGo = `package main
+
+func helper() int { return add(1, 2) }
+`
+
+// handle calls the handler of s directly, decoding the result into result.
+func handle(t *testing.T, s *Server, method string, params, result interface{}) error {
+	t.Helper()
+	value, err := s.Handle(method, raw(t, params))
+	if err != nil {
+		return err
+	}
+	if result != nil {
+		if err := json.Unmarshal(raw(t, value), result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nil
+}
+
+// open writes the files of a package, starts a server without a
+// connection and opens the first file with text.
+func open(t *testing.T, text string, files ...string) (s *Server, paths []string, diags []Diagnostic) {
+	dir := t.TempDir()
+	for i := 0; i < len(files); i += 2 {
+		path := filepath.Join(dir, files[i])
+		if err := ioutil.WriteFile(path, []byte(files[i+1]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	s = NewServer(func(method string, params interface{}) {
+		if p, ok := params.(*PublishDiagnosticsParams); ok && p.URI == pathURI(paths[0]) {
+			diags = p.Diagnostics
+		}
+	})
+	if err := handle(t, s, "initialize", InitializeParams{RootURI: pathURI(dir)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	err := handle(t, s, "textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: pathURI(paths[0]), LanguageID: "yuk", Version: 1, Text: text},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, paths, diags
+}
+
+func TestRename(t *testing.T) {
+	s, paths, _ := open(t, sourceA, "a.yuk", sourceA, "b.yuk", sourceB, "c.go", sourceGo)
+	a, b, c := pathURI(paths[0]), pathURI(paths[1]), pathURI(paths[2])
+	rename := func(line, character int, name string) (*WorkspaceEdit, error) {
+		var edit WorkspaceEdit
+		err := handle(t, s, "textDocument/rename", RenameParams{TextDocumentPositionParams: at(a, line, character), NewName: name}, &edit)
+		return &edit, err
+	}
+	edits := func(name string, ranges ...Range) []TextEdit {
+		var list []TextEdit
+		for _, r := range ranges {
+			list = append(list, TextEdit{Range: r, NewText: name})
+		}
+		return list
+	}
+
+	edit, err := rename(12, 5, "sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]TextEdit{
+		a: edits("sum", rangeOf(12, 5, 8), rangeOf(17, 16, 19)),
+		b: edits("sum", rangeOf(3, 11, 14)),
+		c: edits("sum", rangeOf(2, 27, 30)),
+	}
+	if !reflect.DeepEqual(edit.Changes, expected) {
+		t.Errorf("expected=%+v, got=%+v", expected, edit.Changes)
+	}
+
+	// Fields are renamed where they are declared, set and selected.
+	edit, err = rename(18, 18, "FullName")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = map[string][]TextEdit{
+		a: edits("FullName", rangeOf(5, 12, 16), rangeOf(9, 24, 28), rangeOf(18, 17, 21), rangeOf(19, 48, 52)),
+	}
+	if !reflect.DeepEqual(edit.Changes, expected) {
+		t.Errorf("expected=%+v, got=%+v", expected, edit.Changes)
+	}
+
+	edit, err = rename(8, 15, "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = map[string][]TextEdit{a: edits("Hello", rangeOf(8, 14, 19), rangeOf(19, 21, 26))}
+	if !reflect.DeepEqual(edit.Changes, expected) {
+		t.Errorf("expected=%+v, got=%+v", expected, edit.Changes)
+	}
+
+	for _, tt := range []struct {
+		line, character int
+		name, message   string
+	}{
+		{12, 5, "func", `"func" is not a valid name`},
+		{12, 5, "twice", "twice is already declared in this scope"},
+		{18, 18, "Age", "User already has a field or method Age"},
+		{19, 6, "print", "cannot rename the predeclared println"},
+		{19, 36, "str", "cannot rename the import strings"},
+		{1, 0, "x", "there is no name to rename at the cursor"},
+	} {
+		_, err := rename(tt.line, tt.character, tt.name)
+		if e, ok := err.(*Error); !ok || e.Code != codeRequestFailed || e.Message != tt.message {
+			t.Errorf("%d:%d: expected error %q, got=%v", tt.line, tt.character, tt.message, err)
+		}
+	}
+}
+
+func TestCodeAction(t *testing.T) {
+	const text = `package main
+
+type Post struct {
+	// Title is shown first.
+	Title string ` + "`json:\"title\"`" + `
+	Draft, Hidden bool // not listed
+}
+
+type Pair struct{ Key string; Value int }
+
+type List struct {
+	Items []Post
+}
+`
+	s, paths, _ := open(t, text, "a.yuk", text)
+	actions := func(s *Server, uri string, r Range, diags []Diagnostic) []CodeAction {
+		var actions []CodeAction
+		params := CodeActionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Range: r, Context: CodeActionContext{Diagnostics: diags}}
+		if err := handle(t, s, "textDocument/codeAction", params, &actions); err != nil {
+			t.Fatal(err)
+		}
+		return actions
+	}
+
+	uri := pathURI(paths[0])
+	for _, tt := range []struct {
+		r        Range
+		expected []TextEdit
+	}{
+		{rangeOf(4, 2, 2), []TextEdit{{
+			Range:   Range{Start: Position{Line: 2}, End: Position{Line: 6, Character: 1}},
+			NewText: "struct Post(\n    // Title is shown first.\n    Title  string `json:\"title\"`\n    Draft  bool   // not listed\n    Hidden bool   // not listed\n)",
+		}}},
+		{rangeOf(8, 0, 0), []TextEdit{{Range: rangeOf(8, 0, 41), NewText: "struct Pair(Key string, Value int)"}}},
+		// The shorthand has no spelling for a field of type []Post.
+		{rangeOf(11, 2, 2), nil},
+		{rangeOf(0, 0, 0), nil},
+	} {
+		var got []TextEdit
+		for _, action := range actions(s, uri, tt.r, nil) {
+			if action.Kind != CodeActionRewrite {
+				t.Errorf("unexpected action %+v", action)
+			}
+			got = append(got, action.Edit.Changes[uri]...)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%+v: expected=%+v, got=%+v", tt.r, tt.expected, got)
+		}
+	}
+
+	const missing = `package main
+
+func title() string {
+    return strings.ToUpper("a") + rand.Int()
+}
+`
+	s, paths, diags := open(t, missing, "a.yuk", missing)
+	uri = pathURI(paths[0])
+	var undefined []Diagnostic
+	for _, d := range diags {
+		if strings.HasPrefix(d.Message, "undefined: ") {
+			undefined = append(undefined, d)
+		}
+	}
+	var titles []string
+	for _, action := range actions(s, uri, undefined[0].Range, undefined) {
+		titles = append(titles, action.Title)
+		if action.Title == `Add import "strings"` {
+			expected := []TextEdit{{Range: rangeOf(1, 0, 0), NewText: "\nimport \"strings\"\n"}}
+			if got := action.Edit.Changes[uri]; !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected=%+v, got=%+v", expected, got)
+			}
+		}
+	}
+	expected := []string{`Add import "strings"`, `Add import "math/rand"`, `Add import "crypto/rand"`}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("expected=%q, got=%q", expected, titles)
+	}
+}
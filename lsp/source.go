@@ -0,0 +1,127 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// source converts between the positions of the lexer, with one based
+// lines and byte columns, and the ones of LSP, with zero based lines and
+// UTF-16 columns.
+type source struct {
+	lines []string
+}
+
+func newSource(text string) *source {
+	return &source{lines: strings.Split(text, "\n")}
+}
+
+// line returns the text of the one based line n, or an empty string.
+func (s *source) line(n int) string {
+	if n < 1 || n > len(s.lines) {
+		return ""
+	}
+	return s.lines[n-1]
+}
+
+// position returns the LSP position of line:column.
+func (s *source) position(line, column int) Position {
+	if line < 1 {
+		return Position{}
+	}
+	text := s.line(line)
+	end := column - 1
+	if end > len(text) {
+		end = len(text)
+	}
+	if end < 0 {
+		end = 0
+	}
+	return Position{Line: line - 1, Character: utf16Len(text[:end])}
+}
+
+// offset returns the line:column of the LSP position pos.
+func (s *source) offset(pos Position) (line, column int) {
+	text := s.line(pos.Line + 1)
+	units := 0
+	for i, r := range text {
+		if units >= pos.Character {
+			return pos.Line + 1, i + 1
+		}
+		units += utf16Len(string(r))
+	}
+	return pos.Line + 1, len(text) + 1
+}
+
+// tokenRange returns the range of tok.
+func (s *source) tokenRange(tok token.Token) Range {
+	return Range{
+		Start: s.position(tok.Line, tok.Column),
+		End:   s.position(tok.Line, tok.Column+len(tok.Literal)),
+	}
+}
+
+// errorRange returns the range of an error at line:column, spanning the
+// word it starts, or a single character. Errors without a position are
+// at the start of the file.
+func (s *source) errorRange(line, column int) Range {
+	if line < 1 {
+		return Range{}
+	}
+	text := s.line(line)
+	start := column - 1
+	if start < 0 {
+		start = 0
+	}
+	end := start
+	for end < len(text) && isWordByte(text[end]) {
+		end++
+	}
+	if end == start && end < len(text) {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	return Range{Start: s.position(line, start+1), End: s.position(line, end+1)}
+}
+
+// wordBefore returns the identifier ending at line:column, and whether it
+// follows a dot, with the identifier before the dot.
+func (s *source) wordBefore(line, column int) (word, left string, selector bool) {
+	text := s.line(line)
+	end := column - 1
+	if end > len(text) {
+		end = len(text)
+	}
+	start := end
+	for start > 0 && isWordByte(text[start-1]) {
+		start--
+	}
+	word = text[start:end]
+	if start == 0 || text[start-1] != '.' {
+		return word, "", false
+	}
+	dot := start - 1
+	i := dot
+	for i > 0 && isWordByte(text[i-1]) {
+		i--
+	}
+	return word, text[i:dot], true
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || b >= utf8.RuneSelf
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,144 @@
+package lsp
+
+import (
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// documentSymbol lists the top level declarations of the document: its
+// functions and methods, structs with their fields, types, variables and
+// macros. The declarations before a syntax error are listed.
+func (s *Server) documentSymbol(p DocumentSymbolParams) ([]DocumentSymbol, error) {
+	path, err := uriPath(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	src := s.source(path)
+	program := parser.New(lexer.New(s.text(path))).ParseProgram()
+
+	symbols := []DocumentSymbol{}
+	for _, stmt := range program.Statements {
+		var sym *DocumentSymbol
+		switch st := stmt.(type) {
+		case *ast.VarStatement:
+			if st == nil || st.Name == nil {
+				continue
+			}
+			sym = &DocumentSymbol{
+				Name:           st.Name.Value,
+				Kind:           SymbolVariable,
+				Range:          span(src, st.Token, st.Name.Token),
+				SelectionRange: src.tokenRange(st.Name.Token),
+			}
+			if st.Type != nil {
+				sym.Detail = st.Type.Value
+			}
+		case *ast.StructAttributes:
+			if st == nil || st.Token == nil {
+				continue
+			}
+			sym = &DocumentSymbol{
+				Name:           st.Name.Literal,
+				Detail:         st.Type.Literal,
+				Kind:           SymbolType,
+				Range:          span(src, *st.Token, st.Type),
+				SelectionRange: src.tokenRange(st.Name),
+			}
+		case *ast.MacroStatement:
+			if st == nil || st.Name == nil || st.Body == nil {
+				continue
+			}
+			sym = &DocumentSymbol{
+				Name:           st.Name.Value + "!",
+				Kind:           SymbolFunction,
+				Detail:         "macro",
+				Range:          span(src, st.Token, st.Body.End),
+				SelectionRange: src.tokenRange(st.Name.Token),
+			}
+		case *ast.ExpressionStatement:
+			if st == nil {
+				continue
+			}
+			switch e := st.Expression.(type) {
+			case *ast.StructStatement:
+				sym = structSymbol(src, e)
+			case *ast.FunctionLiteral:
+				sym = funcSymbol(src, e)
+			}
+		}
+		if sym != nil {
+			symbols = append(symbols, *sym)
+		}
+	}
+	return symbols, nil
+}
+
+func structSymbol(src *source, st *ast.StructStatement) *DocumentSymbol {
+	if st == nil || st.Name == nil {
+		return nil
+	}
+	sym := &DocumentSymbol{
+		Name:           st.Name.Literal,
+		Kind:           SymbolStruct,
+		Range:          span(src, st.Token, st.End),
+		SelectionRange: src.tokenRange(*st.Name),
+	}
+	for _, attr := range st.Attributes {
+		if attr == nil {
+			continue
+		}
+		sym.Children = append(sym.Children, DocumentSymbol{
+			Name:           attr.Name.Literal,
+			Detail:         attr.Type.Literal,
+			Kind:           SymbolField,
+			Range:          span(src, attr.Name, attr.Type),
+			SelectionRange: src.tokenRange(attr.Name),
+		})
+	}
+	return sym
+}
+
+func funcSymbol(src *source, fn *ast.FunctionLiteral) *DocumentSymbol {
+	if fn == nil || fn.Name == "" {
+		return nil
+	}
+	sym := &DocumentSymbol{
+		Name:           fn.Name,
+		Detail:         signature(fn),
+		Kind:           SymbolFunction,
+		Range:          src.tokenRange(fn.Token),
+		SelectionRange: src.tokenRange(fn.NameToken),
+	}
+	if fn.Body != nil {
+		sym.Range = span(src, fn.Token, fn.Body.End)
+	}
+	if fn.Receiver != nil && fn.Receiver.Type != nil {
+		sym.Name = "(" + fn.Receiver.Type.Value + ")." + fn.Name
+		sym.Kind = SymbolMethod
+	}
+	return sym
+}
+
+// signature returns the parameters and result of fn as written, like
+// "(a int, b int) int".
+func signature(fn *ast.FunctionLiteral) string {
+	out := "("
+	for i, param := range fn.Params {
+		if i > 0 {
+			out += ", "
+		}
+		out += param.String()
+	}
+	out += ")"
+	if fn.ReturnType != nil {
+		out += " " + fn.ReturnType.String()
+	}
+	return out
+}
+
+// span returns the range from the start of first to the end of last.
+func span(src *source, first, last token.Token) Range {
+	return Range{Start: src.tokenRange(first).Start, End: src.tokenRange(last).End}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ahmadrosid/yuk/lsp"
+)
+
+// lspCommand runs the language server on the standard input and output,
+// for an editor to start.
+func lspCommand(args []string) {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	flags.Usage = func() {
+		log.Print("usage: yuk lsp")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		log.Fatalf("yuk lsp takes no arguments")
+	}
+
+	if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,193 @@
+package macro
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+type builtinFn func(e *Expander, call *ast.MacroCall, asStatement bool) ([]ast.Statement, ast.Expression)
+
+var builtins = map[string]builtinFn{
+	"assert": expandAssert,
+	"todo":   expandTodo,
+	"dbg":    expandDbg,
+	"format": expandFormat,
+}
+
+// expandAssert turns `assert!(cond)` or `assert!(cond, msg)` into
+// `if !(cond) { panic(msg) }`.
+func expandAssert(e *Expander, call *ast.MacroCall, asStatement bool) ([]ast.Statement, ast.Expression) {
+	if !asStatement {
+		e.errorf(call.Token, "assert! can only be used as a statement")
+		return nil, nil
+	}
+	if len(call.Arguments) != 1 && len(call.Arguments) != 2 {
+		e.errorf(call.Token, "assert! takes 1 or 2 argument(s), got %d", len(call.Arguments))
+		return nil, nil
+	}
+
+	cond := call.Arguments[0]
+	var msg ast.Expression = stringAt(call.Token, "assertion failed: "+cond.String())
+	if len(call.Arguments) == 2 {
+		msg = call.Arguments[1]
+	}
+
+	stmt := &ast.ExpressionStatement{
+		Token: tokenAt(call.Token, token.IF, "if"),
+		Expression: &ast.IfExpression{
+			Token: tokenAt(call.Token, token.IF, "if"),
+			Condition: &ast.PrefixExpression{
+				Token:    tokenAt(call.Token, token.BANG, "!"),
+				Operator: "!",
+				Right: &ast.GroupedExpression{
+					Token:      tokenAt(call.Token, token.LPAREN, "("),
+					Expression: cond,
+				},
+			},
+			Consequence: &ast.BlockStatement{
+				Token:      tokenAt(call.Token, token.LBRACE, "{"),
+				Statements: []ast.Statement{callStatement(call.Token, identAt(call.Token, "panic"), msg)},
+			},
+		},
+	}
+	return []ast.Statement{stmt}, nil
+}
+
+// expandTodo turns `todo!()` or `todo!(msg)` into a panic.
+func expandTodo(e *Expander, call *ast.MacroCall, asStatement bool) ([]ast.Statement, ast.Expression) {
+	if len(call.Arguments) > 1 {
+		e.errorf(call.Token, "todo! takes 0 or 1 argument(s), got %d", len(call.Arguments))
+		return nil, nil
+	}
+
+	var msg ast.Expression = stringAt(call.Token, "not yet implemented")
+	if len(call.Arguments) == 1 {
+		msg = &ast.InfixExpression{
+			Token:    tokenAt(call.Token, token.PLUS, "+"),
+			Operator: "+",
+			Left:     stringAt(call.Token, "not yet implemented: "),
+			Right:    call.Arguments[0],
+		}
+	}
+	return result(call, asStatement, callAt(call.Token, identAt(call.Token, "panic"), msg))
+}
+
+// expandDbg turns `dbg!(x)` into `std.Dbg("[line:column] x", x)`, which
+// prints the expression and its value to stderr and returns the value.
+func expandDbg(e *Expander, call *ast.MacroCall, asStatement bool) ([]ast.Statement, ast.Expression) {
+	if len(call.Arguments) != 1 {
+		e.errorf(call.Token, "dbg! takes 1 argument(s), got %d", len(call.Arguments))
+		return nil, nil
+	}
+
+	e.addImport(ast.ExtensionPackage)
+	arg := call.Arguments[0]
+	label := fmt.Sprintf("[%d:%d] %s", call.Token.Line, call.Token.Column, arg.String())
+	fn := selectorAt(call.Token, "std", "Dbg")
+	return result(call, asStatement, callAt(call.Token, fn, stringAt(call.Token, label), arg))
+}
+
+// expandFormat turns `format!("{} is {:?}", a, b)` into
+// `fmt.Sprintf("%v is %#v", a, b)`.
+func expandFormat(e *Expander, call *ast.MacroCall, asStatement bool) ([]ast.Statement, ast.Expression) {
+	if len(call.Arguments) == 0 {
+		e.errorf(call.Token, "format! takes a format string")
+		return nil, nil
+	}
+	lit, ok := call.Arguments[0].(*ast.StringLiteral)
+	if !ok {
+		e.errorf(call.Token, "format! takes a string literal as its first argument, got %s", call.Arguments[0].String())
+		return nil, nil
+	}
+
+	format, count, err := convertFormat(lit.Value)
+	if err != nil {
+		e.errorf(lit.Token, "%s", err)
+		return nil, nil
+	}
+	if count != len(call.Arguments)-1 {
+		e.errorf(call.Token, "format! expects %d argument(s) for the format string, got %d", count, len(call.Arguments)-1)
+		return nil, nil
+	}
+
+	e.addImport("fmt")
+	args := []ast.Expression{&ast.StringLiteral{Token: lit.Token, Value: format}}
+	args = append(args, call.Arguments[1:]...)
+	return result(call, asStatement, callAt(call.Token, selectorAt(call.Token, "fmt", "Sprintf"), args...))
+}
+
+// convertFormat translates the `{}` placeholders of format! to fmt verbs
+// and returns how many arguments the format string takes.
+func convertFormat(format string) (string, int, error) {
+	var out strings.Builder
+	count := 0
+	for i := 0; i < len(format); i++ {
+		ch := format[i]
+		switch {
+		case ch == '%':
+			out.WriteString("%%")
+		case strings.HasPrefix(format[i:], "{{"):
+			out.WriteByte('{')
+			i++
+		case strings.HasPrefix(format[i:], "}}"):
+			out.WriteByte('}')
+			i++
+		case strings.HasPrefix(format[i:], "{}"):
+			out.WriteString("%v")
+			count++
+			i++
+		case strings.HasPrefix(format[i:], "{:?}"):
+			out.WriteString("%#v")
+			count++
+			i += 3
+		case ch == '{' || ch == '}':
+			return "", 0, fmt.Errorf("invalid format string %q, use {{ and }} for literal braces", format)
+		default:
+			out.WriteByte(ch)
+		}
+	}
+	return out.String(), count, nil
+}
+
+func result(call *ast.MacroCall, asStatement bool, exp ast.Expression) ([]ast.Statement, ast.Expression) {
+	if asStatement {
+		return []ast.Statement{&ast.ExpressionStatement{Token: call.Token, Expression: exp}}, nil
+	}
+	return nil, exp
+}
+
+func tokenAt(at token.Token, typ token.TokenType, literal string) token.Token {
+	return token.Token{Type: typ, Literal: literal, Line: at.Line, Column: at.Column}
+}
+
+func identAt(at token.Token, name string) *ast.Identifier {
+	return &ast.Identifier{Token: tokenAt(at, token.IDENT, name), Value: name}
+}
+
+func selectorAt(at token.Token, pkg string, name string) *ast.SelectorExpression {
+	return &ast.SelectorExpression{
+		Token: tokenAt(at, token.DOT, "."),
+		Left:  identAt(at, pkg),
+		Name:  identAt(at, name),
+	}
+}
+
+// stringAt returns a string literal holding s, escaped the way it would be
+// written in the source.
+func stringAt(at token.Token, s string) *ast.StringLiteral {
+	quoted := strconv.Quote(s)
+	value := quoted[1 : len(quoted)-1]
+	return &ast.StringLiteral{Token: tokenAt(at, token.STRING_LIT, value), Value: value}
+}
+
+func callAt(at token.Token, fn ast.Expression, args ...ast.Expression) *ast.CallExpression {
+	return &ast.CallExpression{Token: tokenAt(at, token.LPAREN, "("), Function: fn, Arguments: args}
+}
+
+func callStatement(at token.Token, fn ast.Expression, args ...ast.Expression) ast.Statement {
+	return &ast.ExpressionStatement{Token: at, Expression: callAt(at, fn, args...)}
+}
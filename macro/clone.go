@@ -0,0 +1,71 @@
+package macro
+
+import (
+	"reflect"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+// cloneAt returns a deep copy of node. When at is not nil every token of
+// the copy is moved to the position of at.
+func cloneAt(node ast.Node, at *token.Token) ast.Node {
+	return clone(reflect.ValueOf(node), at).Interface().(ast.Node)
+}
+
+func clone(v reflect.Value, at *token.Token) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(clone(v.Elem(), at))
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(clone(v.Elem(), at))
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		if v.Type() == tokenType {
+			if at != nil {
+				out.FieldByName("Line").SetInt(int64(at.Line))
+				out.FieldByName("Column").SetInt(int64(at.Column))
+			}
+			return out
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if out.Field(i).CanSet() {
+				out.Field(i).Set(clone(v.Field(i), at))
+			}
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(clone(v.Index(i), at))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(clone(iter.Key(), at), clone(iter.Value(), at))
+		}
+		return out
+	}
+	return v
+}
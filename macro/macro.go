@@ -0,0 +1,397 @@
+// Package macro expands `name!(...)` macro calls before code generation.
+//
+// Macros are either builtin (assert!, todo!, dbg!, format!) or defined in
+// yuk with `macro name!(a, b) { ... }`. A user macro receives its arguments
+// as AST fragments, and its body is substituted at the call site. Variables
+// declared inside a macro body are renamed on every expansion so they can
+// never capture or shadow the identifiers of the code calling it.
+package macro
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// maxDepth bounds nested expansions, so a macro expanding to itself is
+// reported instead of looping forever.
+const maxDepth = 64
+
+// maxTrace is the number of macros shown in an expansion trace.
+const maxTrace = 6
+
+type expansion struct {
+	name   string
+	call   token.Token
+	parent *expansion
+}
+
+type Expander struct {
+	macros     map[string]*ast.MacroStatement
+	imports    []string
+	errors     []error
+	expansions []*expansion
+	names      map[string]bool
+	counter    int
+}
+
+func New() *Expander {
+	return &Expander{
+		macros: map[string]*ast.MacroStatement{},
+		names:  map[string]bool{},
+	}
+}
+
+// Expand removes the macro definitions from program and replaces every
+// macro call with its expansion. The tokens of expanded code are placed at
+// the call site, so later diagnostics point at the macro call.
+func (e *Expander) Expand(program *ast.Program) []error {
+	e.collectNames(program)
+	e.collectDefinitions(program)
+	if len(e.errors) > 0 {
+		return e.errors
+	}
+
+	for depth := 0; len(e.errors) == 0; depth++ {
+		calls := findCalls(program)
+		if len(calls) == 0 {
+			break
+		}
+		if depth == maxDepth {
+			call := calls[0]
+			e.errorf(call.Token, "macro expansion of %s! exceeded %d levels, is it recursive?", call.Name.Value, maxDepth)
+			break
+		}
+		e.expandStatements(program)
+		e.expandExpressions(program)
+	}
+	return e.errors
+}
+
+// Imports returns the packages the expanded code needs, e.g. "fmt" for
+// format!.
+func (e *Expander) Imports() []string {
+	return e.imports
+}
+
+// Annotate adds the expansion trace to an error reported at a position
+// produced by a macro expansion.
+func (e *Expander) Annotate(err error) error {
+	terr, ok := err.(*token.Error)
+	if !ok {
+		return err
+	}
+	trace := e.trace(token.Token{Line: terr.Line, Column: terr.Column})
+	if trace == "" {
+		return err
+	}
+	return &token.Error{File: terr.File, Line: terr.Line, Column: terr.Column, Msg: terr.Msg + " " + trace}
+}
+
+func (e *Expander) trace(tok token.Token) string {
+	exp := e.expansionAt(tok)
+	if exp == nil {
+		return ""
+	}
+	var names []string
+	for ; exp != nil; exp = exp.parent {
+		names = append([]string{exp.name + "!"}, names...)
+	}
+	if len(names) > maxTrace {
+		names = append(names[:maxTrace/2], append([]string{"..."}, names[len(names)-maxTrace/2:]...)...)
+	}
+	return "(in expansion of " + strings.Join(names, " -> ") + ")"
+}
+
+func (e *Expander) expansionAt(tok token.Token) *expansion {
+	for i := len(e.expansions) - 1; i >= 0; i-- {
+		exp := e.expansions[i]
+		if exp.call.Line == tok.Line && exp.call.Column == tok.Column {
+			return exp
+		}
+	}
+	return nil
+}
+
+func (e *Expander) errorf(tok token.Token, format string, args ...interface{}) {
+	e.errors = append(e.errors, e.Annotate(token.Errorf(tok, format, args...)))
+}
+
+func (e *Expander) addImport(path string) {
+	for _, imp := range e.imports {
+		if imp == path {
+			return
+		}
+	}
+	e.imports = append(e.imports, path)
+}
+
+func (e *Expander) collectNames(program *ast.Program) {
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Identifier:
+			e.names[n.Value] = true
+		case *ast.VarExpression:
+			e.names[n.Token.Literal] = true
+		}
+		return true
+	})
+}
+
+func (e *Expander) collectDefinitions(program *ast.Program) {
+	statements := program.Statements[:0]
+	for _, stmt := range program.Statements {
+		def, ok := stmt.(*ast.MacroStatement)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+
+		name := def.Name.Value
+		if _, ok := builtins[name]; ok {
+			e.errorf(def.Name.Token, "cannot redefine builtin macro %s!", name)
+		} else if prev, ok := e.macros[name]; ok {
+			e.errorf(def.Name.Token, "macro %s! redeclared, previous definition at %d:%d",
+				name, prev.Name.Token.Line, prev.Name.Token.Column)
+		}
+		e.macros[name] = def
+		program.Comments = removeComments(program.Comments, def)
+	}
+	program.Statements = statements
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		if def, ok := node.(*ast.MacroStatement); ok {
+			e.errorf(def.Token, "macro %s! must be defined at the top level", def.Name.Value)
+		}
+		return true
+	})
+}
+
+// removeComments returns comments without the doc of def and the comments
+// inside it, which go with the definition.
+func removeComments(comments []*ast.CommentGroup, def *ast.MacroStatement) []*ast.CommentGroup {
+	if def.Body == nil {
+		return comments
+	}
+	kept := comments[:0]
+	for _, c := range comments {
+		start := c.List[0]
+		inside := start.Line > def.Token.Line || start.Line == def.Token.Line && start.Column > def.Token.Column
+		inside = inside && (start.Line < def.Body.End.Line || start.Line == def.Body.End.Line && start.Column < def.Body.End.Column)
+		if c != def.Doc && !inside {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// findCalls returns the macro calls left in the program.
+func findCalls(program *ast.Program) []*ast.MacroCall {
+	var calls []*ast.MacroCall
+	ast.Inspect(program, func(node ast.Node) bool {
+		if call, ok := node.(*ast.MacroCall); ok {
+			calls = append(calls, call)
+		}
+		return true
+	})
+	return calls
+}
+
+// expandStatements expands the macro calls used as statements, splicing
+// the statements of the expansion into the enclosing block.
+func (e *Expander) expandStatements(program *ast.Program) {
+	var lists []*[]ast.Statement
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Program:
+			lists = append(lists, &n.Statements)
+		case *ast.BlockStatement:
+			lists = append(lists, &n.Statements)
+		}
+		return true
+	})
+
+	for _, list := range lists {
+		var statements []ast.Statement
+		for _, stmt := range *list {
+			call := statementCall(stmt)
+			if call == nil {
+				statements = append(statements, stmt)
+				continue
+			}
+			expanded, _ := e.expand(call, true)
+			if expanded == nil {
+				statements = append(statements, stmt)
+				continue
+			}
+			statements = append(statements, expanded...)
+		}
+		*list = statements
+	}
+}
+
+// expandExpressions expands the remaining macro calls, which are used as
+// expressions.
+func (e *Expander) expandExpressions(program *ast.Program) {
+	// Statement calls produced by the previous step are expanded in the
+	// next round, where their expansion may be several statements.
+	skip := map[*ast.MacroCall]bool{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if stmt, ok := node.(ast.Statement); ok {
+			if call := statementCall(stmt); call != nil {
+				skip[call] = true
+			}
+		}
+		return true
+	})
+
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.MacroCall)
+		if !ok || skip[call] {
+			return node
+		}
+		_, expanded := e.expand(call, false)
+		if expanded == nil {
+			return node
+		}
+		return expanded
+	})
+}
+
+func statementCall(stmt ast.Statement) *ast.MacroCall {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return nil
+	}
+	call, _ := es.Expression.(*ast.MacroCall)
+	return call
+}
+
+// expand returns the expansion of call, as statements when the call is
+// used as a statement, or as an expression otherwise. It returns nil after
+// reporting an error.
+func (e *Expander) expand(call *ast.MacroCall, asStatement bool) ([]ast.Statement, ast.Expression) {
+	name := call.Name.Value
+	exp := &expansion{name: name, call: call.Token, parent: e.expansionAt(call.Token)}
+
+	if builtin, ok := builtins[name]; ok {
+		statements, expression := builtin(e, call, asStatement)
+		if statements != nil || expression != nil {
+			e.expansions = append(e.expansions, exp)
+		}
+		return statements, expression
+	}
+
+	def, ok := e.macros[name]
+	if !ok {
+		e.errorf(call.Token, "undefined macro %s!", name)
+		return nil, nil
+	}
+	if len(call.Arguments) != len(def.Params) {
+		e.errorf(call.Token, "%s! takes %d argument(s), got %d", name, len(def.Params), len(call.Arguments))
+		return nil, nil
+	}
+
+	body := cloneAt(def.Body, &call.Token).(*ast.BlockStatement)
+	e.rename(body, def.Params)
+	substitute(body, def.Params, call.Arguments)
+
+	if asStatement {
+		e.expansions = append(e.expansions, exp)
+		return body.Statements, nil
+	}
+	if len(body.Statements) != 1 {
+		e.errorf(call.Token, "%s! expands to %d statements and can not be used as an expression", name, len(body.Statements))
+		return nil, nil
+	}
+	es, ok := body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		e.errorf(call.Token, "%s! expands to a statement and can not be used as an expression", name)
+		return nil, nil
+	}
+	e.expansions = append(e.expansions, exp)
+	return nil, es.Expression
+}
+
+// rename gives every variable declared in the expanded body a name that
+// is not used anywhere else in the program.
+func (e *Expander) rename(body *ast.BlockStatement, params []*ast.Identifier) {
+	isParam := map[string]bool{}
+	for _, p := range params {
+		isParam[p.Value] = true
+	}
+
+	renames := map[string]string{}
+	fresh := func(name string) string {
+		if isParam[name] {
+			return name
+		}
+		if renamed, ok := renames[name]; ok {
+			return renamed
+		}
+		for {
+			e.counter++
+			renamed := fmt.Sprintf("%s__%d", name, e.counter)
+			if !e.names[renamed] {
+				e.names[renamed] = true
+				renames[name] = renamed
+				return renamed
+			}
+		}
+	}
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.VarStatement:
+			n.Name.Value = fresh(n.Name.Value)
+			n.Name.Token.Literal = n.Name.Value
+		case *ast.VarExpression:
+			n.Token.Literal = fresh(n.Token.Literal)
+			n.Ident.Literal = n.Token.Literal
+		case *ast.FunctionLiteral:
+			for _, p := range n.Params {
+				p.Name.Value = fresh(p.Name.Value)
+				p.Name.Token.Literal = p.Name.Value
+			}
+		}
+		return true
+	})
+
+	ast.Modify(body, func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			if renamed, ok := renames[ident.Value]; ok {
+				ident.Value = renamed
+				ident.Token.Literal = renamed
+			}
+		}
+		return node
+	})
+}
+
+// substitute replaces the macro parameters in body with copies of the
+// arguments of the call.
+func substitute(body *ast.BlockStatement, params []*ast.Identifier, args []ast.Expression) {
+	values := map[string]ast.Expression{}
+	for i, p := range params {
+		values[p.Value] = args[i]
+	}
+
+	ast.Modify(body, func(node ast.Node) ast.Node {
+		ident, ok := node.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+		arg, ok := values[ident.Value]
+		if !ok {
+			return node
+		}
+		// Keep the argument evaluated as one operand, so square!(1 + 2)
+		// is (1 + 2) * (1 + 2).
+		if infix, ok := arg.(*ast.InfixExpression); ok {
+			return &ast.GroupedExpression{Token: infix.Token, Expression: cloneAt(infix, nil).(ast.Expression)}
+		}
+		return cloneAt(arg, nil)
+	})
+}
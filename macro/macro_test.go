@@ -0,0 +1,123 @@
+package macro
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+)
+
+func expand(t *testing.T, input string) (*ast.Program, *Expander, []error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %q", p.Errors())
+	}
+	e := New()
+	errs := e.Expand(program)
+	return program, e, errs
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"macro square!(x) { x * x }\nvar y = square!(2)",
+			"var y = 2 * 2",
+		},
+		{
+			"macro square!(x) { x * x }\nvar y = square!(1 + 2)",
+			"var y = (1 + 2) * (1 + 2)",
+		},
+		{
+			"macro swap!(a, b) {\ntmp := a\na = b\nb = tmp\n}\nswap!(tmp, x)",
+			"tmp__1 := tmp\ntmp = x\nx = tmp__1",
+		},
+		{
+			"macro twice!(v) { v * 2 }\nmacro quad!(v) { twice!(twice!(v)) }\nvar y = quad!(n)",
+			"var y = (n * 2) * 2",
+		},
+		{
+			"assert!(x == 1)",
+			"if !(x == 1){\npanic(\"assertion failed: x == 1\")\n}",
+		},
+		{
+			"assert!(name == \"yuk\", \"bad name\")",
+			"if !(name == \"yuk\"){\npanic(\"bad name\")\n}",
+		},
+		{
+			"todo!()",
+			"panic(\"not yet implemented\")",
+		},
+		{
+			"var s = format!(\"{} = {:?} {{100%}}\", a, b)",
+			"var s = fmt.Sprintf(\"%v = %#v {100%%}\", a, b)",
+		},
+		{
+			"var y = dbg!(x + 1)",
+			"var y = std.Dbg(\"[1:9] x + 1\", x + 1)",
+		},
+	}
+
+	for _, tt := range tests {
+		program, _, errs := expand(t, tt.input)
+		if len(errs) > 0 {
+			t.Errorf("%s\nunexpected errors: %q", tt.input, errs)
+			continue
+		}
+
+		var out []string
+		for _, stmt := range program.Statements {
+			out = append(out, stmt.String())
+		}
+		got := strings.Join(out, "\n")
+		if got != tt.expected {
+			t.Errorf("expansion wrong\nexpected=%q\ngot=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestExpandImports(t *testing.T) {
+	_, e, errs := expand(t, "var a = format!(\"{}\", 1)\nvar b = dbg!(a)")
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+
+	imports := e.Imports()
+	if len(imports) != 2 || imports[0] != "fmt" || imports[1] != ast.ExtensionPackage {
+		t.Errorf("wrong imports. got=%q", imports)
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"nope!()", "1:1: undefined macro nope!"},
+		{"macro one!(a) { a }\nvar x = one!()", "2:9: one! takes 1 argument(s), got 0"},
+		{"macro assert!(a) { a }", "1:7: cannot redefine builtin macro assert!"},
+		{"macro a!() { 1 }\nmacro a!() { 2 }", "2:7: macro a! redeclared, previous definition at 1:7"},
+		{"var x = assert!(true)", "1:9: assert! can only be used as a statement"},
+		{"var s = format!(\"{}\")", "1:9: format! expects 1 argument(s) for the format string, got 0"},
+		{"var s = format!(\"{\", a)", "1:17: invalid format string \"{\", use {{ and }} for literal braces"},
+		{"macro two!() {\nvar a = 1\nvar b = 2\n}\nvar x = two!()", "5:9: two! expands to 2 statements and can not be used as an expression"},
+		{"macro inner!() { missing!() }\nmacro outer!() { inner!() }\nouter!()", "3:1: undefined macro missing! (in expansion of outer! -> inner!)"},
+		{"macro loop!() { loop!() }\nloop!()", "2:1: macro expansion of loop! exceeded 64 levels, is it recursive? (in expansion of loop! -> loop! -> loop! -> ... -> loop! -> loop! -> loop!)"},
+	}
+
+	for _, tt := range tests {
+		_, _, errs := expand(t, tt.input)
+		if len(errs) == 0 {
+			t.Errorf("%s\nexpected error %q", tt.input, tt.expected)
+			continue
+		}
+		if errs[0].Error() != tt.expected {
+			t.Errorf("wrong error\nexpected=%q\ngot=%q", tt.expected, errs[0].Error())
+		}
+	}
+}
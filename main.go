@@ -1,34 +1,116 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"github.com/ahmadrosid/yuk/compiler"
+	"github.com/ahmadrosid/yuk/build"
+	"github.com/ahmadrosid/yuk/checker"
 	"github.com/ahmadrosid/yuk/lexer"
 	"github.com/ahmadrosid/yuk/parser"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
+var lineDirectives = flag.Bool("lines", true, "emit //line directives so Go errors and stack traces point at the yuk source")
+var output = flag.String("o", "", "write the generated Go to this file instead of the standard output")
+var writeSourceMap = flag.Bool("sourcemap", false, "write a .yuk.map source map next to the file given by -o")
+var listUnsafe = flag.Bool("unsafe", false, "list the unsafe regions of the given files and directories instead of compiling")
+
+// commands are the subcommands, run with the arguments after their name.
+var commands = map[string]func(args []string){
+	"build":     buildCommand,
+	"eval":      evalCommand,
+	"clean":     cleanCommand,
+	"fmt":       fmtCommand,
+	"highlight": highlightCommand,
+	"lsp":       lspCommand,
+	"repl":      replCommand,
+	"run":       runCommand,
+	"watch":     watchCommand,
+}
+
 func main() {
 	log.SetFlags(0)
-	if len(os.Args) == 1 {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
+	flag.Parse()
+	if flag.NArg() == 0 {
 		log.Fatalf("please provide file path!")
 	}
 
-	result, err := ioutil.ReadFile(os.Args[1])
-	if err != nil {
-		log.Fatal(err)
+	if *listUnsafe {
+		listUnsafeRegions(flag.Args())
+		return
 	}
 
-	lex := lexer.New(string(result))
-	par := parser.New(lex)
-	gen := compiler.New(par)
+	if *writeSourceMap && *output == "" {
+		log.Fatalf("-sourcemap needs an output file given by -o")
+	}
 
-	res, errs := gen.Generate()
-	for _, e := range errs {
-		log.Fatalf("error: %q", e.Error())
+	cfg := &build.Config{LineDirectives: *lineDirectives, SourceMap: *writeSourceMap}
+	out, _, errs := cfg.CompileFile(flag.Arg(0), *output)
+	exitOnErrors(errs)
+	if *output == "" {
+		fmt.Println(out.Go)
+		return
+	}
+	if err := out.Write(); err != nil {
+		log.Fatal(err)
 	}
+}
+
+// exitOnErrors prints errs and exits if there are any.
+func exitOnErrors(errs []error) {
+	for _, err := range errs {
+		log.Print(err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+}
+
+// listUnsafeRegions prints every unsafe block and unsafe func found in the
+// .yuk files under paths.
+func listUnsafeRegions(paths []string) {
+	failed := false
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !strings.HasSuffix(path, ".yuk") {
+				return nil
+			}
 
-	fmt.Println(res)
+			source, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			p := parser.New(lexer.New(string(source)))
+			program := p.ParseProgram()
+			for _, e := range p.Errors() {
+				log.Printf("%s: error: %s", path, e)
+				failed = true
+			}
+			for _, region := range checker.UnsafeRegions(program) {
+				fmt.Printf("%s:%s\n", path, region)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Print(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
 }
@@ -5,6 +5,9 @@ import (
 	"github.com/ahmadrosid/yuk/ast"
 	"github.com/ahmadrosid/yuk/lexer"
 	"github.com/ahmadrosid/yuk/token"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -23,12 +26,15 @@ var precedences = map[token.TokenType]int{
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
+	token.GT:       LESSGREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACE:   INDEX,
+	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 type (
@@ -43,6 +49,18 @@ type Parser struct {
 	peekToken      token.Token
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// noStructLiteral is set while parsing an if condition, where `x {`
+	// starts the block rather than a struct literal.
+	noStructLiteral bool
+
+	// comments are the comment groups read so far. leadComment is the group
+	// ending on the line before curToken, and lineComment the group after
+	// curToken on its line, when nothing else follows on that line.
+	comments    []*ast.CommentGroup
+	leadComment *ast.CommentGroup
+	lineComment *ast.CommentGroup
+	peekLead    *ast.CommentGroup
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -57,17 +75,32 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.STRING_LIT, p.parseStringLiteral)
 	p.registerPrefix(token.STRING, p.parseStringType)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
+	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRUCT, p.parseStructLiteral)
 	p.registerPrefix(token.PACKAGE, p.parseExpressionLiteral)
 	p.registerPrefix(token.MAP, p.parseMapLiteral)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.BANG, p.parsePrefixExpression)
+	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.ASTERISK, p.parsePrefixExpression)
+	p.registerPrefix(token.AMPERSAND, p.parsePrefixExpression)
+	p.registerPrefix(token.UNSAFE, p.parseUnsafePackage)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
+	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.LT, p.parseInfixExpression)
+	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.DOT, p.parseSelectorExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
 	// Set curToken adn peekToken
 	p.nextToken()
@@ -79,9 +112,94 @@ func (p *Parser) parseIdentifier() ast.Expression {
 	if p.peekTokenIs(token.COLON) {
 		return p.parseVarExpression()
 	}
+	if p.peekTokenIs(token.LBRACE) && !p.noStructLiteral {
+		return p.parseStructValue(&ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	}
+	if p.peekTokenIs(token.BANG) && !p.peekTokenOnNewLine() {
+		return p.parseMacroCall()
+	}
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseMacroCall() ast.Expression {
+	call := &ast.MacroCall{
+		Token: p.curToken,
+		Name:  &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+	}
+	p.nextToken()
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	call.Arguments = p.parseExpressionList(token.RPAREN)
+	if call.Arguments == nil {
+		return nil
+	}
+	call.End = p.curToken
+	return call
+}
+
+func (p *Parser) parseMacroStatement() ast.Statement {
+	stmt := &ast.MacroStatement{Token: p.curToken, Doc: p.leadComment}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.BANG) || !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	stmt.Params = []*ast.Identifier{}
+	for !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Params = append(stmt.Params, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+	return stmt
+}
+
+// parseStructValue parses a struct literal of the type typ, whose last
+// token is the current one.
+func (p *Parser) parseStructValue(typ *ast.Identifier) ast.Expression {
+	lit := &ast.StructLiteral{Token: typ.Token, Type: typ}
+	p.nextToken()
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.StructLiteralField{
+			Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		field.Value = p.parseExpression(LOWEST)
+		lit.Fields = append(lit.Fields, field)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	lit.End = p.curToken
+	return lit
+}
+
 func (p *Parser) parseVarExpression() ast.Expression {
 	var identifier = p.curToken
 	p.nextToken()
@@ -121,24 +239,37 @@ func (p *Parser) parseBoolean() ast.Expression {
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
-	lit := &ast.FunctionLiteral{Token: p.curToken}
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	lit := &ast.FunctionLiteral{Token: p.curToken, Doc: p.leadComment}
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		lit.Name = p.curToken.Literal
+		lit.NameToken = p.curToken
 	}
 
-	lit.Name = p.curToken.Literal
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 	lit.Params = p.parseFunctionParams()
-
-	if !p.expectPeek(token.RPAREN) {
+	if lit.Params == nil {
 		return nil
 	}
 
 	p.nextToken()
+	// `func (u *User) Name()`: the first parameter list was the receiver.
+	if lit.Name == "" && len(lit.Params) == 1 && p.curTokenIs(token.IDENT) && p.peekTokenIs(token.LPAREN) {
+		lit.Receiver = lit.Params[0]
+		lit.Name = p.curToken.Literal
+		lit.NameToken = p.curToken
+		p.nextToken()
+		lit.Params = p.parseFunctionParams()
+		if lit.Params == nil {
+			return nil
+		}
+		p.nextToken()
+	}
+
 	if !p.curTokenIs(token.LBRACE) {
-		lit.ReturnType = p.parseExpression(LOWEST)
+		lit.ReturnType = p.parseType()
 		p.nextToken()
 	}
 
@@ -151,8 +282,64 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+// parseType reads a type name starting at the current token and returns it
+// as an identifier holding the Go spelling of the type, e.g. `*User`,
+// `[]string` or `map[string]interface{}`.
+func (p *Parser) parseType() *ast.Identifier {
+	typ := &ast.Identifier{Token: p.curToken}
+	switch p.curToken.Type {
+	case token.ASTERISK:
+		p.nextToken()
+		elem := p.parseType()
+		if elem == nil {
+			return nil
+		}
+		typ.Value = "*" + elem.Value
+	case token.LBRACKET:
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		p.nextToken()
+		elem := p.parseType()
+		if elem == nil {
+			return nil
+		}
+		typ.Value = "[]" + elem.Value
+	case token.MAP:
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		p.nextToken()
+		key := p.parseType()
+		if key == nil || !p.expectPeek(token.COMMA) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseType()
+		if value == nil || !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		typ.Value = "map[" + key.Value + "]" + value.Value
+	case token.INTERFACE:
+		typ.Value = "interface{}"
+	case token.IDENT, token.STRING:
+		typ.Value = p.curToken.Literal
+		if p.peekTokenIs(token.DOT) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			typ.Value += "." + p.curToken.Literal
+		}
+	default:
+		p.errors = append(p.errors, fmt.Sprintf("%d:%d: expected type, got '%s' instead", p.curToken.Line, p.curToken.Column, p.curToken.Type))
+		return nil
+	}
+	return typ
+}
+
 func (p *Parser) parseStructLiteral() ast.Expression {
-	lit := &ast.StructStatement{Token: p.curToken}
+	lit := &ast.StructStatement{Token: p.curToken, Doc: p.leadComment}
 	if p.peekTokenIs(token.IDENT) {
 		p.nextToken()
 		name := p.curToken
@@ -163,11 +350,17 @@ func (p *Parser) parseStructLiteral() ast.Expression {
 		return nil
 	}
 
+	errors := len(p.errors)
 	lit.Attributes = p.parseAttributes()
 
 	if !p.curTokenIs(token.RPAREN) {
+		if len(p.errors) == errors {
+			p.errors = append(p.errors, fmt.Sprintf("%d:%d: expected ')' to end the fields of the struct, got '%s' instead",
+				p.curToken.Line, p.curToken.Column, p.curToken.Type))
+		}
 		return nil
 	}
+	lit.End = p.curToken
 
 	return lit
 }
@@ -213,7 +406,9 @@ func (p *Parser) parseMapLiteral() ast.Expression {
 func (p *Parser) parseIfExpression() ast.Expression {
 	exp := &ast.IfExpression{Token: p.curToken}
 	p.nextToken()
+	p.noStructLiteral = true
 	exp.Condition = p.parseExpression(LOWEST)
+	p.noStructLiteral = false
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -226,19 +421,16 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		}
 		exp.Alternative = p.parseBlockStatement()
 	}
-
-	if !p.expectPeek(token.RBRACE) {
-		return nil
-	}
 	return exp
 }
 
 func (p *Parser) parseHashLiteral() *ast.HashLiteral {
-	lit := &ast.HashLiteral{KeyValue: map[ast.Expression]ast.Expression{}}
+	lit := &ast.HashLiteral{}
 
 	if !p.expectPeek(token.LBRACE) {
 		return lit
 	}
+	lit.Start = p.curToken
 
 	for {
 		p.nextToken()
@@ -249,24 +441,26 @@ func (p *Parser) parseHashLiteral() *ast.HashLiteral {
 
 		p.nextToken()
 		val := p.parseExpression(LOWEST)
-		lit.KeyValue[key] = val
+		lit.Pairs = append(lit.Pairs, &ast.HashPair{Key: key, Value: val})
 
 		p.nextToken()
 		if p.curTokenIs(token.RBRACE) {
 			break
 		}
 	}
+	lit.End = p.curToken
 
 	return lit
 }
 
 func (p *Parser) parseImportStatement() ast.Statement {
-	lit := &ast.ImportStatement{Token: p.curToken}
+	lit := &ast.ImportStatement{Token: p.curToken, Doc: p.leadComment}
 	if !p.expectPeek(token.STRING_LIT) {
 		return nil
 	}
 
 	lit.PackageName = p.parseStringLiteral()
+	lit.Comment = p.lineComment
 
 	return lit
 }
@@ -283,12 +477,16 @@ func (p *Parser) parseAttributes() []*ast.StructAttributes {
 			break
 		}
 		if p.curTokenIs(token.COMMA) {
+			// `Name string, // the name`
+			if len(attrs) > 0 && attrs[len(attrs)-1].Comment == nil {
+				attrs[len(attrs)-1].Comment = p.lineComment
+			}
 			p.nextToken()
 			continue
 		}
 
 		if p.curTokenIs(token.ILLEGAL) {
-			p.errors = append(p.errors, fmt.Sprintf("illegal token %s", p.curToken.Literal))
+			p.errors = append(p.errors, fmt.Sprintf("%d:%d: illegal token %s", p.curToken.Line, p.curToken.Column, p.curToken.Literal))
 			return nil
 		}
 
@@ -304,9 +502,15 @@ func (p *Parser) parseAttributes() []*ast.StructAttributes {
 }
 
 func (p *Parser) parseStructAttributes() *ast.StructAttributes {
-	attr := &ast.StructAttributes{Name: p.curToken}
+	attr := &ast.StructAttributes{Doc: p.leadComment}
+	if p.curTokenIs(token.MUT) {
+		attr.Mutable = true
+		p.nextToken()
+	}
+	attr.Name = p.curToken
 	p.nextToken()
 	attr.Type = p.curToken
+	attr.Comment = p.lineComment
 	if !p.peekTokenIs(token.EOF) {
 		p.nextToken()
 	}
@@ -316,6 +520,7 @@ func (p *Parser) parseStructAttributes() *ast.StructAttributes {
 		for {
 			p.nextToken()
 			if p.curTokenIs(token.BACKTICK) {
+				attr.Comment = p.lineComment
 				p.nextToken()
 				break
 			}
@@ -334,13 +539,46 @@ func (p *Parser) parseStructAttributes() *ast.StructAttributes {
 	return attr
 }
 
-func (p *Parser) parseFunctionParams() []*ast.Identifier {
-	var identifiers []*ast.Identifier
+func (p *Parser) peekTypeStart() bool {
+	switch p.peekToken.Type {
+	case token.IDENT, token.STRING, token.ASTERISK, token.LBRACKET, token.MAP, token.INTERFACE:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) parseFunctionParams() []*ast.Parameter {
+	params := []*ast.Parameter{}
 	if p.peekTokenIs(token.RPAREN) {
-		return identifiers
+		p.nextToken()
+		return params
 	}
 
-	return identifiers
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		param := &ast.Parameter{
+			Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		}
+
+		p.nextToken()
+		param.Type = p.parseType()
+		if param.Type == nil {
+			return nil
+		}
+		params = append(params, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return params
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
@@ -357,16 +595,47 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		p.nextToken()
 	}
 
+	block.End = p.curToken
 	return block
 }
 
+// parseUnsafePackage parses `unsafe` used as the name of the Go package,
+// as in `unsafe.Pointer(p)`.
+func (p *Parser) parseUnsafePackage() ast.Expression {
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseUnsafeStatement() ast.Statement {
+	tok := p.curToken
+	switch {
+	case p.peekTokenIs(token.LBRACE):
+		p.nextToken()
+		return &ast.UnsafeStatement{Token: tok, Body: p.parseBlockStatement()}
+	case p.peekTokenIs(token.FUNCTION):
+		doc := p.leadComment
+		p.nextToken()
+		stmt := &ast.ExpressionStatement{Token: p.curToken}
+		fn, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+		if !ok {
+			return nil
+		}
+		fn.Unsafe = true
+		fn.Doc = doc
+		stmt.Expression = fn
+		return stmt
+	default:
+		return p.parseExpressionStatement()
+	}
+}
+
 func (p *Parser) parseTypeStatement() *ast.StructAttributes {
 	typeToken := p.curToken
-	stmt := &ast.StructAttributes{Token: &typeToken}
+	stmt := &ast.StructAttributes{Token: &typeToken, Doc: p.leadComment}
 	p.nextToken()
 	stmt.Name = p.curToken
 	p.nextToken()
 	stmt.Type = p.curToken
+	stmt.Comment = p.lineComment
 
 	if p.peekTokenIs(token.EOF) {
 		return stmt
@@ -396,6 +665,7 @@ func (p *Parser) parseSwitchStatement() *ast.SwitchStatement {
 		}
 	}
 
+	stmt.End = p.curToken
 	return stmt
 }
 
@@ -430,6 +700,107 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	expression := &ast.PrefixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+	}
+	p.nextToken()
+	expression.Right = p.parseExpression(PREFIX)
+	return expression
+}
+
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	exp := &ast.GroupedExpression{Token: p.curToken}
+	p.nextToken()
+	exp.Expression = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return exp
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.End = p.curToken
+	return exp
+}
+
+func (p *Parser) parseSelectorExpression(left ast.Expression) ast.Expression {
+	exp := &ast.SelectorExpression{Token: p.curToken, Left: left}
+	// Keywords are allowed after the dot, so `xs.map(f)` is a method call.
+	if token.IsKeyword(p.peekToken.Literal) {
+		p.nextToken()
+	} else if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	// A struct literal of a type of another package, like `models.User{}`.
+	if pkg, ok := left.(*ast.Identifier); ok && p.peekTokenIs(token.LBRACE) && !p.noStructLiteral {
+		return p.parseStructValue(&ast.Identifier{Token: pkg.Token, Value: pkg.Value + "." + exp.Name.Value})
+	}
+	return exp
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return exp
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	lit := &ast.ArrayLiteral{Token: p.curToken}
+	if !p.peekTokenIs(token.RBRACKET) {
+		lit.Elements = p.parseExpressionList(token.RBRACKET)
+		lit.End = p.curToken
+		return lit
+	}
+
+	p.nextToken()
+	if p.peekTokenOnNewLine() || !p.peekTypeStart() {
+		lit.Elements = []ast.Expression{}
+		lit.End = p.curToken
+		return lit
+	}
+
+	// Go style slice literal: []int{1, 2, 3}
+	p.nextToken()
+	lit.Type = p.parseType()
+	if lit.Type == nil || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	lit.Elements = p.parseExpressionList(token.RBRACE)
+	lit.End = p.curToken
+	return lit
+}
+
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+	return list
+}
+
 func (p *Parser) curPrecedence() int {
 	if p, ok := precedences[p.curToken.Type]; ok {
 		return p
@@ -447,7 +818,44 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
+	p.leadComment = p.peekLead
+	p.lineComment = nil
+	p.peekLead = nil
+	read := len(p.l.Comments())
 	p.peekToken = p.l.NextToken()
+	if comments := p.l.Comments(); len(comments) > read {
+		p.groupComments(comments[read:])
+	}
+}
+
+// groupComments groups the comments read between curToken and peekToken.
+// Comments on consecutive lines are one group, except that the comments on
+// the line of curToken are a group of their own.
+func (p *Parser) groupComments(comments []token.Token) {
+	var groups []*ast.CommentGroup
+	end := 0
+	for _, c := range comments {
+		if len(groups) == 0 || c.Line > end+1 || end == p.curToken.Line && c.Line > end {
+			groups = append(groups, &ast.CommentGroup{})
+		}
+		g := groups[len(groups)-1]
+		g.List = append(g.List, c)
+		end = commentEnd(c)
+	}
+	p.comments = append(p.comments, groups...)
+
+	first, last := groups[0], groups[len(groups)-1]
+	if first.List[0].Line == p.curToken.Line && p.curToken.Type != "" && commentEnd(first.List[len(first.List)-1]) < p.peekToken.Line {
+		p.lineComment = first
+	}
+	if last != p.lineComment && commentEnd(last.List[len(last.List)-1]) == p.peekToken.Line-1 {
+		p.peekLead = last
+	}
+}
+
+// commentEnd returns the line a comment ends on.
+func commentEnd(c token.Token) int {
+	return c.Line + strings.Count(c.Literal, "\n")
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -458,18 +866,41 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 	return p.peekToken.Type == t
 }
 
+// peekTokenOnNewLine reports whether the next token starts a new line. Like
+// Go, an operator can not start a line, so the expression ends there.
+func (p *Parser) peekTokenOnNewLine() bool {
+	return p.peekToken.Line > p.curToken.Line
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be '%s', got '%s' instead", t, p.peekToken.Type)
+	msg := fmt.Sprintf("%d:%d: expected next token to be '%s', got '%s' instead",
+		p.peekToken.Line, p.peekToken.Column, t, p.peekToken.Type)
 	p.errors = append(p.errors, msg)
 }
 
 func (p *Parser) parseVarStatement() *ast.VarStatement {
-	stmt := &ast.VarStatement{Token: p.curToken}
+	stmt := &ast.VarStatement{Token: p.curToken, Doc: p.leadComment}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTypeStart() {
+		p.nextToken()
+		stmt.Type = p.parseType()
+		if stmt.Type == nil {
+			return nil
+		}
+		if !p.peekTokenIs(token.ASSIGN) {
+			if stmt.IsImmutable() {
+				p.errors = append(p.errors, fmt.Sprintf("%d:%d: missing value in let declaration of %s",
+					stmt.Token.Line, stmt.Token.Column, stmt.Name.Value))
+				return nil
+			}
+			stmt.Comment = p.lineComment
+			return stmt
+		}
+	}
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -477,6 +908,7 @@ func (p *Parser) parseVarStatement() *ast.VarStatement {
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
+	stmt.Comment = p.lineComment
 
 	return stmt
 }
@@ -498,7 +930,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		return nil
 	}
 	leftExp := prefix()
-	for !p.peekTokenIs(token.NEW_LINE) && precedence < p.peekPrecedence() {
+	for !p.peekTokenOnNewLine() && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -517,7 +949,7 @@ func (p *Parser) peekPrecedence() int {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for '%s' found", t)
+	msg := fmt.Sprintf("%d:%d: no prefix parse function for '%s' found", p.curToken.Line, p.curToken.Column, t)
 	p.errors = append(p.errors, msg)
 }
 
@@ -534,19 +966,56 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.ASSIGN) && !p.peekTokenOnNewLine() {
+		return p.parseAssignStatement(stmt.Expression)
+	}
 	if p.peekTokenIs(token.NEW_LINE) {
 		p.nextToken()
 	}
 	return stmt
 }
 
+func (p *Parser) parseAssignStatement(target ast.Expression) ast.Statement {
+	switch target.(type) {
+	case *ast.Identifier, *ast.SelectorExpression, *ast.IndexExpression:
+	case nil:
+		// The error is already reported.
+		return nil
+	default:
+		p.errors = append(p.errors, fmt.Sprintf("%d:%d: cannot assign to %s",
+			p.peekToken.Line, p.peekToken.Column, target.String()))
+		return nil
+	}
+
+	p.nextToken()
+	stmt := &ast.AssignStatement{Token: p.curToken, Target: target}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	return stmt
+}
+
 func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// parsePosition matches the position some parser errors start with.
+var parsePosition = regexp.MustCompile(`^(\d+):(\d+): `)
+
+// FileError returns msg, one of the Errors of the parser, as a token.Error
+// of the file file.
+func FileError(file, msg string) error {
+	m := parsePosition.FindStringSubmatch(msg)
+	if m == nil {
+		return &token.Error{File: file, Msg: msg}
+	}
+	line, _ := strconv.Atoi(m[1])
+	column, _ := strconv.Atoi(m[2])
+	return &token.Error{File: file, Line: line, Column: column, Msg: msg[len(m[0]):]}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.VAR:
+	case token.VAR, token.LET:
 		return p.parseVarStatement()
 	case token.IMPORT:
 		return p.parseImportStatement()
@@ -556,6 +1025,10 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseTypeStatement()
 	case token.SWITCH:
 		return p.parseSwitchStatement()
+	case token.MACRO:
+		return p.parseMacroStatement()
+	case token.UNSAFE:
+		return p.parseUnsafeStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -564,6 +1037,9 @@ func (p *Parser) parseStatement() ast.Statement {
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
+	if p.curTokenIs(token.PACKAGE) {
+		program.Doc = p.leadComment
+	}
 
 	for !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
@@ -572,6 +1048,10 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
+	for _, err := range p.l.Errors() {
+		p.errors = append(p.errors, err.Error())
+	}
 
 	return program
 }
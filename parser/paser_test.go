@@ -3,6 +3,7 @@ package parser
 import (
 	"github.com/ahmadrosid/yuk/ast"
 	"github.com/ahmadrosid/yuk/lexer"
+	"strings"
 	"testing"
 )
 
@@ -73,6 +74,440 @@ func TestAnonymousStructExpression(t *testing.T) {
 	}
 }
 
+func TestArrayLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input       string
+		elementType string
+		length      int
+	}{
+		{"[1, 2, 3]", "int", 3},
+		{"[\"a\", \"b\"]", "string", 2},
+		{"[1, \"a\"]", "interface{}", 2},
+		{"[]", "interface{}", 0},
+		{"[]string{\"a\"}", "string", 1},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		array, ok := stmt.Expression.(*ast.ArrayLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.ArrayLiteral. got=%T", stmt.Expression)
+		}
+
+		if array.ElementType() != tt.elementType {
+			t.Errorf("array.ElementType() wrong. expected=%q, got=%q", tt.elementType, array.ElementType())
+		}
+
+		if len(array.Elements) != tt.length {
+			t.Errorf("array.Elements wrong length. expected=%d, got=%d", tt.length, len(array.Elements))
+		}
+	}
+}
+
+func TestMethodCallExpression(t *testing.T) {
+	input := "xs.map(func(x int) int { return x * 2 })"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	selector, ok := call.Function.(*ast.SelectorExpression)
+	if !ok {
+		t.Fatalf("call.Function is not *ast.SelectorExpression. got=%T", call.Function)
+	}
+
+	if selector.Left.String() != "xs" || selector.Name.Value != "map" {
+		t.Fatalf("selector wrong. got=%q", selector.String())
+	}
+
+	if len(call.Arguments) != 1 {
+		t.Fatalf("call.Arguments wrong length. got=%d", len(call.Arguments))
+	}
+
+	fn, ok := call.Arguments[0].(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("call.Arguments[0] is not *ast.FunctionLiteral. got=%T", call.Arguments[0])
+	}
+
+	if fn.Name != "" || len(fn.Params) != 1 || fn.Params[0].String() != "x int" {
+		t.Fatalf("function literal wrong. got=%q", fn.String())
+	}
+}
+
+func TestExpressionEndsAtNewLine(t *testing.T) {
+	input := `var xs = ys
+[1, 2].len()`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+}
+
+func TestIfExpressionFollowedByStatements(t *testing.T) {
+	input := `func main() {
+    if ok {
+        a()
+    } else {
+        b()
+    }
+    c()
+}
+func other() {
+}`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(fn.Body.Statements) != 2 || fn.Body.End.Line != 8 {
+		t.Errorf("wrong body of main, got %d statements ending at line %d", len(fn.Body.Statements), fn.Body.End.Line)
+	}
+}
+
+func TestProgramComments(t *testing.T) {
+	p := New(lexer.New("// Package main.\npackage main\n\nvar x = 1 // one\n"))
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Comments) != 2 || program.Comments[0].List[0].Literal != "// Package main." || program.Comments[1].List[0].Line != 4 {
+		t.Errorf("wrong comments, got=%v", program.Comments)
+	}
+	if program.Doc != program.Comments[0] {
+		t.Errorf("wrong package doc, got=%v", program.Doc)
+	}
+	if v := program.Statements[1].(*ast.VarStatement); v.Comment != program.Comments[1] {
+		t.Errorf("wrong line comment of x, got=%v", v.Comment)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `package main
+
+//go:generate stringer -type=Kind
+// Kind is a kind.
+type Kind int
+
+/* User is
+   a user. */
+struct User(
+    // Name is the full name.
+    Name string // never empty
+    Age int, // in years
+)
+
+// not a doc
+
+func main() {
+}
+
+// Run runs.
+unsafe func Run() {
+}
+`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	kind := program.Statements[1].(*ast.StructAttributes)
+	if got := kind.Doc.Text(); got != "Kind is a kind." {
+		t.Errorf("wrong doc of Kind, got=%q", got)
+	}
+	if len(kind.Doc.List) != 2 {
+		t.Errorf("the directive is not in the doc of Kind, got=%v", kind.Doc.List)
+	}
+	user := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.StructStatement)
+	if got := user.Doc.Text(); got != "User is\na user." {
+		t.Errorf("wrong doc of User, got=%q", got)
+	}
+	name, age := user.Attributes[0], user.Attributes[1]
+	if name.Doc.Text() != "Name is the full name." || name.Comment.Text() != "never empty" {
+		t.Errorf("wrong comments of Name, got=%v and %v", name.Doc, name.Comment)
+	}
+	if age.Doc != nil || age.Comment.Text() != "in years" {
+		t.Errorf("wrong comments of Age, got=%v and %v", age.Doc, age.Comment)
+	}
+	if fn := program.Statements[3].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral); fn.Doc != nil {
+		t.Errorf("main has a doc, got=%v", fn.Doc)
+	}
+	if fn := program.Statements[4].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral); fn.Doc.Text() != "Run runs." {
+		t.Errorf("wrong doc of Run, got=%v", fn.Doc)
+	}
+	if len(program.Comments) != 7 {
+		t.Errorf("wrong comment groups, got=%d", len(program.Comments))
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	p := New(lexer.New("package main\n/* never closed\n"))
+	p.ParseProgram()
+	errs := p.Errors()
+	if len(errs) == 0 || errs[len(errs)-1] != "2:1: comment not terminated" {
+		t.Errorf("wrong errors, got=%q", errs)
+	}
+}
+
+func TestLetStatement(t *testing.T) {
+	l := lexer.New("let x = 5")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.VarStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.VarStatement. got=%T", program.Statements[0])
+	}
+
+	if !stmt.IsImmutable() {
+		t.Errorf("let statement should be immutable")
+	}
+}
+
+func TestTypedVarStatement(t *testing.T) {
+	tests := []struct {
+		input string
+		typ   string
+		value string
+	}{
+		{"var x int = 5", "int", "5"},
+		{"var names []string", "[]string", ""},
+		{"let m map(string, int) = map(string, int){\"a\": 1}", "map[string]int", "map[string]int{\n\"a\":1,\n}"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.VarStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.VarStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Type == nil || stmt.Type.Value != tt.typ {
+			t.Errorf("wrong type. expected=%q, got=%v", tt.typ, stmt.Type)
+		}
+		value := ""
+		if stmt.Value != nil {
+			value = stmt.Value.String()
+		}
+		if value != tt.value {
+			t.Errorf("wrong value. expected=%q, got=%q", tt.value, value)
+		}
+	}
+
+	p := New(lexer.New("let x int"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected an error for let without a value")
+	}
+}
+
+func TestMutStructAttributes(t *testing.T) {
+	l := lexer.New("struct User(mut Name string, Age int)")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	structExpr, ok := stmt.Expression.(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.StructStatement. got=%T", stmt.Expression)
+	}
+
+	if !structExpr.Attributes[0].Mutable || structExpr.Attributes[0].Name.Literal != "Name" {
+		t.Errorf("first attribute should be mutable Name. got=%+v", structExpr.Attributes[0])
+	}
+
+	if structExpr.Attributes[1].Mutable {
+		t.Errorf("second attribute should not be mutable. got=%+v", structExpr.Attributes[1])
+	}
+}
+
+func TestQualifiedStructLiteral(t *testing.T) {
+	l := lexer.New(`var u = models.User{Name: "yuk"}`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.VarStatement)
+	lit, ok := stmt.Value.(*ast.StructLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not *ast.StructLiteral. got=%T", stmt.Value)
+	}
+	if lit.Type.Value != "models.User" || lit.Token.Literal != "models" || len(lit.Fields) != 1 {
+		t.Errorf("wrong struct literal. got=%s", lit)
+	}
+
+	l = lexer.New("func f(u models.User) {\nif u.Admin {\nprintln(1)\n}\n}")
+	p = New(l)
+	p.ParseProgram()
+	checkParseErrors(t, p)
+}
+
+func TestMethodDeclaration(t *testing.T) {
+	input := `func (u *User) Rename(name string) {
+	u.Name = name
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	fn, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+
+	if fn.Receiver == nil || fn.Receiver.String() != "u *User" {
+		t.Fatalf("fn.Receiver wrong. got=%+v", fn.Receiver)
+	}
+
+	if fn.Name != "Rename" || len(fn.Params) != 1 {
+		t.Fatalf("method signature wrong. got=%q", fn.String())
+	}
+
+	assign, ok := fn.Body.Statements[0].(*ast.AssignStatement)
+	if !ok {
+		t.Fatalf("fn.Body.Statements[0] is not *ast.AssignStatement. got=%T", fn.Body.Statements[0])
+	}
+
+	if assign.Target.String() != "u.Name" || assign.Value.String() != "name" {
+		t.Fatalf("assignment wrong. got=%q", assign.String())
+	}
+}
+
+func TestStructLiteralExpression(t *testing.T) {
+	l := lexer.New("var u = User{Name: \"yuk\", Age: 1}")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.VarStatement)
+	lit, ok := stmt.Value.(*ast.StructLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not *ast.StructLiteral. got=%T", stmt.Value)
+	}
+
+	if lit.Type.Value != "User" || len(lit.Fields) != 2 {
+		t.Fatalf("struct literal wrong. got=%q", lit.String())
+	}
+}
+
+func TestMapLiteralKeepsSourceOrder(t *testing.T) {
+	l := lexer.New(`var m = map(string, int){"c": 3, "a": 1, "b": 2}`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.VarStatement)
+	lit, ok := stmt.Value.(*ast.MapLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not *ast.MapLiteral. got=%T", stmt.Value)
+	}
+
+	var keys []string
+	for _, pair := range lit.KeyValue.Pairs {
+		keys = append(keys, pair.Key.String())
+	}
+	if strings.Join(keys, " ") != `"c" "a" "b"` {
+		t.Fatalf("keys out of order. got=%q", keys)
+	}
+}
+
+func TestMacroStatement(t *testing.T) {
+	input := `macro swap!(a, b) {
+	tmp := a
+	a = b
+	b = tmp
+}
+swap!(x, y)`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	def, ok := program.Statements[0].(*ast.MacroStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.MacroStatement. got=%T", program.Statements[0])
+	}
+
+	if def.Name.Value != "swap" || len(def.Params) != 2 || len(def.Body.Statements) != 3 {
+		t.Fatalf("macro definition wrong. got=%q", def.String())
+	}
+
+	stmt := program.Statements[1].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.MacroCall)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.MacroCall. got=%T", stmt.Expression)
+	}
+
+	if call.String() != "swap!(x, y)" {
+		t.Fatalf("macro call wrong. got=%q", call.String())
+	}
+}
+
+func TestUnsafeStatement(t *testing.T) {
+	input := `unsafe func peek(p *int) int {
+	return *p
+}
+func main() {
+	unsafe {
+		var addr = unsafe.Pointer(&x)
+	}
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	peek := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if !peek.Unsafe || peek.Name != "peek" {
+		t.Fatalf("expected unsafe func peek. got=%q", peek.String())
+	}
+
+	main := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	block, ok := main.Body.Statements[0].(*ast.UnsafeStatement)
+	if !ok {
+		t.Fatalf("main.Body.Statements[0] is not *ast.UnsafeStatement. got=%T", main.Body.Statements[0])
+	}
+
+	if block.String() != "{\nvar addr = unsafe.Pointer(&x)\n}" {
+		t.Fatalf("unsafe block wrong. got=%q", block.String())
+	}
+}
+
 func testVarStatement(t *testing.T, stmt ast.Statement, identifier string) bool {
 	if stmt.TokenLiteral() != "var" {
 		t.Errorf("stmt.TokenLiteral no 'var'. got-%q", stmt.TokenLiteral())
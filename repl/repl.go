@@ -0,0 +1,382 @@
+// Package repl implements `yuk repl`, which reads yuk declarations,
+// statements and expressions and runs them with the local Go toolchain.
+//
+// Each input is compiled with the ones entered before it into the main
+// package of a temporary program. Imports and declarations are kept at the
+// top level, and statements in main, where they are run again on every
+// input with their output hidden, so the variables they declare keep their
+// values. The value of an expression is printed.
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/compiler"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+	"github.com/ahmadrosid/yuk/resolver"
+	"github.com/ahmadrosid/yuk/run"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// marker separates the output of the replayed statements from the one of
+// the input.
+const marker = "\x00yuk-repl\x00"
+
+// Session holds the inputs entered so far.
+type Session struct {
+	imports []string
+	decls   []string
+	stmts   []string
+	// vars are the variables declared by stmts, used at the end of main so
+	// the ones the later inputs do not use are not reported.
+	vars []string
+}
+
+func New() *Session {
+	return &Session{}
+}
+
+// Complete reports whether input closes the parentheses, brackets and
+// braces it opens, so it can be run.
+func Complete(input string) bool {
+	_, depth := depths(input)
+	return depth <= 0
+}
+
+// depths returns the nesting of parentheses, brackets and braces at the
+// end of each line of input that has tokens, and at the end of input.
+func depths(input string) (lines map[int]int, depth int) {
+	lines = map[int]int{}
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
+		}
+		lines[tok.Line] = depth
+	}
+	return lines, depth
+}
+
+// chunks splits input into its top level statements, each with the lines
+// without tokens, like comments, before it.
+func chunks(input string) []string {
+	lines := strings.Split(input, "\n")
+	ends, _ := depths(input)
+	var out []string
+	start := 0
+	for i := range lines {
+		if depth, ok := ends[i+1]; ok && depth <= 0 {
+			out = append(out, strings.Join(lines[start:i+1], "\n"))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(strings.Join(lines[start:], "\n")); rest != "" {
+		if len(out) == 0 {
+			return []string{rest}
+		}
+		out[len(out)-1] += "\n" + rest
+	}
+	return out
+}
+
+// input is an input split into the parts of the program.
+type input struct {
+	imports, decls, stmts, vars []string
+	// expr is set when the input is a single expression, printed when it
+	// has a value.
+	expr string
+}
+
+// split parses the chunks of text and sorts them into imports,
+// declarations and statements.
+func split(text string) (*input, error) {
+	in := &input{}
+	parts := chunks(text)
+	for _, chunk := range parts {
+		p := parser.New(lexer.New(chunk))
+		program := p.ParseProgram()
+		if errs := p.Errors(); len(errs) > 0 {
+			return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+		}
+		if len(program.Statements) == 0 {
+			continue
+		}
+		switch stmt := program.Statements[0]; {
+		case isPackage(stmt):
+			return nil, fmt.Errorf("the inputs are in package main, without a package clause")
+		case isDecl(stmt):
+			in.decls = append(in.decls, chunk)
+		case isImport(stmt):
+			for _, stmt := range program.Statements {
+				if imp, ok := stmt.(*ast.ImportStatement); ok {
+					if lit, ok := imp.PackageName.(*ast.StringLiteral); ok {
+						in.imports = append(in.imports, lit.Value)
+					}
+				}
+			}
+		default:
+			if v, ok := stmt.(*ast.VarStatement); ok && v.Name != nil {
+				in.vars = append(in.vars, v.Name.Value)
+			}
+			if es, ok := stmt.(*ast.ExpressionStatement); ok && len(parts) == 1 && len(program.Statements) == 1 && es.Expression != nil {
+				in.expr = strings.TrimSpace(chunk)
+			}
+			in.stmts = append(in.stmts, chunk)
+		}
+	}
+	return in, nil
+}
+
+func isImport(stmt ast.Statement) bool {
+	_, ok := stmt.(*ast.ImportStatement)
+	return ok
+}
+
+func isPackage(stmt ast.Statement) bool {
+	if s, ok := stmt.(*ast.ExpressionStatement); ok {
+		if lit, ok := s.Expression.(*ast.ExpressionLiteral); ok {
+			return lit.Token.Type == token.PACKAGE
+		}
+	}
+	return false
+}
+
+// isDecl reports whether stmt is a top level declaration: a named
+// function or method, a named struct, a type or a macro.
+func isDecl(stmt ast.Statement) bool {
+	switch s := stmt.(type) {
+	case *ast.MacroStatement:
+		return true
+	case *ast.ExpressionStatement:
+		switch e := s.Expression.(type) {
+		case *ast.FunctionLiteral:
+			return e.Name != ""
+		case *ast.StructStatement:
+			return e.Name != nil
+		case *ast.ExpressionLiteral:
+			return true
+		}
+	}
+	return false
+}
+
+// source returns the program running in, with the statements of the
+// input printing their value when expr is set. The statements of the input
+// start on the returned line.
+func (s *Session) source(in *input, imports []string, expr bool) (string, int) {
+	var b strings.Builder
+	b.WriteString("package main\n\n")
+	for _, path := range imports {
+		fmt.Fprintf(&b, "import %q\n", path)
+	}
+	for _, decl := range append(s.decls, in.decls...) {
+		b.WriteString("\n" + strings.TrimSpace(decl) + "\n")
+	}
+	b.WriteString("\nfunc main() {\n")
+	for _, stmt := range s.stmts {
+		b.WriteString(stmt + "\n")
+	}
+	fmt.Fprintf(&b, "fmt.Print(%q)\n", marker)
+	start := strings.Count(b.String(), "\n") + 1
+	if expr {
+		b.WriteString("fmt.Println(" + in.expr + ")\n")
+	} else {
+		for _, stmt := range in.stmts {
+			b.WriteString(stmt + "\n")
+		}
+	}
+	for _, v := range append(s.vars, in.vars...) {
+		b.WriteString("yukReplUse(" + v + ")\n")
+	}
+	b.WriteString("}\n\nfunc yukReplUse(v interface) {}\n")
+	return b.String(), start
+}
+
+// check type checks the program of in written to file, leaving out the
+// imports no input uses yet. It returns the source of the program and
+// the line the input starts on.
+func (s *Session) check(file string, in *input, expr bool) (string, int, error) {
+	imports := []string{"fmt"}
+	for _, path := range append(s.imports, in.imports...) {
+		if !contains(imports, path) {
+			imports = append(imports, path)
+		}
+	}
+	for {
+		src, start := s.source(in, imports, expr)
+		pkg := &compiler.Package{Dir: filepath.Dir(file), Files: []*compiler.File{{Path: file, Source: []byte(src)}}}
+		if errs := pkg.Generate(); len(errs) > 0 {
+			return "", 0, joinErrors(errs, start)
+		}
+		var unused []string
+		for _, scope := range pkg.Names.Files {
+			for _, sym := range scope.Symbols {
+				path := sym.Decl.Literal
+				if sym.Kind == resolver.Package && path != "fmt" && contains(imports, path) && len(pkg.Names.ReferencesAt(sym)) == 1 {
+					unused = append(unused, path)
+				}
+			}
+		}
+		if len(unused) == 0 {
+			return src, start, nil
+		}
+		var used []string
+		for _, path := range imports {
+			if !contains(unused, path) {
+				used = append(used, path)
+			}
+		}
+		imports = used
+	}
+}
+
+// joinErrors returns the errors of a program, with the positions in the
+// input, starting on the line start, relative to it.
+func joinErrors(errs []error, start int) error {
+	var msgs []string
+	for _, err := range errs {
+		terr, ok := err.(*token.Error)
+		switch {
+		case !ok:
+			msgs = append(msgs, err.Error())
+		case terr.Line >= start:
+			msgs = append(msgs, fmt.Sprintf("%d:%d: %s", terr.Line-start+1, terr.Column, terr.Msg))
+		default:
+			msgs = append(msgs, terr.Msg)
+		}
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "\n"))
+}
+
+// Eval runs text with the inputs evaluated before it, returning its
+// output. The input is kept for the next ones unless it fails to compile
+// or to run.
+func (s *Session) Eval(text string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return "", nil
+	}
+	in, err := split(text)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := ioutil.TempDir("", "yuk-repl-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "repl.yuk")
+
+	// An expression is printed when it has a value, which the type checker
+	// tells by rejecting the print otherwise.
+	printed := in.expr != ""
+	src, _, err := s.check(file, in, printed)
+	if err != nil && printed {
+		printed = false
+		src, _, err = s.check(file, in, false)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+		return "", err
+	}
+	out, err := execute(file)
+	if i := strings.LastIndex(out, marker); i >= 0 {
+		out = out[i+len(marker):]
+	}
+	if err != nil {
+		return out, err
+	}
+
+	for _, path := range in.imports {
+		if !contains(s.imports, path) {
+			s.imports = append(s.imports, path)
+		}
+	}
+	s.decls = append(s.decls, in.decls...)
+	// A printed expression has no effect to replay.
+	if !printed {
+		s.stmts = append(s.stmts, in.stmts...)
+	}
+	s.vars = append(s.vars, in.vars...)
+	return out, nil
+}
+
+// execute builds and runs the program of file, returning its standard
+// output and error.
+func execute(file string) (string, error) {
+	program, errs := run.Compile([]string{file}, nil)
+	if len(errs) > 0 {
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return "", fmt.Errorf("%s", strings.Join(msgs, "\n"))
+	}
+	defer program.Remove()
+	exe, err := program.Build()
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Command(exe).CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); ok {
+		return string(out), fmt.Errorf("exit status %s", strconv.Itoa(exit.ExitCode()))
+	}
+	return string(out), err
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Run reads inputs from r until its end or `:quit`, writing their output
+// and errors to w. An input spans lines until its parentheses, brackets
+// and braces are closed, and `:reset` forgets the inputs before it.
+func Run(r io.Reader, w io.Writer) error {
+	s := New()
+	scanner := bufio.NewScanner(r)
+	var text string
+	fmt.Fprint(w, ">>> ")
+	for scanner.Scan() {
+		text += scanner.Text() + "\n"
+		if !Complete(text) {
+			fmt.Fprint(w, "... ")
+			continue
+		}
+		switch strings.TrimSpace(text) {
+		case ":quit":
+			return nil
+		case ":reset":
+			s = New()
+		default:
+			out, err := s.Eval(text)
+			fmt.Fprint(w, out)
+			if err != nil {
+				fmt.Fprintln(w, err)
+			}
+		}
+		text = ""
+		fmt.Fprint(w, ">>> ")
+	}
+	fmt.Fprintln(w)
+	return scanner.Err()
+}
@@ -0,0 +1,99 @@
+package repl
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"var x = 1", true},
+		{"func f() {", false},
+		{"func f() {\n    return\n}", true},
+		{"struct User(\n    Name string", false},
+		{"var xs = [1,\n", false},
+		{`var s = "{"`, true},
+		{"// {", true},
+	}
+	for _, tt := range tests {
+		if got := Complete(tt.input); got != tt.expected {
+			t.Errorf("%q: expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	in, err := split(`import "strings"
+// double doubles.
+func double(n int) int {
+    return n * 2
+}
+var x = double(2)
+x = 3
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &input{
+		imports: []string{"strings"},
+		decls:   []string{"// double doubles.\nfunc double(n int) int {\n    return n * 2\n}"},
+		stmts:   []string{"var x = double(2)", "x = 3"},
+		vars:    []string{"x"},
+	}
+	if !reflect.DeepEqual(in, expected) {
+		t.Errorf("expected=%+v, got=%+v", expected, in)
+	}
+
+	in, err = split("len([1, 2])\n")
+	if err != nil || in.expr != "len([1, 2])" {
+		t.Errorf("expected an expression, got=%+v, %v", in, err)
+	}
+	if _, err := split("package main"); err == nil {
+		t.Errorf("expected an error for a package clause")
+	}
+}
+
+func TestSession(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds with the go command")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no go command")
+	}
+
+	s := New()
+	tests := []struct {
+		input, expected, err string
+	}{
+		{`import "strings"`, "", ""},
+		{"struct Point(mut X int, Y int)", "", ""},
+		{"var p = Point{X: 1, Y: 2}", "", ""},
+		{`println("hi")`, "hi\n", ""},
+		{"p.X = p.X + 10", "", ""},
+		{"p.X + p.Y", "13\n", ""},
+		{`strings.Repeat("a", p.Y)`, "aa\n", ""},
+		{"undefined + 1", "", "1:1: undefined: undefined"},
+		{"func inc(n int) int {\n    return n + 1\n}", "", ""},
+		{"inc(p.X)", "12\n", ""},
+	}
+	for _, tt := range tests {
+		out, err := s.Eval(tt.input)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: expected error %q, got=%v", tt.input, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tt.input, err)
+		}
+		if out != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, out)
+		}
+	}
+}
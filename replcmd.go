@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ahmadrosid/yuk/repl"
+)
+
+// replCommand reads yuk inputs from the standard input and runs them,
+// printing their output and the values of expressions.
+func replCommand(args []string) {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	flags.Usage = func() {
+		log.Print("usage: yuk repl")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		log.Fatalf("yuk repl takes no arguments")
+	}
+
+	if err := repl.Run(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,541 @@
+// Package resolver binds every name of a program to its declaration.
+//
+// It builds the scopes of the package, functions, blocks and switch arms,
+// and reports undefined names, names declared twice in the same scope and
+// local variables that are never used. The bindings it records double as a
+// go-to-definition index for editor tooling.
+package resolver
+
+import (
+	"fmt"
+	goast "go/ast"
+	gotoken "go/token"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// Ref is an occurrence of a name, either declaring or using a symbol.
+type Ref struct {
+	// File is the name of the file of the occurrence, empty when a single
+	// program is resolved.
+	File   string
+	Tok    token.Token
+	Symbol *Symbol
+}
+
+// Info holds the result of resolving a program.
+type Info struct {
+	Package *Scope
+	// Files are the scopes of the imports of each program, in order.
+	Files []*Scope
+	// Defs maps the identifiers declaring a name to their symbol.
+	Defs map[*ast.Identifier]*Symbol
+	// Uses maps the identifiers using a name to the symbol they refer to.
+	Uses map[*ast.Identifier]*Symbol
+	// Refs lists every resolved occurrence of a name, including the ones
+	// that are plain tokens in the AST, like the name of a function.
+	Refs []Ref
+}
+
+// Definition returns the symbol named at line:column of a program resolved
+// alone, or nil.
+func (info *Info) Definition(line, column int) *Symbol {
+	return info.DefinitionAt("", line, column)
+}
+
+// DefinitionAt returns the symbol named at line:column of file, or nil.
+func (info *Info) DefinitionAt(file string, line, column int) *Symbol {
+	for _, ref := range info.Refs {
+		if ref.File == file && ref.Tok.Line == line && ref.Tok.Column <= column && column < ref.Tok.Column+len(ref.Tok.Literal) {
+			return ref.Symbol
+		}
+	}
+	return nil
+}
+
+// References returns the positions of the declaration and every use of
+// sym, in source order. Use ReferencesAt for the files of a package.
+func (info *Info) References(sym *Symbol) []token.Token {
+	var refs []token.Token
+	for _, ref := range info.ReferencesAt(sym) {
+		refs = append(refs, ref.Tok)
+	}
+	return refs
+}
+
+// ReferencesAt returns the declaration and every use of sym, sorted by
+// file and position.
+func (info *Info) ReferencesAt(sym *Symbol) []Ref {
+	var refs []Ref
+	for _, ref := range info.Refs {
+		if ref.Symbol == sym {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Tok.Line != b.Tok.Line {
+			return a.Tok.Line < b.Tok.Line
+		}
+		return a.Tok.Column < b.Tok.Column
+	})
+	return refs
+}
+
+// Config holds the declarations of the package outside its yuk files.
+type Config struct {
+	// GoFiles are the hand-written Go files of the package, positioned by
+	// Fset. Their top level names are visible to the yuk files.
+	Fset    *gotoken.FileSet
+	GoFiles []*goast.File
+	// IgnoreUnused leaves out the local variables declared but never
+	// used, an error only for the Go compiler.
+	IgnoreUnused bool
+}
+
+type resolver struct {
+	info   *Info
+	errors []error
+	scope  *Scope
+	// file is the name of the program being resolved.
+	file         string
+	ignoreUnused bool
+}
+
+// Resolve builds the scopes of the programs, the files of one package, and
+// binds their names. It reports undefined names, duplicate declarations in
+// one scope, and local variables that are declared but never used, sorted
+// by file and position.
+func Resolve(programs ...*ast.Program) (*Info, []error) {
+	return (&Config{}).Resolve(programs...)
+}
+
+// Resolve resolves the programs of a package with the declarations of conf.
+func (conf *Config) Resolve(programs ...*ast.Program) (*Info, []error) {
+	// The universe is shared by every program, so it does not list the
+	// package scope as a child.
+	pkg := newScope(PackageScope, nil)
+	pkg.Parent = universe
+	r := &resolver{
+		info: &Info{
+			Package: pkg,
+			Defs:    map[*ast.Identifier]*Symbol{},
+			Uses:    map[*ast.Identifier]*Symbol{},
+		},
+		scope:        pkg,
+		ignoreUnused: conf.IgnoreUnused,
+	}
+
+	r.goDecls(conf.Fset, conf.GoFiles)
+	// The package level names of every file are declared first, so they
+	// can be used by the other files.
+	methods := map[string]*Symbol{}
+	for _, program := range programs {
+		r.file = program.File
+		r.scope = pkg
+		r.collect(program, methods)
+	}
+	for i, program := range programs {
+		r.file = program.File
+		r.scope = r.info.Files[i]
+		for _, stmt := range program.Statements {
+			r.statement(stmt)
+		}
+	}
+
+	order := map[string]int{}
+	for i, program := range programs {
+		order[program.File] = i
+	}
+	sort.SliceStable(r.errors, func(i, j int) bool {
+		a, b := r.errors[i].(*token.Error), r.errors[j].(*token.Error)
+		if a.File != b.File {
+			return order[a.File] < order[b.File]
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return r.info, r.errors
+}
+
+func (r *resolver) errorf(tok token.Token, format string, args ...interface{}) {
+	err := token.Errorf(tok, format, args...)
+	err.File = r.file
+	r.errors = append(r.errors, err)
+}
+
+// position formats the position of the declaration of sym for messages,
+// with its file when it is not the file being resolved.
+func (r *resolver) position(sym *Symbol) string {
+	if sym.File != r.file {
+		return fmt.Sprintf("%s:%d:%d", sym.File, sym.Decl.Line, sym.Decl.Column)
+	}
+	return fmt.Sprintf("%d:%d", sym.Decl.Line, sym.Decl.Column)
+}
+
+// goDecls declares the top level names of the Go files of the package.
+func (r *resolver) goDecls(fset *gotoken.FileSet, files []*goast.File) {
+	for _, file := range files {
+		declare := func(ident *goast.Ident, kind SymbolKind) {
+			if ident.Name == "_" || ident.Name == "init" && kind == Func {
+				return
+			}
+			pos := fset.Position(ident.Pos())
+			r.file = pos.Filename
+			r.declare(token.Token{Type: token.IDENT, Literal: ident.Name, Line: pos.Line, Column: pos.Column}, ident.Name, kind)
+		}
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *goast.FuncDecl:
+				if d.Recv == nil {
+					declare(d.Name, Func)
+				}
+			case *goast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *goast.TypeSpec:
+						declare(s.Name, Type)
+					case *goast.ValueSpec:
+						for _, name := range s.Names {
+							declare(name, Var)
+						}
+					}
+				}
+			}
+		}
+	}
+	r.file = ""
+}
+
+// collect declares the package level names first, as they can be used
+// before the line declaring them.
+// The imports of each file are declared in a scope of their own, between
+// the package scope and the scopes of its functions.
+func (r *resolver) collect(program *ast.Program, methods map[string]*Symbol) {
+	file := newScope(FileScope, r.scope)
+	file.File = program.File
+	r.info.Files = append(r.info.Files, file)
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *ast.ImportStatement:
+			if lit, ok := s.PackageName.(*ast.StringLiteral); ok {
+				tok := lit.Token
+				tok.Literal = lit.Value
+				r.scope = file
+				r.declare(tok, packageName(lit.Value), Package)
+				r.scope = file.Parent
+			}
+		case *ast.StructAttributes:
+			if s.Token != nil {
+				r.declare(s.Name, s.Name.Literal, Type)
+			}
+		case *ast.VarStatement:
+			r.info.Defs[s.Name] = r.declare(s.Name.Token, s.Name.Value, Var)
+		case *ast.ExpressionStatement:
+			switch e := s.Expression.(type) {
+			case *ast.StructStatement:
+				if e.Name != nil {
+					r.declare(*e.Name, e.Name.Literal, Type)
+				}
+			case *ast.FunctionLiteral:
+				if e.Name == "" {
+					continue
+				}
+				if e.Receiver == nil {
+					r.declare(e.NameToken, e.Name, Func)
+					continue
+				}
+				key := strings.TrimPrefix(e.Receiver.Type.Value, "*") + "." + e.Name
+				if prev, ok := methods[key]; ok {
+					r.errorf(e.NameToken, "method %s already declared at %s", key, r.position(prev))
+					continue
+				}
+				sym := &Symbol{Name: e.Name, Kind: Method, Decl: e.NameToken, File: r.file, Scope: r.scope}
+				methods[key] = sym
+				r.ref(e.NameToken, sym)
+			}
+		}
+	}
+}
+
+// packageName returns the name a package is imported as, which is the
+// last element of its path without a major version suffix.
+func packageName(importPath string) string {
+	name := path.Base(importPath)
+	if majorVersion.MatchString(name) {
+		name = path.Base(path.Dir(importPath))
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// declare adds a symbol to the current scope, reporting a previous
+// declaration of the same name in that scope.
+func (r *resolver) declare(tok token.Token, name string, kind SymbolKind) *Symbol {
+	if prev, ok := r.scope.names[name]; ok {
+		r.errorf(tok, "%s redeclared in this block, previous declaration at %s", name, r.position(prev))
+		return prev
+	}
+	sym := &Symbol{Name: name, Kind: kind, Decl: tok, File: r.file, Scope: r.scope}
+	r.scope.Symbols = append(r.scope.Symbols, sym)
+	r.scope.names[name] = sym
+	tok.Literal = name
+	r.ref(tok, sym)
+	return sym
+}
+
+func (r *resolver) ref(tok token.Token, sym *Symbol) {
+	r.info.Refs = append(r.info.Refs, Ref{File: r.file, Tok: tok, Symbol: sym})
+}
+
+// use resolves the name at tok. Assignments to a variable resolve it
+// without counting as a use.
+func (r *resolver) use(tok token.Token, name string, counts bool) *Symbol {
+	sym := r.scope.Lookup(name)
+	if sym == nil {
+		r.errorf(tok, "undefined: %s", name)
+		return nil
+	}
+	if counts {
+		sym.uses++
+	}
+	tok.Literal = name
+	r.ref(tok, sym)
+	return sym
+}
+
+func (r *resolver) identifier(ident *ast.Identifier, counts bool) {
+	if sym := r.use(ident.Token, ident.Value, counts); sym != nil {
+		r.info.Uses[ident] = sym
+	}
+}
+
+// topLevel reports whether the resolver is outside of every function.
+func (r *resolver) topLevel() bool {
+	return r.scope.Kind == PackageScope || r.scope.Kind == FileScope
+}
+
+func (r *resolver) openScope(kind ScopeKind, start, end token.Token) {
+	r.scope = newScope(kind, r.scope)
+	r.scope.Start, r.scope.End = start, end
+}
+
+// closeScope leaves the current scope, reporting its unused variables.
+func (r *resolver) closeScope() {
+	for _, sym := range r.scope.Symbols {
+		if sym.Kind == Var && sym.uses == 0 && !r.ignoreUnused {
+			r.errorf(sym.Decl, "declared and not used: %s", sym.Name)
+		}
+	}
+	r.scope = r.scope.Parent
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.VarStatement:
+		r.typeName(s.Type)
+		r.expression(s.Value)
+		if !r.topLevel() {
+			r.info.Defs[s.Name] = r.declare(s.Name.Token, s.Name.Value, Var)
+		}
+	case *ast.AssignStatement:
+		if ident, ok := s.Target.(*ast.Identifier); ok {
+			r.identifier(ident, false)
+		} else {
+			r.expression(s.Target)
+		}
+		r.expression(s.Value)
+	case *ast.ReturnStatement:
+		r.expression(s.ReturnValue)
+	case *ast.ExpressionStatement:
+		// `break` and `continue` are parsed as identifiers.
+		if ident, ok := s.Expression.(*ast.Identifier); ok && (ident.Value == "break" || ident.Value == "continue") {
+			return
+		}
+		r.expression(s.Expression)
+	case *ast.BlockStatement:
+		r.block(s)
+	case *ast.UnsafeStatement:
+		r.block(s.Body)
+	case *ast.StructAttributes:
+		r.typeToken(s.Type)
+	case *ast.SwitchStatement:
+		r.token(s.Input)
+		for _, cl := range s.Case {
+			if cl == nil {
+				continue
+			}
+			r.token(cl.Token)
+			r.openScope(CaseScope, cl.Token, cl.Body.End)
+			r.statements(cl.Body)
+			r.closeScope()
+		}
+	}
+}
+
+func (r *resolver) statements(block *ast.BlockStatement) {
+	for _, stmt := range block.Statements {
+		r.statement(stmt)
+	}
+}
+
+func (r *resolver) block(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	r.openScope(BlockScope, block.Token, block.End)
+	r.statements(block)
+	r.closeScope()
+}
+
+// token resolves a switch input or case written as a single token.
+func (r *resolver) token(tok token.Token) {
+	if tok.Type == token.IDENT {
+		r.use(tok, tok.Literal, true)
+	}
+}
+
+func (r *resolver) expression(exp ast.Expression) {
+	switch e := exp.(type) {
+	case *ast.Identifier:
+		r.identifier(e, true)
+	case *ast.VarExpression:
+		r.expression(e.Value)
+		r.declare(e.Ident, e.Ident.Literal, Var)
+	case *ast.InfixExpression:
+		r.expression(e.Left)
+		r.expression(e.Right)
+	case *ast.PrefixExpression:
+		r.expression(e.Right)
+	case *ast.GroupedExpression:
+		r.expression(e.Expression)
+	case *ast.IfExpression:
+		r.expression(e.Condition)
+		r.block(e.Consequence)
+		r.block(e.Alternative)
+	case *ast.FunctionLiteral:
+		r.function(e)
+	case *ast.CallExpression:
+		r.expression(e.Function)
+		for _, arg := range e.Arguments {
+			r.expression(arg)
+		}
+	case *ast.SelectorExpression:
+		// Field and method names depend on the type of the left side and
+		// are resolved by the type checker.
+		r.expression(e.Left)
+	case *ast.IndexExpression:
+		r.expression(e.Left)
+		r.expression(e.Index)
+	case *ast.ArrayLiteral:
+		r.typeName(e.Type)
+		for _, el := range e.Elements {
+			r.expression(el)
+		}
+	case *ast.MapLiteral:
+		r.typeName(e.Key)
+		r.typeName(e.Value)
+		if e.KeyValue != nil {
+			for _, pair := range e.KeyValue.Pairs {
+				r.expression(pair.Key)
+				r.expression(pair.Value)
+			}
+		}
+	case *ast.StructLiteral:
+		r.typeName(e.Type)
+		for _, f := range e.Fields {
+			r.expression(f.Value)
+		}
+	case *ast.StructStatement:
+		for _, attr := range e.Attributes {
+			r.typeToken(attr.Type)
+		}
+	case *ast.MacroCall:
+		for _, arg := range e.Arguments {
+			r.expression(arg)
+		}
+	}
+}
+
+func (r *resolver) function(fn *ast.FunctionLiteral) {
+	if fn.Receiver != nil {
+		r.typeName(fn.Receiver.Type)
+	}
+	for _, p := range fn.Params {
+		r.typeName(p.Type)
+	}
+	if ret, ok := fn.ReturnType.(*ast.Identifier); ok {
+		r.typeName(ret)
+	}
+	if fn.Name != "" && fn.Receiver == nil && !r.topLevel() {
+		r.declare(fn.NameToken, fn.Name, Func)
+	}
+	if fn.Body == nil {
+		return
+	}
+
+	// Parameters and the top level statements of the body share a scope,
+	// so a parameter can not be redeclared in the body.
+	r.openScope(FuncScope, fn.Token, fn.Body.End)
+	if fn.Receiver != nil {
+		r.info.Defs[fn.Receiver.Name] = r.declare(fn.Receiver.Name.Token, fn.Receiver.Name.Value, Param)
+	}
+	for _, p := range fn.Params {
+		r.info.Defs[p.Name] = r.declare(p.Name.Token, p.Name.Value, Param)
+	}
+	r.statements(fn.Body)
+	r.closeScope()
+}
+
+// typeName resolves the names in a type spelled by the parser, like
+// "[]*User" or "map[string]json.RawMessage".
+func (r *resolver) typeName(typ *ast.Identifier) {
+	if typ == nil {
+		return
+	}
+	names := typeNames.FindAllString(typ.Value, -1)
+	// The position of the name is only known when the type is written as
+	// a name after pointer and slice markers, like `[]*User`.
+	prefix := strings.TrimLeft(typ.Value, "[]*")
+	simple := len(names) == 1 && prefix == names[0]
+
+	for _, name := range names {
+		if name == "map" || name == "interface" || name == "func" {
+			continue
+		}
+		tok := typ.Token
+		if simple {
+			tok.Column += len(typ.Value) - len(prefix)
+		}
+		if pkg := strings.Index(name, "."); pkg >= 0 {
+			name = name[:pkg]
+		}
+		sym := r.use(tok, name, true)
+		if simple && sym != nil {
+			r.info.Uses[typ] = sym
+		}
+	}
+}
+
+var typeNames = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_.]*`)
+
+// typeToken resolves the type of a struct field or type declaration.
+func (r *resolver) typeToken(tok token.Token) {
+	if tok.Type == token.IDENT {
+		r.use(tok, tok.Literal, true)
+	}
+}
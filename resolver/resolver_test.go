@@ -0,0 +1,209 @@
+package resolver
+
+import (
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"testing"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+)
+
+func resolve(t *testing.T, input string) (*Info, []error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%s\nparse errors: %q", input, p.Errors())
+	}
+	return Resolve(program)
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"var x = 1\nvar y = x", nil},
+		{"var y = x", []string{"1:9: undefined: x"}},
+		{"var y = f()\nfunc f() int {\nreturn 1\n}", nil},
+		{"func f() {\nvar x = 1\n}", []string{"2:5: declared and not used: x"}},
+		{"func f() {\nx := 1\n}", []string{"2:1: declared and not used: x"}},
+		{"func f() {\nvar x = 1\nx = 2\n}", []string{"2:5: declared and not used: x"}},
+		{"var x = 1\nvar x = 2", []string{"2:5: x redeclared in this block, previous declaration at 1:5"}},
+		{"func f() {}\nfunc f() {}", []string{"2:6: f redeclared in this block, previous declaration at 1:6"}},
+		{"func f(a int) {\nvar a = 1\nprintln(a)\n}", []string{"2:5: a redeclared in this block, previous declaration at 1:8"}},
+		{"var a = 1\nfunc f() {\nvar a = 2\nprintln(a)\n}", nil},
+		{
+			"func f(a bool) {\nif a {\nvar ok = 1\nprintln(ok)\n} else {\nvar ok = 2\nprintln(ok)\n}\n}",
+			nil,
+		},
+		{
+			"func f() {\nvar ok = 1\nvar ok = 2\nprintln(ok)\n}",
+			[]string{"3:5: ok redeclared in this block, previous declaration at 2:5"},
+		},
+		{
+			"func f(c byte) {\nswitch c {\n'a' => {\nvar n = 1\nprintln(n)\n}, 'b' => {\nvar n = 2\nprintln(n)\n}\n}\n}",
+			nil,
+		},
+		{"func f(c byte) {\nswitch c {\n'a' => {\nbreak\n}\n}\n}", nil},
+		{"func f() {\nswitch c {\n'a' => {\nprintln(1)\n}\n}\n}", []string{"2:8: undefined: c"}},
+		{"func f(u *User) {}", []string{"1:11: undefined: User"}},
+		{"struct User(Name string)\nfunc f(u *User) []User {\nreturn [*u]\n}", nil},
+		{"struct User(Name string)\nfunc (u *User) Get() string {\nreturn u.Name\n}", nil},
+		{
+			"struct User(Name string)\nfunc (u User) Get() {}\nfunc (u *User) Get() {}",
+			[]string{"3:16: method User.Get already declared at 2:15"},
+		},
+		{"struct Post(Author User)", []string{"1:20: undefined: User"}},
+		{"type Name string\nstruct User(Name Name)", nil},
+		{"var m = map(string, Kind)", []string{"1:21: undefined: Kind"}},
+		{"import \"encoding/json\"\nvar raw json.RawMessage", nil},
+		{"import \"gopkg.in/yaml.v3\"\nvar y = yaml.Marshal", nil},
+		{"import \"github.com/jackc/pgx/v5\"\nvar p = pgx.Connect", nil},
+		{"var s = strings.Split", []string{"1:9: undefined: strings"}},
+		{"var u = User{Name: name}", []string{"1:9: undefined: User", "1:20: undefined: name"}},
+		{"var xs = [1, 2]\nvar n = len(xs)\nvar ok = xs.contains(1)", nil},
+		{"var f = func(x int) int { return x + y }", []string{"1:38: undefined: y"}},
+		{"macro twice!(x) { x + x }\nvar y = 1\nvar z = twice!(y)", nil},
+	}
+
+	for _, tt := range tests {
+		_, errs := resolve(t, tt.input)
+		if len(errs) != len(tt.expected) {
+			t.Errorf("%s\nexpected %d errors, got=%q", tt.input, len(tt.expected), errs)
+			continue
+		}
+		for i, err := range errs {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("%s\nwrong error. expected=%q, got=%q", tt.input, tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+func TestInfo_Definition(t *testing.T) {
+	input := `struct User(Name string)
+func greet(u *User) string {
+	var name = u.Name
+	return name
+}
+var g = greet`
+	info, errs := resolve(t, input)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+
+	tests := []struct {
+		line, column int
+		name         string
+		kind         SymbolKind
+		declLine     int
+		declColumn   int
+	}{
+		{2, 16, "User", Type, 1, 8},
+		{3, 13, "u", Param, 2, 12},
+		{4, 9, "name", Var, 3, 6},
+		{4, 12, "name", Var, 3, 6},
+		{6, 9, "greet", Func, 2, 6},
+		{2, 6, "greet", Func, 2, 6},
+	}
+	for _, tt := range tests {
+		sym := info.Definition(tt.line, tt.column)
+		if sym == nil {
+			t.Errorf("%d:%d: no symbol", tt.line, tt.column)
+			continue
+		}
+		if sym.Name != tt.name || sym.Kind != tt.kind || sym.Decl.Line != tt.declLine || sym.Decl.Column != tt.declColumn {
+			t.Errorf("%d:%d: expected %s %s at %d:%d, got=%s %s at %d:%d", tt.line, tt.column,
+				tt.kind, tt.name, tt.declLine, tt.declColumn, sym.Kind, sym.Name, sym.Decl.Line, sym.Decl.Column)
+		}
+	}
+
+	if sym := info.Definition(3, 16); sym != nil {
+		t.Errorf("3:16: field names are not resolved, got=%s", sym.Name)
+	}
+
+	refs := info.References(info.Definition(4, 9))
+	if len(refs) != 2 || refs[0].Line != 3 || refs[1].Line != 4 {
+		t.Errorf("wrong references of name, got=%v", refs)
+	}
+}
+
+func TestScope_Innermost(t *testing.T) {
+	input := `func f(a int) {
+	if a > 0 {
+		var b = a
+		println(b)
+	}
+}`
+	info, errs := resolve(t, input)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+
+	scope := info.Package.Innermost(4, 3)
+	if scope.Kind != BlockScope {
+		t.Fatalf("expected a block scope, got=%d", scope.Kind)
+	}
+	for _, name := range []string{"b", "a", "f", "println"} {
+		if scope.Lookup(name) == nil {
+			t.Errorf("%s is not visible in the block", name)
+		}
+	}
+	if scope := info.Package.Innermost(6, 3); scope != info.Files[0] {
+		t.Errorf("expected the file scope after f, got=%d", scope.Kind)
+	}
+}
+
+func TestConfig_Resolve(t *testing.T) {
+	sources := map[string]string{
+		"user.yuk":  "package models\nimport \"strings\"\nstruct User(Name string)\nfunc Upper(u User) string {\nreturn strings.ToUpper(u.Name)\n}",
+		"admin.yuk": "package models\nfunc Admin() User {\nreturn User{Name: root}\n}\nfunc Upper() {}\nvar s = strings.ToLower",
+	}
+	var programs []*ast.Program
+	for _, name := range []string{"user.yuk", "admin.yuk"} {
+		p := parser.New(lexer.New(sources[name]))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s: parse errors: %q", name, p.Errors())
+		}
+		program.File = name
+		programs = append(programs, program)
+	}
+	fset := gotoken.NewFileSet()
+	goFile, err := goparser.ParseFile(fset, "root.go", "package models\n\nconst root = \"root\"\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &Config{Fset: fset, GoFiles: []*goast.File{goFile}}
+	info, errs := conf.Resolve(programs...)
+	expected := []string{
+		"admin.yuk:5:6: Upper redeclared in this block, previous declaration at user.yuk:4:6",
+		"admin.yuk:6:9: undefined: strings",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got=%q", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong error. expected=%q, got=%q", expected[i], err.Error())
+		}
+	}
+
+	if sym := info.DefinitionAt("admin.yuk", 2, 16); sym == nil || sym.File != "user.yuk" || sym.Decl.Line != 3 {
+		t.Errorf("User in admin.yuk does not refer to user.yuk:3, got=%+v", sym)
+	}
+	if sym := info.DefinitionAt("admin.yuk", 3, 19); sym == nil || sym.File != "root.go" || sym.Decl.Line != 3 {
+		t.Errorf("root does not refer to root.go:3, got=%+v", sym)
+	}
+	if len(info.Files) != 2 || info.Files[0].Lookup("strings") == nil || info.Files[1].Lookup("strings") != nil {
+		t.Errorf("imports are not scoped to their file")
+	}
+	refs := info.ReferencesAt(info.DefinitionAt("user.yuk", 3, 8))
+	if len(refs) != 4 || refs[0].File != "admin.yuk" || refs[3].File != "user.yuk" {
+		t.Errorf("wrong references of User, got=%+v", refs)
+	}
+}
@@ -0,0 +1,131 @@
+package resolver
+
+import "github.com/ahmadrosid/yuk/token"
+
+type SymbolKind int
+
+const (
+	Var SymbolKind = iota
+	Param
+	Func
+	Method
+	Type
+	Package
+	// Universe is a predeclared name like int, len or nil.
+	Universe
+)
+
+var kindNames = map[SymbolKind]string{
+	Var:      "var",
+	Param:    "param",
+	Func:     "func",
+	Method:   "method",
+	Type:     "type",
+	Package:  "package",
+	Universe: "universe",
+}
+
+func (k SymbolKind) String() string { return kindNames[k] }
+
+// Symbol is a declared name.
+type Symbol struct {
+	Name string
+	Kind SymbolKind
+	// Decl is the token declaring the name, it is zero for the universe.
+	Decl token.Token
+	// File is the name of the file declaring the name, empty when a single
+	// program is resolved.
+	File  string
+	Scope *Scope
+	uses  int
+}
+
+type ScopeKind int
+
+const (
+	PackageScope ScopeKind = iota
+	FuncScope
+	BlockScope
+	// CaseScope is the scope of a switch arm.
+	CaseScope
+	UniverseScope
+	// FileScope holds the imports of one file of the package.
+	FileScope
+)
+
+// Scope holds the symbols declared in a package, function, block or
+// switch arm.
+type Scope struct {
+	Kind     ScopeKind
+	Parent   *Scope
+	Children []*Scope
+	// Symbols are in declaration order.
+	Symbols []*Symbol
+	// Start and End delimit the scope in the source, they are zero for the
+	// package, file and universe scopes.
+	Start, End token.Token
+	// File is the name of the file of a file scope.
+	File  string
+	names map[string]*Symbol
+}
+
+func newScope(kind ScopeKind, parent *Scope) *Scope {
+	s := &Scope{Kind: kind, Parent: parent, names: map[string]*Symbol{}}
+	if parent != nil {
+		parent.Children = append(parent.Children, s)
+	}
+	return s
+}
+
+// Lookup returns the symbol called name in s or its enclosing scopes.
+func (s *Scope) Lookup(name string) *Symbol {
+	for ; s != nil; s = s.Parent {
+		if sym, ok := s.names[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the position line:column is inside s.
+func (s *Scope) Contains(line, column int) bool {
+	if s.Start.Line == 0 {
+		return s.Kind != FuncScope && s.Kind != BlockScope && s.Kind != CaseScope
+	}
+	return before(s.Start.Line, s.Start.Column, line, column) && before(line, column, s.End.Line, s.End.Column)
+}
+
+// Innermost returns the innermost scope under s containing line:column.
+func (s *Scope) Innermost(line, column int) *Scope {
+	for _, child := range s.Children {
+		if child.Contains(line, column) {
+			return child.Innermost(line, column)
+		}
+	}
+	return s
+}
+
+func before(line1, column1, line2, column2 int) bool {
+	return line1 < line2 || line1 == line2 && column1 <= column2
+}
+
+var universe = newScope(UniverseScope, nil)
+
+func init() {
+	names := []string{
+		// types
+		"any", "bool", "byte", "error", "float32", "float64", "int", "int8", "int16",
+		"int32", "int64", "rune", "string", "uint", "uint8", "uint16", "uint32",
+		"uint64", "uintptr",
+		// functions
+		"append", "cap", "clear", "close", "copy", "delete", "len", "make", "max",
+		"min", "new", "panic", "print", "println", "recover",
+		// constants
+		"false", "iota", "nil", "true",
+	}
+	for _, name := range names {
+		sym := &Symbol{Name: name, Kind: Universe, Scope: universe}
+		universe.Symbols = append(universe.Symbols, sym)
+		universe.names[name] = sym
+	}
+}
@@ -0,0 +1,47 @@
+//go:build ignore
+// +build ignore
+
+// Genstd writes stdfiles.go, holding the Go files of the std package for
+// the modules that do not require yuk. Run it with go generate when std
+// changes.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	files, err := filepath.Glob(filepath.Join("..", "std", "*.go"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var out bytes.Buffer
+	out.WriteString("// Code generated by genstd.go; DO NOT EDIT.\n\npackage run\n\n")
+	out.WriteString("// stdFiles are the Go files of the std package, without its tests.\n")
+	out.WriteString("var stdFiles = []struct{ name, src string }{\n")
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&out, "{%q, %s},\n", filepath.Base(file), strconv.Quote(string(src)))
+	}
+	out.WriteString("}\n")
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("stdfiles.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
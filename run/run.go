@@ -0,0 +1,273 @@
+// Package run compiles a yuk program to a temporary directory and builds
+// it with the local Go toolchain, for `yuk run`.
+//
+// Inside a Go module, the directory is created under the module root, so
+// the program builds against the module and its requirements. Elsewhere it
+// holds a module of its own, with a copy of the std helper package. A
+// module that does not require yuk gets the copy too, in the directory,
+// and the imports of std are rewritten to it.
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/build"
+	"github.com/ahmadrosid/yuk/sourcemap"
+)
+
+// goVersion is the go directive of a synthesized go.mod.
+const goVersion = "1.18"
+
+// yukModule is the module of the std helper package.
+var yukModule = path.Dir(ast.ExtensionPackage)
+
+// Program is a yuk program compiled to Go.
+type Program struct {
+	// Dir is the temporary directory holding the Go files.
+	Dir string
+	// Module is the root of the Go module the program is built in.
+	Module string
+	// Files are the Go files, and maps their source maps.
+	Files []string
+	maps  map[string]*sourcemap.Map
+}
+
+// Compile compiles the yuk files of a main package, which must all be in
+// one directory with its hand-written goFiles, to a new temporary
+// directory. The Go files are copied next to the generated ones. Remove
+// deletes the directory.
+func Compile(files, goFiles []string) (*Program, []error) {
+	if len(files) == 0 {
+		return nil, []error{fmt.Errorf("no .yuk files to run")}
+	}
+	dir := filepath.Dir(files[0])
+	for _, file := range append(files[1:], goFiles...) {
+		if filepath.Dir(file) != dir {
+			return nil, []error{fmt.Errorf("named files must all be in one directory; have %s and %s", dir, filepath.Dir(file))}
+		}
+	}
+
+	p := &Program{maps: map[string]*sourcemap.Map{}}
+	cfg := &build.Config{LineDirectives: true, SourceMap: true}
+	module, err := build.FindModule(dir)
+	if err == nil && module != nil {
+		p.Module = module.Root
+		// Unlike a name starting with a dot, the underscore leaves the
+		// directory out of ./... patterns yet lets it hold an importable
+		// package.
+		p.Dir, err = ioutil.TempDir(module.Root, "_yuk-run-")
+		if err == nil && !requiresYuk(module) {
+			stdDir := filepath.Join(p.Dir, path.Base(ast.ExtensionPackage))
+			err = writeStd(stdDir)
+			if importPath, ok := module.ImportPath(stdDir); ok {
+				cfg.ImportMap = map[string]string{ast.ExtensionPackage: importPath}
+			}
+		}
+	} else if err == nil {
+		p.Dir, err = ioutil.TempDir("", "yuk-run-")
+		p.Module = p.Dir
+		if err == nil {
+			err = writeModule(p.Dir)
+		}
+	}
+	if err != nil {
+		p.Remove()
+		return nil, []error{err}
+	}
+
+	// The Go files are written flat into the temporary directory.
+	outputPath := func(path string) (string, error) {
+		return filepath.Join(p.Dir, strings.TrimSuffix(filepath.Base(path), ".yuk")+".go"), nil
+	}
+	pkg := &build.Package{Dir: dir, Files: files, GoFiles: goFiles}
+	outputs, errors := cfg.CompileTo(pkg, outputPath)
+	if len(errors) == 0 && pkg.Name != "main" {
+		errors = append(errors, &build.Error{Path: files[0], Err: fmt.Errorf("package %s is not a main package", pkg.Name)})
+	}
+	if len(errors) > 0 {
+		p.Remove()
+		return nil, errors
+	}
+	for _, out := range outputs {
+		if err := out.Write(); err != nil {
+			p.Remove()
+			return nil, []error{err}
+		}
+		p.Files = append(p.Files, out.Path)
+		p.maps[out.Path] = out.Map
+	}
+	for _, file := range goFiles {
+		src, err := ioutil.ReadFile(file)
+		if err == nil {
+			out := filepath.Join(p.Dir, filepath.Base(file))
+			err = ioutil.WriteFile(out, src, 0644)
+			p.Files = append(p.Files, out)
+		}
+		if err != nil {
+			p.Remove()
+			return nil, []error{err}
+		}
+	}
+	return p, nil
+}
+
+// Build builds the program with `go build`, returning the path of the
+// executable. The positions in the output of a failed build are remapped
+// to the yuk files.
+func (p *Program) Build() (string, error) {
+	exe := filepath.Join(p.Dir, "main")
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	args := append([]string{"build", "-o", exe}, p.Files...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = p.Module
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return "", err
+		}
+		return "", fmt.Errorf("%s", strings.TrimSpace(p.Remap(string(out), cmd.Dir)))
+	}
+	return exe, nil
+}
+
+// Remove deletes the temporary directory of the program.
+func (p *Program) Remove() error {
+	if p.Dir == "" {
+		return nil
+	}
+	return os.RemoveAll(p.Dir)
+}
+
+// position matches a position at the start of a line of go output.
+var position = regexp.MustCompile(`(?m)^(\S+?\.(?:go|yuk)):(\d+)(?::(\d+))?`)
+
+// Remap rewrites the positions starting the lines of the output of a go
+// command run in dir. Positions in the generated files are moved to the yuk
+// source using their source maps, and all paths are made relative to the
+// current directory. The `# package` headers go prints are dropped.
+func (p *Program) Remap(output, dir string) string {
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			continue
+		}
+		out.WriteString(position.ReplaceAllStringFunc(line, func(match string) string {
+			return p.remap(position.FindStringSubmatch(match), dir)
+		}))
+	}
+	return out.String()
+}
+
+func (p *Program) remap(match []string, dir string) string {
+	file := match[1]
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	line, _ := strconv.Atoi(match[2])
+	column, _ := strconv.Atoi(match[3])
+
+	if m := p.maps[file]; m != nil {
+		source, ok := m.SourcePosition(sourcemap.Position{Line: line, Column: column})
+		if !ok {
+			return match[0]
+		}
+		file = filepath.Join(filepath.Dir(file), filepath.FromSlash(m.Source))
+		line, column = source.Line, source.Column
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+	}
+	if match[3] == "" {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return fmt.Sprintf("%s:%d:%d", file, line, column)
+}
+
+// requiresYuk reports whether the module resolves the std package: it is
+// yuk itself, or requires it.
+func requiresYuk(m *build.Module) bool {
+	if m.Path == yukModule {
+		return true
+	}
+	mod, err := ioutil.ReadFile(filepath.Join(m.Root, "go.mod"))
+	if err != nil {
+		return false
+	}
+	block := false
+	for _, line := range strings.Split(string(mod), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case block && fields[0] == ")":
+			block = false
+			continue
+		case fields[0] == "require":
+			fields = fields[1:]
+			if len(fields) > 0 && fields[0] == "(" {
+				block = true
+				continue
+			}
+		case !block:
+			continue
+		}
+		if len(fields) > 0 && strings.Trim(fields[0], `"`) == yukModule {
+			return true
+		}
+	}
+	return false
+}
+
+// writeModule makes dir the root of a module requiring yuk, replaced by a
+// copy of the std package.
+func writeModule(dir string) error {
+	var mod bytes.Buffer
+	fmt.Fprintf(&mod, "module yukrun\n\ngo %s\n\n", goVersion)
+	fmt.Fprintf(&mod, "require %s v0.0.0\n\n", yukModule)
+	fmt.Fprintf(&mod, "replace %s => ./yuk\n", yukModule)
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), mod.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	yukMod := fmt.Sprintf("module %s\n\ngo %s\n", yukModule, goVersion)
+	if err := os.MkdirAll(filepath.Join(dir, "yuk"), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "yuk", "go.mod"), []byte(yukMod), 0644); err != nil {
+		return err
+	}
+	return writeStd(filepath.Join(dir, "yuk", path.Base(ast.ExtensionPackage)))
+}
+
+//go:generate go run genstd.go
+
+// writeStd writes a copy of the std package to dir.
+func writeStd(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range stdFiles {
+		if err := ioutil.WriteFile(filepath.Join(dir, f.name), []byte(f.src), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
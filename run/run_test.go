@@ -0,0 +1,157 @@
+package run
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ahmadrosid/yuk/sourcemap"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCompile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	lib := writeFile(t, dir, "lib.yuk", "package lib")
+	main := writeFile(t, dir, "main.yuk", "package main\n\nfunc main() {\n    println(x)\n}")
+	other := writeFile(t, t.TempDir(), "other.yuk", "package main")
+
+	tests := []struct {
+		files    []string
+		expected string
+	}{
+		{nil, "no .yuk files to run"},
+		{[]string{lib}, lib + ": package lib is not a main package"},
+		{[]string{main}, main + ":4:13: undefined: x"},
+		{[]string{main, other}, "named files must all be in one directory"},
+	}
+	for _, tt := range tests {
+		_, errs := Compile(tt.files, nil)
+		if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), tt.expected) {
+			t.Errorf("%q: expected %q, got=%q", tt.files, tt.expected, errs)
+		}
+	}
+}
+
+// The copy of std must be regenerated with go generate when std changes.
+func TestStdFiles(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "std", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := map[string]string{}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src[filepath.Base(file)] = string(data)
+	}
+	for _, f := range stdFiles {
+		if src[f.name] != f.src {
+			t.Errorf("%s differs from std, run go generate", f.name)
+		}
+		delete(src, f.name)
+	}
+	for name := range src {
+		t.Errorf("%s of std is missing, run go generate", name)
+	}
+}
+
+func TestProgram_Remap(t *testing.T) {
+	p := &Program{maps: map[string]*sourcemap.Map{
+		"/tmp/run/main.go": {
+			Source:   "../src/main.yuk",
+			Mappings: []sourcemap.Mapping{{Generated: sourcemap.Position{Line: 7, Column: 1}, Source: sourcemap.Position{Line: 3, Column: 5}, Length: 3}},
+		},
+	}}
+
+	output := "# command-line-arguments\n./main.go:7:2: undefined: foo\n/abs/other.go:1:1: x\n\tmain.go:7 +0x1d\n"
+	expected := "/tmp/src/main.yuk:3:6: undefined: foo\n/abs/other.go:1:1: x\n\tmain.go:7 +0x1d\n"
+	if got := p.Remap(output, "/tmp/run"); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestProgram_Build(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds with the go command")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no go command")
+	}
+
+	src := `package main
+
+import "fmt"
+
+func main() {
+    var xs = [1, 2, 3]
+    fmt.Println(xs.map(func(x int) int { return x * 2 }))
+}`
+	// Outside of a module, and in a module that does not require yuk,
+	// whose build must not look std up.
+	module := t.TempDir()
+	writeFile(t, module, "go.mod", "module example.com/m\n\ngo 1.18\n")
+	for _, dir := range []string{t.TempDir(), module} {
+		main := writeFile(t, dir, "main.yuk", src)
+		p, errs := Compile([]string{main}, nil)
+		if len(errs) > 0 {
+			t.Fatalf("unexpected errors: %q", errs)
+		}
+		defer p.Remove()
+
+		exe, err := p.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command(exe).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != "[2 4 6]\n" {
+			t.Errorf("%s: wrong output. got=%q", dir, out)
+		}
+	}
+}
+
+func TestProgram_Build_GoFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds with the go command")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no go command")
+	}
+
+	dir := t.TempDir()
+	main := writeFile(t, dir, "main.yuk", "package main\n\nfunc main() {\n    println(greet(\"yuk\"))\n}")
+	greet := writeFile(t, dir, "greet.go", "package main\n\nfunc greet(name string) string { return \"hi \" + name }\n")
+	p, errs := Compile([]string{main}, []string{greet})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+	defer p.Remove()
+
+	exe, err := p.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(exe).CombinedOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "hi yuk\n" {
+		t.Errorf("wrong output. got=%q", out)
+	}
+}
@@ -0,0 +1,10 @@
+// Code generated by genstd.go; DO NOT EDIT.
+
+package run
+
+// stdFiles are the Go files of the std package, without its tests.
+var stdFiles = []struct{ name, src string }{
+	{"debug.go", "package std\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\n// Dbg prints label and v to stderr and returns v, so it can wrap any\n// expression. It backs the dbg! macro.\nfunc Dbg[T any](label string, v T) T {\n\tfmt.Fprintf(os.Stderr, \"%s = %#v\\n\", label, v)\n\treturn v\n}\n"},
+	{"option.go", "package std\n\n// Option holds a value that may be absent, used instead of nil pointers\n// or sentinel values.\ntype Option[T any] struct {\n\tvalue T\n\tok    bool\n}\n\nfunc Some[T any](v T) Option[T] {\n\treturn Option[T]{value: v, ok: true}\n}\n\nfunc None[T any]() Option[T] {\n\treturn Option[T]{}\n}\n\nfunc (o Option[T]) IsSome() bool {\n\treturn o.ok\n}\n\nfunc (o Option[T]) IsNone() bool {\n\treturn !o.ok\n}\n\n// Get returns the value and whether it is present.\nfunc (o Option[T]) Get() (T, bool) {\n\treturn o.value, o.ok\n}\n\n// UnwrapOr returns the value, or def when it is absent.\nfunc (o Option[T]) UnwrapOr(def T) T {\n\tif !o.ok {\n\t\treturn def\n\t}\n\treturn o.value\n}\n\n// Unwrap returns the value and panics when it is absent.\nfunc (o Option[T]) Unwrap() T {\n\tif !o.ok {\n\t\tpanic(\"std: unwrap of an empty Option\")\n\t}\n\treturn o.value\n}\n"},
+	{"slice.go", "// Package std holds the runtime helpers referenced by code generated from\n// yuk, such as the slice extension methods `xs.map(f)` or `xs.first()`.\npackage std\n\n// Map returns a new slice with f applied to every element of s.\nfunc Map[T, U any](s []T, f func(T) U) []U {\n\tout := make([]U, 0, len(s))\n\tfor _, v := range s {\n\t\tout = append(out, f(v))\n\t}\n\treturn out\n}\n\n// Filter returns the elements of s for which f returns true.\nfunc Filter[T any](s []T, f func(T) bool) []T {\n\tout := make([]T, 0, len(s))\n\tfor _, v := range s {\n\t\tif f(v) {\n\t\t\tout = append(out, v)\n\t\t}\n\t}\n\treturn out\n}\n\n// Reduce folds s into a single value, starting from init.\nfunc Reduce[T, U any](s []T, init U, f func(U, T) U) U {\n\tacc := init\n\tfor _, v := range s {\n\t\tacc = f(acc, v)\n\t}\n\treturn acc\n}\n\n// Contains reports whether v is present in s.\nfunc Contains[T comparable](s []T, v T) bool {\n\treturn IndexOf(s, v) >= 0\n}\n\n// IndexOf returns the index of the first occurrence of v in s, or -1.\nfunc IndexOf[T comparable](s []T, v T) int {\n\tfor i, el := range s {\n\t\tif el == v {\n\t\t\treturn i\n\t\t}\n\t}\n\treturn -1\n}\n\n// First returns the first element of s, or None when s is empty.\nfunc First[T any](s []T) Option[T] {\n\tif len(s) == 0 {\n\t\treturn None[T]()\n\t}\n\treturn Some(s[0])\n}\n\n// Last returns the last element of s, or None when s is empty.\nfunc Last[T any](s []T) Option[T] {\n\tif len(s) == 0 {\n\t\treturn None[T]()\n\t}\n\treturn Some(s[len(s)-1])\n}\n"},
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/build"
+	"github.com/ahmadrosid/yuk/run"
+)
+
+// runCommand compiles and runs the yuk files starting the arguments, with
+// the hand-written Go files of their directory, passing it the rest of
+// them. It exits with the exit code of the program.
+func runCommand(args []string) {
+	var files []string
+	for len(args) > 0 && strings.HasSuffix(args[0], ".yuk") {
+		files = append(files, args[0])
+		args = args[1:]
+	}
+	if len(files) == 0 {
+		log.Fatal("usage: yuk run file.yuk... [arguments...]")
+	}
+
+	pkg, err := build.Load(filepath.Dir(files[0]))
+	if err != nil {
+		log.Fatal(err)
+	}
+	program, errs := run.Compile(files, pkg.GoFiles)
+	exitOnErrors(errs)
+	exe, err := program.Build()
+	if err != nil {
+		program.Remove()
+		log.Print(err)
+		os.Exit(1)
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// An interrupt reaches the program, which decides whether to exit,
+	// and the temporary directory is still removed after it.
+	signal.Ignore(os.Interrupt)
+	err = cmd.Run()
+	program.Remove()
+	if exit, ok := err.(*exec.ExitError); ok {
+		os.Exit(exit.ExitCode())
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
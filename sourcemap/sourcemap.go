@@ -0,0 +1,173 @@
+// Package sourcemap reads and writes the .yuk.map files that relate the
+// positions of a generated Go file to the yuk file it was compiled from.
+//
+// A map is a JSON object:
+//
+//	{
+//		"version": 1,
+//		"file": "main.go",
+//		"source": "main.yuk",
+//		"mappings": [[1, 1, 1, 1, 7], [1, 9, 1, 9, 4]]
+//	}
+//
+// Each mapping is a token of the generated file, as [generated line,
+// generated column, source line, source column, length]. Lines and columns
+// start at 1 and columns count bytes, like the positions printed by the Go
+// toolchain. The length is the number of bytes of an identifier or literal
+// token, and 0 for a token only known by where it starts, like the keyword
+// starting a statement. Mappings are sorted by generated position.
+package sourcemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Version is the version of the format written by this package.
+const Version = 1
+
+// Position is a line and column, both starting at 1.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+func (p Position) before(q Position) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Column < q.Column
+}
+
+// Mapping relates a token of the generated file to its source.
+type Mapping struct {
+	Generated Position
+	Source    Position
+	// Length is the length of the token in bytes, or 0 when unknown.
+	Length int
+}
+
+func (m Mapping) MarshalJSON() ([]byte, error) {
+	return json.Marshal([5]int{m.Generated.Line, m.Generated.Column, m.Source.Line, m.Source.Column, m.Length})
+}
+
+func (m *Mapping) UnmarshalJSON(data []byte) error {
+	var fields [5]int
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*m = Mapping{
+		Generated: Position{fields[0], fields[1]},
+		Source:    Position{fields[2], fields[3]},
+		Length:    fields[4],
+	}
+	return nil
+}
+
+// Map relates the positions of a generated Go file to its yuk source.
+type Map struct {
+	Version int `json:"version"`
+	// File is the name of the generated file and Source the name of the
+	// yuk file, both relative to the directory of the map.
+	File     string    `json:"file"`
+	Source   string    `json:"source"`
+	Mappings []Mapping `json:"mappings"`
+}
+
+// Path returns the path of the map of the generated file goFile: main.go
+// has its map in main.yuk.map.
+func Path(goFile string) string {
+	return strings.TrimSuffix(goFile, ".go") + ".yuk.map"
+}
+
+// Read decodes a map.
+func Read(r io.Reader) (*Map, error) {
+	m := &Map{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("sourcemap: %v", err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("sourcemap: unsupported version %d", m.Version)
+	}
+	sort.SliceStable(m.Mappings, func(i, j int) bool {
+		return m.Mappings[i].Generated.before(m.Mappings[j].Generated)
+	})
+	return m, nil
+}
+
+// ReadFile reads the map in the file name.
+func ReadFile(name string) (*Map, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Write encodes m.
+func (m *Map) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(m)
+}
+
+// WriteFile writes m to the file name.
+func (m *Map) WriteFile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := m.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SourcePosition returns the yuk position of a position of the generated
+// file. A position inside a token, or right after it like the end of a
+// column range reported by go vet, moves along the source token. Any other
+// position maps to the start of the token before it on its line. It
+// returns false for a line without a token before the column, like the
+// line of a closing brace.
+func (m *Map) SourcePosition(generated Position) (Position, bool) {
+	i := sort.Search(len(m.Mappings), func(i int) bool {
+		return generated.before(m.Mappings[i].Generated)
+	}) - 1
+	if i < 0 || m.Mappings[i].Generated.Line != generated.Line {
+		return Position{}, false
+	}
+	return translate(m.Mappings[i].Generated, m.Mappings[i].Source, m.Mappings[i].Length, generated), true
+}
+
+// GeneratedPosition returns the position of the generated file a yuk
+// position was compiled to, translated the same way as by SourcePosition.
+func (m *Map) GeneratedPosition(source Position) (Position, bool) {
+	best := -1
+	for i, mapping := range m.Mappings {
+		if mapping.Source.Line != source.Line || source.before(mapping.Source) {
+			continue
+		}
+		if best < 0 || m.Mappings[best].Source.before(mapping.Source) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Position{}, false
+	}
+	mapping := m.Mappings[best]
+	return translate(mapping.Source, mapping.Generated, mapping.Length, source), true
+}
+
+// translate moves pos, at or after the token of the given length starting
+// at from, to the token starting at to.
+func translate(from, to Position, length int, pos Position) Position {
+	if offset := pos.Column - from.Column; offset <= length {
+		to.Column += offset
+	}
+	return to
+}
@@ -0,0 +1,121 @@
+package sourcemap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// The map of
+//
+//	func at(xs []int, i int) int {
+//		return xs[i]
+//	}
+//
+// compiled from
+//
+//	func at(xs []int, i int) int {
+//	    return xs[i]
+//	}
+//
+// with line directives.
+const input = `{"version":1,"file":"main.go","source":"p.yuk","mappings":[
+	[2,1,1,1,0],[2,6,1,6,2],[2,9,1,9,2],
+	[4,1,2,5,0],[4,8,2,12,2],[4,11,2,15,1]
+]}`
+
+func TestRead(t *testing.T) {
+	m, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.File != "main.go" || m.Source != "p.yuk" || len(m.Mappings) != 6 {
+		t.Fatalf("wrong map. got=%+v", m)
+	}
+
+	var out bytes.Buffer
+	if err := m.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Read(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, mapping := range again.Mappings {
+		if mapping != m.Mappings[i] {
+			t.Errorf("mapping %d changed writing it. expected=%+v, got=%+v", i, m.Mappings[i], mapping)
+		}
+	}
+
+	if _, err := Read(strings.NewReader(`{"version":2}`)); err == nil {
+		t.Errorf("expected an error reading an unknown version")
+	}
+}
+
+func TestMap_SourcePosition(t *testing.T) {
+	m, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		generated Position
+		expected  string
+	}{
+		{Position{2, 1}, "1:1"},
+		{Position{2, 7}, "1:7"},
+		{Position{2, 8}, "1:8"},
+		{Position{4, 3}, "2:5"},
+		{Position{4, 8}, "2:12"},
+		{Position{4, 10}, "2:14"},
+		{Position{4, 12}, "2:16"},
+		{Position{4, 13}, "2:15"},
+		{Position{3, 1}, ""},
+		{Position{5, 1}, ""},
+		{Position{1, 1}, ""},
+	}
+	for _, tt := range tests {
+		source, ok := m.SourcePosition(tt.generated)
+		got := ""
+		if ok {
+			got = source.String()
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.generated, tt.expected, got)
+		}
+	}
+}
+
+func TestMap_GeneratedPosition(t *testing.T) {
+	m, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source   Position
+		expected string
+	}{
+		{Position{1, 9}, "2:9"},
+		{Position{2, 5}, "4:1"},
+		{Position{2, 13}, "4:9"},
+		{Position{2, 1}, ""},
+		{Position{3, 1}, ""},
+	}
+	for _, tt := range tests {
+		generated, ok := m.GeneratedPosition(tt.source)
+		got := ""
+		if ok {
+			got = generated.String()
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.source, tt.expected, got)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	if got := Path("out/main.go"); got != "out/main.yuk.map" {
+		t.Errorf("wrong path. got=%q", got)
+	}
+}
@@ -0,0 +1,13 @@
+package std
+
+import (
+	"fmt"
+	"os"
+)
+
+// Dbg prints label and v to stderr and returns v, so it can wrap any
+// expression. It backs the dbg! macro.
+func Dbg[T any](label string, v T) T {
+	fmt.Fprintf(os.Stderr, "%s = %#v\n", label, v)
+	return v
+}
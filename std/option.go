@@ -0,0 +1,45 @@
+package std
+
+// Option holds a value that may be absent, used instead of nil pointers
+// or sentinel values.
+type Option[T any] struct {
+	value T
+	ok    bool
+}
+
+func Some[T any](v T) Option[T] {
+	return Option[T]{value: v, ok: true}
+}
+
+func None[T any]() Option[T] {
+	return Option[T]{}
+}
+
+func (o Option[T]) IsSome() bool {
+	return o.ok
+}
+
+func (o Option[T]) IsNone() bool {
+	return !o.ok
+}
+
+// Get returns the value and whether it is present.
+func (o Option[T]) Get() (T, bool) {
+	return o.value, o.ok
+}
+
+// UnwrapOr returns the value, or def when it is absent.
+func (o Option[T]) UnwrapOr(def T) T {
+	if !o.ok {
+		return def
+	}
+	return o.value
+}
+
+// Unwrap returns the value and panics when it is absent.
+func (o Option[T]) Unwrap() T {
+	if !o.ok {
+		panic("std: unwrap of an empty Option")
+	}
+	return o.value
+}
@@ -0,0 +1,63 @@
+// Package std holds the runtime helpers referenced by code generated from
+// yuk, such as the slice extension methods `xs.map(f)` or `xs.first()`.
+package std
+
+// Map returns a new slice with f applied to every element of s.
+func Map[T, U any](s []T, f func(T) U) []U {
+	out := make([]U, 0, len(s))
+	for _, v := range s {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+// Filter returns the elements of s for which f returns true.
+func Filter[T any](s []T, f func(T) bool) []T {
+	out := make([]T, 0, len(s))
+	for _, v := range s {
+		if f(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Reduce folds s into a single value, starting from init.
+func Reduce[T, U any](s []T, init U, f func(U, T) U) U {
+	acc := init
+	for _, v := range s {
+		acc = f(acc, v)
+	}
+	return acc
+}
+
+// Contains reports whether v is present in s.
+func Contains[T comparable](s []T, v T) bool {
+	return IndexOf(s, v) >= 0
+}
+
+// IndexOf returns the index of the first occurrence of v in s, or -1.
+func IndexOf[T comparable](s []T, v T) int {
+	for i, el := range s {
+		if el == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// First returns the first element of s, or None when s is empty.
+func First[T any](s []T) Option[T] {
+	if len(s) == 0 {
+		return None[T]()
+	}
+	return Some(s[0])
+}
+
+// Last returns the last element of s, or None when s is empty.
+func Last[T any](s []T) Option[T] {
+	if len(s) == 0 {
+		return None[T]()
+	}
+	return Some(s[len(s)-1])
+}
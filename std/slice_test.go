@@ -0,0 +1,45 @@
+package std
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSliceHelpers(t *testing.T) {
+	xs := []int{1, 2, 3}
+
+	doubled := Map(xs, func(x int) int { return x * 2 })
+	if !reflect.DeepEqual(doubled, []int{2, 4, 6}) {
+		t.Errorf("Map wrong. got=%v", doubled)
+	}
+
+	odd := Filter(xs, func(x int) bool { return x%2 == 1 })
+	if !reflect.DeepEqual(odd, []int{1, 3}) {
+		t.Errorf("Filter wrong. got=%v", odd)
+	}
+
+	sum := Reduce(xs, 0, func(acc int, x int) int { return acc + x })
+	if sum != 6 {
+		t.Errorf("Reduce wrong. got=%d", sum)
+	}
+
+	if !Contains(xs, 2) || Contains(xs, 4) {
+		t.Errorf("Contains wrong")
+	}
+
+	if IndexOf(xs, 3) != 2 || IndexOf(xs, 4) != -1 {
+		t.Errorf("IndexOf wrong")
+	}
+
+	if v, ok := First(xs).Get(); !ok || v != 1 {
+		t.Errorf("First wrong. got=%d, %v", v, ok)
+	}
+
+	if v, ok := Last(xs).Get(); !ok || v != 3 {
+		t.Errorf("Last wrong. got=%d, %v", v, ok)
+	}
+
+	if First([]int{}).IsSome() || Last([]int{}).UnwrapOr(7) != 7 {
+		t.Errorf("First/Last of empty slice should be None")
+	}
+}
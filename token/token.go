@@ -1,11 +1,20 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 const (
 	ILLEGAL  = "ILLEGAL"
 	EOF      = "EOF"
 	NEW_LINE = "\n"
+	COMMENT  = "COMMENT"
+	// WHITESPACE is a run of spaces, tabs and newlines, only returned by
+	// the lexers keeping trivia.
+	WHITESPACE = "WHITESPACE"
 
 	// Identifiers + literals
 	IDENT  = "IDENT"
@@ -14,12 +23,13 @@ const (
 	CHAR   = "CHAR"
 
 	// Operators
-	ASSIGN   = "="
-	PLUS     = "+"
-	MINUS    = "-"
-	BANG     = "!"
-	ASTERISK = "*"
-	SLASH    = "/"
+	ASSIGN    = "="
+	PLUS      = "+"
+	MINUS     = "-"
+	BANG      = "!"
+	ASTERISK  = "*"
+	SLASH     = "/"
+	AMPERSAND = "&"
 
 	LT = "<"
 	GT = ">"
@@ -39,12 +49,14 @@ const (
 	LBRACE   = "{"
 	RBRACE   = "}"
 	LBRACKET = "["
-	RBRACKET = "["
+	RBRACKET = "]"
 
 	PACKAGE    = "PACKAGE"
 	IMPORT     = "IMPORT"
 	FUNCTION   = "FUNCTION"
 	VAR        = "VAR"
+	LET        = "LET"
+	MUT        = "MUT"
 	MAP        = "MAP"
 	TRUE       = "TRUE"
 	FALSE      = "FALSE"
@@ -55,12 +67,16 @@ const (
 	STRUCT     = "STRUCT"
 	SWITCH     = "SWITCH"
 	TYPE       = "TYPE"
+	MACRO      = "MACRO"
+	UNSAFE     = "UNSAFE"
 	STRING_LIT = "STRING_LIT"
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int
+	Column  int
 }
 
 var keywords = map[string]TokenType{
@@ -68,6 +84,8 @@ var keywords = map[string]TokenType{
 	"import":    IMPORT,
 	"func":      FUNCTION,
 	"var":       VAR,
+	"let":       LET,
+	"mut":       MUT,
 	"map":       MAP,
 	"true":      TRUE,
 	"false":     FALSE,
@@ -79,6 +97,8 @@ var keywords = map[string]TokenType{
 	"switch":    SWITCH,
 	"string":    STRING,
 	"type":      TYPE,
+	"macro":     MACRO,
+	"unsafe":    UNSAFE,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -87,3 +107,44 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// IsKeyword reports whether ident is a reserved word.
+func IsKeyword(ident string) bool {
+	_, ok := keywords[ident]
+	return ok
+}
+
+// Keywords returns the reserved words, sorted.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Error is a diagnostic reported at a position in the yuk source.
+type Error struct {
+	// File is the name of the source file, when the program is one of the
+	// files of a package.
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+	}
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// Errorf returns an Error positioned at tok.
+func Errorf(tok Token, format string, args ...interface{}) *Error {
+	return &Error{Line: tok.Line, Column: tok.Column, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,627 @@
+package types
+
+import (
+	gotoken "go/token"
+	gotypes "go/types"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// Info holds the results of type checking a program.
+type Info struct {
+	// Types maps every checked expression to its type.
+	Types map[ast.Expression]Type
+	// Defs maps declared identifiers to the objects they declare.
+	Defs map[*ast.Identifier]*Object
+	// Uses maps identifiers to the objects they refer to.
+	Uses map[*ast.Identifier]*Object
+	// Extensions maps the calls of slice extension methods, like
+	// `xs.len()`, to the extension they call. Calls of a field or method
+	// of the receiver named like an extension are not in it.
+	Extensions map[*ast.CallExpression]ast.Extension
+	// Scope is the package scope, holding the package level declarations
+	// of every file.
+	Scope *Scope
+}
+
+// TypeOf returns the type of exp, or nil when it was not checked.
+func (info *Info) TypeOf(exp ast.Expression) Type {
+	return info.Types[exp]
+}
+
+type checker struct {
+	info     *Info
+	errors   []error
+	importer Importer
+	// pkg is the scope of the package level declarations of every file,
+	// and file the file being checked.
+	pkg   *Scope
+	files []*file
+	file  *file
+	scope *Scope
+	// goFiles are the names of the Go files of the package, positioned by
+	// fset.
+	goFiles map[string]bool
+	fset    *gotoken.FileSet
+	// sig is the signature of the function being checked.
+	sig *Signature
+	// options caches the std.Option types by element type.
+	options map[string]*Named
+}
+
+// file is a yuk file of the package, its scope holds its imports.
+type file struct {
+	name  string
+	scope *Scope
+}
+
+// funcDecl is a function or method and the file declaring it.
+type funcDecl struct {
+	fn   *ast.FunctionLiteral
+	file *file
+}
+
+// ReturnsError reports whether call returns an error as its last result,
+// like json.Marshal or os.Open.
+func (info *Info) ReturnsError(call *ast.CallExpression) bool {
+	last := info.Types[call]
+	if t, ok := last.(*Tuple); ok {
+		if t.Len() == 0 {
+			return false
+		}
+		last = t.At(t.Len() - 1).Type
+	}
+	return last == Type(universeError)
+}
+
+// Check type checks the programs, the files of one package, loading the Go
+// packages they import with GoImporter.
+func Check(programs ...*ast.Program) (*Info, []error) {
+	conf := &Config{Importer: GoImporter()}
+	return conf.Check(programs...)
+}
+
+// Check infers the types of the programs and reports every assignment,
+// call argument, return value, map entry and struct field whose value does
+// not fit the type it is used as, and every operation its operands do not
+// support. The errors are sorted by file and position.
+func (conf *Config) Check(programs ...*ast.Program) (*Info, []error) {
+	pkg := NewScope(Universe)
+	c := &checker{
+		info: &Info{
+			Types:      map[ast.Expression]Type{},
+			Defs:       map[*ast.Identifier]*Object{},
+			Uses:       map[*ast.Identifier]*Object{},
+			Extensions: map[*ast.CallExpression]ast.Extension{},
+			Scope:      pkg,
+		},
+		importer: conf.Importer,
+		pkg:      pkg,
+		scope:    pkg,
+		options:  map[string]*Named{},
+	}
+
+	local := c.checkGo(conf)
+	funcs := c.collect(programs, local)
+	for i, program := range programs {
+		c.enter(c.files[i])
+		for _, stmt := range program.Statements {
+			if isFuncDecl(stmt) {
+				continue
+			}
+			c.statement(stmt)
+		}
+	}
+	// Function bodies are checked last, they may use package level
+	// variables declared after them.
+	for _, d := range funcs {
+		c.enter(d.file)
+		c.function(d.fn, c.signature(d.fn))
+	}
+
+	order := map[string]int{}
+	for i, program := range programs {
+		order[program.File] = i
+	}
+	for i, f := range conf.GoFiles {
+		order[conf.Fset.File(f.Pos()).Name()] = len(programs) + i
+	}
+	sort.SliceStable(c.errors, func(i, j int) bool {
+		a, b := c.errors[i].(*token.Error), c.errors[j].(*token.Error)
+		if a.File != b.File {
+			return order[a.File] < order[b.File]
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.info, c.errors
+}
+
+// enter makes f the file being checked.
+func (c *checker) enter(f *file) {
+	c.file, c.scope = f, f.scope
+}
+
+func (c *checker) errorf(tok token.Token, format string, args ...interface{}) {
+	err := token.Errorf(tok, format, args...)
+	if c.file != nil {
+		err.File = c.file.name
+	}
+	c.errors = append(c.errors, err)
+}
+
+func isFuncDecl(stmt ast.Statement) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	fn, ok := es.Expression.(*ast.FunctionLiteral)
+	return ok && fn.Name != ""
+}
+
+// collect declares the imported packages, types and functions of the
+// programs, and the declarations of the Go files of the package in local,
+// so they can be used before the line declaring them. It returns the
+// functions and methods to check.
+func (c *checker) collect(programs []*ast.Program, local *gotypes.Package) []funcDecl {
+	type typeDecl struct {
+		named *Named
+		attr  *ast.StructAttributes
+		st    *ast.StructStatement
+		file  *file
+	}
+	var decls []typeDecl
+	var funcs []funcDecl
+
+	for _, program := range programs {
+		f := &file{name: program.File, scope: NewScope(c.pkg)}
+		c.files = append(c.files, f)
+		c.enter(f)
+		for _, stmt := range program.Statements {
+			switch s := stmt.(type) {
+			case *ast.ImportStatement:
+				c.importPackage(s)
+			case *ast.StructAttributes:
+				if s.Token != nil {
+					named := &Named{Name: s.Name.Literal}
+					c.pkg.Insert(&Object{Kind: TypeObject, Name: named.Name, Type: named, Pos: s.Name})
+					decls = append(decls, typeDecl{named: named, attr: s, file: f})
+				}
+			case *ast.ExpressionStatement:
+				switch e := s.Expression.(type) {
+				case *ast.StructStatement:
+					if e.Name != nil {
+						named := &Named{Name: e.Name.Literal}
+						c.pkg.Insert(&Object{Kind: TypeObject, Name: named.Name, Type: named, Pos: *e.Name})
+						decls = append(decls, typeDecl{named: named, st: e, file: f})
+					}
+				case *ast.FunctionLiteral:
+					if e.Name != "" {
+						funcs = append(funcs, funcDecl{fn: e, file: f})
+					}
+				}
+			}
+		}
+	}
+	if local != nil {
+		c.goDecls(local)
+	}
+
+	for _, d := range decls {
+		c.enter(d.file)
+		if d.st != nil {
+			d.named.underlying = c.structType(d.st)
+		} else {
+			d.named.underlying = c.typeExpr(d.attr.Type.Literal)
+		}
+	}
+
+	for _, d := range funcs {
+		c.enter(d.file)
+		fn := d.fn
+		sig := c.signature(fn)
+		if fn.Receiver == nil {
+			c.pkg.Insert(&Object{Kind: FuncObject, Name: fn.Name, Type: sig, Pos: fn.NameToken})
+			continue
+		}
+		recv := c.typeExpr(fn.Receiver.Type.Value)
+		if p, ok := recv.(*Pointer); ok {
+			recv = p.Elem
+		}
+		if named, ok := recv.(*Named); ok {
+			named.addMethod(&Func{Name: fn.Name, Sig: sig})
+		}
+	}
+	return funcs
+}
+
+func (c *checker) importPackage(s *ast.ImportStatement) {
+	lit, ok := s.PackageName.(*ast.StringLiteral)
+	if !ok {
+		return
+	}
+	obj := &Object{Kind: PackageObject, Name: path.Base(lit.Value), Type: Typ[Invalid], Pos: s.Token}
+	// The std helpers are generic, calls to them are checked as the
+	// extension methods they are generated from.
+	if c.importer != nil && lit.Value != ast.ExtensionPackage {
+		pkg, err := c.importer.Import(lit.Value)
+		if err != nil {
+			c.errorf(lit.Token, "could not import %s (%v)", lit.Value, err)
+		} else {
+			obj.Name, obj.Pkg = pkg.Name, pkg
+		}
+	}
+	c.scope.Insert(obj)
+}
+
+// member returns the member of an imported package, or nil after
+// reporting an error. Members of packages that could not be loaded are
+// unknown and nil too.
+func (c *checker) member(pkg *Object, name *ast.Identifier) *Object {
+	if pkg.Pkg == nil {
+		return nil
+	}
+	obj := pkg.Pkg.Scope.Lookup(name.Value)
+	if obj == nil {
+		if first := name.Value[0]; 'a' <= first && first <= 'z' || first == '_' {
+			c.errorf(name.Token, "name %s not exported by package %s", name.Value, pkg.Name)
+		} else {
+			c.errorf(name.Token, "undefined: %s.%s", pkg.Name, name.Value)
+		}
+		return nil
+	}
+	c.info.Uses[name] = obj
+	return obj
+}
+
+// typeExpr returns the type spelled by a type expression of the parser,
+// such as "[]*User" or "map[string]int".
+func (c *checker) typeExpr(spelling string) Type {
+	switch {
+	case strings.HasPrefix(spelling, "*"):
+		return &Pointer{Elem: c.typeExpr(spelling[1:])}
+	case strings.HasPrefix(spelling, "[]"):
+		return &Slice{Elem: c.typeExpr(spelling[2:])}
+	case strings.HasPrefix(spelling, "map["):
+		depth := 0
+		for i := len("map"); i < len(spelling); i++ {
+			switch spelling[i] {
+			case '[':
+				depth++
+			case ']':
+				depth--
+				if depth == 0 {
+					return &Map{Key: c.typeExpr(spelling[4:i]), Elem: c.typeExpr(spelling[i+1:])}
+				}
+			}
+		}
+		return Typ[Invalid]
+	case spelling == "interface{}" || spelling == "interface":
+		return &Interface{}
+	case spelling == "unsafe.Pointer":
+		return Typ[UnsafePointer]
+	}
+
+	if dot := strings.Index(spelling, "."); dot >= 0 {
+		pkg := c.scope.Lookup(spelling[:dot])
+		if pkg == nil || pkg.Kind != PackageObject || pkg.Pkg == nil {
+			return Typ[Invalid]
+		}
+		if obj := pkg.Pkg.Scope.Lookup(spelling[dot+1:]); obj != nil && obj.Kind == TypeObject {
+			return obj.Type
+		}
+		return Typ[Invalid]
+	}
+	if obj := c.scope.Lookup(spelling); obj != nil && obj.Kind == TypeObject {
+		return obj.Type
+	}
+	return Typ[Invalid]
+}
+
+func (c *checker) structType(st *ast.StructStatement) *Struct {
+	s := &Struct{}
+	for _, attr := range st.Attributes {
+		s.Fields = append(s.Fields, &Var{Name: attr.Name.Literal, Type: c.typeExpr(attr.Type.Literal)})
+	}
+	return s
+}
+
+func (c *checker) signature(fn *ast.FunctionLiteral) *Signature {
+	sig := &Signature{Params: NewTuple(), Results: NewTuple()}
+	for _, p := range fn.Params {
+		sig.Params.Vars = append(sig.Params.Vars, &Var{Name: p.Name.Value, Type: c.typeExpr(p.Type.Value)})
+	}
+	if ret, ok := fn.ReturnType.(*ast.Identifier); ok {
+		sig.Results.Vars = append(sig.Results.Vars, &Var{Type: c.typeExpr(ret.Value)})
+	}
+	return sig
+}
+
+func (c *checker) declare(ident *ast.Identifier, kind ObjectKind, typ Type) {
+	obj := &Object{Kind: kind, Name: ident.Value, Type: typ, Pos: ident.Token}
+	if c.file != nil && c.scope == c.file.scope {
+		// A package level variable.
+		c.pkg.Insert(obj)
+	} else {
+		c.scope.Insert(obj)
+	}
+	c.info.Defs[ident] = obj
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.VarStatement:
+		c.varDecl(s)
+	case *ast.AssignStatement:
+		target := c.expression(s.Target)
+		c.assignment(s.Value, c.value(s.Value), target, "assignment")
+	case *ast.ReturnStatement:
+		c.returnStmt(s)
+	case *ast.ExpressionStatement:
+		c.expression(s.Expression)
+	case *ast.BlockStatement:
+		c.block(s)
+	case *ast.UnsafeStatement:
+		c.block(s.Body)
+	case *ast.SwitchStatement:
+		c.switchStmt(s)
+	}
+}
+
+func (c *checker) block(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	c.scope = NewScope(c.scope)
+	for _, stmt := range block.Statements {
+		c.statement(stmt)
+	}
+	c.scope = c.scope.parent
+}
+
+func (c *checker) varDecl(s *ast.VarStatement) {
+	var typ Type
+	if s.Type != nil {
+		typ = c.typeExpr(s.Type.Value)
+	}
+	if s.Value != nil {
+		value := c.value(s.Value)
+		if typ != nil {
+			c.assignment(s.Value, value, typ, "variable declaration")
+		} else {
+			typ = c.inferred(s.Value, value)
+		}
+	}
+	if typ == nil {
+		typ = Typ[Invalid]
+	}
+	c.declare(s.Name, VarObject, typ)
+}
+
+// inferred returns the type of a variable initialized with exp.
+func (c *checker) inferred(exp ast.Expression, typ Type) Type {
+	if basicKind(typ) == UntypedNil {
+		c.errorf(start(exp), "use of untyped nil in variable declaration")
+		return Typ[Invalid]
+	}
+	return Default(typ)
+}
+
+func (c *checker) returnStmt(s *ast.ReturnStatement) {
+	if s.ReturnValue == nil {
+		return
+	}
+	value := c.value(s.ReturnValue)
+	if c.sig == nil {
+		return
+	}
+	switch c.sig.Results.Len() {
+	case 0:
+		c.errorf(start(s.ReturnValue), "too many return values, have (%s), want ()", value)
+	case 1:
+		c.assignment(s.ReturnValue, value, c.sig.Results.At(0).Type, "return statement")
+	}
+}
+
+func (c *checker) switchStmt(s *ast.SwitchStatement) {
+	input := c.literalType(s.Input)
+	for _, cl := range s.Case {
+		if cl == nil {
+			continue
+		}
+		if cl.Token.Type != token.UNDERSCORE {
+			typ := c.literalType(cl.Token)
+			if _, ok := c.match(input, typ); !ok {
+				c.errorf(cl.Token, "invalid case %s in switch on %s (mismatched types %s and %s)",
+					spell(cl.Token), spell(s.Input), typ, input)
+			}
+		}
+		c.block(cl.Body)
+	}
+}
+
+// literalType returns the type of the single token operands of a switch.
+func (c *checker) literalType(tok token.Token) Type {
+	switch tok.Type {
+	case token.CHAR:
+		return Typ[UntypedRune]
+	case token.STRING_LIT:
+		return Typ[UntypedString]
+	case token.INT:
+		return Typ[UntypedInt]
+	case token.TRUE, token.FALSE:
+		return Typ[UntypedBool]
+	case token.IDENT:
+		if obj := c.scope.Lookup(tok.Literal); obj != nil && obj.Kind != TypeObject {
+			return obj.Type
+		}
+	}
+	return Typ[Invalid]
+}
+
+func spell(tok token.Token) string {
+	switch tok.Type {
+	case token.CHAR:
+		return "'" + tok.Literal + "'"
+	case token.STRING_LIT:
+		return `"` + tok.Literal + `"`
+	}
+	return tok.Literal
+}
+
+// function checks the body of fn in a new scope holding its receiver and
+// parameters.
+func (c *checker) function(fn *ast.FunctionLiteral, sig *Signature) {
+	outer, outerSig := c.scope, c.sig
+	c.scope, c.sig = NewScope(c.scope), sig
+	if fn.Receiver != nil {
+		c.declare(fn.Receiver.Name, VarObject, c.typeExpr(fn.Receiver.Type.Value))
+	}
+	for i, p := range fn.Params {
+		c.declare(p.Name, VarObject, sig.Params.At(i).Type)
+	}
+	if fn.Body != nil {
+		for _, stmt := range fn.Body.Statements {
+			c.statement(stmt)
+		}
+		if sig.Results.Len() > 0 && !terminates(fn.Body) {
+			c.errorf(fn.Body.End, "missing return")
+		}
+	}
+	c.scope, c.sig = outer, outerSig
+}
+
+// terminates reports whether the block ends with a statement that never
+// falls through, like a return or an if whose branches both return.
+func terminates(block *ast.BlockStatement) bool {
+	if block == nil || len(block.Statements) == 0 {
+		return false
+	}
+	switch s := block.Statements[len(block.Statements)-1].(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.BlockStatement:
+		return terminates(s)
+	case *ast.UnsafeStatement:
+		return terminates(s.Body)
+	case *ast.SwitchStatement:
+		hasDefault := false
+		for _, cl := range s.Case {
+			if cl == nil || !terminates(cl.Body) {
+				return false
+			}
+			hasDefault = hasDefault || cl.Token.Type == token.UNDERSCORE
+		}
+		return hasDefault
+	case *ast.ExpressionStatement:
+		switch e := s.Expression.(type) {
+		case *ast.IfExpression:
+			return terminates(e.Consequence) && terminates(e.Alternative)
+		case *ast.CallExpression:
+			ident, ok := e.Function.(*ast.Identifier)
+			return ok && ident.Value == "panic"
+		}
+	}
+	return false
+}
+
+// assignment reports an error when a value of type typ, computed by exp,
+// can not be used as a value of type target.
+func (c *checker) assignment(exp ast.Expression, typ, target Type, context string) {
+	if !AssignableTo(typ, target) {
+		c.errorf(start(exp), "cannot use %s (%s) as %s value in %s", brief(exp), c.describe(exp, typ), target, context)
+	}
+}
+
+// brief returns the source of exp for error messages, leaving out the
+// bodies of function literals.
+func brief(exp ast.Expression) string {
+	if _, ok := exp.(*ast.FunctionLiteral); ok {
+		return "func literal"
+	}
+	return exp.String()
+}
+
+// describe explains what kind of value exp is, for error messages.
+func (c *checker) describe(exp ast.Expression, typ Type) string {
+	if isUntyped(typ) {
+		if basicKind(typ) == UntypedNil {
+			return typ.String()
+		}
+		return typ.String() + " constant"
+	}
+	var ident *ast.Identifier
+	switch e := exp.(type) {
+	case *ast.Identifier:
+		ident = e
+	case *ast.SelectorExpression:
+		ident = e.Name
+	}
+	if obj := c.info.Uses[ident]; obj != nil {
+		switch obj.Kind {
+		case VarObject:
+			return "variable of type " + typ.String()
+		case ConstObject:
+			return "constant of type " + typ.String()
+		}
+	}
+	return "value of type " + typ.String()
+}
+
+// start returns the token an expression starts at, which is where errors
+// about it are reported.
+func start(exp ast.Expression) token.Token {
+	switch e := exp.(type) {
+	case *ast.InfixExpression:
+		return start(e.Left)
+	case *ast.CallExpression:
+		return start(e.Function)
+	case *ast.SelectorExpression:
+		return start(e.Left)
+	case *ast.IndexExpression:
+		return start(e.Left)
+	case *ast.StructLiteral:
+		return e.Type.Token
+	case *ast.VarExpression:
+		return e.Ident
+	}
+	return tokenOf(exp)
+}
+
+func tokenOf(exp ast.Expression) token.Token {
+	switch e := exp.(type) {
+	case *ast.Identifier:
+		return e.Token
+	case *ast.IntegerLiteral:
+		return e.Token
+	case *ast.StringLiteral:
+		return e.Token
+	case *ast.Boolean:
+		return e.Token
+	case *ast.PrefixExpression:
+		return e.Token
+	case *ast.GroupedExpression:
+		return e.Token
+	case *ast.FunctionLiteral:
+		return e.Token
+	case *ast.ArrayLiteral:
+		return e.Token
+	case *ast.MapLiteral:
+		return e.Token
+	case *ast.IfExpression:
+		return e.Token
+	case *ast.StructStatement:
+		return e.Token
+	case *ast.ExpressionLiteral:
+		return e.Token
+	case *ast.MacroCall:
+		return e.Token
+	}
+	return token.Token{}
+}
@@ -0,0 +1,311 @@
+package types
+
+import (
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"strings"
+	"testing"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/lexer"
+	"github.com/ahmadrosid/yuk/parser"
+)
+
+func check(t *testing.T, input string) (*ast.Program, *Info, []error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("%s\nparse errors: %q", input, p.Errors())
+	}
+	info, errs := Check(program)
+	return program, info, errs
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`var x int = 1`, nil},
+		{`var x int = "hello"`, []string{`1:13: cannot use "hello" (untyped string constant) as int value in variable declaration`}},
+		{"var x string\nx = 1", []string{"2:5: cannot use 1 (untyped int constant) as string value in assignment"}},
+		{"var x = 1\nvar y string = x", []string{"2:16: cannot use x (variable of type int) as string value in variable declaration"}},
+		{"x := \"a\"\nvar y int = x", []string{"2:13: cannot use x (variable of type string) as int value in variable declaration"}},
+		{"var x float64 = 1\nvar y int64 = 2", nil},
+		{"type Celsius float64\nvar c Celsius = 2\nvar f float64 = c", []string{"3:17: cannot use c (variable of type Celsius) as float64 value in variable declaration"}},
+		{"var p *int = nil\nvar q = nil", []string{"2:9: use of untyped nil in variable declaration"}},
+		{
+			`var m = map(string, int){"a": 1, 2: "b"}`,
+			[]string{
+				"1:34: cannot use 2 (untyped int constant) as string value in map literal",
+				`1:37: cannot use "b" (untyped string constant) as int value in map literal`,
+			},
+		},
+		{`var m = map(string, interface){"a": 1, "b": "c"}`, nil},
+		{`var m = map(string, int){"a": 1, "b": 2, "a": 3}`, []string{`1:42: duplicate key "a" in map literal`}},
+		{"var m = map(int, bool){1: true, (1): false}", []string{"1:33: duplicate key (1) in map literal"}},
+		{`var m = map(string, int){"a": 1, "\x61": 2}`, []string{`1:34: duplicate key "\x61" in map literal`}},
+		{"var m = map(int, bool){1: true, 01: false, 010: true, 8: false}", []string{"1:33: duplicate key 01 in map literal", "1:55: duplicate key 8 in map literal"}},
+		{"var m = map(int, int){1 + 1: 1, 2: 2}", []string{"1:33: duplicate key 2 in map literal"}},
+		{"var m = map(int, int){-1: 1, 2 - 3: 2, 7 / 2: 3, (1 + 2) * 1: 4}", []string{"1:30: duplicate key 2 - 3 in map literal", "1:50: duplicate key (1 + 2) * 1 in map literal"}},
+		{`var m = map(string, bool){"ab": true, "a" + "b": false, 1 < 2: true}`, []string{`1:39: duplicate key "a" + "b" in map literal`, "1:57: cannot use 1 < 2 (untyped bool constant) as string value in map literal"}},
+		{"var m = map(string, int){\"a\": 1}\nm[1] = 2", []string{"2:3: cannot use 1 (untyped int constant) as string value in map index"}},
+		{`var xs = []string{"a", 1}`, []string{"1:24: cannot use 1 (untyped int constant) as string value in slice literal"}},
+		{
+			"struct User(Name string, Age int)\nvar u = User{Name: \"yuk\", Age: \"one\", Email: \"x\"}",
+			[]string{
+				`2:32: cannot use "one" (untyped string constant) as int value in struct literal`,
+				"2:39: unknown field Email in struct literal of type User",
+			},
+		},
+		{"struct User(Name string)\nvar u = User{Name: \"a\"}\nvar n = u.Nmae", []string{"3:11: u.Nmae undefined (type User has no field or method Nmae)"}},
+		{"struct User(Name string)\nvar u = &User{Name: \"a\"}\nvar n int = u.Name", []string{"3:13: cannot use u.Name (value of type string) as int value in variable declaration"}},
+		{
+			"func add(a int, b int) int {\nreturn a + b\n}\nvar x = add(1)\nvar y = add(1, \"2\")\nvar z = add(1, 2, 3)",
+			[]string{
+				"4:12: not enough arguments in call to add, have (untyped int), want (int, int)",
+				`5:16: cannot use "2" (untyped string constant) as int value in argument to add`,
+				"6:19: too many arguments in call to add, have (untyped int, untyped int, untyped int), want (int, int)",
+			},
+		},
+		{
+			"struct User(Name string)\nfunc (u *User) Greet(greeting string) string {\nreturn greeting + u.Name\n}\nvar u = User{Name: \"a\"}\nvar s = u.Greet(1)",
+			[]string{"6:17: cannot use 1 (untyped int constant) as string value in argument to u.Greet"},
+		},
+		{"func f() int {\nreturn \"x\"\n}", []string{`2:8: cannot use "x" (untyped string constant) as int value in return statement`}},
+		{"func f() {\nreturn 1\n}", []string{"2:8: too many return values, have (untyped int), want ()"}},
+		{"func f() int {\nvar x = 1\n}", []string{"3:1: missing return"}},
+		{"func f(ok bool) int {\nif ok {\nreturn 1\n} else {\nreturn 2\n}\n}", nil},
+		{"func f() {\n}\nvar x = f()", []string{"3:9: f() (no value) used as value"}},
+		{`var x = 1 + "a"`, []string{`1:9: invalid operation: 1 + "a" (mismatched types untyped int and untyped string)`}},
+		{"var x = 1\nvar y = \"a\"\nvar z = x == y", []string{"3:9: invalid operation: x == y (mismatched types int and string)"}},
+		{"var x = true\nvar y = -x", []string{"2:9: invalid operation: operator - not defined on x (variable of type bool)"}},
+		{"var x = 1\nfunc f() {\nif x {\n}\n}", []string{"3:4: non-boolean condition in if statement"}},
+		{"var name = \"a\"\nswitch name {\n'a' => {\n}\n}", []string{"3:1: invalid case 'a' in switch on name (mismatched types untyped rune and string)"}},
+		{"var x = string(1)\nvar y = int(\"a\")", []string{`2:13: cannot convert "a" (untyped string constant) to type int`}},
+		{"var xs = [1, 2]\nvar ys = append(xs, \"a\")", []string{`2:21: cannot use "a" (untyped string constant) as int value in argument to append`}},
+		{"var n = len(1)", []string{"1:13: invalid argument: 1 (untyped int constant) for built-in len"}},
+		{"var xs = [1, 2]\nvar s string = xs[0]", []string{"2:16: cannot use xs[0] (value of type int) as string value in variable declaration"}},
+		{"var xs = [1, 2]\nvar ys []string = xs.map(func(x int) int {\nreturn x\n})", []string{"2:19: cannot use std.Map(xs, func(x int) int {\nreturn x\n}) (value of type []int) as []string value in variable declaration"}},
+		{"var xs = [1, 2]\nvar ys = xs.filter(func(x string) bool {\nreturn true\n})", []string{"2:20: cannot use func literal (value of type func(string) bool) as func(int) bool value in argument to xs.filter"}},
+		{"var xs = [1, 2]\nvar ok = xs.contains(\"a\")", []string{`2:22: cannot use "a" (untyped string constant) as int value in argument to xs.contains`}},
+		{"var xs = [1, 2]\nvar s string = xs.first().UnwrapOr(0)", []string{"2:16: cannot use std.First(xs).UnwrapOr(0) (value of type int) as string value in variable declaration"}},
+		{"var total = [1, 2].reduce(0, func(acc int, x int) int {\nreturn acc + x\n})", nil},
+		{"var s = \"a\"\nvar x = s.map(func(x int) int {\nreturn x\n})", []string{"2:11: s.map undefined (type string is not a slice)"}},
+		{"import \"fmt\"\nvar x int = fmt.Sprint(1)", []string{"2:13: cannot use fmt.Sprint(1) (value of type string) as int value in variable declaration"}},
+		{"import \"strings\"\nvar xs = strings.Split(\"a\")", []string{"2:23: not enough arguments in call to strings.Split, have (untyped string), want (string, string)"}},
+		{"import \"strings\"\nvar s = strings.ToUpper(1)", []string{"2:25: cannot use 1 (untyped int constant) as string value in argument to strings.ToUpper"}},
+		{"import \"encoding/json\"\nvar b = json.Marshal(1)", []string{"2:9: multiple-value json.Marshal(1) (value of type ([]uint8, error)) in single-value context"}},
+		{"import \"encoding/json\"\nvar b = json.marshal(1)\nvar c = json.Nope", []string{"2:14: name marshal not exported by package json", "3:14: undefined: json.Nope"}},
+		{"import \"time\"\nvar d = 2 * time.Second\nvar n int = d", []string{"3:13: cannot use d (variable of type time.Duration) as int value in variable declaration"}},
+		{"import \"strings\"\nvar sb strings.Builder\nvar n = sb.Len()\nvar s string = sb.String()", nil},
+		{"import \"encoding/json\"\nvar raw = json.RawMessage(\"{}\")", nil},
+		{"import \"github.com/ahmadrosid/yuk/none\"", []string{"1:8: could not import github.com/ahmadrosid/yuk/none"}},
+		{"var x int = unknown\nvar y = x.Foo", []string{"2:11: x.Foo undefined (type int has no field or method Foo)"}},
+		{"var err error = nil\nvar s string = err.Error()", nil},
+	}
+
+	for _, tt := range tests {
+		_, _, errs := check(t, tt.input)
+		if len(errs) != len(tt.expected) {
+			t.Errorf("%s\nexpected %d errors, got=%q", tt.input, len(tt.expected), errs)
+			continue
+		}
+		for i, err := range errs {
+			if !strings.HasPrefix(err.Error(), tt.expected[i]) {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+type fakeImporter map[string]*Package
+
+func (f fakeImporter) Import(path string) (*Package, error) {
+	if pkg, ok := f[path]; ok {
+		return pkg, nil
+	}
+	return nil, fmt.Errorf("package %s not found", path)
+}
+
+func TestConfig_Importer(t *testing.T) {
+	greet := &Signature{Params: NewTuple(&Var{Type: Typ[String]}), Results: NewTuple(&Var{Type: Typ[String]})}
+	pkg := &Package{Path: "example.com/hello", Name: "hello", Scope: NewScope(nil)}
+	pkg.Scope.Insert(&Object{Kind: FuncObject, Name: "Greet", Type: greet})
+	conf := &Config{Importer: fakeImporter{pkg.Path: pkg}}
+
+	p := parser.New(lexer.New("import \"example.com/hello\"\nvar s = hello.Greet(1)\nimport \"example.com/other\""))
+	program := p.ParseProgram()
+	_, errs := conf.Check(program)
+
+	expected := []string{
+		"2:21: cannot use 1 (untyped int constant) as string value in argument to hello.Greet",
+		"3:8: could not import example.com/other (package example.com/other not found)",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got=%q", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong error. expected=%q, got=%q", expected[i], err.Error())
+		}
+	}
+}
+
+func TestInfo_ReturnsError(t *testing.T) {
+	input := `import "os"
+import "strings"
+func main() {
+	os.Open("a")
+	os.Remove("a")
+	strings.ToUpper("a")
+	main()
+}`
+	program, info, errs := check(t, input)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+
+	expected := map[string]bool{
+		`os.Open("a")`:         true,
+		`os.Remove("a")`:       true,
+		`strings.ToUpper("a")`: false,
+		"main()":               false,
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok {
+			if got := info.ReturnsError(call); got != expected[call.String()] {
+				t.Errorf("ReturnsError(%s) = %t, expected %t", call, got, expected[call.String()])
+			}
+		}
+		return true
+	})
+}
+
+func TestCheck_Info(t *testing.T) {
+	input := `struct User(Name string)
+func main() {
+	var u = &User{Name: "yuk"}
+	n := u.Name
+	var xs = [1, 2].map(func(x int) string {
+		return "a"
+	})
+}`
+	program, info, errs := check(t, input)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+
+	expected := map[string]string{
+		"u":  "*User",
+		"n":  "string",
+		"xs": "[]string",
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.VarStatement:
+			obj := info.Defs[n.Name]
+			if obj == nil {
+				t.Errorf("no definition for %s", n.Name.Value)
+			} else if obj.Type.String() != expected[n.Name.Value] {
+				t.Errorf("wrong type for %s. expected=%q, got=%q", n.Name.Value, expected[n.Name.Value], obj.Type)
+			}
+		case *ast.VarExpression:
+			if typ := info.TypeOf(n); typ.String() != expected[n.Ident.Literal] {
+				t.Errorf("wrong type for %s. expected=%q, got=%q", n.Ident.Literal, expected[n.Ident.Literal], typ)
+			}
+		case *ast.SelectorExpression:
+			ident, ok := n.Left.(*ast.Identifier)
+			if !ok {
+				break
+			}
+			if obj := info.Uses[ident]; obj == nil || obj.Name != "u" {
+				t.Errorf("u.Name does not use u, got=%v", obj)
+			}
+		}
+		return true
+	})
+}
+
+func TestInfo_Extensions(t *testing.T) {
+	input := `struct Stack(top int)
+func (s Stack) len() int { return s.top }
+func (s Stack) contains(x int) bool { return x < s.top }
+var s = Stack{top: 2}
+var xs = [1, 2]
+var n = s.len() + xs.len()
+var ok = s.contains(1) && xs.contains(1)`
+	program, info, errs := check(t, input)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %q", errs)
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		recv := call.Function.(*ast.SelectorExpression).Left.String()
+		if _, ok := info.Extensions[call]; ok != (recv == "xs") {
+			t.Errorf("%s: extension=%t, expected %t", call.String(), ok, recv == "xs")
+		}
+		return true
+	})
+}
+
+func TestConfig_Check_Package(t *testing.T) {
+	sources := []string{
+		"package models\nstruct User(Name string)\nfunc (u User) Greeting() string {\nreturn prefix + u.Name\n}",
+		"package models\nfunc Hello(u User) int {\nreturn u.Shout()\n}\nvar g string = u.Greeting()\nvar u = NewUser()",
+	}
+	var programs []*ast.Program
+	for i, input := range sources {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("%s\nparse errors: %q", input, p.Errors())
+		}
+		program.File = fmt.Sprintf("%d.yuk", i)
+		programs = append(programs, program)
+	}
+	stub := "package models\n\ntype User struct {\n\tName string\n}\n\nfunc (u User) Greeting() string {\n\treturn prefix + u.Name\n}\n"
+	handwritten := "package models\n\nconst prefix = \"hi \"\n\nfunc NewUser() *User {\n\treturn &User{Name: 1}\n}\n\nfunc (u User) Shout() string {\n\treturn u.Greeting() + \"!\"\n}\n"
+
+	fset := gotoken.NewFileSet()
+	var files []*goast.File
+	for _, src := range []struct{ name, src string }{{"0.go", stub}, {"user.go", handwritten}} {
+		f, err := goparser.ParseFile(fset, src.name, src.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+
+	conf := &Config{Importer: GoImporter(), Fset: fset, GoFiles: files[1:], GoStubs: files[:1]}
+	info, errs := conf.Check(programs...)
+	expected := []string{
+		"1.yuk:3:8: cannot use u.Shout() (value of type string) as int value in return statement",
+		"user.go:6:21: cannot use 1 (untyped int constant) as string value in struct literal",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got=%q", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong error. expected=%q, got=%q", expected[i], err.Error())
+		}
+	}
+
+	var u *ast.Identifier
+	ast.Inspect(programs[1], func(node ast.Node) bool {
+		if s, ok := node.(*ast.VarStatement); ok && s.Name.Value == "u" {
+			u = s.Name
+		}
+		return true
+	})
+	if obj := info.Defs[u]; obj == nil || obj.Type.String() != "*User" {
+		t.Errorf("NewUser does not return the yuk User, got=%v", obj)
+	}
+}
@@ -0,0 +1,729 @@
+package types
+
+import (
+	"go/constant"
+	gotoken "go/token"
+
+	"github.com/ahmadrosid/yuk/ast"
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// expression checks exp and records its type. Calls returning zero or
+// several values have a *Tuple type.
+func (c *checker) expression(exp ast.Expression) Type {
+	if exp == nil {
+		return Typ[Invalid]
+	}
+	typ := c.exprType(exp)
+	if typ == nil {
+		typ = Typ[Invalid]
+	}
+	c.info.Types[exp] = typ
+	return typ
+}
+
+// value checks exp, which is used as a single value.
+func (c *checker) value(exp ast.Expression) Type {
+	typ := c.expression(exp)
+	if t, ok := typ.(*Tuple); ok {
+		if t.Len() == 0 {
+			c.errorf(start(exp), "%s (no value) used as value", exp)
+		} else {
+			c.errorf(start(exp), "multiple-value %s (value of type %s) in single-value context", exp, t)
+		}
+		return Typ[Invalid]
+	}
+	return typ
+}
+
+func (c *checker) exprType(exp ast.Expression) Type {
+	switch e := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Typ[UntypedInt]
+	case *ast.StringLiteral:
+		return Typ[UntypedString]
+	case *ast.Boolean:
+		return Typ[UntypedBool]
+	case *ast.Identifier:
+		return c.identifier(e)
+	case *ast.VarExpression:
+		typ := c.inferred(e.Value, c.value(e.Value))
+		c.scope.Insert(&Object{Kind: VarObject, Name: e.Ident.Literal, Type: typ, Pos: e.Ident})
+		return typ
+	case *ast.GroupedExpression:
+		return c.value(e.Expression)
+	case *ast.InfixExpression:
+		return c.binary(e)
+	case *ast.PrefixExpression:
+		return c.unary(e)
+	case *ast.IfExpression:
+		if cond := c.value(e.Condition); !isInvalid(cond) && !isBoolean(cond) {
+			c.errorf(start(e.Condition), "non-boolean condition in if statement")
+		}
+		c.block(e.Consequence)
+		c.block(e.Alternative)
+		return NewTuple()
+	case *ast.FunctionLiteral:
+		sig := c.signature(e)
+		c.function(e, sig)
+		return sig
+	case *ast.CallExpression:
+		return c.call(e)
+	case *ast.SelectorExpression:
+		return c.selector(e)
+	case *ast.IndexExpression:
+		return c.index(e)
+	case *ast.ArrayLiteral:
+		return c.arrayLiteral(e)
+	case *ast.MapLiteral:
+		return c.mapLiteral(e)
+	case *ast.StructLiteral:
+		return c.structLiteral(e)
+	case *ast.StructStatement:
+		if e.Name != nil {
+			return c.typeExpr(e.Name.Literal)
+		}
+		return c.structType(e)
+	}
+	return Typ[Invalid]
+}
+
+func (c *checker) identifier(ident *ast.Identifier) Type {
+	obj := c.scope.Lookup(ident.Value)
+	if obj == nil {
+		return Typ[Invalid]
+	}
+	c.info.Uses[ident] = obj
+	switch obj.Kind {
+	case TypeObject:
+		c.errorf(ident.Token, "%s (type) is not an expression", ident.Value)
+		return Typ[Invalid]
+	case PackageObject:
+		c.errorf(ident.Token, "use of package %s without selector", ident.Value)
+		return Typ[Invalid]
+	case BuiltinObject:
+		c.errorf(ident.Token, "%s (built-in function) must be called", ident.Value)
+		return Typ[Invalid]
+	}
+	return obj.Type
+}
+
+func (c *checker) binary(e *ast.InfixExpression) Type {
+	left, right := c.value(e.Left), c.value(e.Right)
+	if isInvalid(left) || isInvalid(right) {
+		return Typ[Invalid]
+	}
+
+	typ, ok := c.match(left, right)
+	if !ok {
+		c.errorf(start(e), "invalid operation: %s (mismatched types %s and %s)", e, left, right)
+		return Typ[Invalid]
+	}
+
+	switch e.Operator {
+	case "==", "!=":
+		return Typ[UntypedBool]
+	case "<", ">":
+		if !isNumeric(typ) && !isString(typ) {
+			c.errorf(start(e), "invalid operation: %s (operator %s not defined on %s)", e, e.Operator, typ)
+		}
+		return Typ[UntypedBool]
+	case "+":
+		if !isNumeric(typ) && !isString(typ) {
+			c.errorf(start(e), "invalid operation: %s (operator + not defined on %s)", e, typ)
+			return Typ[Invalid]
+		}
+	default:
+		if !isNumeric(typ) {
+			c.errorf(start(e), "invalid operation: %s (operator %s not defined on %s)", e, e.Operator, typ)
+			return Typ[Invalid]
+		}
+	}
+	return typ
+}
+
+// match returns the type both operands of a binary operation are
+// converted to, an untyped constant taking the type of the other operand.
+func (c *checker) match(x, y Type) (Type, bool) {
+	switch {
+	case isInvalid(x):
+		return y, true
+	case isInvalid(y), Identical(x, y):
+		return x, true
+	case isUntyped(x) && isUntyped(y):
+		// Both are constants, 1 + 'a' is a rune and 1 + 2.5 a float.
+		if isNumeric(x) && isNumeric(y) {
+			if basicKind(x) > basicKind(y) {
+				return x, true
+			}
+			return y, true
+		}
+		return nil, false
+	case isUntyped(x) && AssignableTo(x, y):
+		return y, true
+	case isUntyped(y) && AssignableTo(y, x):
+		return x, true
+	}
+	return nil, false
+}
+
+func (c *checker) unary(e *ast.PrefixExpression) Type {
+	operand := c.value(e.Right)
+	if isInvalid(operand) {
+		return operand
+	}
+	switch e.Operator {
+	case "!":
+		if !isBoolean(operand) {
+			c.errorf(e.Token, "invalid operation: operator ! not defined on %s (%s)", e.Right, c.describe(e.Right, operand))
+			return Typ[Invalid]
+		}
+	case "-":
+		if !isNumeric(operand) {
+			c.errorf(e.Token, "invalid operation: operator - not defined on %s (%s)", e.Right, c.describe(e.Right, operand))
+			return Typ[Invalid]
+		}
+	case "*":
+		p, ok := operand.Underlying().(*Pointer)
+		if !ok {
+			c.errorf(e.Token, "invalid operation: cannot indirect %s (%s)", e.Right, c.describe(e.Right, operand))
+			return Typ[Invalid]
+		}
+		return p.Elem
+	case "&":
+		return &Pointer{Elem: operand}
+	}
+	return operand
+}
+
+func (c *checker) call(e *ast.CallExpression) Type {
+	switch fn := e.Function.(type) {
+	case *ast.Identifier:
+		if obj := c.scope.Lookup(fn.Value); obj != nil {
+			switch obj.Kind {
+			case TypeObject:
+				c.info.Uses[fn] = obj
+				return c.conversion(e, obj.Type)
+			case BuiltinObject:
+				c.info.Uses[fn] = obj
+				return c.builtin(e, fn.Value)
+			}
+		}
+	case *ast.SelectorExpression:
+		// Conversions to imported types, like json.RawMessage(b).
+		if pkg := c.packageOf(fn); pkg != nil && pkg.Pkg != nil {
+			if obj := pkg.Pkg.Scope.Lookup(fn.Name.Value); obj != nil && obj.Kind == TypeObject {
+				c.info.Uses[fn.Name] = obj
+				return c.conversion(e, obj.Type)
+			}
+		}
+	case *ast.ExpressionLiteral:
+		// string(b), the only type keyword the parser reads as a value.
+		if fn.Token.Type == token.STRING {
+			return c.conversion(e, Typ[String])
+		}
+	}
+
+	var typ Type
+	if ext, ok := ast.LookupExtension(e); ok && c.packageOf(e.Function.(*ast.SelectorExpression)) == nil {
+		// A field or method of the receiver's type hides the extension.
+		sel := e.Function.(*ast.SelectorExpression)
+		recv := c.value(sel.Left)
+		member, ok := fieldOrMethod(recv, sel.Name.Value)
+		if !ok {
+			c.info.Extensions[e] = ext
+			return c.extension(e, ext, recv)
+		}
+		c.info.Types[sel] = member
+		typ = member
+	} else {
+		typ = c.value(e.Function)
+	}
+	sig, ok := typ.Underlying().(*Signature)
+	if !ok {
+		if !isInvalid(typ) {
+			c.errorf(start(e), "invalid operation: cannot call non-function %s (%s)", e.Function, c.describe(e.Function, typ))
+		}
+		for _, arg := range e.Arguments {
+			c.value(arg)
+		}
+		return Typ[Invalid]
+	}
+	c.arguments(e, e.Function.String(), sig)
+	return sig.result()
+}
+
+// arguments checks the arguments of a call to a function with signature
+// sig.
+func (c *checker) arguments(e *ast.CallExpression, name string, sig *Signature) {
+	args := make([]Type, len(e.Arguments))
+	for i, arg := range e.Arguments {
+		args[i] = c.value(arg)
+	}
+
+	params := sig.Params.Len()
+	if len(args) < params && !(sig.Variadic && len(args) == params-1) {
+		c.errorf(e.Token, "not enough arguments in call to %s, have %s, want %s", name, tupleOf(args), sig.Params)
+		return
+	}
+	if len(args) > params && !sig.Variadic {
+		c.errorf(start(e.Arguments[params]), "too many arguments in call to %s, have %s, want %s", name, tupleOf(args), sig.Params)
+		return
+	}
+
+	for i, arg := range e.Arguments {
+		var param Type
+		if sig.Variadic && i >= params-1 {
+			param = sig.Params.At(params - 1).Type.(*Slice).Elem
+		} else {
+			param = sig.Params.At(i).Type
+		}
+		c.assignment(arg, args[i], param, "argument to "+name)
+	}
+}
+
+func tupleOf(types []Type) *Tuple {
+	t := NewTuple()
+	for _, typ := range types {
+		t.Vars = append(t.Vars, &Var{Type: typ})
+	}
+	return t
+}
+
+func (c *checker) conversion(e *ast.CallExpression, typ Type) Type {
+	if len(e.Arguments) != 1 {
+		c.errorf(e.Token, "wrong argument count in conversion to %s", typ)
+		return typ
+	}
+	arg := e.Arguments[0]
+	value := c.value(arg)
+	if !convertible(value, typ) {
+		c.errorf(start(arg), "cannot convert %s (%s) to type %s", arg, c.describe(arg, value), typ)
+	}
+	return typ
+}
+
+func convertible(v, t Type) bool {
+	if AssignableTo(v, t) || Identical(v.Underlying(), t.Underlying()) {
+		return true
+	}
+	if isNumeric(v) && isNumeric(t) {
+		return true
+	}
+	if basicKind(v) == UnsafePointer || basicKind(t) == UnsafePointer {
+		// Between unsafe.Pointer and pointers or uintptr.
+		other := t
+		if basicKind(t) == UnsafePointer {
+			other = v
+		}
+		_, isPointer := other.Underlying().(*Pointer)
+		return isPointer || basicKind(other) == Uintptr
+	}
+	if isString(t) {
+		if isInteger(v) {
+			return true
+		}
+		if s, ok := v.Underlying().(*Slice); ok {
+			k := basicKind(s.Elem)
+			return k == Uint8 || k == Int32
+		}
+	}
+	if isString(v) {
+		if s, ok := t.Underlying().(*Slice); ok {
+			k := basicKind(s.Elem)
+			return k == Uint8 || k == Int32
+		}
+	}
+	return false
+}
+
+func (c *checker) builtin(e *ast.CallExpression, name string) Type {
+	args := make([]Type, len(e.Arguments))
+	for i, arg := range e.Arguments {
+		args[i] = c.value(arg)
+	}
+
+	switch name {
+	case "len", "cap":
+		if len(args) != 1 {
+			c.errorf(e.Token, "wrong number of arguments in call to %s, have %d, want 1", name, len(args))
+		} else if !hasLength(args[0]) {
+			c.errorf(start(e.Arguments[0]), "invalid argument: %s (%s) for built-in %s",
+				e.Arguments[0], c.describe(e.Arguments[0], args[0]), name)
+		}
+		return Typ[Int]
+	case "append":
+		if len(args) == 0 {
+			c.errorf(e.Token, "not enough arguments for append()")
+			return Typ[Invalid]
+		}
+		s, ok := args[0].Underlying().(*Slice)
+		if !ok {
+			if !isInvalid(args[0]) {
+				c.errorf(start(e.Arguments[0]), "invalid argument: %s (%s) is not a slice",
+					e.Arguments[0], c.describe(e.Arguments[0], args[0]))
+			}
+			return Typ[Invalid]
+		}
+		for i, arg := range e.Arguments[1:] {
+			c.assignment(arg, args[i+1], s.Elem, "argument to append")
+		}
+		return args[0]
+	case "copy":
+		return Typ[Int]
+	case "panic", "print", "println", "delete":
+		return NewTuple()
+	}
+	// make and new take a type, which the parser reads as a value.
+	return Typ[Invalid]
+}
+
+func hasLength(t Type) bool {
+	if isInvalid(t) || isString(t) {
+		return true
+	}
+	switch t.Underlying().(type) {
+	case *Slice, *Array, *Map:
+		return true
+	}
+	return false
+}
+
+// extension checks a call to a slice extension method like `xs.map(f)`
+// on a receiver of type recv. The number of arguments is checked by the
+// compiler.
+func (c *checker) extension(e *ast.CallExpression, ext ast.Extension, recv Type) Type {
+	sel := e.Function.(*ast.SelectorExpression)
+	args := make([]Type, len(e.Arguments))
+	for i, arg := range e.Arguments {
+		args[i] = c.value(arg)
+	}
+
+	if ext.Name == "len" || ext.Name == "is_empty" {
+		if !hasLength(recv) {
+			c.errorf(sel.Name.Token, "%s undefined (type %s has no length)", sel, recv)
+		}
+		if ext.Name == "len" {
+			return Typ[Int]
+		}
+		return Typ[Bool]
+	}
+
+	var elem Type = Typ[Invalid]
+	if s, ok := recv.Underlying().(*Slice); ok {
+		elem = s.Elem
+	} else if !isInvalid(recv) {
+		c.errorf(sel.Name.Token, "%s undefined (type %s is not a slice)", sel, recv)
+		return Typ[Invalid]
+	}
+	name := sel.String()
+
+	switch ext.Name {
+	case "first", "last":
+		return c.option(elem)
+	case "contains", "index_of":
+		if len(args) == 1 {
+			c.assignment(e.Arguments[0], args[0], elem, "argument to "+name)
+		}
+		if ext.Name == "contains" {
+			return Typ[Bool]
+		}
+		return Typ[Int]
+	case "filter":
+		if len(args) == 1 {
+			c.callback(e.Arguments[0], args[0], name, []Type{elem}, Typ[Bool])
+		}
+		return recv
+	case "map":
+		if len(args) == 1 {
+			if result := c.callback(e.Arguments[0], args[0], name, []Type{elem}, nil); result != nil {
+				return &Slice{Elem: result}
+			}
+		}
+		return &Slice{Elem: Typ[Invalid]}
+	case "reduce":
+		if len(args) == 2 {
+			acc := c.inferred(e.Arguments[0], args[0])
+			c.callback(e.Arguments[1], args[1], name, []Type{acc, elem}, acc)
+			return acc
+		}
+	}
+	return Typ[Invalid]
+}
+
+// callback checks the function passed to an extension method, which is
+// called with params and must return result, or any single value when
+// result is nil. It returns the result type of the function.
+func (c *checker) callback(arg ast.Expression, typ Type, name string, params []Type, result Type) Type {
+	if isInvalid(typ) {
+		return nil
+	}
+	sig, ok := typ.Underlying().(*Signature)
+	fits := ok && sig.Params.Len() == len(params) && sig.Results.Len() == 1 && !sig.Variadic
+	if fits {
+		for i, p := range params {
+			if !AssignableTo(p, sig.Params.At(i).Type) {
+				fits = false
+			}
+		}
+		if result != nil && !Identical(sig.Results.At(0).Type, result) {
+			fits = false
+		}
+	}
+	if !fits {
+		want := &Signature{Params: tupleOf(params), Results: NewTuple(&Var{Type: result})}
+		if result == nil {
+			want.Results = NewTuple(&Var{Type: &Named{Name: "T"}})
+		}
+		c.errorf(start(arg), "cannot use %s (%s) as %s value in argument to %s", brief(arg), c.describe(arg, typ), want, name)
+		return nil
+	}
+	return sig.Results.At(0).Type
+}
+
+// option returns std.Option[elem], the type returned by xs.first().
+func (c *checker) option(elem Type) Type {
+	if opt, ok := c.options[elem.String()]; ok {
+		return opt
+	}
+	method := func(name string, params []Type, results ...Type) *Func {
+		return &Func{Name: name, Sig: &Signature{Params: tupleOf(params), Results: tupleOf(results)}}
+	}
+	opt := &Named{Name: "std.Option[" + elem.String() + "]", underlying: &Struct{}}
+	opt.addMethod(method("IsSome", nil, Typ[Bool]))
+	opt.addMethod(method("IsNone", nil, Typ[Bool]))
+	opt.addMethod(method("Get", nil, elem, Typ[Bool]))
+	opt.addMethod(method("UnwrapOr", []Type{elem}, elem))
+	opt.addMethod(method("Unwrap", nil, elem))
+	c.options[elem.String()] = opt
+	return opt
+}
+
+func (c *checker) selector(e *ast.SelectorExpression) Type {
+	if pkg := c.packageOf(e); pkg != nil {
+		obj := c.member(pkg, e.Name)
+		if obj == nil {
+			return Typ[Invalid]
+		}
+		if obj.Kind == TypeObject {
+			c.errorf(e.Name.Token, "%s (type) is not an expression", e)
+			return Typ[Invalid]
+		}
+		return obj.Type
+	}
+
+	typ := c.value(e.Left)
+	if isInvalid(typ) {
+		return typ
+	}
+	name := e.Name.Value
+	if member, ok := fieldOrMethod(typ, name); ok {
+		return member
+	}
+	c.errorf(e.Name.Token, "%s undefined (type %s has no field or method %s)", e, typ, name)
+	return Typ[Invalid]
+}
+
+// fieldOrMethod returns the type of the method or struct field name of
+// a value of type typ.
+func fieldOrMethod(typ Type, name string) (Type, bool) {
+	if isInvalid(typ) {
+		return typ, false
+	}
+	if m := methodOf(typ, name); m != nil {
+		return m.Sig, true
+	}
+	base := typ
+	if p, ok := typ.Underlying().(*Pointer); ok {
+		base = p.Elem
+		if isInvalid(base) {
+			return base, true
+		}
+	}
+	if s, ok := base.Underlying().(*Struct); ok {
+		if f := s.Field(name); f != nil {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+// packageOf returns the imported package a selector like `fmt.Println`
+// refers to, or nil when its left side is not a package.
+func (c *checker) packageOf(e *ast.SelectorExpression) *Object {
+	ident, ok := e.Left.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	obj := c.scope.Lookup(ident.Value)
+	if obj == nil || obj.Kind != PackageObject {
+		return nil
+	}
+	c.info.Uses[ident] = obj
+	return obj
+}
+
+func (c *checker) index(e *ast.IndexExpression) Type {
+	typ := c.value(e.Left)
+	index := c.value(e.Index)
+	if isInvalid(typ) {
+		return typ
+	}
+
+	switch t := typ.Underlying().(type) {
+	case *Map:
+		c.assignment(e.Index, index, t.Key, "map index")
+		return t.Elem
+	case *Slice:
+		c.integerIndex(e.Index, index)
+		return t.Elem
+	case *Array:
+		c.integerIndex(e.Index, index)
+		return t.Elem
+	}
+	if isString(typ) {
+		c.integerIndex(e.Index, index)
+		return Typ[Uint8]
+	}
+	c.errorf(start(e), "invalid operation: cannot index %s (%s)", e.Left, c.describe(e.Left, typ))
+	return Typ[Invalid]
+}
+
+func (c *checker) integerIndex(exp ast.Expression, typ Type) {
+	if !isInvalid(typ) && !isInteger(typ) {
+		c.errorf(start(exp), "invalid argument: index %s (%s) must be integer", exp, c.describe(exp, typ))
+	}
+}
+
+func (c *checker) arrayLiteral(e *ast.ArrayLiteral) Type {
+	elem := c.typeExpr(e.ElementType())
+	for _, el := range e.Elements {
+		c.assignment(el, c.value(el), elem, "slice literal")
+	}
+	return &Slice{Elem: elem}
+}
+
+func (c *checker) mapLiteral(e *ast.MapLiteral) Type {
+	m := &Map{Key: c.typeExpr(e.Key.Value), Elem: c.typeExpr(e.Value.Value)}
+	if e.KeyValue == nil {
+		return m
+	}
+	seen := map[string]bool{}
+	for _, pair := range e.KeyValue.Pairs {
+		c.assignment(pair.Key, c.value(pair.Key), m.Key, "map literal")
+		c.assignment(pair.Value, c.value(pair.Value), m.Elem, "map literal")
+		if key, ok := constantKey(pair.Key); ok {
+			if seen[key] {
+				c.errorf(start(pair.Key), "duplicate key %s in map literal", pair.Key)
+			}
+			seen[key] = true
+		}
+	}
+	return m
+}
+
+// constantKey returns a string identifying the value of a constant map
+// key, so that equal keys compare equal however they are spelled, like "a"
+// and "\x61", 2 and 1 + 1, or 1 and 01.
+func constantKey(exp ast.Expression) (string, bool) {
+	v := constantValue(exp)
+	if v.Kind() == constant.Unknown {
+		return "", false
+	}
+	return v.ExactString(), true
+}
+
+// constantOps maps the yuk operators of constant expressions to the Go
+// ones.
+var constantOps = map[string]gotoken.Token{
+	"+":  gotoken.ADD,
+	"-":  gotoken.SUB,
+	"*":  gotoken.MUL,
+	"/":  gotoken.QUO,
+	"!":  gotoken.NOT,
+	"==": gotoken.EQL,
+	"!=": gotoken.NEQ,
+	"<":  gotoken.LSS,
+	">":  gotoken.GTR,
+}
+
+// constantValue evaluates the constant expression exp, made of literals and
+// operators, as Go would. The value is unknown when exp is not constant or
+// its operation is invalid.
+func constantValue(exp ast.Expression) constant.Value {
+	unknown := constant.MakeUnknown()
+	switch e := exp.(type) {
+	case *ast.StringLiteral:
+		if v := constant.MakeFromLiteral(`"`+e.Value+`"`, gotoken.STRING, 0); v.Kind() != constant.Unknown {
+			return v
+		}
+		return constant.MakeString(e.Value)
+	case *ast.IntegerLiteral:
+		return constant.MakeFromLiteral(e.Value, gotoken.INT, 0)
+	case *ast.Boolean:
+		return constant.MakeBool(e.Token.Literal == "true")
+	case *ast.GroupedExpression:
+		return constantValue(e.Expression)
+	case *ast.PrefixExpression:
+		op, ok := constantOps[e.Operator]
+		x := constantValue(e.Right)
+		if !ok || x.Kind() == constant.Unknown {
+			return unknown
+		}
+		if (op == gotoken.NOT) != (x.Kind() == constant.Bool) || x.Kind() == constant.String {
+			return unknown
+		}
+		return constant.UnaryOp(op, x, 0)
+	case *ast.InfixExpression:
+		op, ok := constantOps[e.Operator]
+		x, y := constantValue(e.Left), constantValue(e.Right)
+		if !ok || op == gotoken.NOT || x.Kind() == constant.Unknown || x.Kind() != y.Kind() {
+			return unknown
+		}
+		switch op {
+		case gotoken.EQL, gotoken.NEQ, gotoken.LSS, gotoken.GTR:
+			if x.Kind() == constant.Bool && (op == gotoken.LSS || op == gotoken.GTR) {
+				return unknown
+			}
+			return constant.MakeBool(constant.Compare(x, op, y))
+		case gotoken.ADD:
+			if x.Kind() == constant.Bool {
+				return unknown
+			}
+		default:
+			if x.Kind() != constant.Int {
+				return unknown
+			}
+		}
+		if op == gotoken.QUO {
+			if constant.Sign(y) == 0 {
+				return unknown
+			}
+			// Integer constants divide truncating, as Go's / does.
+			op = gotoken.QUO_ASSIGN
+		}
+		return constant.BinaryOp(x, op, y)
+	}
+	return unknown
+}
+
+func (c *checker) structLiteral(e *ast.StructLiteral) Type {
+	typ := c.typeExpr(e.Type.Value)
+	s, ok := typ.Underlying().(*Struct)
+	if !ok && !isInvalid(typ) {
+		c.errorf(e.Type.Token, "invalid composite literal type %s", typ)
+	}
+	for _, f := range e.Fields {
+		value := c.value(f.Value)
+		if s == nil {
+			continue
+		}
+		field := s.Field(f.Name.Value)
+		if field == nil {
+			c.errorf(f.Name.Token, "unknown field %s in struct literal of type %s", f.Name.Value, typ)
+			continue
+		}
+		c.assignment(f.Value, value, field.Type, "struct literal")
+	}
+	return typ
+}
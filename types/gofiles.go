@@ -0,0 +1,102 @@
+package types
+
+import (
+	goast "go/ast"
+	gotoken "go/token"
+	gotypes "go/types"
+
+	"github.com/ahmadrosid/yuk/token"
+)
+
+// lockedImporter shares the source importer of GoImporter, which is not
+// safe for concurrent use.
+type lockedImporter struct {
+	g *goImporter
+}
+
+func (l lockedImporter) Import(path string) (*gotypes.Package, error) {
+	l.g.mu.Lock()
+	defer l.g.mu.Unlock()
+	return l.g.from.Import(path)
+}
+
+// checkGo type checks the Go files of the package with the Go code of its
+// yuk files, and reports the errors located in the Go files. The errors
+// of the generated code are left to the yuk checks. It returns nil when
+// the package has no Go files.
+func (c *checker) checkGo(conf *Config) *gotypes.Package {
+	if len(conf.GoFiles) == 0 {
+		return nil
+	}
+	c.goFiles = map[string]bool{}
+	for _, f := range conf.GoFiles {
+		c.goFiles[conf.Fset.File(f.Pos()).Name()] = true
+	}
+	c.fset = conf.Fset
+
+	goconf := &gotypes.Config{
+		Importer: lockedImporter{defaultImporter},
+		Error: func(err error) {
+			terr, ok := err.(gotypes.Error)
+			if !ok {
+				return
+			}
+			pos := terr.Fset.Position(terr.Pos)
+			if c.goFiles[pos.Filename] {
+				c.errors = append(c.errors, &token.Error{File: pos.Filename, Line: pos.Line, Column: pos.Column, Msg: terr.Msg})
+			}
+		},
+	}
+	files := append(append([]*goast.File{}, conf.GoStubs...), conf.GoFiles...)
+	pkg, _ := goconf.Check(conf.GoFiles[0].Name.Name, conf.Fset, files, nil)
+	return pkg
+}
+
+// goDecls declares the package level objects of the Go files in the
+// package scope, and adds the methods they declare on the types of the
+// yuk files to them.
+func (c *checker) goDecls(local *gotypes.Package) {
+	g := &goImporter{named: map[*gotypes.TypeName]*Named{}, local: local, yuk: map[string]*Named{}}
+	for name, obj := range c.pkg.objects {
+		if named, ok := obj.Type.(*Named); ok && obj.Kind == TypeObject {
+			g.yuk[name] = named
+		}
+	}
+
+	scope := local.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		pos := c.fset.Position(obj.Pos())
+		if !c.goFiles[pos.Filename] {
+			continue
+		}
+		member := g.object(obj)
+		member.Pos = goToken(name, pos)
+		c.pkg.Insert(member)
+	}
+
+	for name, named := range g.yuk {
+		obj, ok := scope.Lookup(name).(*gotypes.TypeName)
+		if !ok {
+			continue
+		}
+		t, ok := obj.Type().(*gotypes.Named)
+		if !ok {
+			continue
+		}
+		for i := 0; i < t.NumMethods(); i++ {
+			m := t.Method(i)
+			if !c.goFiles[c.fset.Position(m.Pos()).Filename] {
+				continue
+			}
+			if sig, ok := g.convert(m.Type()).(*Signature); ok {
+				named.addMethod(&Func{Name: m.Name(), Sig: sig})
+			}
+		}
+	}
+}
+
+// goToken returns a token for the declaration of name at pos in a Go file.
+func goToken(name string, pos gotoken.Position) token.Token {
+	return token.Token{Type: token.IDENT, Literal: name, Line: pos.Line, Column: pos.Column}
+}
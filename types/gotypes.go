@@ -0,0 +1,208 @@
+package types
+
+import (
+	"go/importer"
+	gotoken "go/token"
+	gotypes "go/types"
+	"sync"
+)
+
+// goImporter loads packages from the source files of the local toolchain,
+// GOPATH and module cache, so it never needs the network. It converts the
+// exported members of a package to yuk types.
+type goImporter struct {
+	mu    sync.Mutex
+	from  gotypes.Importer
+	pkgs  map[string]*Package
+	named map[*gotypes.TypeName]*Named
+
+	// local is the package being checked when converting the declarations
+	// of its Go files. Its types are named without their package, and yuk
+	// holds the ones declared by the yuk files.
+	local *gotypes.Package
+	yuk   map[string]*Named
+}
+
+var defaultImporter = &goImporter{
+	from:  importer.ForCompiler(gotoken.NewFileSet(), "source", nil),
+	pkgs:  map[string]*Package{},
+	named: map[*gotypes.TypeName]*Named{},
+}
+
+// GoImporter returns the importer used by Check. Loaded packages are
+// cached and shared by every caller.
+func GoImporter() Importer {
+	return defaultImporter
+}
+
+func (g *goImporter) Import(path string) (*Package, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if pkg, ok := g.pkgs[path]; ok {
+		return pkg, nil
+	}
+	gopkg, err := g.from.Import(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &Package{Path: path, Name: gopkg.Name(), Scope: NewScope(nil)}
+	scope := gopkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		pkg.Scope.Insert(g.object(obj))
+	}
+	g.pkgs[path] = pkg
+	return pkg, nil
+}
+
+// object converts a member of a Go package.
+func (g *goImporter) object(obj gotypes.Object) *Object {
+	member := &Object{Name: obj.Name(), Type: g.convert(obj.Type())}
+	switch obj.(type) {
+	case *gotypes.Func:
+		member.Kind = FuncObject
+	case *gotypes.Const:
+		member.Kind = ConstObject
+	case *gotypes.TypeName:
+		member.Kind = TypeObject
+	default:
+		member.Kind = VarObject
+	}
+	return member
+}
+
+// convert returns the yuk type of a Go type. Generic types and functions,
+// channels and complex numbers have no yuk counterpart and are invalid.
+func (g *goImporter) convert(t gotypes.Type) Type {
+	switch t := t.(type) {
+	case *gotypes.Basic:
+		return convertBasic(t)
+	case *gotypes.Pointer:
+		return &Pointer{Elem: g.convert(t.Elem())}
+	case *gotypes.Slice:
+		return &Slice{Elem: g.convert(t.Elem())}
+	case *gotypes.Array:
+		return &Array{Len: t.Len(), Elem: g.convert(t.Elem())}
+	case *gotypes.Map:
+		return &Map{Key: g.convert(t.Key()), Elem: g.convert(t.Elem())}
+	case *gotypes.Struct:
+		s := &Struct{}
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			s.Fields = append(s.Fields, &Var{Name: f.Name(), Type: g.convert(f.Type()), Embedded: f.Embedded()})
+		}
+		return s
+	case *gotypes.Tuple:
+		return g.tuple(t)
+	case *gotypes.Signature:
+		if t.TypeParams().Len() > 0 {
+			return Typ[Invalid]
+		}
+		return &Signature{Params: g.tuple(t.Params()), Results: g.tuple(t.Results()), Variadic: t.Variadic()}
+	case *gotypes.Interface:
+		if !t.IsMethodSet() {
+			return Typ[Invalid]
+		}
+		it := &Interface{}
+		for i := 0; i < t.NumMethods(); i++ {
+			m := t.Method(i)
+			it.Methods = append(it.Methods, &Func{Name: m.Name(), Sig: g.convert(m.Type()).(*Signature)})
+		}
+		return it
+	case *gotypes.Named:
+		return g.convertNamed(t)
+	}
+	// Aliases convert to the type they stand for.
+	if u := t.Underlying(); u != t {
+		return g.convert(u)
+	}
+	return Typ[Invalid]
+}
+
+func (g *goImporter) tuple(t *gotypes.Tuple) *Tuple {
+	tuple := NewTuple()
+	for i := 0; i < t.Len(); i++ {
+		v := t.At(i)
+		tuple.Vars = append(tuple.Vars, &Var{Name: v.Name(), Type: g.convert(v.Type())})
+	}
+	return tuple
+}
+
+func (g *goImporter) convertNamed(t *gotypes.Named) Type {
+	obj := t.Obj()
+	if obj.Pkg() == nil {
+		if obj.Name() == "error" {
+			return universeError
+		}
+		return Typ[Invalid]
+	}
+	if t.TypeParams().Len() > 0 || t.TypeArgs().Len() > 0 {
+		return Typ[Invalid]
+	}
+	if named, ok := g.named[obj]; ok {
+		return named
+	}
+	local := g.local != nil && obj.Pkg() == g.local
+	if named, ok := g.yuk[obj.Name()]; ok && local {
+		return named
+	}
+
+	// Register the type before converting its underlying type and
+	// methods, which may refer back to it.
+	named := &Named{Name: obj.Pkg().Name() + "." + obj.Name()}
+	if local {
+		named.Name = obj.Name()
+	}
+	g.named[obj] = named
+	named.underlying = g.convert(t.Underlying())
+	// The method set of *T has the methods of both receiver kinds and the
+	// methods promoted from embedded fields.
+	methods := gotypes.NewMethodSet(gotypes.NewPointer(t))
+	for i := 0; i < methods.Len(); i++ {
+		m := methods.At(i).Obj()
+		if !m.Exported() && !local {
+			continue
+		}
+		if sig, ok := g.convert(m.Type()).(*Signature); ok {
+			named.addMethod(&Func{Name: m.Name(), Sig: sig})
+		}
+	}
+	return named
+}
+
+var basicKinds = map[gotypes.BasicKind]BasicKind{
+	gotypes.Bool:          Bool,
+	gotypes.Int:           Int,
+	gotypes.Int8:          Int8,
+	gotypes.Int16:         Int16,
+	gotypes.Int32:         Int32,
+	gotypes.Int64:         Int64,
+	gotypes.Uint:          Uint,
+	gotypes.Uint8:         Uint8,
+	gotypes.Uint16:        Uint16,
+	gotypes.Uint32:        Uint32,
+	gotypes.Uint64:        Uint64,
+	gotypes.Uintptr:       Uintptr,
+	gotypes.Float32:       Float32,
+	gotypes.Float64:       Float64,
+	gotypes.String:        String,
+	gotypes.UnsafePointer: UnsafePointer,
+	gotypes.UntypedBool:   UntypedBool,
+	gotypes.UntypedInt:    UntypedInt,
+	gotypes.UntypedRune:   UntypedRune,
+	gotypes.UntypedFloat:  UntypedFloat,
+	gotypes.UntypedString: UntypedString,
+	gotypes.UntypedNil:    UntypedNil,
+}
+
+func convertBasic(t *gotypes.Basic) Type {
+	if kind, ok := basicKinds[t.Kind()]; ok {
+		return Typ[kind]
+	}
+	return Typ[Invalid]
+}
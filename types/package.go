@@ -0,0 +1,85 @@
+package types
+
+import (
+	"fmt"
+	goast "go/ast"
+	gotoken "go/token"
+	"strings"
+)
+
+// Package is an imported Go or yuk package. Its scope holds the exported
+// functions, variables, constants and types of the package.
+type Package struct {
+	Path  string
+	Name  string
+	Scope *Scope
+}
+
+// NewPackage returns the package with the import path path whose package
+// scope is scope, as checked by Check, for the programs importing it. It
+// holds the exported objects of scope, and qualifies the names of its types
+// with name like the ones of imported Go packages, so it must only be
+// created once the package is checked.
+func NewPackage(path, name string, scope *Scope) *Package {
+	pkg := &Package{Path: path, Name: name, Scope: NewScope(nil)}
+	for _, obj := range scope.objects {
+		if !gotoken.IsExported(obj.Name) {
+			continue
+		}
+		if named, ok := obj.Type.(*Named); ok && obj.Kind == TypeObject && !strings.Contains(named.Name, ".") {
+			named.Name = name + "." + named.Name
+		}
+		pkg.Scope.Insert(obj)
+	}
+	return pkg
+}
+
+// API describes the exported objects of pkg, one per line: their kind,
+// name and type, with the underlying type and the methods of named types.
+// Two versions of a package with the same API can be used the same way.
+func (pkg *Package) API() string {
+	var out strings.Builder
+	for _, name := range pkg.Scope.Names() {
+		obj := pkg.Scope.Lookup(name)
+		fmt.Fprintf(&out, "%s %s %s\n", objectKinds[obj.Kind], name, obj.Type)
+		named, ok := obj.Type.(*Named)
+		if !ok || obj.Kind != TypeObject {
+			continue
+		}
+		fmt.Fprintf(&out, "\t%s\n", named.Underlying())
+		for _, m := range named.Methods {
+			fmt.Fprintf(&out, "\t%s%s\n", m.Name, m.Sig.signature())
+		}
+	}
+	return out.String()
+}
+
+var objectKinds = map[ObjectKind]string{
+	VarObject:     "var",
+	ConstObject:   "const",
+	FuncObject:    "func",
+	TypeObject:    "type",
+	PackageObject: "package",
+	BuiltinObject: "builtin",
+	NilObject:     "nil",
+}
+
+// Importer loads the package with the given import path.
+type Importer interface {
+	Import(path string) (*Package, error)
+}
+
+// Config configures a type check.
+type Config struct {
+	// Importer loads the Go packages imported by the program. Imported
+	// packages have no members when it is nil.
+	Importer Importer
+
+	// GoFiles are the hand-written Go files of the package, positioned by
+	// Fset. Their declarations are visible to the programs, and they are
+	// type checked with GoStubs, the Go code generated for the programs,
+	// so they can use the declarations of the programs too.
+	Fset    *gotoken.FileSet
+	GoFiles []*goast.File
+	GoStubs []*goast.File
+}
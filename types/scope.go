@@ -0,0 +1,100 @@
+package types
+
+import (
+	"sort"
+
+	"github.com/ahmadrosid/yuk/token"
+)
+
+type ObjectKind int
+
+const (
+	VarObject ObjectKind = iota
+	ConstObject
+	FuncObject
+	TypeObject
+	PackageObject
+	BuiltinObject
+	NilObject
+)
+
+// Object is a named entity: a variable, a constant, a function, a type,
+// an imported package, a builtin function or nil.
+type Object struct {
+	Kind ObjectKind
+	Name string
+	Type Type
+	// Pkg is the package an imported package object refers to, it is nil
+	// when the package could not be loaded.
+	Pkg *Package
+	// Pos is the position of the declaration, it is zero for the objects
+	// of the universe scope.
+	Pos token.Token
+}
+
+// Scope maps names to the objects they are declared as, falling back to
+// the enclosing scope.
+type Scope struct {
+	parent  *Scope
+	objects map[string]*Object
+}
+
+func NewScope(parent *Scope) *Scope {
+	return &Scope{parent: parent, objects: map[string]*Object{}}
+}
+
+func (s *Scope) Parent() *Scope { return s.parent }
+
+// Lookup returns the object called name in s or its enclosing scopes.
+func (s *Scope) Lookup(name string) *Object {
+	for ; s != nil; s = s.parent {
+		if obj, ok := s.objects[name]; ok {
+			return obj
+		}
+	}
+	return nil
+}
+
+// Names returns the names declared in s, not its enclosing scopes, sorted.
+func (s *Scope) Names() []string {
+	names := make([]string, 0, len(s.objects))
+	for name := range s.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Insert declares obj in s, replacing any object with the same name.
+func (s *Scope) Insert(obj *Object) {
+	s.objects[obj.Name] = obj
+}
+
+// Universe holds the predeclared types, builtin functions and nil.
+var Universe = NewScope(nil)
+
+var universeRune = Typ[Int32]
+
+var universeError = &Named{Name: "error"}
+
+func init() {
+	for _, t := range Typ {
+		if t.Kind != Invalid && t.Kind != UnsafePointer && !isUntyped(t) {
+			Universe.Insert(&Object{Kind: TypeObject, Name: t.Name, Type: t})
+		}
+	}
+	Universe.Insert(&Object{Kind: TypeObject, Name: "byte", Type: Typ[Uint8]})
+	Universe.Insert(&Object{Kind: TypeObject, Name: "rune", Type: universeRune})
+	Universe.Insert(&Object{Kind: TypeObject, Name: "any", Type: &Interface{}})
+
+	universeError.underlying = &Interface{Methods: []*Func{{
+		Name: "Error",
+		Sig:  &Signature{Params: NewTuple(), Results: NewTuple(&Var{Type: Typ[String]})},
+	}}}
+	Universe.Insert(&Object{Kind: TypeObject, Name: "error", Type: universeError})
+
+	Universe.Insert(&Object{Kind: NilObject, Name: "nil", Type: Typ[UntypedNil]})
+	for _, name := range []string{"append", "cap", "copy", "delete", "len", "make", "new", "panic", "print", "println"} {
+		Universe.Insert(&Object{Kind: BuiltinObject, Name: name, Type: Typ[Invalid]})
+	}
+}
@@ -0,0 +1,507 @@
+// Package types infers the type of every expression of a yuk program and
+// reports the expressions whose types do not fit, such as a string assigned
+// to an int variable, at their yuk source positions.
+//
+// Imported Go packages are loaded from the local toolchain and module
+// cache, so calls into them are checked too. Names that can not be
+// resolved, and the members of packages that could not be loaded, get the
+// invalid type. The invalid type is compatible with every other
+// type, so a single unknown name never causes a cascade of errors.
+package types
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of a yuk value. Types are spelled like their Go
+// counterparts, as that is what they compile to.
+type Type interface {
+	// Underlying returns the type a named type is defined with, or the
+	// type itself for every other type.
+	Underlying() Type
+	String() string
+}
+
+type BasicKind int
+
+const (
+	Invalid BasicKind = iota
+
+	Bool
+	Int
+	Int8
+	Int16
+	Int32
+	Int64
+	Uint
+	Uint8
+	Uint16
+	Uint32
+	Uint64
+	Uintptr
+	Float32
+	Float64
+	String
+	UnsafePointer
+
+	// The types of constants and nil, before they are given a type by the
+	// context they are used in.
+	UntypedBool
+	UntypedInt
+	UntypedRune
+	UntypedFloat
+	UntypedString
+	UntypedNil
+)
+
+// Basic is a predeclared type like int or string, or the type of an
+// untyped constant.
+type Basic struct {
+	Kind BasicKind
+	Name string
+}
+
+func (b *Basic) Underlying() Type { return b }
+func (b *Basic) String() string   { return b.Name }
+
+// Typ holds the basic types, indexed by kind.
+var Typ = []*Basic{
+	Invalid:       {Invalid, "invalid type"},
+	Bool:          {Bool, "bool"},
+	Int:           {Int, "int"},
+	Int8:          {Int8, "int8"},
+	Int16:         {Int16, "int16"},
+	Int32:         {Int32, "int32"},
+	Int64:         {Int64, "int64"},
+	Uint:          {Uint, "uint"},
+	Uint8:         {Uint8, "uint8"},
+	Uint16:        {Uint16, "uint16"},
+	Uint32:        {Uint32, "uint32"},
+	Uint64:        {Uint64, "uint64"},
+	Uintptr:       {Uintptr, "uintptr"},
+	Float32:       {Float32, "float32"},
+	Float64:       {Float64, "float64"},
+	String:        {String, "string"},
+	UnsafePointer: {UnsafePointer, "unsafe.Pointer"},
+	UntypedBool:   {UntypedBool, "untyped bool"},
+	UntypedInt:    {UntypedInt, "untyped int"},
+	UntypedRune:   {UntypedRune, "untyped rune"},
+	UntypedFloat:  {UntypedFloat, "untyped float"},
+	UntypedString: {UntypedString, "untyped string"},
+	UntypedNil:    {UntypedNil, "untyped nil"},
+}
+
+type Pointer struct {
+	Elem Type
+}
+
+func (p *Pointer) Underlying() Type { return p }
+func (p *Pointer) String() string   { return "*" + p.Elem.String() }
+
+type Slice struct {
+	Elem Type
+}
+
+func (s *Slice) Underlying() Type { return s }
+func (s *Slice) String() string   { return "[]" + s.Elem.String() }
+
+// Array is a fixed size array, which only comes from imported packages,
+// e.g. the [32]byte returned by sha256.Sum256.
+type Array struct {
+	Len  int64
+	Elem Type
+}
+
+func (a *Array) Underlying() Type { return a }
+func (a *Array) String() string {
+	return "[" + strconv.FormatInt(a.Len, 10) + "]" + a.Elem.String()
+}
+
+type Map struct {
+	Key  Type
+	Elem Type
+}
+
+func (m *Map) Underlying() Type { return m }
+func (m *Map) String() string   { return "map[" + m.Key.String() + "]" + m.Elem.String() }
+
+// Var is a struct field, a function parameter or a result.
+type Var struct {
+	Name string
+	Type Type
+	// Embedded is set for the embedded fields of imported struct types.
+	Embedded bool
+}
+
+type Struct struct {
+	Fields []*Var
+}
+
+func (s *Struct) Underlying() Type { return s }
+func (s *Struct) String() string {
+	fields := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		fields[i] = f.Name + " " + f.Type.String()
+	}
+	return "struct{" + strings.Join(fields, "; ") + "}"
+}
+
+// Field returns the field called name, including the fields promoted from
+// embedded structs, or nil.
+func (s *Struct) Field(name string) *Var {
+	return s.field(name, map[*Struct]bool{})
+}
+
+func (s *Struct) field(name string, seen map[*Struct]bool) *Var {
+	seen[s] = true
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	for _, f := range s.Fields {
+		if !f.Embedded {
+			continue
+		}
+		typ := f.Type
+		if p, ok := typ.(*Pointer); ok {
+			typ = p.Elem
+		}
+		if embedded, ok := typ.Underlying().(*Struct); ok && !seen[embedded] {
+			if found := embedded.field(name, seen); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// Tuple is the list of parameters or results of a function. It is also
+// the type of a call returning zero or several values.
+type Tuple struct {
+	Vars []*Var
+}
+
+func NewTuple(vars ...*Var) *Tuple {
+	return &Tuple{Vars: vars}
+}
+
+func (t *Tuple) Len() int         { return len(t.Vars) }
+func (t *Tuple) At(i int) *Var    { return t.Vars[i] }
+func (t *Tuple) Underlying() Type { return t }
+func (t *Tuple) String() string {
+	types := make([]string, len(t.Vars))
+	for i, v := range t.Vars {
+		types[i] = v.Type.String()
+	}
+	return "(" + strings.Join(types, ", ") + ")"
+}
+
+type Signature struct {
+	Params  *Tuple
+	Results *Tuple
+	// Variadic is set when the last parameter is `...T`, its type is then
+	// []T.
+	Variadic bool
+}
+
+func (s *Signature) Underlying() Type { return s }
+func (s *Signature) String() string {
+	return "func" + s.signature()
+}
+
+func (s *Signature) signature() string {
+	params := make([]string, s.Params.Len())
+	for i, v := range s.Params.Vars {
+		params[i] = v.Type.String()
+		if s.Variadic && i == len(params)-1 {
+			params[i] = "..." + v.Type.(*Slice).Elem.String()
+		}
+	}
+	out := "(" + strings.Join(params, ", ") + ")"
+	switch s.Results.Len() {
+	case 0:
+	case 1:
+		out += " " + s.Results.At(0).Type.String()
+	default:
+		out += " " + s.Results.String()
+	}
+	return out
+}
+
+// result returns the type of a call to a function with this signature.
+func (s *Signature) result() Type {
+	if s.Results.Len() == 1 {
+		return s.Results.At(0).Type
+	}
+	return s.Results
+}
+
+// Func is a method of a named type or an interface.
+type Func struct {
+	Name string
+	Sig  *Signature
+}
+
+type Interface struct {
+	Methods []*Func
+}
+
+func (i *Interface) Underlying() Type { return i }
+func (i *Interface) String() string {
+	methods := make([]string, len(i.Methods))
+	for j, m := range i.Methods {
+		methods[j] = m.Name + m.Sig.signature()
+	}
+	return "interface{" + strings.Join(methods, "; ") + "}"
+}
+
+// Named is a type declared with `type Name T` or `struct Name(...)`.
+type Named struct {
+	Name       string
+	underlying Type
+	Methods    []*Func
+}
+
+// Underlying follows a chain of named types, e.g. `type A B`, to the type
+// it is defined with. A cycle has the invalid type.
+func (n *Named) Underlying() Type {
+	seen := map[*Named]bool{}
+	var t Type = n
+	for {
+		named, ok := t.(*Named)
+		if !ok {
+			return t
+		}
+		if seen[named] || named.underlying == nil {
+			return Typ[Invalid]
+		}
+		seen[named] = true
+		t = named.underlying
+	}
+}
+
+func (n *Named) String() string { return n.Name }
+
+func (n *Named) addMethod(m *Func) {
+	n.Methods = append(n.Methods, m)
+	sort.Slice(n.Methods, func(i, j int) bool { return n.Methods[i].Name < n.Methods[j].Name })
+}
+
+// Method returns the method called name, or nil.
+func (n *Named) Method(name string) *Func {
+	for _, m := range n.Methods {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+func isInvalid(t Type) bool {
+	b, ok := t.(*Basic)
+	return ok && b.Kind == Invalid
+}
+
+func isUntyped(t Type) bool {
+	b, ok := t.(*Basic)
+	return ok && b.Kind >= UntypedBool
+}
+
+func basicKind(t Type) BasicKind {
+	if b, ok := t.Underlying().(*Basic); ok {
+		return b.Kind
+	}
+	return -1
+}
+
+func isBoolean(t Type) bool {
+	k := basicKind(t)
+	return k == Bool || k == UntypedBool
+}
+
+func isString(t Type) bool {
+	k := basicKind(t)
+	return k == String || k == UntypedString
+}
+
+func isInteger(t Type) bool {
+	k := basicKind(t)
+	return k >= Int && k <= Uintptr || k == UntypedInt || k == UntypedRune
+}
+
+func isNumeric(t Type) bool {
+	k := basicKind(t)
+	return isInteger(t) || k == Float32 || k == Float64 || k == UntypedFloat
+}
+
+// isNamed reports whether t has a name, which makes it distinct from any
+// other type with the same underlying type.
+func isNamed(t Type) bool {
+	switch t := t.(type) {
+	case *Named:
+		return true
+	case *Basic:
+		return !isUntyped(t)
+	}
+	return false
+}
+
+// Default returns the type an untyped constant gets when nothing else
+// decides it, e.g. int for `x := 1`.
+func Default(t Type) Type {
+	b, ok := t.(*Basic)
+	if !ok {
+		return t
+	}
+	switch b.Kind {
+	case UntypedBool:
+		return Typ[Bool]
+	case UntypedInt:
+		return Typ[Int]
+	case UntypedRune:
+		return universeRune
+	case UntypedFloat:
+		return Typ[Float64]
+	case UntypedString:
+		return Typ[String]
+	}
+	return t
+}
+
+// Identical reports whether x and y are the same type.
+func Identical(x, y Type) bool {
+	if x == y {
+		return true
+	}
+	switch x := x.(type) {
+	case *Basic:
+		y, ok := y.(*Basic)
+		return ok && x.Kind == y.Kind
+	case *Pointer:
+		y, ok := y.(*Pointer)
+		return ok && Identical(x.Elem, y.Elem)
+	case *Slice:
+		y, ok := y.(*Slice)
+		return ok && Identical(x.Elem, y.Elem)
+	case *Array:
+		y, ok := y.(*Array)
+		return ok && x.Len == y.Len && Identical(x.Elem, y.Elem)
+	case *Map:
+		y, ok := y.(*Map)
+		return ok && Identical(x.Key, y.Key) && Identical(x.Elem, y.Elem)
+	case *Struct:
+		y, ok := y.(*Struct)
+		if !ok || len(x.Fields) != len(y.Fields) {
+			return false
+		}
+		for i, f := range x.Fields {
+			if f.Name != y.Fields[i].Name || !Identical(f.Type, y.Fields[i].Type) {
+				return false
+			}
+		}
+		return true
+	case *Tuple:
+		y, ok := y.(*Tuple)
+		if !ok || x.Len() != y.Len() {
+			return false
+		}
+		for i, v := range x.Vars {
+			if !Identical(v.Type, y.At(i).Type) {
+				return false
+			}
+		}
+		return true
+	case *Signature:
+		y, ok := y.(*Signature)
+		return ok && x.Variadic == y.Variadic &&
+			Identical(x.Params, y.Params) && Identical(x.Results, y.Results)
+	case *Interface:
+		y, ok := y.(*Interface)
+		if !ok || len(x.Methods) != len(y.Methods) {
+			return false
+		}
+		for i, m := range x.Methods {
+			if m.Name != y.Methods[i].Name || !Identical(m.Sig, y.Methods[i].Sig) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// AssignableTo reports whether a value of type v can be assigned to a
+// variable of type t.
+func AssignableTo(v, t Type) bool {
+	if isInvalid(v) || isInvalid(t) || Identical(v, t) {
+		return true
+	}
+
+	vu, tu := v.Underlying(), t.Underlying()
+	if it, ok := tu.(*Interface); ok {
+		if basicKind(v) == UntypedNil {
+			return true
+		}
+		return implements(Default(v), it)
+	}
+
+	if isUntyped(v) {
+		switch v.(*Basic).Kind {
+		case UntypedBool:
+			return isBoolean(tu)
+		case UntypedString:
+			return isString(tu)
+		case UntypedInt, UntypedRune:
+			return isNumeric(tu)
+		case UntypedFloat:
+			k := basicKind(tu)
+			return k == Float32 || k == Float64 || k == UntypedFloat
+		case UntypedNil:
+			switch tu.(type) {
+			case *Pointer, *Slice, *Map, *Signature:
+				return true
+			}
+			return basicKind(tu) == UnsafePointer
+		}
+	}
+
+	return Identical(vu, tu) && (!isNamed(v) || !isNamed(t))
+}
+
+// implements reports whether t has every method of the interface it.
+func implements(t Type, it *Interface) bool {
+	for _, m := range it.Methods {
+		found := methodOf(t, m.Name)
+		if found == nil || !Identical(found.Sig, m.Sig) {
+			return false
+		}
+	}
+	return true
+}
+
+// methodOf returns the method called name of t, looking through a
+// pointer to a named type.
+func methodOf(t Type, name string) *Func {
+	if p, ok := t.(*Pointer); ok {
+		t = p.Elem
+	}
+	switch t := t.(type) {
+	case *Named:
+		if m := t.Method(name); m != nil {
+			return m
+		}
+		if it, ok := t.Underlying().(*Interface); ok {
+			return methodOf(it, name)
+		}
+	case *Interface:
+		for _, m := range t.Methods {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	return nil
+}
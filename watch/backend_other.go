@@ -0,0 +1,13 @@
+//go:build !linux
+
+package watch
+
+import "time"
+
+// pollInterval is the interval of the poller used where inotify is not
+// available.
+const pollInterval = 500 * time.Millisecond
+
+func newBackend(events chan<- event, errs chan<- error, done <-chan struct{}) (backend, error) {
+	return newPoller(pollInterval, events, done), nil
+}
@@ -0,0 +1,108 @@
+package watch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask selects the events of complete writes, and of files and
+// directories appearing or disappearing.
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// inotify watches directories with an inotify instance.
+type inotify struct {
+	fd int
+	// file wraps fd, so closing it unblocks the pending read.
+	file *os.File
+
+	mu   sync.Mutex
+	dirs map[int]string
+}
+
+func newBackend(events chan<- event, errs chan<- error, done <-chan struct{}) (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	in := &inotify{fd: fd, file: os.NewFile(uintptr(fd), "inotify"), dirs: map[int]string{}}
+	go in.read(events, errs, done)
+	return in, nil
+}
+
+func (in *inotify) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(in.fd, dir, inotifyMask|syscall.IN_ONLYDIR)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	in.mu.Lock()
+	in.dirs[wd] = dir
+	in.mu.Unlock()
+	return nil
+}
+
+func (in *inotify) close() error {
+	return in.file.Close()
+}
+
+// errOverflow is reported when the kernel dropped events.
+var errOverflow = errors.New("watch: inotify event queue overflowed, some changes were missed")
+
+// read sends the events read from the instance until it is closed.
+func (in *inotify) read(events chan<- event, errs chan<- error, done <-chan struct{}) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := in.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				select {
+				case errs <- err:
+				default:
+				}
+			}
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			name := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+			offset += syscall.SizeofInotifyEvent + int(raw.Len)
+
+			if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				select {
+				case errs <- errOverflow:
+				default:
+				}
+				continue
+			}
+			in.mu.Lock()
+			dir, ok := in.dirs[int(raw.Wd)]
+			if raw.Mask&syscall.IN_IGNORED != 0 {
+				delete(in.dirs, int(raw.Wd))
+			}
+			in.mu.Unlock()
+			if !ok || raw.Len == 0 {
+				continue
+			}
+			// The name is padded with NUL bytes.
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			ev := event{
+				path: filepath.Join(dir, string(name)),
+				dir:  raw.Mask&syscall.IN_ISDIR != 0 && raw.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0,
+			}
+			if raw.Mask&syscall.IN_ISDIR != 0 && !ev.dir {
+				continue
+			}
+			select {
+			case events <- ev:
+			case <-done:
+				return
+			}
+		}
+	}
+}
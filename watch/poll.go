@@ -0,0 +1,126 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// poller watches directories by comparing the modification times and sizes
+// of their files every interval.
+type poller struct {
+	mu sync.Mutex
+	// dirs maps the watched directories to the state of their entries.
+	dirs map[string]map[string]stat
+	stop chan struct{}
+	once sync.Once
+}
+
+type stat struct {
+	modTime time.Time
+	size    int64
+	dir     bool
+}
+
+func newPoller(interval time.Duration, events chan<- event, done <-chan struct{}) *poller {
+	p := &poller{dirs: map[string]map[string]stat{}, stop: make(chan struct{})}
+	go p.loop(interval, events, done)
+	return p
+}
+
+func (p *poller) add(dir string) error {
+	entries, err := scan(dir)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.dirs[dir] = entries
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *poller) close() error {
+	p.once.Do(func() { close(p.stop) })
+	return nil
+}
+
+func (p *poller) loop(interval time.Duration, events chan<- event, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		case <-done:
+			return
+		}
+		for _, ev := range p.poll() {
+			select {
+			case events <- ev:
+			case <-p.stop:
+				return
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+// poll rescans the watched directories, returning the changes since the
+// last scan. A directory which disappeared is no longer watched.
+func (p *poller) poll() []event {
+	p.mu.Lock()
+	dirs := make(map[string]map[string]stat, len(p.dirs))
+	for dir, entries := range p.dirs {
+		dirs[dir] = entries
+	}
+	p.mu.Unlock()
+
+	var events []event
+	for dir, old := range dirs {
+		entries, err := scan(dir)
+		if os.IsNotExist(err) {
+			p.mu.Lock()
+			delete(p.dirs, dir)
+			p.mu.Unlock()
+		}
+		if err != nil {
+			continue
+		}
+		for name, now := range entries {
+			before, ok := old[name]
+			switch {
+			case now.dir && !ok:
+				events = append(events, event{path: filepath.Join(dir, name), dir: true})
+			case !now.dir && (!ok || before.dir || !before.modTime.Equal(now.modTime) || before.size != now.size):
+				events = append(events, event{path: filepath.Join(dir, name)})
+			}
+		}
+		for name, before := range old {
+			if _, ok := entries[name]; !ok && !before.dir {
+				events = append(events, event{path: filepath.Join(dir, name)})
+			}
+		}
+		p.mu.Lock()
+		if _, ok := p.dirs[dir]; ok {
+			p.dirs[dir] = entries
+		}
+		p.mu.Unlock()
+	}
+	return events
+}
+
+func scan(dir string) (map[string]stat, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]stat, len(infos))
+	for _, info := range infos {
+		entries[info.Name()] = stat{modTime: info.ModTime(), size: info.Size(), dir: info.IsDir()}
+	}
+	return entries, nil
+}
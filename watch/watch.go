@@ -0,0 +1,207 @@
+// Package watch reports the changes to the files of directories, for
+// `yuk watch`.
+//
+// On Linux the directories are watched with inotify. Elsewhere they are
+// polled.
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watcher sends the files changed in the watched directories in batches. A
+// batch is sent once no file changed for the delay of the watcher, so an
+// editor saving several files, or writing one in several steps, triggers
+// one batch.
+type Watcher struct {
+	// Changes receives the paths of the files created, written or removed
+	// in a batch, sorted.
+	Changes chan []string
+	// Errors receives the errors of the watcher, like a missed event.
+	Errors chan error
+
+	match   func(path string) bool
+	delay   time.Duration
+	backend backend
+	events  chan event
+	done    chan struct{}
+
+	mu sync.Mutex
+	// trees are the directories watched with their subdirectories.
+	trees []string
+}
+
+// event is a change reported by a backend. dir is set when a directory
+// was created or moved into a watched one.
+type event struct {
+	path string
+	dir  bool
+}
+
+// backend watches the directories given to add, without their
+// subdirectories, sending their events until it is closed.
+type backend interface {
+	add(dir string) error
+	close() error
+}
+
+// New returns a watcher of the files for which match returns true, with
+// the given delay between a change and its batch.
+func New(match func(path string) bool, delay time.Duration) (*Watcher, error) {
+	w := newWatcher(match, delay)
+	b, err := newBackend(w.events, w.Errors, w.done)
+	if err != nil {
+		return nil, err
+	}
+	w.backend = b
+	go w.loop()
+	return w, nil
+}
+
+// NewPolling is like New, but polls the directories every interval on
+// every system.
+func NewPolling(match func(path string) bool, delay, interval time.Duration) (*Watcher, error) {
+	w := newWatcher(match, delay)
+	w.backend = newPoller(interval, w.events, w.done)
+	go w.loop()
+	return w, nil
+}
+
+func newWatcher(match func(path string) bool, delay time.Duration) *Watcher {
+	return &Watcher{
+		Changes: make(chan []string),
+		Errors:  make(chan error, 1),
+		match:   match,
+		delay:   delay,
+		events:  make(chan event, 64),
+		done:    make(chan struct{}),
+	}
+}
+
+// Add watches dir, and all the directories below it when recursive is set,
+// including the ones created later. Like the ./... pattern of yuk build,
+// directories starting with . or _ and testdata directories are skipped.
+func (w *Watcher) Add(dir string, recursive bool) error {
+	if !recursive {
+		return w.backend.add(dir)
+	}
+	w.mu.Lock()
+	w.trees = append(w.trees, filepath.Clean(dir))
+	w.mu.Unlock()
+	_, err := w.addTree(dir)
+	return err
+}
+
+// addTree watches root and the directories below it, returning the files
+// already in them.
+func (w *Watcher) addTree(root string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			return nil
+		}
+		if path != root && skipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		return w.backend.add(path)
+	})
+	return files, err
+}
+
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata"
+}
+
+// inTree reports whether dir is below a directory watched recursively.
+func (w *Watcher) inTree(dir string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, root := range w.trees {
+		if rel, err := filepath.Rel(root, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() error {
+	close(w.done)
+	return w.backend.close()
+}
+
+// loop collects the events into batches.
+func (w *Watcher) loop() {
+	pending := map[string]bool{}
+	var timer *time.Timer
+	var fire <-chan time.Time
+	changed := func(path string) {
+		if !w.match(path) {
+			return
+		}
+		pending[path] = true
+		if timer == nil {
+			timer = time.NewTimer(w.delay)
+		} else {
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(w.delay)
+		}
+		fire = timer.C
+	}
+
+	for {
+		select {
+		case ev := <-w.events:
+			if !ev.dir {
+				changed(ev.path)
+				continue
+			}
+			// The files of a new directory may be written before it is
+			// watched, they are reported as changed.
+			if !skipDir(filepath.Base(ev.path)) && w.inTree(ev.path) {
+				files, err := w.addTree(ev.path)
+				if err != nil {
+					w.error(err)
+				}
+				for _, file := range files {
+					changed(file)
+				}
+			}
+		case <-fire:
+			fire = nil
+			timer = nil
+			batch := make([]string, 0, len(pending))
+			for path := range pending {
+				batch = append(batch, path)
+			}
+			sort.Strings(batch)
+			pending = map[string]bool{}
+			select {
+			case w.Changes <- batch:
+			case <-w.done:
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// error sends err unless an error is already waiting to be received.
+func (w *Watcher) error(err error) {
+	select {
+	case w.Errors <- err:
+	default:
+	}
+}
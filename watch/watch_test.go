@@ -0,0 +1,104 @@
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func isYuk(path string) bool {
+	return strings.HasSuffix(path, ".yuk")
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// next returns the next batch of w, failing after a few seconds.
+func next(t *testing.T, w *Watcher) []string {
+	t.Helper()
+	select {
+	case batch := <-w.Changes:
+		return batch
+	case err := <-w.Errors:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no changes received")
+	}
+	return nil
+}
+
+func testWatcher(t *testing.T, w *Watcher, wait time.Duration) {
+	defer w.Close()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yuk"), "package a")
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	// The poller compares modification times, which may be coarse.
+	time.Sleep(wait)
+
+	// A burst of writes is one batch, and other files are ignored.
+	a, b := filepath.Join(dir, "a.yuk"), filepath.Join(dir, "sub", "b.yuk")
+	writeFile(t, a, "package a\n")
+	writeFile(t, b, "package sub")
+	writeFile(t, filepath.Join(dir, "a.go"), "package a")
+	writeFile(t, a, "package a\n\n")
+	if got, expected := next(t, w), []string{a, b}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected=%q, got=%q", expected, got)
+	}
+
+	// The files of new directories are watched, and removals reported.
+	c := filepath.Join(dir, "new", "c.yuk")
+	if err := os.Mkdir(filepath.Dir(c), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, c, "package new")
+	if got, expected := next(t, w), []string{c}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected=%q, got=%q", expected, got)
+	}
+	time.Sleep(wait)
+	writeFile(t, c, "package new\n")
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := next(t, w), []string{c, b}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected=%q, got=%q", expected, got)
+	}
+
+	// Skipped directories are not watched.
+	if err := os.Mkdir(filepath.Join(dir, "testdata"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "testdata", "d.yuk"), "package d")
+	select {
+	case batch := <-w.Changes:
+		t.Errorf("unexpected changes %q", batch)
+	case <-time.After(4 * wait):
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	w, err := New(isYuk, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testWatcher(t, w, 100*time.Millisecond)
+}
+
+func TestWatcher_Polling(t *testing.T) {
+	w, err := NewPolling(isYuk, 50*time.Millisecond, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testWatcher(t, w, 100*time.Millisecond)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ahmadrosid/yuk/build"
+	"github.com/ahmadrosid/yuk/run"
+	"github.com/ahmadrosid/yuk/watch"
+)
+
+// watchCommand builds the yuk packages matched by the arguments like
+// buildCommand, then builds them again each time their files change. With
+// -run, the main package in the given directory is run after each build
+// that succeeds, stopping the previous run.
+func watchCommand(args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	flags.Usage = func() {
+		log.Print("usage: yuk watch [-o dir] [-lines=false] [-sourcemap] [-delay d] [-run dir] [dir | dir/...]... [-- arguments...]")
+		flags.PrintDefaults()
+	}
+	output := flags.String("o", "", "write the Go files under this directory instead of next to their sources")
+	lines := flags.Bool("lines", true, "emit //line directives so Go errors and stack traces point at the yuk source")
+	sourceMap := flags.Bool("sourcemap", false, "write a .yuk.map source map next to each Go file")
+	delay := flags.Duration("delay", 100*time.Millisecond, "wait this long after a change for more changes before building")
+	runDir := flags.String("run", "", "run the main package in this directory after each successful build, passing it the arguments after --")
+	flags.Parse(args)
+
+	patterns := flags.Args()
+	var runArgs []string
+	for i, arg := range patterns {
+		if arg == "--" {
+			patterns, runArgs = patterns[:i], patterns[i+1:]
+			break
+		}
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	cfg := &build.Config{Output: *output, LineDirectives: *lines, SourceMap: *sourceMap, Cache: build.CacheDir()}
+	w, err := watch.New(watchMatch(*output), *delay)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer w.Close()
+	for _, pattern := range patterns {
+		root := strings.TrimSuffix(pattern, "...")
+		if err := w.Add(filepath.Clean(root), root != pattern); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	r := &runner{dir: *runDir, args: runArgs}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	rebuild := func() {
+		if watchBuild(cfg, patterns) && r.dir != "" {
+			r.restart()
+		}
+	}
+	rebuild()
+	for {
+		select {
+		case changes := <-w.Changes:
+			log.Printf("yuk watch: %d changed: %s", len(changes), strings.Join(changes, " "))
+			rebuild()
+		case err := <-w.Errors:
+			log.Printf("yuk watch: %v", err)
+		case <-interrupt:
+			r.stop()
+			return
+		}
+	}
+}
+
+// watchMatch returns whether a changed file can change a build: a .yuk
+// file, or a hand-written Go file. The Go files the build writes, next to
+// their sources or under the output directory, are not, or each build
+// would trigger the next.
+func watchMatch(output string) func(path string) bool {
+	if output != "" {
+		output, _ = filepath.Abs(output)
+	}
+	return func(path string) bool {
+		if output != "" {
+			if abs, err := filepath.Abs(path); err == nil && (abs == output || strings.HasPrefix(abs, output+string(filepath.Separator))) {
+				return false
+			}
+		}
+		if strings.HasSuffix(path, ".yuk") {
+			return true
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return false
+		}
+		_, err := os.Stat(strings.TrimSuffix(path, ".go") + ".yuk")
+		return os.IsNotExist(err)
+	}
+}
+
+// watchBuild builds the packages matched by patterns, which may match new
+// directories since the last build, printing the diagnostics and a summary.
+// Packages which did not change since the last build are taken from the
+// build cache. It reports whether the build succeeded.
+func watchBuild(cfg *build.Config, patterns []string) bool {
+	start := time.Now()
+	dirs, err := build.Dirs(patterns)
+	if err != nil {
+		log.Printf("yuk watch: %v", err)
+		return false
+	}
+	errs := cfg.Build(dirs)
+	for _, err := range errs {
+		log.Print(err)
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+	switch len(errs) {
+	case 0:
+		log.Printf("yuk watch: built %d packages in %v", len(dirs), elapsed)
+		return true
+	case 1:
+		log.Printf("yuk watch: 1 error in %v", elapsed)
+	default:
+		log.Printf("yuk watch: %d errors in %v", len(errs), elapsed)
+	}
+	return false
+}
+
+// runner runs the main package in dir for watchCommand.
+type runner struct {
+	dir  string
+	args []string
+
+	program *run.Program
+	cmd     *exec.Cmd
+	// exited is closed when the run exits, and stopping before it is
+	// stopped by the runner.
+	exited   chan struct{}
+	stopping chan struct{}
+}
+
+// stopTimeout is how long a run has to exit after an interrupt before it
+// is killed.
+const stopTimeout = 5 * time.Second
+
+// restart stops the current run and starts a new one. A program which
+// fails to compile or build is reported, and nothing runs until the next
+// successful build.
+func (r *runner) restart() {
+	r.stop()
+	pkg, err := build.Load(r.dir)
+	if err != nil {
+		log.Printf("yuk watch: %v", err)
+		return
+	}
+	program, errs := run.Compile(pkg.Files, pkg.GoFiles)
+	for _, err := range errs {
+		log.Print(err)
+	}
+	if len(errs) > 0 {
+		return
+	}
+	exe, err := program.Build()
+	if err != nil {
+		program.Remove()
+		log.Print(err)
+		return
+	}
+
+	cmd := exec.Command(exe, r.args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		program.Remove()
+		log.Printf("yuk watch: %v", err)
+		return
+	}
+	r.program, r.cmd = program, cmd
+	r.exited, r.stopping = make(chan struct{}), make(chan struct{})
+	go func(dir string, exited, stopping chan struct{}) {
+		defer close(exited)
+		err := cmd.Wait()
+		select {
+		case <-stopping:
+			return
+		default:
+		}
+		if exit, ok := err.(*exec.ExitError); ok {
+			log.Printf("yuk watch: %s exited with code %d", dir, exit.ExitCode())
+		} else if err != nil {
+			log.Printf("yuk watch: %v", err)
+		}
+	}(r.dir, r.exited, r.stopping)
+}
+
+// stop interrupts the current run, killing it if it does not exit in time,
+// and removes its temporary directory.
+func (r *runner) stop() {
+	if r.cmd == nil {
+		return
+	}
+	close(r.stopping)
+	select {
+	case <-r.exited:
+	default:
+		if err := r.cmd.Process.Signal(os.Interrupt); err != nil {
+			r.cmd.Process.Kill()
+		}
+		select {
+		case <-r.exited:
+		case <-time.After(stopTimeout):
+			r.cmd.Process.Kill()
+			<-r.exited
+		}
+	}
+	r.program.Remove()
+	r.program, r.cmd, r.exited, r.stopping = nil, nil, nil, nil
+}